@@ -1,5 +1,35 @@
 const API_BASE = '/api';
 
+// Per-project endpoints live under /api/projects/{name}; selectProject
+// sets the project the other calls address.
+let projectBase = '';
+
+export interface ProjectInfo {
+  name: string;
+  loaded: boolean;
+  version?: number;
+  validation: { valid: boolean; summary: string; errors: number; warnings: number } | null;
+}
+
+export async function fetchProjects(): Promise<ProjectInfo[]> {
+  const res = await fetch(`${API_BASE}/projects`);
+  if (!res.ok) throw new Error(`Failed to fetch projects: ${res.status}`);
+  return (await res.json()).projects;
+}
+
+// selectProject addresses the named project, or the one in the page's
+// ?project= query parameter, or else the workspace's first project.
+export async function selectProject(name?: string): Promise<string> {
+  name ??= new URLSearchParams(window.location.search).get('project') ?? undefined;
+  if (!name) {
+    const projects = await fetchProjects();
+    if (projects.length === 0) throw new Error('No projects in workspace');
+    name = projects[0].name;
+  }
+  projectBase = `${API_BASE}/projects/${encodeURIComponent(name)}`;
+  return name;
+}
+
 export interface SceneGraph {
   metadata: {
     spec_version: string;
@@ -33,25 +63,89 @@ export interface Entity {
 }
 
 export async function fetchScene(): Promise<SceneGraph> {
-  const res = await fetch(`${API_BASE}/scene`);
+  const res = await fetch(`${projectBase}/scene`);
   if (!res.ok) throw new Error(`Failed to fetch scene: ${res.status}`);
   return res.json();
 }
 
 export async function fetchCost(): Promise<unknown> {
-  const res = await fetch(`${API_BASE}/cost`);
+  const res = await fetch(`${projectBase}/cost`);
   if (!res.ok) throw new Error(`Failed to fetch cost: ${res.status}`);
   return res.json();
 }
 
 export async function fetchValidation(): Promise<unknown> {
-  const res = await fetch(`${API_BASE}/validation`);
+  const res = await fetch(`${projectBase}/validation`);
   if (!res.ok) throw new Error(`Failed to fetch validation: ${res.status}`);
   return res.json();
 }
 
-export async function triggerSolve(): Promise<unknown> {
-  const res = await fetch(`${API_BASE}/solve`, { method: 'POST' });
+export type JobStatus = 'queued' | 'running' | 'succeeded' | 'rejected' | 'failed' | 'canceled';
+
+export interface Job {
+  id: string;
+  project: string;
+  status: JobStatus;
+  stage?: string;
+  stages_done: number;
+  stages_total: number;
+  error?: string;
+  version?: number;
+}
+
+// triggerSolve starts a solve job, or joins the one already queued.
+export async function triggerSolve(): Promise<Job> {
+  const res = await fetch(`${projectBase}/solve`, { method: 'POST' });
   if (!res.ok) throw new Error(`Failed to trigger solve: ${res.status}`);
   return res.json();
 }
+
+// subscribeJob reports a job's progress until it finishes.
+export function subscribeJob(id: string, onUpdate: (job: Job) => void): EventSource {
+  const source = new EventSource(`${API_BASE}/jobs/${encodeURIComponent(id)}/events`);
+  const statuses: JobStatus[] = ['queued', 'running', 'succeeded', 'rejected', 'failed', 'canceled'];
+  for (const status of statuses) {
+    source.addEventListener(status, (msg: MessageEvent) => {
+      const job: Job = JSON.parse(msg.data);
+      onUpdate(job);
+      if (job.status !== 'queued' && job.status !== 'running') source.close();
+    });
+  }
+  return source;
+}
+
+export async function cancelJob(id: string): Promise<Job> {
+  const res = await fetch(`${API_BASE}/jobs/${encodeURIComponent(id)}`, { method: 'DELETE' });
+  if (!res.ok && res.status !== 409) throw new Error(`Failed to cancel job: ${res.status}`);
+  const body = await res.json();
+  return res.status === 409 ? body.job : body;
+}
+
+// patchSpec applies a JSON Merge Patch to the spec and re-solves. With
+// write set, the change is also saved to the project's city.yaml.
+export async function patchSpec(patch: unknown, write = false): Promise<unknown> {
+  const res = await fetch(`${projectBase}/spec${write ? '?write=true' : ''}`, {
+    method: 'PATCH',
+    headers: { 'Content-Type': 'application/merge-patch+json' },
+    body: JSON.stringify(patch),
+  });
+  if (!res.ok) throw new Error(`Failed to patch spec: ${res.status}`);
+  return res.json();
+}
+
+export interface SolveEvent {
+  type: 'solved' | 'solve_failed';
+  version: number;
+  valid: boolean;
+  summary: string;
+  errors?: { message: string; spec_path: string }[];
+  error?: string;
+}
+
+export function subscribeEvents(onEvent: (event: SolveEvent) => void): EventSource {
+  const source = new EventSource(`${projectBase}/events`);
+  const handler = (msg: MessageEvent) => onEvent(JSON.parse(msg.data));
+  source.addEventListener('solved', handler);
+  source.addEventListener('solve_failed', handler);
+  return source;
+}
@@ -1,7 +1,7 @@
 import * as THREE from 'three';
 import { CameraModeManager } from './camera/modes';
 import { loadSceneGraph } from './scene/loader';
-import { fetchScene } from './api';
+import { fetchScene, selectProject, subscribeEvents } from './api';
 import { initControls } from './ui/controls';
 import { RouteTracer } from './ui/route-tracer';
 
@@ -64,7 +64,15 @@ function animate(): void {
 animate();
 
 // Load scene from solver
-loadCity();
+selectProject()
+  .then(() => {
+    loadCity();
+    watchSolver();
+  })
+  .catch((err) => {
+    overlay.querySelector('p')!.textContent =
+      `Failed to load projects: ${err instanceof Error ? err.message : 'unknown error'}. Is the solver running on :3000?`;
+  });
 
 async function loadCity(): Promise<void> {
   try {
@@ -84,3 +92,21 @@ async function loadCity(): Promise<void> {
       `Failed to load: ${err instanceof Error ? err.message : 'unknown error'}. Is the solver running on :3000?`;
   }
 }
+
+// Reload when the solver serves a newer scene (the server re-solves on spec
+// edits). A failed solve keeps the current scene and logs the errors.
+function watchSolver(): void {
+  let loadedVersion: number | null = null;
+  subscribeEvents((event) => {
+    if (loadedVersion === null) {
+      loadedVersion = event.version;
+    } else if (event.type === 'solved' && event.version > loadedVersion) {
+      window.location.reload();
+      return;
+    }
+    if (event.type === 'solve_failed') {
+      const detail = event.error ?? event.errors?.map((e) => `${e.spec_path}: ${e.message}`).join('; ');
+      console.warn(`Solve failed (${event.summary || 'error'}): ${detail}`);
+    }
+  });
+}
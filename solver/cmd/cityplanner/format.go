@@ -2,8 +2,15 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
+	"github.com/ChicagoDave/cityplanner/pkg/artifact"
+	"github.com/ChicagoDave/cityplanner/pkg/compare"
 	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/snapshot"
 	"github.com/ChicagoDave/cityplanner/pkg/validation"
 )
 
@@ -122,3 +129,183 @@ func formatMoney(v float64) string {
 	}
 	return fmt.Sprintf("%.0f", v)
 }
+
+func printComparison(c *compare.Comparison) {
+	fmt.Printf("Comparison: %s -> %s\n", c.A, c.B)
+	fmt.Println("==============================")
+	fmt.Println()
+
+	if len(c.SpecChanges) > 0 {
+		fmt.Printf("Spec changes (%d):\n", len(c.SpecChanges))
+		for _, p := range c.SpecChanges {
+			fmt.Printf("  %s\n", p)
+		}
+		fmt.Println()
+	}
+
+	printDeltaSection("Parameters", c.A, c.B, c.Parameters, formatNumber)
+	printDeltaSection("Cost", c.A, c.B, c.Cost, formatMoney)
+	printDeltaSection("Validation", c.A, c.B, c.Validation, formatNumber)
+	for _, g := range c.Rings {
+		printDeltaSection("Ring "+g.Name, c.A, c.B, g.Deltas, formatNumber)
+	}
+	for _, g := range c.Pods {
+		printDeltaSection("Pod "+g.Name, c.A, c.B, g.Deltas, formatNumber)
+	}
+}
+
+func printDeltaSection(title, a, b string, ds []compare.Delta, format func(float64) string) {
+	if len(ds) == 0 {
+		return
+	}
+	fmt.Println(title)
+	fmt.Printf("  %-40s %14s %14s %14s %9s\n", "Metric", a, b, "Change", "%")
+	for _, d := range ds {
+		pct := ""
+		if d.Pct != nil {
+			pct = fmt.Sprintf("%+.1f%%", *d.Pct)
+		}
+		change := format(math.Abs(d.Change))
+		if d.Change > 0 {
+			change = "+" + change
+		} else if d.Change < 0 {
+			change = "-" + change
+		}
+		fmt.Printf("  %-40s %14s %14s %14s %9s\n", d.Metric, format(d.A), format(d.B), change, pct)
+	}
+	fmt.Println()
+}
+
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	if math.Abs(v) < 1 {
+		return fmt.Sprintf("%.4f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+func printMetrics(r *metrics.Report, pods bool) {
+	fmt.Println("Land-Use Metrics")
+	fmt.Println("================")
+	fmt.Printf("  %-12s %8s %6s %9s %14s %16s %8s %10s %11s\n",
+		"", "Area ha", "FAR", "Coverage", "DU/ha (target)", "Res/ha (target)", "Jobs/ha", "Green m²/p", "Impervious")
+	row := func(m metrics.Metrics) {
+		fmt.Printf("  %-12s %8.1f %6.2f %8.0f%% %6.0f (%5.0f) %7.0f (%6.0f) %8.0f %10.1f %10.0f%%\n",
+			m.Name, m.AreaHa, m.FAR, m.GroundCoverage*100, m.DUPerHa, m.TargetDUPerHa,
+			m.ResidentsPerHa, m.TargetResidentsPerHa, m.JobsPerHa, m.GreenM2PerCapita, m.ImperviousFraction*100)
+	}
+	for _, m := range r.Rings {
+		row(m)
+	}
+	row(r.City)
+	if !pods {
+		return
+	}
+	fmt.Println()
+	for _, m := range r.Pods {
+		row(m)
+	}
+}
+
+func printSceneDiff(d *scene.GraphDiff, limit int) {
+	fmt.Println("Scene Graph Diff")
+	fmt.Println("================")
+	fmt.Println()
+
+	printDiffSummaryHeader("")
+	printDiffSummaryRow("TOTAL", d.Summary)
+	fmt.Println()
+
+	printDiffGroups("Pods", d.Pods)
+	printDiffGroups("Systems", d.Systems)
+	printDiffGroups("Layers", d.Layers)
+	printDiffGroups("Entity types", d.Types)
+
+	if len(d.Changes) == 0 {
+		fmt.Println("No entity changes.")
+		return
+	}
+	shown := d.Changes
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	fmt.Printf("Changed entities (%d of %d):\n", len(shown), len(d.Changes))
+	for _, c := range shown {
+		fmt.Printf("  %-24s %-14s %s\n", c.ID, c.Type, describeChange(c))
+	}
+}
+
+// printDiffGroups lists the groups with changes; unchanged groups are
+// counted but not listed.
+func printDiffGroups[K ~string](title string, groups map[K]*scene.DiffSummary) {
+	var changed []K
+	for _, k := range scene.SortedKeys(groups) {
+		if groups[k].Changed > 0 {
+			changed = append(changed, k)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	fmt.Printf("%s (%d of %d changed)\n", title, len(changed), len(groups))
+	printDiffSummaryHeader("")
+	for _, k := range changed {
+		printDiffSummaryRow(string(k), *groups[k])
+	}
+	fmt.Println()
+}
+
+func printDiffSummaryHeader(label string) {
+	fmt.Printf("  %-20s %8s %8s %8s %8s %8s %8s %8s %10s\n",
+		label, "Added", "Removed", "Renamed", "Moved", "Resized", "Rotated", "Remat.", "Unchanged")
+}
+
+func printDiffSummaryRow(label string, s scene.DiffSummary) {
+	fmt.Printf("  %-20s %8d %8d %8d %8d %8d %8d %8d %10d\n",
+		label, s.Added, s.Removed, s.Renamed, s.Moved, s.Resized, s.Rotated, s.Rematerialized, s.Unchanged)
+}
+
+func describeChange(c scene.EntityChange) string {
+	var parts []string
+	for _, k := range c.Kinds {
+		switch k {
+		case scene.ChangeRenamed:
+			parts = append(parts, "renamed to "+c.NewID)
+		case scene.ChangeMoved:
+			parts = append(parts, fmt.Sprintf("moved %.2fm", c.Distance))
+		case scene.ChangeResized:
+			parts = append(parts, fmt.Sprintf("resized %s -> %s", formatVec(*c.OldDimensions), formatVec(*c.NewDimensions)))
+		case scene.ChangeRematerialized:
+			parts = append(parts, fmt.Sprintf("material %s -> %s", c.OldMaterial, c.NewMaterial))
+		case scene.ChangeAdded:
+			parts = append(parts, "added at "+formatVec(*c.NewPosition))
+		case scene.ChangeRemoved:
+			parts = append(parts, "removed from "+formatVec(*c.OldPosition))
+		default:
+			parts = append(parts, string(k))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatVec(v scene.Vec3) string {
+	return fmt.Sprintf("(%.1f, %.1f, %.1f)", v.X, v.Y, v.Z)
+}
+
+func printSnapshotDiff(path string, diffs []snapshot.Difference) {
+	fmt.Printf("Snapshot differs from %s (%d values):\n", path, len(diffs))
+	for _, d := range diffs {
+		fmt.Printf("  %s\n", d)
+	}
+}
+
+func printBuildManifest(dir string, m *artifact.Manifest) {
+	fmt.Printf("Built %s into %s\n", m.Project, dir)
+	for _, f := range m.Files {
+		fmt.Printf("  %-18s %12d bytes  sha256:%s\n", f.Name, f.Size, f.SHA256[:16])
+	}
+	fmt.Printf("  %s\n", artifact.ManifestFile)
+	fmt.Printf("Validation: %s\n", m.Validation.Summary)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/ChicagoDave/cityplanner/internal/server"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +19,11 @@ func main() {
 	rootCmd.AddCommand(costCmd())
 	rootCmd.AddCommand(serveCmd())
 	rootCmd.AddCommand(layout2dCmd())
+	rootCmd.AddCommand(compareCmd())
+	rootCmd.AddCommand(diffCmd())
+	rootCmd.AddCommand(snapshotCmd())
+	rootCmd.AddCommand(buildCmd())
+	rootCmd.AddCommand(metricsCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -25,14 +31,20 @@ func main() {
 }
 
 func solveCmd() *cobra.Command {
-	return &cobra.Command{
+	var force, deterministic bool
+
+	cmd := &cobra.Command{
 		Use:   "solve [project-path]",
 		Short: "Run the full solver pipeline and generate a scene graph",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			return runSolve(args[0])
+			return runSolve(args[0], force, deterministic)
 		},
 	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "re-solve even if the cached scene graph is current")
+	cmd.Flags().BoolVar(&deterministic, "deterministic", false, "fix generation timestamps so identical specs give byte-identical output (implies --force)")
+	return cmd
 }
 
 func validateCmd() *cobra.Command {
@@ -59,18 +71,27 @@ func costCmd() *cobra.Command {
 
 func serveCmd() *cobra.Command {
 	var port int
+	var opts server.Options
 
 	cmd := &cobra.Command{
-		Use:   "serve [project-path]",
+		Use:   "serve [workspace-path]",
 		Short: "Start the local dev server with interactive 3D renderer",
-		Args:  cobra.ExactArgs(1),
+		Long: `Serve a workspace of projects: every subdirectory containing a city.yaml,
+or a single project directory. Each project's endpoints are under
+/api/projects/{name}/; GET /api/projects lists them. Projects are solved
+on first request and the least recently used are unloaded beyond
+--max-projects.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			srv := server.New(args[0], port)
+			srv := server.New(args[0], port, opts)
 			return srv.Start()
 		},
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 3000, "HTTP server port")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "solve projects on load even if the cached scene graph is current")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", true, "re-solve when a spec changes and notify the renderer over the project's events endpoint")
+	cmd.Flags().IntVar(&opts.MaxProjects, "max-projects", server.DefaultMaxProjects, "projects to keep solved in memory")
 	return cmd
 }
 
@@ -84,3 +105,131 @@ func layout2dCmd() *cobra.Command {
 		},
 	}
 }
+
+func compareCmd() *cobra.Command {
+	var format string
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "compare [project-path] [scenario-a] [scenario-b]",
+		Short: "Compare two scenarios of a project side by side",
+		Long: `Compare solves two scenarios and reports the differences in resolved
+parameters, cost, validation counts, per-ring statistics and per-pod
+building totals. A scenario is "baseline" (the project's city.yaml) or the
+name of an overlay in the project's scenarios/ directory.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runCompare(args[0], args[1], args[2], format, all)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	cmd.Flags().BoolVar(&all, "all", false, "include metrics that are unchanged")
+	return cmd
+}
+
+func diffCmd() *cobra.Command {
+	var format string
+	var limit int
+	var opts scene.DiffOptions
+	var failOnChange bool
+
+	cmd := &cobra.Command{
+		Use:   "diff [old-scene] [new-scene]",
+		Short: "Report what changed between two solved scene graphs",
+		Long: `Diff compares two scene graph files (JSON or binary) entity by entity.
+Entities are matched by ID, then by type and position, and reported as
+added, removed, renamed, moved, resized, rotated or rematerialized, with
+summaries per pod, system, layer and entity type.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runDiff(args[0], args[1], format, limit, opts, failOnChange)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	cmd.Flags().IntVar(&limit, "limit", 20, "changed entities to list in table output (0 for all)")
+	cmd.Flags().Float64Var(&opts.MatchRadius, "radius", 5, "max distance in meters for matching entities by position (negative disables)")
+	cmd.Flags().Float64Var(&opts.Epsilon, "epsilon", 1e-6, "tolerance for equal positions, dimensions and rotations")
+	cmd.Flags().BoolVar(&failOnChange, "fail-on-change", false, "exit with status 1 if the scene graphs differ")
+	return cmd
+}
+
+func snapshotCmd() *cobra.Command {
+	var output, check, scenario string
+
+	cmd := &cobra.Command{
+		Use:   "snapshot [project-path]",
+		Short: "Write or check a canonical golden snapshot of a solve",
+		Long: `Snapshot solves the project deterministically and prints a canonical JSON
+summary: resolved parameters, cost, validation findings, and entity counts
+and content digests of the scene graph per pod, system, layer and entity
+type. With --check, the summary is compared with a stored snapshot and
+every differing value is listed by path; the exit status is 1 if any differ.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runSnapshot(args[0], scenario, output, check)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the snapshot to this file instead of stdout")
+	cmd.Flags().StringVar(&check, "check", "", "compare with this stored snapshot instead of printing")
+	cmd.Flags().StringVar(&scenario, "scenario", "baseline", "scenario to snapshot")
+	return cmd
+}
+
+func buildCmd() *cobra.Command {
+	var output, failOn string
+	var formats []string
+	var compact, deterministic bool
+
+	cmd := &cobra.Command{
+		Use:   "build [project-path]",
+		Short: "Solve a project and write every artifact to an output directory",
+		Long: `Build solves the project and writes its outputs as separate files:
+parameters.json, cost.json, validation.json, the scene graph (scene.json
+and/or scene.bin), scene2d.json, employment.json with the jobs-housing
+balance and commute matrices, education.json with the school catchments
+and, if the spec sets
+site_requirements.solar_access, solar.json, plus manifest.json listing
+each file with its size and SHA-256. The project's own cached artifacts
+are left untouched. Artifacts are written even when validation finds
+problems; the exit status is 1 if it finds any at or above the --fail-on
+severity.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runBuild(args[0], output, formats, compact, deterministic, failOn)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "out", "directory to write artifacts to")
+	cmd.Flags().StringSliceVar(&formats, "scene-format", []string{"json"}, "scene graph encodings to write: json, binary or both (json,binary)")
+	cmd.Flags().BoolVar(&compact, "compact", false, "write JSON artifacts without indentation")
+	cmd.Flags().BoolVar(&deterministic, "deterministic", false, "fix generation timestamps so identical specs give identical hashes")
+	cmd.Flags().StringVar(&failOn, "fail-on", "error", "exit with status 1 on validation findings of this severity or worse: error, warning or none")
+	return cmd
+}
+
+func metricsCmd() *cobra.Command {
+	var format string
+	var pods bool
+
+	cmd := &cobra.Command{
+		Use:   "metrics [project-path]",
+		Short: "Measure density and land-use metrics of the placed city",
+		Long: `Metrics places the city's buildings and measures, per ring and for the
+whole city, the floor area ratio, ground coverage, dwelling units per
+hectare of residential land, residents and jobs per hectare, green area
+per resident and impervious fraction. Dwelling and resident densities are
+listed beside their analytical targets, and the rings that stray from them
+by more than 25% are reported as warnings.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runMetrics(args[0], format, pods)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table or json")
+	cmd.Flags().BoolVar(&pods, "pods", false, "list every pod in table output")
+	return cmd
+}
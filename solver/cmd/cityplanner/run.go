@@ -2,15 +2,18 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/artifact"
+	"github.com/ChicagoDave/cityplanner/pkg/compare"
 	"github.com/ChicagoDave/cityplanner/pkg/cost"
-	"github.com/ChicagoDave/cityplanner/pkg/layout"
-	"github.com/ChicagoDave/cityplanner/pkg/routing"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/project"
 	"github.com/ChicagoDave/cityplanner/pkg/scene"
-	"github.com/ChicagoDave/cityplanner/pkg/scene2d"
+	"github.com/ChicagoDave/cityplanner/pkg/snapshot"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 	"github.com/ChicagoDave/cityplanner/pkg/validation"
 )
@@ -68,111 +71,303 @@ func runCost(projectPath string) error {
 	return nil
 }
 
-func runSolve(projectPath string) error {
-	citySpec, schemaReport, err := loadAndValidate(projectPath)
+func runSolve(projectPath string, force, deterministic bool) error {
+	proj, err := project.Open(projectPath)
 	if err != nil {
 		return err
 	}
-	if !schemaReport.Valid {
-		printValidationReport(schemaReport)
-		return fmt.Errorf("spec has validation errors")
+	specHash, err := proj.SpecHash()
+	if err != nil {
+		return err
 	}
-
-	params, analyticsReport := analytics.Resolve(citySpec)
-	if !analyticsReport.Valid {
-		printValidationReport(analyticsReport)
-		return fmt.Errorf("analytical validation failed")
+	citySpec, err := spec.Load(proj.SpecPath())
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
 	}
 
-	costReport := cost.Estimate(citySpec, params)
-	params.PerCapitaCost = costReport.Summary.PerCapita
-	params.BreakEvenRent = costReport.Summary.BreakEvenMonthlyRent
-
-	// Phase 2: Spatial generation.
-	pods, adjacency, podReport := layout.LayoutPods(citySpec, params)
-	analyticsReport.Merge(podReport)
-
-	buildings, paths, buildReport := layout.PlaceBuildings(citySpec, pods, adjacency, params)
-	analyticsReport.Merge(buildReport)
-
-	segments, routeReport := routing.RouteInfrastructure(citySpec, pods, buildings)
-	analyticsReport.Merge(routeReport)
-
-	bikePaths, bikeReport := layout.GenerateBikePaths(pods, adjacency, citySpec.CityZones.Rings)
-	analyticsReport.Merge(bikeReport)
-
-	shuttleRoutes, stations, shuttleReport := layout.GenerateShuttleRoutes(bikePaths, pods)
-	analyticsReport.Merge(shuttleReport)
-
-	sportsFields, sportsReport := layout.PlaceSportsFields(pods, adjacency, citySpec.CityZones.Rings)
-	analyticsReport.Merge(sportsReport)
-
-	greenZones := layout.CollectGreenZones(citySpec, pods)
-
-	plazas, plazaReport := layout.GeneratePlazas(pods, citySpec)
-	analyticsReport.Merge(plazaReport)
+	if !force && !deterministic {
+		valid, err := proj.CacheValid()
+		if err != nil {
+			return err
+		}
+		if valid {
+			return printCachedSolve(proj, citySpec)
+		}
+	}
 
-	trees, treeReport := layout.PlaceTrees(pods, greenZones, paths, bikePaths, plazas)
-	analyticsReport.Merge(treeReport)
+	res, err := pipeline.Run(citySpec, pipeline.Options{
+		Targets:       []pipeline.Stage{pipeline.StageScene, pipeline.StageSolar, pipeline.StageMetrics, pipeline.StageEmployment, pipeline.StageEducation},
+		Deterministic: deterministic,
+	})
+	if err != nil {
+		var verr *pipeline.ValidationError
+		if errors.As(err, &verr) {
+			printValidationReport(verr.Report)
+		}
+		return err
+	}
+	analysis := project.NewAnalysis(res)
+	if err := proj.SaveArtifacts(specHash, res.Graph, res.Cost, analysis); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: writing project artifacts: %v\n", err)
+	}
 
-	graph := scene.Assemble(citySpec, pods, buildings, paths, segments, greenZones, bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees)
+	return printSolve(res.Params, res.Cost, res.Graph, analysis)
+}
 
+// printSolve prints the solve output. The cold and cached paths both print
+// through it, so the output has the same shape either way.
+func printSolve(params *analytics.ResolvedParameters, costReport, graph any, analysis *project.Analysis) error {
 	output := map[string]any{
 		"phase":       2,
 		"parameters":  params,
 		"cost":        costReport,
-		"validation":  analyticsReport,
+		"validation":  analysis.Validation,
 		"scene_graph": graph,
 	}
+	if analysis.Solar != nil {
+		output["solar"] = analysis.Solar
+	}
+	if analysis.Metrics != nil {
+		output["metrics"] = analysis.Metrics
+	}
+	if analysis.Employment != nil {
+		output["employment"] = analysis.Employment
+	}
+	if analysis.Education != nil {
+		output["education"] = analysis.Education
+	}
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	return enc.Encode(output)
 }
 
+// printCachedSolve prints solve output using the project's cached scene
+// graph, cost report and analysis. Only the analytical stages run, to
+// resolve the parameters.
+func printCachedSolve(proj *project.Project, citySpec *spec.CitySpec) error {
+	res, err := pipeline.Run(citySpec, pipeline.Options{StopAfter: pipeline.StageCost})
+	if err != nil {
+		var verr *pipeline.ValidationError
+		if errors.As(err, &verr) {
+			printValidationReport(verr.Report)
+		}
+		return err
+	}
+	graph, err := proj.ReadSceneGraph()
+	if err != nil {
+		return fmt.Errorf("reading cached scene graph: %w", err)
+	}
+	costReport, err := proj.ReadCostReport()
+	if err != nil {
+		return fmt.Errorf("reading cached cost report: %w", err)
+	}
+	analysis, err := proj.ReadAnalysis()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "using cached scene graph (spec unchanged); pass --force to re-solve")
+	return printSolve(res.Params, json.RawMessage(costReport), json.RawMessage(graph), analysis)
+}
+
 func runLayout2D(projectPath string) error {
-	citySpec, schemaReport, err := loadAndValidate(projectPath)
+	res, err := pipeline.RunProject(projectPath, pipeline.Options{
+		Targets: []pipeline.Stage{pipeline.StageScene2D},
+	})
 	if err != nil {
+		var verr *pipeline.ValidationError
+		if errors.As(err, &verr) {
+			printValidationReport(verr.Report)
+		}
 		return err
 	}
-	if !schemaReport.Valid {
-		printValidationReport(schemaReport)
-		return fmt.Errorf("spec has validation errors")
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res.Scene2D)
+}
+
+func runMetrics(projectPath, format string, pods bool) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q (want table or json)", format)
+	}
+	res, err := pipeline.RunProject(projectPath, pipeline.Options{
+		Targets: []pipeline.Stage{pipeline.StageMetrics},
+	})
+	if err != nil {
+		var verr *pipeline.ValidationError
+		if errors.As(err, &verr) {
+			printValidationReport(verr.Report)
+		}
+		return err
 	}
 
-	params, analyticsReport := analytics.Resolve(citySpec)
-	if !analyticsReport.Valid {
-		printValidationReport(analyticsReport)
-		return fmt.Errorf("analytical validation failed")
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res.Metrics)
 	}
+	printMetrics(res.Metrics, pods)
+	if rep := res.StageReport(pipeline.StageMetrics); rep != nil && len(rep.Warnings) > 0 {
+		fmt.Println()
+		printValidationReport(rep)
+	}
+	return nil
+}
 
-	// Spatial generation.
-	pods, adjacency, podReport := layout.LayoutPods(citySpec, params)
-	analyticsReport.Merge(podReport)
+func runCompare(projectPath, a, b, format string, all bool) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q (want table or json)", format)
+	}
+	proj, err := project.Open(projectPath)
+	if err != nil {
+		return err
+	}
 
-	buildings, paths, buildReport := layout.PlaceBuildings(citySpec, pods, adjacency, params)
-	analyticsReport.Merge(buildReport)
+	results := make([]*pipeline.Result, 2)
+	for i, name := range []string{a, b} {
+		citySpec, err := proj.LoadScenario(name)
+		if err != nil {
+			return err
+		}
+		if results[i], err = compare.Solve(citySpec); err != nil {
+			return fmt.Errorf("scenario %s: %w", name, err)
+		}
+	}
 
-	bikePaths, bikeReport := layout.GenerateBikePaths(pods, adjacency, citySpec.CityZones.Rings)
-	analyticsReport.Merge(bikeReport)
+	c := compare.Compare(a, results[0], b, results[1])
+	if !all {
+		c = c.Changed()
+	}
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	printComparison(c)
+	return nil
+}
 
-	shuttleRoutes, stations, shuttleReport := layout.GenerateShuttleRoutes(bikePaths, pods)
-	analyticsReport.Merge(shuttleReport)
+func runDiff(oldPath, newPath, format string, limit int, opts scene.DiffOptions, failOnChange bool) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q (want table or json)", format)
+	}
+	before, err := scene.ReadFile(oldPath)
+	if err != nil {
+		return err
+	}
+	after, err := scene.ReadFile(newPath)
+	if err != nil {
+		return err
+	}
 
-	sportsFields, sportsReport := layout.PlaceSportsFields(pods, adjacency, citySpec.CityZones.Rings)
-	analyticsReport.Merge(sportsReport)
+	d := scene.Diff(before, after, opts)
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	} else {
+		printSceneDiff(d, limit)
+	}
 
-	greenZones := layout.CollectGreenZones(citySpec, pods)
+	if failOnChange && !d.Empty() {
+		os.Exit(1)
+	}
+	return nil
+}
 
-	plazas, plazaReport := layout.GeneratePlazas(pods, citySpec)
-	analyticsReport.Merge(plazaReport)
+func runSnapshot(projectPath, scenario, output, check string) error {
+	proj, err := project.Open(projectPath)
+	if err != nil {
+		return err
+	}
+	citySpec, err := proj.LoadScenario(scenario)
+	if err != nil {
+		return err
+	}
+	res, err := pipeline.Run(citySpec, snapshot.Options())
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot.Take(res)
+	if err != nil {
+		return err
+	}
 
-	trees, treeReport := layout.PlaceTrees(pods, greenZones, paths, bikePaths, plazas)
-	analyticsReport.Merge(treeReport)
+	if check != "" {
+		want, err := snapshot.Load(check)
+		if err != nil {
+			return err
+		}
+		got, err := snap.Document()
+		if err != nil {
+			return err
+		}
+		diffs := snapshot.Compare(want, got)
+		if len(diffs) == 0 {
+			fmt.Printf("snapshot matches %s\n", check)
+			return nil
+		}
+		printSnapshotDiff(check, diffs)
+		os.Exit(1)
+	}
 
-	sc := scene2d.Assemble2D(citySpec, params, pods, buildings, paths, greenZones,
-		bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees)
+	data, err := snap.Marshal()
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(output, data, 0o644)
+}
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(sc)
+func runBuild(projectPath, outDir string, formats []string, compact, deterministic bool, failOn string) error {
+	if failOn != "error" && failOn != "warning" && failOn != "none" {
+		return fmt.Errorf("unknown --fail-on %q (want error, warning or none)", failOn)
+	}
+	opts := artifact.Options{Compact: compact}
+	for _, name := range formats {
+		f, err := artifact.ParseFormat(name)
+		if err != nil {
+			return err
+		}
+		opts.SceneFormats = append(opts.SceneFormats, f)
+	}
+
+	proj, err := project.Open(projectPath)
+	if err != nil {
+		return err
+	}
+	if opts.SpecHash, err = proj.SpecHash(); err != nil {
+		return err
+	}
+	opts.Project = proj.Manifest.ProjectName
+	citySpec, err := spec.Load(proj.SpecPath())
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
+	}
+
+	res, err := pipeline.Run(citySpec, pipeline.Options{
+		Targets:       []pipeline.Stage{pipeline.StageScene, pipeline.StageScene2D, pipeline.StageSolar, pipeline.StageEmployment, pipeline.StageEducation},
+		Lenient:       true,
+		Deterministic: deterministic,
+	})
+	if err != nil {
+		return err
+	}
+	m, err := artifact.Write(outDir, res, opts)
+	if err != nil {
+		return err
+	}
+	printBuildManifest(outDir, m)
+
+	v := m.Validation
+	if (failOn == "error" && v.Errors > 0) || (failOn == "warning" && v.Errors+v.Warnings > 0) {
+		fmt.Fprintf(os.Stderr, "build failed: %s (--fail-on %s)\n", v.Summary, failOn)
+		os.Exit(1)
+	}
+	return nil
 }
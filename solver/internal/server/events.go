@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// Event types published on a project's events endpoint.
+const (
+	EventSolved      = "solved"
+	EventSolveFailed = "solve_failed"
+)
+
+// sseHeartbeat is how often an idle event stream sends a comment line so
+// proxies keep the connection open.
+const sseHeartbeat = 30 * time.Second
+
+// Event reports the outcome of a solve to renderer clients. Version is the
+// version of the scene the server is serving; it only advances when the
+// scene is replaced, so a failed solve carries the last good version.
+type Event struct {
+	Type    string              `json:"type"`
+	Version int64               `json:"version"`
+	Valid   bool                `json:"valid"`
+	Summary string              `json:"summary"`
+	Errors  []validation.Result `json:"errors,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Reuse   *pipeline.Reuse     `json:"reuse,omitempty"`
+}
+
+// broker fans events out to subscribers. Each subscriber holds at most one
+// pending event: clients only care about the latest state, so a slow client
+// sees the newest event instead of blocking the publisher.
+type broker[T any] struct {
+	mu     sync.Mutex
+	subs   map[chan T]struct{}
+	last   *T
+	closed bool
+}
+
+func newBroker[T any]() *broker[T] {
+	return &broker[T]{subs: map[chan T]struct{}{}}
+}
+
+// subscribe registers a subscriber and returns its channel, primed with the
+// most recent event, and a function that unregisters it. The channel of a
+// closed broker holds the last event, then is closed.
+func (b *broker[T]) subscribe() (<-chan T, func()) {
+	ch := make(chan T, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last != nil {
+		ch <- *b.last
+	}
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+}
+
+func (b *broker[T]) publish(e T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.last = &e
+	for ch := range b.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- e
+	}
+}
+
+// close ends every subscription once its pending event is read.
+func (b *broker[T]) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+		delete(b.subs, ch)
+	}
+}
+
+// handleEvents streams solve events as Server-Sent Events. The current
+// state is sent on connect so a client can tell whether its scene is stale.
+// The stream ends when the project is unloaded; a reconnecting client loads
+// it again.
+func (s *projectServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	streamEvents(w, r, s.events, func(e Event) (string, int64) { return e.Type, e.Version })
+}
+
+// streamEvents writes b's events to w as Server-Sent Events until the
+// client disconnects or b is closed. frame names each event and gives its
+// id.
+func streamEvents[T any](w http.ResponseWriter, r *http.Request, b *broker[T], frame func(T) (string, int64)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming unsupported"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events, unsubscribe := b.subscribe()
+	defer unsubscribe()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			typ, id := frame(e)
+			fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", typ, id, data)
+			flusher.Flush()
+		}
+	}
+}
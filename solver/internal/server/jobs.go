@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+)
+
+// JobStatus is the state of a solve job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobRejected  JobStatus = "rejected" // solved, but the spec is invalid; the last good scene is kept
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// finished reports whether the status is terminal.
+func (st JobStatus) finished() bool {
+	return st != JobQueued && st != JobRunning
+}
+
+// maxFinishedJobs is how many finished jobs stay queryable.
+const maxFinishedJobs = 100
+
+// Job reports a solve job's progress. Stage is the most recently finished
+// pipeline stage; StagesDone counts finished stages, reused ones included.
+type Job struct {
+	ID          string         `json:"id"`
+	Project     string         `json:"project"`
+	Status      JobStatus      `json:"status"`
+	Stage       pipeline.Stage `json:"stage,omitempty"`
+	StagesDone  int            `json:"stages_done"`
+	StagesTotal int            `json:"stages_total"`
+	Error       string         `json:"error,omitempty"`
+	Version     int64          `json:"version,omitempty"` // scene version once finished
+	CreatedAt   time.Time      `json:"created_at"`
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	seq         int64          // event id
+}
+
+// job is a queued or running solve. Its events stream the Job after every
+// change; the stream ends when the job finishes.
+type job struct {
+	id     string
+	num    int64 // creation order
+	proj   *projectServer
+	events *broker[Job]
+	done   chan struct{} // closed when the job finishes
+
+	mu     sync.Mutex
+	state  Job
+	cancel context.CancelFunc // set while running
+}
+
+// snapshot returns the job's current state.
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// update changes the job's state and publishes the result. A finished job
+// no longer changes.
+func (j *job) update(fn func(*Job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.updateLocked(fn)
+}
+
+// updateLocked is update for callers holding j.mu. Publishing under the
+// lock keeps events in order, so the final event is always the last.
+func (j *job) updateLocked(fn func(*Job)) {
+	if j.state.Status.finished() {
+		return
+	}
+	fn(&j.state)
+	j.state.seq++
+	j.events.publish(j.state)
+	if j.state.Status.finished() {
+		j.events.close()
+		close(j.done)
+	}
+}
+
+func (j *job) finish(status JobStatus, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishLocked(status, err)
+}
+
+func (j *job) finishLocked(status JobStatus, err error) {
+	now := time.Now()
+	j.updateLocked(func(st *Job) {
+		st.Status = status
+		st.FinishedAt = &now
+		if err != nil {
+			st.Error = err.Error()
+		}
+		st.Version = j.proj.currentVersion()
+	})
+}
+
+// jobRegistry holds the workspace's jobs by ID.
+type jobRegistry struct {
+	mu   sync.Mutex
+	next int64
+	jobs map[string]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: map[string]*job{}}
+}
+
+// create registers a new queued job for proj.
+func (reg *jobRegistry) create(proj *projectServer) *job {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.next++
+	j := &job{
+		id:     fmt.Sprintf("job_%d", reg.next),
+		num:    reg.next,
+		proj:   proj,
+		events: newBroker[Job](),
+		done:   make(chan struct{}),
+		state: Job{
+			ID:          fmt.Sprintf("job_%d", reg.next),
+			Project:     proj.name,
+			Status:      JobQueued,
+			StagesTotal: len(pipeline.Stages()),
+			CreatedAt:   time.Now(),
+		},
+	}
+	j.events.publish(j.state)
+	reg.jobs[j.id] = j
+	reg.prune()
+	return j
+}
+
+// prune forgets the oldest finished jobs beyond maxFinishedJobs. Callers
+// hold reg.mu.
+func (reg *jobRegistry) prune() {
+	var finished []*job
+	for _, j := range reg.jobs {
+		select {
+		case <-j.done:
+			finished = append(finished, j)
+		default:
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].num < finished[b].num })
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(reg.jobs, j.id)
+	}
+}
+
+func (reg *jobRegistry) get(id string) (*job, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	j, ok := reg.jobs[id]
+	return j, ok
+}
+
+// submitSolve returns a job that will solve the project's spec file as it
+// is now. Requests coalesce: while a solve runs, one follow-up job is
+// queued and every later request joins it, so a burst of requests costs at
+// most two solves and the last always sees the latest spec.
+func (s *projectServer) submitSolve() *job {
+	s.jobMu.Lock()
+	defer s.jobMu.Unlock()
+	if s.queued != nil {
+		return s.queued
+	}
+	j := s.jobs.create(s)
+	if s.running != nil {
+		s.queued = j
+		return j
+	}
+	s.running = j
+	go s.runJobs(j)
+	return j
+}
+
+// runJobs runs j, then each job queued meanwhile.
+func (s *projectServer) runJobs(j *job) {
+	for j != nil {
+		s.runJob(j)
+		s.jobMu.Lock()
+		j, s.queued = s.queued, nil
+		s.running = j
+		s.jobMu.Unlock()
+	}
+}
+
+func (s *projectServer) runJob(j *job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	now := time.Now()
+	j.mu.Lock()
+	if j.state.Status.finished() { // canceled while queued
+		j.mu.Unlock()
+		return
+	}
+	j.cancel = cancel
+	j.updateLocked(func(st *Job) {
+		st.Status = JobRunning
+		st.StartedAt = &now
+	})
+	j.mu.Unlock()
+
+	progress := func(stage pipeline.Stage, _ *pipeline.Result) error {
+		j.update(func(st *Job) {
+			st.Stage = stage
+			st.StagesDone++
+		})
+		return nil
+	}
+	err := s.loadAndSolve(ctx, progress)
+	switch {
+	case err == nil:
+		j.finish(JobSucceeded, nil)
+	case errors.Is(err, context.Canceled):
+		j.finish(JobCanceled, nil)
+	case errors.Is(err, errSolveRejected):
+		j.finish(JobRejected, err)
+	default:
+		j.finish(JobFailed, err)
+	}
+}
+
+// cancelJob cancels a queued or running job. It reports false if the job
+// had already finished.
+func (s *projectServer) cancelJob(j *job) bool {
+	s.jobMu.Lock()
+	if s.queued == j {
+		s.queued = nil
+	}
+	s.jobMu.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state.Status.finished() {
+		return false
+	}
+	if j.cancel != nil {
+		j.cancel() // runJob records the cancellation once the solve stops
+	} else {
+		j.finishLocked(JobCanceled, nil)
+	}
+	return true
+}
+
+// cancelJobs cancels the project's running and queued jobs.
+func (s *projectServer) cancelJobs() {
+	s.jobMu.Lock()
+	running, queued := s.running, s.queued
+	s.jobMu.Unlock()
+	for _, j := range []*job{queued, running} {
+		if j != nil {
+			s.cancelJob(j)
+		}
+	}
+}
+
+// currentVersion returns the version of the scene being served.
+func (s *projectServer) currentVersion() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// handleSolve starts a solve job, or joins one already queued, and returns
+// it with 202 Accepted. Progress is at /api/jobs/{id}/events. With
+// ?wait=true the response waits for the job and carries the solve results.
+func (s *projectServer) handleSolve(w http.ResponseWriter, r *http.Request) {
+	j := s.submitSolve()
+	if r.URL.Query().Get("wait") != "true" {
+		w.Header().Set("Location", "/api/jobs/"+j.id)
+		writeJSON(w, http.StatusAccepted, j.snapshot())
+		return
+	}
+
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		return
+	}
+	st := j.snapshot()
+	code := http.StatusOK
+	switch st.Status {
+	case JobRejected:
+		code = http.StatusUnprocessableEntity
+	case JobFailed:
+		code = http.StatusInternalServerError
+	case JobCanceled:
+		code = http.StatusConflict
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	writeJSON(w, code, map[string]any{
+		"job":         st,
+		"parameters":  s.params,
+		"cost":        s.costReport,
+		"validation":  s.valReport,
+		"scene_graph": s.sceneGraph,
+		"scene_2d":    s.scene2D,
+		"reuse":       s.reuse,
+		"version":     s.version,
+	})
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown job"})
+		return
+	}
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
+
+// handleJobEvents streams a job's progress as Server-Sent Events: a
+// "queued" or "running" event on connect and after every stage, then one
+// event named after the final status, after which the stream ends.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown job"})
+		return
+	}
+	streamEvents(w, r, j.events, func(st Job) (string, int64) { return string(st.Status), st.seq })
+}
+
+// handleCancelJob cancels a queued or running job. Canceling a finished job
+// is a conflict.
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown job"})
+		return
+	}
+	if !j.proj.cancelJob(j) {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "job already finished", "job": j.snapshot()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/ChicagoDave/cityplanner/pkg/compare"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/project"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// scenarioCache keeps the latest comparison solve of each scenario, keyed
+// by a hash of its spec so an edited overlay or base spec is re-solved.
+type scenarioCache struct {
+	mu      sync.Mutex
+	entries map[string]scenarioEntry
+}
+
+type scenarioEntry struct {
+	hash [sha256.Size]byte
+	res  *pipeline.Result
+}
+
+func newScenarioCache() *scenarioCache {
+	return &scenarioCache{entries: map[string]scenarioEntry{}}
+}
+
+// solve returns the comparison solve of the named scenario.
+func (c *scenarioCache) solve(name string, s *spec.CitySpec) (*pipeline.Result, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[name]; ok && e.hash == hash {
+		return e.res, nil
+	}
+	res, err := compare.Solve(s)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[name] = scenarioEntry{hash: hash, res: res}
+	return res, nil
+}
+
+func (s *projectServer) handleScenarios(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	names, err := s.proj.Scenarios()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	type scenario struct {
+		Name        string   `json:"name"`
+		SpecChanges []string `json:"spec_changes"`
+		Error       string   `json:"error,omitempty"`
+	}
+	base, err := s.proj.LoadScenario(project.Baseline)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]scenario, 0, len(names))
+	for _, name := range names {
+		sc := scenario{Name: name, SpecChanges: []string{}}
+		if v, err := s.proj.LoadScenario(name); err != nil {
+			sc.Error = err.Error()
+		} else if d := spec.Diff(base, v); d != nil {
+			sc.SpecChanges = d
+		}
+		out = append(out, sc)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"scenarios": out})
+}
+
+// handleCompare compares scenarios a and b (default: baseline). Unchanged
+// metrics are omitted unless all=true.
+func (s *projectServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+	a, b := q.Get("a"), q.Get("b")
+	if a == "" {
+		a = project.Baseline
+	}
+	if b == "" {
+		http.Error(w, `{"error":"query parameter b is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	results := make([]*pipeline.Result, 2)
+	for i, name := range []string{a, b} {
+		citySpec, err := s.proj.LoadScenario(name)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		if results[i], err = s.scenarios.solve(name, citySpec); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+
+	c := compare.Compare(a, results[0], b, results[1])
+	if q.Get("all") != "true" {
+		c = c.Changed()
+	}
+	json.NewEncoder(w).Encode(c)
+}
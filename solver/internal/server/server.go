@@ -1,26 +1,53 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 	"github.com/ChicagoDave/cityplanner/pkg/cost"
-	"github.com/ChicagoDave/cityplanner/pkg/layout"
-	"github.com/ChicagoDave/cityplanner/pkg/routing"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/project"
 	"github.com/ChicagoDave/cityplanner/pkg/scene"
 	"github.com/ChicagoDave/cityplanner/pkg/scene2d"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 	"github.com/ChicagoDave/cityplanner/pkg/validation"
 )
 
-// Server is the local development server for interactive design.
-type Server struct {
-	projectPath string
-	port        int
+// errSolveRejected reports a solve whose spec has validation errors while
+// the server already has a scene; the last good scene is kept.
+var errSolveRejected = errors.New("spec has validation errors; keeping the last good scene")
+
+// projectServer holds the solved state of one project in the workspace
+// and serves its /api/projects/{project}/... endpoints.
+type projectServer struct {
+	name      string
+	dir       string
+	opts      Options
+	cache     *pipeline.Cache
+	proj      *project.Project
+	events    *broker[Event]
+	scenarios *scenarioCache
+	jobs      *jobRegistry
+	stop      context.CancelFunc // stops the spec watcher
+
+	// jobMu guards the running solve job, the one queued behind it and
+	// the job solving for the 2D scene of a scene loaded from cache.
+	jobMu      sync.Mutex
+	running    *job
+	queued     *job
+	scene2DJob *job
+
+	// solveMu serializes solves; saveMu serializes artifact writes.
+	solveMu sync.Mutex
+	saveMu  sync.Mutex
 
 	mu         sync.RWMutex
 	citySpec   *spec.CitySpec
@@ -28,161 +55,332 @@ type Server struct {
 	costReport *cost.Report
 	valReport  *validation.Report
 	sceneGraph *scene.Graph
+	sceneTiles *scene.TileSet
 	scene2D    *scene2d.Scene2D
+	reuse      pipeline.Reuse
+	fromCache  bool  // scene and cost were loaded from project artifacts
+	version    int64 // advances each time the scene is replaced
 }
 
-// New creates a server for the given project directory.
-func New(projectPath string, port int) *Server {
-	return &Server{
-		projectPath: projectPath,
-		port:        port,
+// openProject opens the project in dir, loads or solves its scene and,
+// with opts.Watch, starts watching its spec until close is called. A failed
+// initial solve is logged, not returned: the project is still served so
+// its validation errors can be inspected and fixed.
+func openProject(name, dir string, opts Options, jobs *jobRegistry) (*projectServer, error) {
+	proj, err := project.Open(dir)
+	if err != nil {
+		return nil, err
 	}
-}
-
-// Start launches the HTTP server.
-func (s *Server) Start() error {
-	if err := s.loadAndSolve(); err != nil {
-		log.Printf("Warning: initial solve failed: %v", err)
+	s := &projectServer{
+		name:      name,
+		dir:       dir,
+		opts:      opts,
+		cache:     pipeline.NewCache(),
+		proj:      proj,
+		events:    newBroker[Event](),
+		scenarios: newScenarioCache(),
+		jobs:      jobs,
 	}
 
-	mux := http.NewServeMux()
+	log.Printf("Loading project %s (%s)", name, dir)
+	if err := s.loadInitial(); err != nil {
+		log.Printf("Warning: %s: initial solve failed: %v", name, err)
+	}
 
-	mux.HandleFunc("GET /api/scene", s.handleScene)
-	mux.HandleFunc("GET /api/scene2d", s.handleScene2D)
-	mux.HandleFunc("GET /api/cost", s.handleCost)
-	mux.HandleFunc("GET /api/validation", s.handleValidation)
-	mux.HandleFunc("POST /api/solve", s.handleSolve)
-	mux.HandleFunc("GET /api/spec", s.handleSpec)
-	mux.HandleFunc("GET /api/parameters", s.handleParameters)
-	mux.HandleFunc("GET /", s.handleIndex)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	if opts.Watch {
+		go watchDir(ctx, dir, s.resolveOnChange)
+	}
+	return s, nil
+}
 
-	addr := fmt.Sprintf(":%d", s.port)
-	log.Printf("CityPlanner server starting on http://localhost%s", addr)
-	log.Printf("Project: %s", s.projectPath)
+// close stops watching the project, cancels its solve jobs and ends its
+// event streams. Other requests already holding the project finish
+// normally.
+func (s *projectServer) close() {
+	s.stop()
+	s.cancelJobs()
+	s.events.close()
+}
 
-	return http.ListenAndServe(addr, mux)
+// loadInitial serves the project's cached scene graph and cost report when
+// they match the spec, and solves otherwise.
+func (s *projectServer) loadInitial() error {
+	if !s.opts.Force {
+		valid, err := s.proj.CacheValid()
+		if err != nil {
+			return err
+		}
+		if valid {
+			err := s.loadCached()
+			if err == nil {
+				log.Printf("%s: using cached scene graph (spec unchanged); POST /api/projects/%s/solve or --force to re-solve", s.name, s.name)
+				return nil
+			}
+			log.Printf("Warning: %s: cached artifacts unusable, solving: %v", s.name, err)
+		}
+	}
+	return s.loadAndSolve(context.Background(), nil)
 }
 
-func (s *Server) loadAndSolve() error {
-	citySpec, err := spec.LoadProject(s.projectPath)
+// loadCached loads the cached artifacts and runs only the analytical stages
+// for parameters. The 2D scene is solved on first request.
+func (s *projectServer) loadCached() error {
+	graph, costReport, err := s.proj.LoadCached()
+	if err != nil {
+		return err
+	}
+	analysis, err := s.proj.ReadAnalysis()
+	if err != nil {
+		return err
+	}
+	citySpec, err := spec.Load(s.proj.SpecPath())
 	if err != nil {
 		return fmt.Errorf("loading spec: %w", err)
 	}
+	res, err := pipeline.Run(citySpec, pipeline.Options{Lenient: true, StopAfter: pipeline.StageCost})
+	if err != nil {
+		return err
+	}
+	tiles := scene.BuildTiles(graph, scene.DefaultTileOptions())
 
-	schemaReport := validation.ValidateSchema(citySpec)
-	params, analyticsReport := analytics.Resolve(citySpec)
-	schemaReport.Merge(analyticsReport)
-
-	costReport := cost.Estimate(citySpec, params)
-	params.PerCapitaCost = costReport.Summary.PerCapita
-	params.BreakEvenRent = costReport.Summary.BreakEvenMonthlyRent
-
-	// Phase 2: Spatial generation.
-	pods, adjacency, podReport := layout.LayoutPods(citySpec, params)
-	schemaReport.Merge(podReport)
-
-	buildings, paths, buildReport := layout.PlaceBuildings(citySpec, pods, adjacency, params)
-	schemaReport.Merge(buildReport)
-
-	segments, routeReport := routing.RouteInfrastructure(citySpec, pods, buildings)
-	schemaReport.Merge(routeReport)
-
-	bikePaths, bikeReport := layout.GenerateBikePaths(pods, adjacency, citySpec.CityZones.Rings)
-	schemaReport.Merge(bikeReport)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.citySpec = res.Spec
+	s.params = res.Params
+	s.costReport = costReport
+	s.valReport = analysis.Validation
+	s.sceneGraph = graph
+	s.sceneTiles = tiles
+	s.scene2D = nil
+	s.fromCache = true
+	s.version++
+	s.events.publish(s.solvedEvent())
+	return nil
+}
 
-	shuttleRoutes, stations, shuttleReport := layout.GenerateShuttleRoutes(bikePaths, pods)
-	schemaReport.Merge(shuttleReport)
+// resolveOnChange submits a solve job after the watcher sees a spec change.
+// It coalesces with solves requested over the API.
+func (s *projectServer) resolveOnChange() {
+	j := s.submitSolve()
+	log.Printf("%s: spec changed; re-solving (%s)", s.name, j.id)
+}
 
-	sportsFields, sportsReport := layout.PlaceSportsFields(pods, adjacency, citySpec.CityZones.Rings)
-	schemaReport.Merge(sportsReport)
+// loadAndSolve solves the project spec file and publishes the outcome.
+// progress, if set, runs after every pipeline stage.
+func (s *projectServer) loadAndSolve(ctx context.Context, progress pipeline.Hook) error {
+	s.solveMu.Lock()
+	defer s.solveMu.Unlock()
 
-	greenZones := layout.CollectGreenZones(citySpec, pods)
+	specHash, err := s.proj.SpecHash()
+	if err != nil {
+		s.publishFailure(err.Error(), nil)
+		return err
+	}
+	citySpec, err := spec.Load(s.proj.SpecPath())
+	if err != nil {
+		err = fmt.Errorf("loading spec: %w", err)
+		s.publishFailure(err.Error(), nil)
+		return err
+	}
+	return s.solveSpec(ctx, citySpec, specHash, progress)
+}
 
-	plazas, plazaReport := layout.GeneratePlazas(pods, citySpec)
-	schemaReport.Merge(plazaReport)
+// solveSpec solves citySpec, installs the result and publishes the outcome.
+// Callers hold solveMu. The solve is lenient so that a first solve of a spec
+// with errors still gives the renderer a city; the errors are reported
+// through the validation endpoint. Once the server has a scene, a spec with
+// errors does not replace it: the errors are published and the last good
+// scene is kept. specHash is the hash of the spec file citySpec was read from, or
+// empty when citySpec is not on disk, in which case no artifacts are saved.
+// A solve canceled through ctx leaves the scene as it was.
+func (s *projectServer) solveSpec(ctx context.Context, citySpec *spec.CitySpec, specHash string, progress pipeline.Hook) error {
+	opts := pipeline.Options{Lenient: true, Cache: s.cache}
+	if progress != nil {
+		opts.After = []pipeline.Hook{progress}
+	}
+	res, err := pipeline.RunContext(ctx, citySpec, opts)
+	if err != nil {
+		s.publishFailure(err.Error(), nil)
+		return err
+	}
 
-	trees, treeReport := layout.PlaceTrees(pods, greenZones, paths, bikePaths, plazas)
-	schemaReport.Merge(treeReport)
+	s.mu.RLock()
+	tiles := s.sceneTiles
+	haveScene := s.sceneGraph != nil
+	graphReused := res.Graph == s.sceneGraph
+	s.mu.RUnlock()
+
+	if !res.Report.Valid && haveScene {
+		s.mu.Lock()
+		s.citySpec = res.Spec
+		s.valReport = res.Report
+		s.reuse = res.Reuse
+		s.mu.Unlock()
+		s.publishFailure("", res.Report)
+		return fmt.Errorf("%w: %s", errSolveRejected, res.Report.Summary)
+	}
 
-	graph := scene.Assemble(citySpec, pods, buildings, paths, segments, greenZones, bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees)
-	sc2d := scene2d.Assemble2D(citySpec, params, pods, buildings, paths, greenZones, bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees)
+	if !graphReused || tiles == nil {
+		tiles = scene.BuildTiles(res.Graph, scene.DefaultTileOptions())
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.citySpec = citySpec
-	s.params = params
-	s.costReport = costReport
-	s.valReport = schemaReport
-	s.sceneGraph = graph
-	s.scene2D = sc2d
+	s.citySpec = res.Spec
+	s.params = res.Params
+	s.costReport = res.Cost
+	s.valReport = res.Report
+	s.sceneGraph = res.Graph
+	s.sceneTiles = tiles
+	s.scene2D = res.Scene2D
+	s.reuse = res.Reuse
+	s.fromCache = false
+	if !graphReused {
+		s.version++
+	}
+	s.events.publish(s.solvedEvent())
+
+	if specHash != "" {
+		go s.saveArtifacts(specHash, res)
+	}
 	return nil
 }
 
-func (s *Server) handleIndex(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "text/html")
-	fmt.Fprint(w, `<!DOCTYPE html>
-<html><head><title>CityPlanner</title></head>
-<body style="margin:0;background:#111;color:#fff;font-family:system-ui;display:flex;align-items:center;justify-content:center;height:100vh">
-<div style="text-align:center">
-<h1>CityPlanner</h1>
-<p>Renderer not yet embedded. Run <code>npm run dev</code> in renderer/ for development.</p>
-<p>API endpoints: <a href="/api/spec">/api/spec</a> | <a href="/api/validation">/api/validation</a> | <a href="/api/cost">/api/cost</a> | <a href="/api/parameters">/api/parameters</a> | <a href="/api/scene2d">/api/scene2d</a></p>
-</div>
-</body></html>`)
+// solvedEvent describes the scene being served. Callers hold s.mu.
+func (s *projectServer) solvedEvent() Event {
+	e := Event{Type: EventSolved, Version: s.version, Valid: true}
+	if s.valReport != nil {
+		e.Valid = s.valReport.Valid
+		e.Summary = s.valReport.Summary
+		e.Errors = s.valReport.Errors
+	}
+	if !s.fromCache {
+		reuse := s.reuse
+		e.Reuse = &reuse
+	}
+	return e
 }
 
-func (s *Server) handleScene(w http.ResponseWriter, _ *http.Request) {
+// publishFailure announces a solve that did not replace the scene, either
+// because it failed outright (msg) or because the spec is invalid (report).
+func (s *projectServer) publishFailure(msg string, report *validation.Report) {
+	s.mu.RLock()
+	e := Event{Type: EventSolveFailed, Version: s.version, Error: msg}
+	s.mu.RUnlock()
+	if report != nil {
+		e.Summary = report.Summary
+		e.Errors = report.Errors
+	}
+	s.events.publish(e)
+}
+
+// saveArtifacts writes the solved scene graph and cost report to the
+// project unless the cache already holds them.
+func (s *projectServer) saveArtifacts(specHash string, res *pipeline.Result) {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	m := s.proj.Manifest
+	if m.CacheValid && m.SpecHash == specHash && m.SolverVersion == pipeline.Version {
+		return
+	}
+	if err := s.proj.SaveArtifacts(specHash, res.Graph, res.Cost, project.NewAnalysis(res)); err != nil {
+		log.Printf("Warning: %s: writing project artifacts: %v", s.name, err)
+	}
+}
+
+func (s *projectServer) handleScene(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	w.Header().Set("Vary", "Accept")
 	w.Header().Set("Content-Type", "application/json")
 	if s.sceneGraph == nil {
 		http.Error(w, `{"error":"no scene graph available"}`, http.StatusServiceUnavailable)
 		return
 	}
+	if acceptsBinaryScene(r) {
+		w.Header().Set("Content-Type", scene.BinaryContentType)
+		if err := scene.EncodeBinary(w, s.sceneGraph); err != nil {
+			log.Printf("encoding binary scene: %v", err)
+		}
+		return
+	}
 	json.NewEncoder(w).Encode(s.sceneGraph)
 }
 
-func (s *Server) handleCost(w http.ResponseWriter, _ *http.Request) {
+// acceptsBinaryScene reports whether the client asked for the compact
+// binary scene encoding via the Accept header.
+func acceptsBinaryScene(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if mediaType == scene.BinaryContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *projectServer) handleTileIndex(w http.ResponseWriter, _ *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	w.Header().Set("Content-Type", "application/json")
-	if s.costReport == nil {
-		http.Error(w, `{"error":"no cost data available"}`, http.StatusServiceUnavailable)
+	if s.sceneTiles == nil {
+		http.Error(w, `{"error":"no scene tiles available"}`, http.StatusServiceUnavailable)
 		return
 	}
-	json.NewEncoder(w).Encode(s.costReport)
+	json.NewEncoder(w).Encode(s.sceneTiles.Index())
 }
 
-func (s *Server) handleValidation(w http.ResponseWriter, _ *http.Request) {
+func (s *projectServer) handleTile(w http.ResponseWriter, r *http.Request) {
+	z, errZ := strconv.Atoi(r.PathValue("z"))
+	x, errX := strconv.Atoi(r.PathValue("x"))
+	y, errY := strconv.Atoi(r.PathValue("y"))
+	w.Header().Set("Content-Type", "application/json")
+	if errZ != nil || errX != nil || errY != nil {
+		http.Error(w, `{"error":"tile address must be integers z/x/y"}`, http.StatusBadRequest)
+		return
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	w.Header().Set("Content-Type", "application/json")
-	if s.valReport == nil {
-		http.Error(w, `{"error":"no validation data available"}`, http.StatusServiceUnavailable)
+	if s.sceneTiles == nil {
+		http.Error(w, `{"error":"no scene tiles available"}`, http.StatusServiceUnavailable)
 		return
 	}
-	json.NewEncoder(w).Encode(s.valReport)
+	tile, ok := s.sceneTiles.Lookup(z, x, y)
+	if !ok {
+		http.Error(w, fmt.Sprintf(`{"error":"no tile %s"}`, scene.TileID(z, x, y)), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(tile)
 }
 
-func (s *Server) handleSolve(w http.ResponseWriter, _ *http.Request) {
-	if err := s.loadAndSolve(); err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+func (s *projectServer) handleCost(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	if s.costReport == nil {
+		http.Error(w, `{"error":"no cost data available"}`, http.StatusServiceUnavailable)
 		return
 	}
+	json.NewEncoder(w).Encode(s.costReport)
+}
+
+func (s *projectServer) handleValidation(w http.ResponseWriter, _ *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"status":      "ok",
-		"parameters":  s.params,
-		"cost":        s.costReport,
-		"validation":  s.valReport,
-		"scene_graph": s.sceneGraph,
-		"scene_2d":    s.scene2D,
-	})
+	if s.valReport == nil {
+		http.Error(w, `{"error":"no validation data available"}`, http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(s.valReport)
 }
 
-func (s *Server) handleSpec(w http.ResponseWriter, _ *http.Request) {
+func (s *projectServer) handleSpec(w http.ResponseWriter, _ *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	w.Header().Set("Content-Type", "application/json")
@@ -193,7 +391,7 @@ func (s *Server) handleSpec(w http.ResponseWriter, _ *http.Request) {
 	json.NewEncoder(w).Encode(s.citySpec)
 }
 
-func (s *Server) handleParameters(w http.ResponseWriter, _ *http.Request) {
+func (s *projectServer) handleParameters(w http.ResponseWriter, _ *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	w.Header().Set("Content-Type", "application/json")
@@ -204,7 +402,25 @@ func (s *Server) handleParameters(w http.ResponseWriter, _ *http.Request) {
 	json.NewEncoder(w).Encode(s.params)
 }
 
-func (s *Server) handleScene2D(w http.ResponseWriter, _ *http.Request) {
+// handleScene2D serves the 2D scene. A scene loaded from the project's
+// cached artifacts has none: the first request starts a solve job for it,
+// and until the job installs a scene, requests get 503 naming the job.
+func (s *projectServer) handleScene2D(w http.ResponseWriter, _ *http.Request) {
+	s.mu.RLock()
+	pending := s.scene2D == nil && s.fromCache
+	s.mu.RUnlock()
+	if pending {
+		st := s.solveScene2D().snapshot()
+		msg := "2D scene is being solved"
+		if st.Status.finished() {
+			msg = "no 2D scene available"
+		}
+		w.Header().Set("Location", "/api/jobs/"+st.ID)
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": msg, "job": st})
+		return
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	w.Header().Set("Content-Type", "application/json")
@@ -214,3 +430,21 @@ func (s *Server) handleScene2D(w http.ResponseWriter, _ *http.Request) {
 	}
 	json.NewEncoder(w).Encode(s.scene2D)
 }
+
+// solveScene2D returns the job solving for the 2D scene, submitting one
+// unless an earlier one was submitted and not canceled. A job that failed
+// or was rejected is not retried here; a spec edit or an explicit solve
+// request starts the next.
+func (s *projectServer) solveScene2D() *job {
+	s.jobMu.Lock()
+	j := s.scene2DJob
+	s.jobMu.Unlock()
+	if j != nil && j.snapshot().Status != JobCanceled {
+		return j
+	}
+	j = s.submitSolve()
+	s.jobMu.Lock()
+	s.scene2DJob = j
+	s.jobMu.Unlock()
+	return j
+}
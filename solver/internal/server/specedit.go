@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// Patch media types accepted by PATCH /api/projects/{project}/spec.
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// maxPatchBytes bounds the size of a spec patch request body.
+const maxPatchBytes = 1 << 20
+
+// handlePatchSpec applies a JSON Merge Patch or JSON Patch to the current
+// spec, checks it with schema validation and re-solves. A plain
+// application/json body is treated as a JSON Patch when it is an array and
+// a merge patch otherwise. With ?write=true the patched spec is also written
+// back to the project's spec file, keeping its comments and key order;
+// without it the change lasts until the spec file is next reloaded.
+func (s *projectServer) handlePatchSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPatchBytes+1))
+	if err != nil {
+		http.Error(w, `{"error":"reading request body"}`, http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxPatchBytes {
+		http.Error(w, `{"error":"patch too large"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+	apply, err := patchFunc(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		return
+	}
+	write := r.URL.Query().Get("write") == "true"
+
+	s.solveMu.Lock()
+	defer s.solveMu.Unlock()
+
+	s.mu.RLock()
+	base := s.citySpec
+	s.mu.RUnlock()
+	if base == nil {
+		http.Error(w, `{"error":"no spec loaded"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	patched, err := apply(base, body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	changes := spec.Diff(base, patched)
+	if schema := validation.ValidateSchema(patched); !schema.Valid {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"status":     "invalid",
+			"error":      "patched spec fails schema validation",
+			"changes":    changes,
+			"validation": schema,
+		})
+		return
+	}
+
+	specHash := ""
+	if write {
+		if err := spec.Save(s.proj.SpecPath(), patched); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		if specHash, err = s.proj.SpecHash(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if err := s.solveSpec(r.Context(), patched, specHash, nil); errors.Is(err, errSolveRejected) {
+		status, code = "rejected", http.StatusUnprocessableEntity
+	} else if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	writeJSON(w, code, map[string]any{
+		"status":     status,
+		"changes":    changes,
+		"written":    write,
+		"version":    s.version,
+		"parameters": s.params,
+		"cost":       s.costReport,
+		"validation": s.valReport,
+		"reuse":      s.reuse,
+	})
+}
+
+// patchFunc picks the patch format from the request's content type.
+func patchFunc(contentType string, body []byte) (func(*spec.CitySpec, []byte) (*spec.CitySpec, error), error) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, fmt.Errorf("invalid content type")
+		}
+	}
+	switch mediaType {
+	case mergePatchType:
+		return spec.MergePatch, nil
+	case jsonPatchType:
+		return spec.JSONPatch, nil
+	case "application/json":
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			return spec.JSONPatch, nil
+		}
+		return spec.MergePatch, nil
+	default:
+		return nil, fmt.Errorf("content type must be %s or %s", mergePatchType, jsonPatchType)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
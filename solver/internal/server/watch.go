@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	watchDebounce = 250 * time.Millisecond
+)
+
+// fileStamp identifies a version of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchDir polls dir for changes to spec files and calls onChange once the
+// directory has been quiet for the debounce interval, so an editor's
+// save-as-rename or a burst of writes triggers a single solve. Polling keeps
+// the watcher portable and free of external dependencies.
+func watchDir(ctx context.Context, dir string, onChange func()) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	last := scanDir(dir)
+	var pending <-chan time.Time
+	var debounce *time.Timer
+	for {
+		select {
+		case <-ctx.Done():
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+		case <-ticker.C:
+			cur := scanDir(dir)
+			if sameStamps(last, cur) {
+				continue
+			}
+			last = cur
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.NewTimer(watchDebounce)
+			pending = debounce.C
+		case <-pending:
+			pending = nil
+			debounce = nil
+			onChange()
+		}
+	}
+}
+
+// scanDir stamps the spec files in dir. Derived artifacts, the manifest and
+// hidden files are ignored: the server writes the first two itself, and
+// editors keep their swap files hidden.
+func scanDir(dir string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return stamps
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !isSpecFile(name) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		stamps[name] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps
+}
+
+func isSpecFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sa := range a {
+		sb, ok := b[name]
+		if !ok || !sa.modTime.Equal(sb.modTime) || sa.size != sb.size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"container/list"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ChicagoDave/cityplanner/internal/webui"
+	"github.com/ChicagoDave/cityplanner/pkg/project"
+)
+
+// DefaultMaxProjects is how many projects stay loaded when
+// Options.MaxProjects is zero.
+const DefaultMaxProjects = 3
+
+// Options configures a Server.
+type Options struct {
+	// Force solves a project when it is loaded even when its cached
+	// artifacts are current.
+	Force bool
+	// Watch re-solves when a spec file in a loaded project changes.
+	Watch bool
+	// MaxProjects is how many projects keep their solved state in memory.
+	// Loading another evicts the least recently used. Zero means
+	// DefaultMaxProjects.
+	MaxProjects int
+}
+
+// Server is the local development server for interactive design. It serves
+// a workspace: a directory of projects, each a subdirectory containing a
+// city.yaml (a project directory is a workspace of one). Projects are
+// loaded on first request and evicted least recently used.
+type Server struct {
+	workspace string
+	port      int
+	opts      Options
+	jobs      *jobRegistry
+
+	mu        sync.Mutex
+	loaded    map[string]*list.Element  // of *projectSlot
+	lru       *list.List                // front is most recently used
+	summaries map[string]projectSummary // last validation of evicted projects
+}
+
+// projectSlot is a loaded or loading project. ready is closed once ps or
+// err is set.
+type projectSlot struct {
+	name  string
+	ready chan struct{}
+	ps    *projectServer
+	err   error
+}
+
+// projectSummary is a project's entry in /api/projects.
+type projectSummary struct {
+	Name       string             `json:"name"`
+	Loaded     bool               `json:"loaded"`
+	Version    int64              `json:"version,omitempty"`
+	Validation *validationSummary `json:"validation"` // null until first solved
+}
+
+type validationSummary struct {
+	Valid    bool   `json:"valid"`
+	Summary  string `json:"summary"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+}
+
+// New creates a server for the given workspace directory.
+func New(workspace string, port int, opts Options) *Server {
+	if opts.MaxProjects <= 0 {
+		opts.MaxProjects = DefaultMaxProjects
+	}
+	return &Server{
+		workspace: workspace,
+		port:      port,
+		opts:      opts,
+		jobs:      newJobRegistry(),
+		loaded:    map[string]*list.Element{},
+		lru:       list.New(),
+		summaries: map[string]projectSummary{},
+	}
+}
+
+// Start launches the HTTP server.
+func (s *Server) Start() error {
+	projects, err := project.Discover(s.workspace)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		return fmt.Errorf("no projects in %s: expected subdirectories containing %s", s.workspace, project.DefaultSpecFile)
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/projects", s.handleProjects)
+	s.handleProject(mux, "GET /scene", (*projectServer).handleScene)
+	s.handleProject(mux, "GET /scene/tiles", (*projectServer).handleTileIndex)
+	s.handleProject(mux, "GET /scene/tiles/{z}/{x}/{y}", (*projectServer).handleTile)
+	s.handleProject(mux, "GET /scene2d", (*projectServer).handleScene2D)
+	s.handleProject(mux, "GET /cost", (*projectServer).handleCost)
+	s.handleProject(mux, "GET /validation", (*projectServer).handleValidation)
+	s.handleProject(mux, "POST /solve", (*projectServer).handleSolve)
+	s.handleProject(mux, "GET /spec", (*projectServer).handleSpec)
+	s.handleProject(mux, "PATCH /spec", (*projectServer).handlePatchSpec)
+	s.handleProject(mux, "GET /parameters", (*projectServer).handleParameters)
+	s.handleProject(mux, "GET /events", (*projectServer).handleEvents)
+	s.handleProject(mux, "GET /scenarios", (*projectServer).handleScenarios)
+	s.handleProject(mux, "GET /compare", (*projectServer).handleCompare)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /api/jobs/{id}/events", s.handleJobEvents)
+	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleCancelJob)
+	mux.Handle("GET /api/", http.NotFoundHandler())
+	if ui := webui.Handler(); ui != nil {
+		mux.Handle("GET /", ui)
+	} else {
+		mux.HandleFunc("GET /", s.handleIndex)
+	}
+
+	addr := fmt.Sprintf(":%d", s.port)
+	log.Printf("CityPlanner server starting on http://localhost%s", addr)
+	log.Printf("Workspace: %s (%d projects, up to %d loaded)", s.workspace, len(projects), s.opts.MaxProjects)
+	if s.opts.Watch {
+		log.Printf("Watching loaded projects for spec changes")
+	}
+
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleProject registers a per-project endpoint under
+// /api/projects/{project}. pattern is a method and a path relative to the
+// project.
+func (s *Server) handleProject(mux *http.ServeMux, pattern string, h func(*projectServer, http.ResponseWriter, *http.Request)) {
+	method, path, _ := strings.Cut(pattern, " ")
+	mux.HandleFunc(method+" /api/projects/{project}"+path, func(w http.ResponseWriter, r *http.Request) {
+		ps, status, err := s.project(r.PathValue("project"))
+		if err != nil {
+			writeJSON(w, status, map[string]any{"error": err.Error()})
+			return
+		}
+		h(ps, w, r)
+	})
+}
+
+// project returns the named project, loading it if needed. Concurrent
+// requests for a project being loaded wait for the one load.
+func (s *Server) project(name string) (*projectServer, int, error) {
+	s.mu.Lock()
+	if el, ok := s.loaded[name]; ok {
+		s.lru.MoveToFront(el)
+		slot := el.Value.(*projectSlot)
+		s.mu.Unlock()
+		<-slot.ready
+		if slot.err != nil {
+			return nil, http.StatusInternalServerError, slot.err
+		}
+		return slot.ps, 0, nil
+	}
+	s.mu.Unlock()
+
+	dir, ok, err := s.lookup(name)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("unknown project %q", name)
+	}
+
+	s.mu.Lock()
+	if _, ok := s.loaded[name]; ok {
+		// Another request started loading it meanwhile.
+		s.mu.Unlock()
+		return s.project(name)
+	}
+	slot := &projectSlot{name: name, ready: make(chan struct{})}
+	s.loaded[name] = s.lru.PushFront(slot)
+	s.evict()
+	s.mu.Unlock()
+
+	slot.ps, slot.err = openProject(name, dir, s.opts, s.jobs)
+	close(slot.ready)
+	if slot.err != nil {
+		s.mu.Lock()
+		if el, ok := s.loaded[name]; ok && el.Value == slot {
+			s.lru.Remove(el)
+			delete(s.loaded, name)
+		}
+		s.mu.Unlock()
+		return nil, http.StatusInternalServerError, slot.err
+	}
+	return slot.ps, 0, nil
+}
+
+// lookup finds a project's directory by name.
+func (s *Server) lookup(name string) (string, bool, error) {
+	projects, err := project.Discover(s.workspace)
+	if err != nil {
+		return "", false, err
+	}
+	for _, p := range projects {
+		if p.Name == name {
+			return p.Dir, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// evict unloads least recently used projects beyond the limit, keeping
+// their validation summaries for /api/projects. Callers hold s.mu.
+func (s *Server) evict() {
+	for s.lru.Len() > s.opts.MaxProjects {
+		el := s.lru.Back()
+		slot := el.Value.(*projectSlot)
+		s.lru.Remove(el)
+		delete(s.loaded, slot.name)
+		go func() {
+			<-slot.ready
+			if slot.ps == nil {
+				return
+			}
+			sum := slot.ps.summary()
+			s.mu.Lock()
+			s.summaries[slot.name] = sum
+			s.mu.Unlock()
+			slot.ps.close()
+			log.Printf("Unloaded project %s", slot.name)
+		}()
+	}
+}
+
+// summary describes the project for /api/projects.
+func (s *projectServer) summary() projectSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sum := projectSummary{Name: s.name, Loaded: true, Version: s.version}
+	if r := s.valReport; r != nil {
+		sum.Validation = &validationSummary{
+			Valid:    r.Valid,
+			Summary:  r.Summary,
+			Errors:   len(r.Errors),
+			Warnings: len(r.Warnings),
+		}
+	}
+	return sum
+}
+
+// handleProjects lists the workspace's projects with the validation
+// summary of their last solve. Listing does not load projects; a project
+// never loaded has a null validation.
+func (s *Server) handleProjects(w http.ResponseWriter, _ *http.Request) {
+	projects, err := project.Discover(s.workspace)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	out := make([]projectSummary, 0, len(projects))
+	for _, p := range projects {
+		out = append(out, s.summary(p.Name))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"projects": out})
+}
+
+func (s *Server) summary(name string) projectSummary {
+	s.mu.Lock()
+	el, ok := s.loaded[name]
+	sum, evicted := s.summaries[name]
+	s.mu.Unlock()
+	if ok {
+		slot := el.Value.(*projectSlot)
+		select {
+		case <-slot.ready:
+			if slot.ps != nil {
+				return slot.ps.summary()
+			}
+		default: // still loading
+		}
+	}
+	if !evicted {
+		sum = projectSummary{Name: name}
+	}
+	sum.Loaded, sum.Version = false, 0
+	return sum
+}
+
+// handleIndex lists the projects when the binary was built without the
+// renderer.
+func (s *Server) handleIndex(w http.ResponseWriter, _ *http.Request) {
+	projects, err := project.Discover(s.workspace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var links strings.Builder
+	for _, p := range projects {
+		name := html.EscapeString(p.Name)
+		fmt.Fprintf(&links, `<li>%s: <a href="/api/projects/%s/spec">spec</a> | <a href="/api/projects/%s/validation">validation</a> | <a href="/api/projects/%s/cost">cost</a> | <a href="/api/projects/%s/scene2d">scene2d</a> | <a href="/api/projects/%s/scenarios">scenarios</a></li>`,
+			name, name, name, name, name, name)
+	}
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><title>CityPlanner</title></head>
+<body style="margin:0;background:#111;color:#fff;font-family:system-ui;display:flex;align-items:center;justify-content:center;height:100vh">
+<div style="text-align:center">
+<h1>CityPlanner</h1>
+<p>Renderer not embedded in this build. Run <code>make build</code> to embed it, or <code>make dev-renderer</code> for development.</p>
+<p>Projects (<a href="/api/projects">/api/projects</a>):</p>
+<ul style="list-style:none;padding:0">%s</ul>
+</div>
+</body></html>`, links.String())
+}
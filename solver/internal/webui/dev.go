@@ -0,0 +1,34 @@
+//go:build dev
+
+package webui
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+)
+
+// DefaultViteURL is the Vite dev server proxied to when CITYPLANNER_VITE_URL
+// is unset.
+const DefaultViteURL = "http://localhost:5173"
+
+// handler proxies to the Vite dev server, websockets included, so hot
+// module reloading works through the proxy.
+func handler() http.Handler {
+	target := os.Getenv("CITYPLANNER_VITE_URL")
+	if target == "" {
+		target = DefaultViteURL
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		log.Fatalf("invalid CITYPLANNER_VITE_URL %q: %v", target, err)
+	}
+	log.Printf("Dev mode: proxying the renderer to %s", u)
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+		http.Error(w, "Vite dev server unavailable ("+err.Error()+"). Run `make dev-renderer`.", http.StatusBadGateway)
+	}
+	return proxy
+}
@@ -0,0 +1,213 @@
+//go:build !dev
+
+package webui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// dist holds the renderer build; `make build` copies renderer/dist here.
+// The tracked .gitkeep keeps the pattern matching before the first build.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// minGzipSize is the smallest file worth compressing.
+const minGzipSize = 1024
+
+// asset is an embedded file prepared for serving.
+type asset struct {
+	data  []byte
+	gz    []byte // nil when not worth compressing
+	etag  string
+	ctype string
+}
+
+// assets serves the embedded renderer. Paths without an extension that
+// match no file get index.html, so client-side routes load the app.
+type assets map[string]*asset
+
+func handler() http.Handler {
+	root, err := fs.Sub(dist, "dist")
+	if err != nil {
+		log.Printf("Warning: embedded renderer unreadable: %v", err)
+		return nil
+	}
+	a, err := loadAssets(root)
+	if err != nil {
+		log.Printf("Warning: embedded renderer unreadable: %v", err)
+		return nil
+	}
+	if _, ok := a["index.html"]; !ok {
+		return nil
+	}
+	return a
+}
+
+// loadAssets reads every non-hidden file in root, hashing each for its
+// ETag and compressing the ones that shrink.
+func loadAssets(root fs.FS) (assets, error) {
+	a := assets{}
+	err := fs.WalkDir(root, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && name != "." {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(root, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		f := &asset{
+			data:  data,
+			etag:  hex.EncodeToString(sum[:8]),
+			ctype: contentType(name, data),
+		}
+		if len(data) >= minGzipSize && compressible(f.ctype) {
+			f.gz = compress(data)
+		}
+		a[name] = f
+		return nil
+	})
+	return a, err
+}
+
+// contentTypes fixes the types of the renderer's file kinds rather than
+// trusting the host's MIME tables, which vary across systems.
+var contentTypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".css":         "text/css; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".ico":         "image/x-icon",
+	".wasm":        "application/wasm",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".txt":         "text/plain; charset=utf-8",
+}
+
+func contentType(name string, data []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+func compressible(ctype string) bool {
+	mt, _, _ := strings.Cut(ctype, ";")
+	switch mt {
+	case "application/json", "application/manifest+json", "application/wasm", "image/svg+xml":
+		return true
+	}
+	return strings.HasPrefix(mt, "text/")
+}
+
+// compress gzips data, returning nil if that does not make it smaller.
+func compress(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	if buf.Len() >= len(data) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func (a assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	f, ok := a[name]
+	if !ok {
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name, f = "index.html", a["index.html"]
+	}
+
+	h := w.Header()
+	switch {
+	case name == "index.html":
+		h.Set("Cache-Control", "no-cache")
+	case strings.HasPrefix(name, "assets/"):
+		// Vite puts a content hash in every file name under assets/.
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	body, etag := f.data, `"`+f.etag+`"`
+	if f.gz != nil {
+		h.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			body, etag = f.gz, `"`+f.etag+`-gz"`
+			h.Set("Content-Encoding", "gzip")
+		}
+	}
+	h.Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		h.Del("Content-Encoding")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", f.ctype)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for it.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
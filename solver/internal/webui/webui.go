@@ -0,0 +1,14 @@
+// Package webui serves the renderer from the cityplanner binary.
+//
+// Normal builds embed the renderer's production build, copied into dist/ by
+// `make build`. Builds with the dev tag proxy to the Vite dev server
+// instead, so renderer changes hot-reload behind the solver's own port.
+package webui
+
+import "net/http"
+
+// Handler returns the handler serving the renderer, or nil when the binary
+// has no renderer to serve (dist/ was empty when it was built).
+func Handler() http.Handler {
+	return handler()
+}
@@ -25,6 +25,7 @@ type ResolvedParameters struct {
 	TotalAdults   int               `json:"total_adults"`
 	TotalChildren int               `json:"total_children"`
 	TotalStudents int               `json:"total_students"`
+	LaborForce    int               `json:"labor_force"` // adults working or seeking work
 	WeightedAvgHH float64           `json:"weighted_avg_household_size"`
 }
 
@@ -37,7 +38,7 @@ func Resolve(s *spec.CitySpec) (*ResolvedParameters, *validation.Report) {
 	// 1. Demographics
 	cohorts, weightedAvg := resolveDemographics(s)
 	depRatio := computeDependencyRatio(cohorts)
-	adults, children, students := sumCohortTotals(cohorts)
+	adults, children, students, workers := sumCohortTotals(cohorts)
 
 	// 2. Areas
 	areas := resolveAreas(s)
@@ -88,6 +89,7 @@ func Resolve(s *spec.CitySpec) (*ResolvedParameters, *validation.Report) {
 		TotalAdults:         adults,
 		TotalChildren:       children,
 		TotalStudents:       students,
+		LaborForce:          workers,
 		WeightedAvgHH:       weightedAvg,
 	}
 
@@ -128,6 +128,7 @@ func TestResolveDefaultCity(t *testing.T) {
 
 func TestResolveInfeasibleDensity(t *testing.T) {
 	s := fullDefaultSpec()
+	s.City.HeightProfile = spec.ProfileStep
 	s.City.Population = 500000 // 10x population, same area
 	_, report := Resolve(s)
 
@@ -146,6 +147,24 @@ func TestResolveInfeasibleDensity(t *testing.T) {
 	}
 }
 
+func TestResolveInfeasibleDensityBowl(t *testing.T) {
+	s := fullDefaultSpec()
+	s.City.Population = 500000
+	_, report := Resolve(s)
+
+	// A bowl's heights come from the profile, not ring max_stories.
+	hasError := false
+	for _, e := range report.Errors {
+		if e.SpecPath == "city.height_profile" {
+			hasError = true
+			break
+		}
+	}
+	if !hasError {
+		t.Error("expected density feasibility error against the height profile")
+	}
+}
+
 func TestResolveInsufficientBattery(t *testing.T) {
 	s := fullDefaultSpec()
 	s.Infrastructure.Electrical.BatteryCapacityMWh = 1000 // Not enough for 24h
@@ -6,22 +6,26 @@ import (
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 )
 
-// cohortDef defines the fixed household size and adult/child breakdown per cohort.
+// cohortDef defines the fixed household size and adult/child breakdown per
+// cohort, and the share of its adults in the labor force.
 type cohortDef struct {
 	name          string
 	householdSize float64
 	adultsPerHH   float64
 	childrenPerHH float64
+	participation float64
 }
 
 // Household sizes and compositions from the technical specification.
+// Participation is lower where young children keep a parent at home and
+// near zero for retirees.
 var cohortDefs = []cohortDef{
-	{"singles", 1.0, 1.0, 0.0},
-	{"couples", 2.0, 2.0, 0.0},
-	{"families_young", 3.5, 2.0, 1.5},
-	{"families_teen", 4.0, 2.0, 2.0},
-	{"empty_nest", 2.0, 2.0, 0.0},
-	{"retirees", 1.5, 1.5, 0.0},
+	{"singles", 1.0, 1.0, 0.0, 0.85},
+	{"couples", 2.0, 2.0, 0.0, 0.85},
+	{"families_young", 3.5, 2.0, 1.5, 0.75},
+	{"families_teen", 4.0, 2.0, 2.0, 0.80},
+	{"empty_nest", 2.0, 2.0, 0.0, 0.70},
+	{"retirees", 1.5, 1.5, 0.0, 0.05},
 }
 
 // cohortRatio extracts the ratio for a cohort from the Demographics struct.
@@ -73,6 +77,7 @@ func resolveDemographics(s *spec.CitySpec) ([]CohortBreakdown, float64) {
 		pop := int(math.Round(float64(hh) * cd.householdSize))
 		adults := int(math.Round(float64(hh) * cd.adultsPerHH))
 		children := int(math.Round(float64(hh) * cd.childrenPerHH))
+		workers := int(math.Round(float64(adults) * cd.participation))
 
 		cohorts = append(cohorts, CohortBreakdown{
 			Name:          cd.name,
@@ -82,6 +87,7 @@ func resolveDemographics(s *spec.CitySpec) ([]CohortBreakdown, float64) {
 			Population:    pop,
 			Adults:        adults,
 			Children:      children,
+			Workers:       workers,
 		})
 	}
 
@@ -108,11 +114,13 @@ func computeDependencyRatio(cohorts []CohortBreakdown) float64 {
 	return float64(dependents) / float64(workingAge)
 }
 
-// sumCohortTotals returns total adults, children, and estimated students.
-func sumCohortTotals(cohorts []CohortBreakdown) (adults, children, students int) {
+// sumCohortTotals returns total adults, children, estimated students and
+// the resident labor force.
+func sumCohortTotals(cohorts []CohortBreakdown) (adults, children, students, workers int) {
 	for _, c := range cohorts {
 		adults += c.Adults
 		children += c.Children
+		workers += c.Workers
 	}
 	// Students = all children (elementary + secondary age)
 	students = children
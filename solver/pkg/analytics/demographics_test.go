@@ -90,11 +90,16 @@ func TestDependencyRatio(t *testing.T) {
 func TestSumCohortTotals(t *testing.T) {
 	s := defaultDemographics()
 	cohorts, _ := resolveDemographics(s)
-	adults, children, students := sumCohortTotals(cohorts)
+	adults, children, students, workers := sumCohortTotals(cohorts)
 
 	if adults <= 0 {
 		t.Error("expected positive adult count")
 	}
+	// Retirees barely work and parents of young children less, so the
+	// labor force is most but not all adults.
+	if workers <= adults/2 || workers >= adults {
+		t.Errorf("labor force = %d of %d adults, want most of them", workers, adults)
+	}
 	if children <= 0 {
 		t.Error("expected positive child count from family cohorts")
 	}
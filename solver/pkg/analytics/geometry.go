@@ -61,17 +61,20 @@ func characterAvgHouseholdSize(character string) float64 {
 
 // resolveRings computes per-ring area, population, pod, and density data.
 // Supports an arbitrary number of rings. Population is distributed proportionally
-// to each ring's residential capacity weight (area × max_stories × residential_fraction).
+// to each ring's residential capacity weight (area × mean envelope stories ×
+// residential_fraction).
 // Inner rings with tall buildings get more people per pod; outer rings with
 // family housing get fewer.
 func resolveRings(s *spec.CitySpec, totalPop int) []RingData {
 	outerRadius := s.CityZones.OuterRadius()
 	totalCityAreaM2 := math.Pi * outerRadius * outerRadius
 	podAreaM2 := math.Pi * s.Pods.WalkRadius * s.Pods.WalkRadius
+	env := s.HeightEnvelope()
 
 	// First pass: compute pod counts (geometry-based) and capacity weights.
 	type ringInfo struct {
 		ring     spec.RingDef
+		stories  float64 // mean height envelope stories
 		areaM2   float64
 		areaHa   float64
 		podCount int
@@ -93,10 +96,12 @@ func resolveRings(s *spec.CitySpec, totalPop int) []RingData {
 		}
 		resFrac := characterResidentialFraction(ring.Character)
 		avgHH := characterAvgHouseholdSize(ring.Character)
-		weight := areaHa * float64(ring.MaxStories) * resFrac
+		stories := env.MeanStories(ring.RadiusFrom, ring.RadiusTo)
+		weight := areaHa * stories * resFrac
 
 		infos[i] = ringInfo{
 			ring:     ring,
+			stories:  stories,
 			areaM2:   areaM2,
 			areaHa:   areaHa,
 			podCount: podCount,
@@ -135,7 +140,7 @@ func resolveRings(s *spec.CitySpec, totalPop int) []RingData {
 			requiredDensity = float64(ringHH) / residentialHa
 		}
 
-		achievableDensity := float64(info.ring.MaxStories) * groundCoverage * m2PerHa / avgUnitSizeM2
+		achievableDensity := info.stories * groundCoverage * m2PerHa / avgUnitSizeM2
 
 		rings = append(rings, RingData{
 			Name:              info.ring.Name,
@@ -147,7 +152,8 @@ func resolveRings(s *spec.CitySpec, totalPop int) []RingData {
 			Households:        ringHH,
 			PodCount:          info.podCount,
 			PodPopulation:     podPop,
-			MaxStories:        info.ring.MaxStories,
+			MaxStories:        env.PeakStories(info.ring.RadiusFrom, info.ring.RadiusTo),
+			MeanStories:       info.stories,
 			AvgHouseholdSize:  info.avgHH,
 			RequiredDensity:   requiredDensity,
 			AchievableDensity: achievableDensity,
@@ -12,6 +12,7 @@ func defaultSpec() *spec.CitySpec {
 		City: spec.CityDef{
 			Population:      50000,
 			ExcavationDepth: 8,
+			HeightProfile:   spec.ProfileStep,
 			MaxHeightCenter: 20,
 			MaxHeightEdge:   4,
 		},
@@ -187,3 +188,27 @@ func TestResolveRingsCapacityWeightedPopulation(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveRingsHeightProfile(t *testing.T) {
+	s := defaultSpec()
+	step := resolveRings(s, 50000)
+	if step[1].MaxStories != 10 || step[1].MeanStories != 10 {
+		t.Errorf("step middle ring = %d peak, %.1f mean stories; want 10 and 10", step[1].MaxStories, step[1].MeanStories)
+	}
+
+	// A linear bowl from 20 stories at the center to 4 at 900 m.
+	s.City.HeightProfile = spec.ProfileBowl
+	bowl := resolveRings(s, 50000)
+	if bowl[0].MaxStories != 20 || bowl[2].MaxStories != 9 {
+		t.Errorf("bowl peaks = %d and %d, want 20 at the center and 9 at the edge ring", bowl[0].MaxStories, bowl[2].MaxStories)
+	}
+	for i, ring := range bowl {
+		if ring.MeanStories >= float64(ring.MaxStories) {
+			t.Errorf("%s ring mean %.1f not below its peak %d", ring.Name, ring.MeanStories, ring.MaxStories)
+		}
+		// Achievable density follows the mean, not the ring's max_stories.
+		if i == 0 && ring.AchievableDensity >= step[0].AchievableDensity {
+			t.Errorf("bowl center achievable density %.0f not below the step's %.0f", ring.AchievableDensity, step[0].AchievableDensity)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package analytics
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // serviceThreshold defines the population threshold per unit for a service type.
 type serviceThreshold struct {
@@ -23,11 +26,19 @@ var ServiceThresholds = map[string]serviceThreshold{
 	"daycare":           {5000, "persons"},
 }
 
-// resolveServices computes the required count for each service type.
+// resolveServices computes the required count for each service type,
+// sorted by service name.
 func resolveServices(totalPop int, totalStudents int) []ServiceCount {
 	services := make([]ServiceCount, 0, len(ServiceThresholds))
 
-	for name, st := range ServiceThresholds {
+	names := make([]string, 0, len(ServiceThresholds))
+	for name := range ServiceThresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		st := ServiceThresholds[name]
 		relevantPop := totalPop
 		if st.metric == "students" {
 			relevantPop = totalStudents
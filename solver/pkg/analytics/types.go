@@ -9,6 +9,7 @@ type CohortBreakdown struct {
 	Population    int     `json:"population"`
 	Adults        int     `json:"adults"`
 	Children      int     `json:"children"`
+	Workers       int     `json:"workers"` // adults in the labor force
 }
 
 // RingData holds computed data for one concentric ring.
@@ -22,7 +23,8 @@ type RingData struct {
 	Households        int     `json:"households"`
 	PodCount          int     `json:"pod_count"`
 	PodPopulation     int     `json:"pod_population"`
-	MaxStories        int     `json:"max_stories"`
+	MaxStories        int     `json:"max_stories"`  // peak of the height envelope over the ring
+	MeanStories       float64 `json:"mean_stories"` // area-weighted mean of the envelope
 	AvgHouseholdSize  float64 `json:"avg_household_size"`
 	RequiredDensity   float64 `json:"required_density_du_ha"`
 	AchievableDensity float64 `json:"achievable_density_du_ha"`
@@ -10,7 +10,7 @@ import (
 
 // validateAnalytical runs Phase 1 analytical validation checks.
 func validateAnalytical(s *spec.CitySpec, p *ResolvedParameters, report *validation.Report) {
-	validateDensityFeasibility(p, report)
+	validateDensityFeasibility(s, p, report)
 	validatePodServices(s, p, report)
 	validateSiteArea(s, p, report)
 	validateEnergyBalance(s, p, report)
@@ -18,11 +18,12 @@ func validateAnalytical(s *spec.CitySpec, p *ResolvedParameters, report *validat
 	validateDependencyRatio(p, report)
 }
 
-func validateDensityFeasibility(p *ResolvedParameters, report *validation.Report) {
+func validateDensityFeasibility(s *spec.CitySpec, p *ResolvedParameters, report *validation.Report) {
+	profile := s.HeightEnvelope().Profile
 	for i, ring := range p.Rings {
 		if ring.RequiredDensity > ring.AchievableDensity {
 			neededStories := int(math.Ceil(ring.RequiredDensity * avgUnitSizeM2 / (groundCoverage * m2PerHa)))
-			report.AddError(validation.Result{
+			r := validation.Result{
 				Level:       validation.LevelAnalytical,
 				Message:     fmt.Sprintf("%s ring: required density %.0f du/ha exceeds achievable %.0f du/ha at %d stories", ring.Name, ring.RequiredDensity, ring.AchievableDensity, ring.MaxStories),
 				SpecPath:    fmt.Sprintf("city_zones.rings[%d].max_stories", i),
@@ -33,7 +34,15 @@ func validateDensityFeasibility(p *ResolvedParameters, report *validation.Report
 					fmt.Sprintf("Increase %s max_stories to at least %d", ring.Name, neededStories),
 					"Reduce population or increase zone radius",
 				},
-			})
+			}
+			if profile != spec.ProfileStep {
+				// The ring's height comes from the profile, not max_stories.
+				r.Message = fmt.Sprintf("%s ring: required density %.0f du/ha exceeds achievable %.0f du/ha at a mean of %.1f stories", ring.Name, ring.RequiredDensity, ring.AchievableDensity, ring.MeanStories)
+				r.SpecPath = "city.height_profile"
+				r.ActualValue = ring.MeanStories
+				r.Suggestions[0] = fmt.Sprintf("Raise the %s height profile over the %s ring to a mean of at least %d stories", profile, ring.Name, neededStories)
+			}
+			report.AddError(r)
 		}
 	}
 }
@@ -0,0 +1,273 @@
+// Package artifact writes the outputs of a solve to a directory as separate
+// files — parameters, cost, validation, scene graph, 2D scene, employment,
+// education and, when the spec requests it, solar access — with a manifest listing each file's size
+// and SHA-256, so CI can publish a project's design artifacts per commit and
+// consumers can verify them.
+package artifact
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+)
+
+// ManifestFile is the manifest's file name within an output directory.
+const ManifestFile = "manifest.json"
+
+// Format is the encoding of an artifact file.
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatBinary Format = "binary" // scene graph only; see scene.EncodeBinary
+)
+
+// ParseFormat parses a scene graph format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJSON, FormatBinary:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want json or binary)", s)
+}
+
+// Options configures Write.
+type Options struct {
+	// Project and SpecHash identify the solve in the manifest.
+	Project  string
+	SpecHash string
+	// SceneFormats are the encodings the scene graph is written in. Empty
+	// means JSON only.
+	SceneFormats []Format
+	// Compact writes JSON without indentation.
+	Compact bool
+}
+
+// Manifest is the contents of manifest.json.
+type Manifest struct {
+	Project       string            `json:"project"`
+	SpecHash      string            `json:"spec_hash,omitempty"`
+	SolverVersion string            `json:"solver_version"`
+	GeneratedAt   string            `json:"generated_at"`
+	Validation    ValidationSummary `json:"validation"`
+	Files         []File            `json:"files"`
+}
+
+// ValidationSummary counts the solve's validation findings.
+type ValidationSummary struct {
+	Valid    bool   `json:"valid"`
+	Summary  string `json:"summary"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+	Info     int    `json:"info"`
+}
+
+// File describes one artifact file.
+type File struct {
+	Name      string `json:"name"` // relative to the output directory
+	Artifact  string `json:"artifact"`
+	Format    Format `json:"format"`
+	MediaType string `json:"media_type"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// output is an artifact file Write can produce.
+type output struct {
+	name      string
+	artifact  string
+	format    Format
+	mediaType string
+}
+
+var outputs = []output{
+	{"parameters.json", "parameters", FormatJSON, "application/json"},
+	{"cost.json", "cost", FormatJSON, "application/json"},
+	{"validation.json", "validation", FormatJSON, "application/json"},
+	{"scene.json", "scene", FormatJSON, "application/json"},
+	{"scene.bin", "scene", FormatBinary, scene.BinaryContentType},
+	{"scene2d.json", "scene2d", FormatJSON, "application/json"},
+	{"solar.json", "solar", FormatJSON, "application/json"},
+	{"employment.json", "employment", FormatJSON, "application/json"},
+	{"education.json", "education", FormatJSON, "application/json"},
+}
+
+// Write writes r's artifacts and their manifest to dir, creating it if
+// needed. r must come from a run through the scene and 2D scene stages.
+// The manifest is removed first and written last, so a directory with a
+// manifest always holds the files it lists; artifact files a previous
+// build wrote in formats not requested now are removed.
+func Write(dir string, r *pipeline.Result, opts Options) (*Manifest, error) {
+	if r.Params == nil || r.Cost == nil || r.Report == nil || r.Graph == nil || r.Scene2D == nil {
+		return nil, errors.New("solve result is incomplete: run the pipeline through the scene and scene2d stages")
+	}
+	formats := map[Format]bool{}
+	for _, f := range opts.SceneFormats {
+		formats[f] = true
+	}
+	if len(formats) == 0 {
+		formats[FormatJSON] = true
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := removeIfExists(filepath.Join(dir, ManifestFile)); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Project:       opts.Project,
+		SpecHash:      opts.SpecHash,
+		SolverVersion: pipeline.Version,
+		GeneratedAt:   r.Graph.Metadata.GeneratedAt,
+		Validation: ValidationSummary{
+			Valid:    r.Report.Valid,
+			Summary:  r.Report.Summary,
+			Errors:   len(r.Report.Errors),
+			Warnings: len(r.Report.Warnings),
+			Info:     len(r.Report.Info),
+		},
+		Files: []File{},
+	}
+	values := map[string]any{
+		"parameters": r.Params,
+		"cost":       r.Cost,
+		"validation": r.Report,
+		"scene":      r.Graph,
+		"scene2d":    r.Scene2D,
+		"solar":      r.Solar,
+		"employment": r.Employment,
+		"education":  r.Education,
+	}
+	// Analysis artifacts are written only when their stage ran; the solar
+	// analysis runs only when the spec asks for it.
+	present := map[string]bool{
+		"solar":      r.Solar != nil,
+		"employment": r.Employment != nil,
+		"education":  r.Education != nil,
+	}
+	for _, out := range outputs {
+		path := filepath.Join(dir, out.name)
+		ran, analysis := present[out.artifact]
+		if (out.artifact == "scene" && !formats[out.format]) || (analysis && !ran) {
+			if err := removeIfExists(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v := values[out.artifact]
+		f, err := writeHashed(path, func(w io.Writer) error {
+			if out.format == FormatBinary {
+				return scene.EncodeBinary(w, r.Graph)
+			}
+			enc := json.NewEncoder(w)
+			if !opts.Compact {
+				enc.SetIndent("", "  ")
+			}
+			return enc.Encode(v)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("writing %s: %w", out.name, err)
+		}
+		f.Name, f.Artifact, f.Format, f.MediaType = out.name, out.artifact, out.format, out.mediaType
+		m.Files = append(m.Files, f)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("writing manifest: %w", err)
+	}
+	return m, nil
+}
+
+// writeHashed writes a file, returning its size and SHA-256.
+func writeHashed(path string, write func(io.Writer) error) (File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return File{}, err
+	}
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(f, h)}
+	bw := bufio.NewWriterSize(cw, 1<<20)
+	if err := write(bw); err != nil {
+		f.Close()
+		return File{}, err
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return File{}, err
+	}
+	if err := f.Close(); err != nil {
+		return File{}, err
+	}
+	return File{Size: cw.n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Verify checks the files listed in dir's manifest against their recorded
+// sizes and hashes, returning the manifest.
+func Verify(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	for _, f := range m.Files {
+		got, err := hashFile(filepath.Join(dir, f.Name))
+		if err != nil {
+			return nil, err
+		}
+		if got.Size != f.Size || got.SHA256 != f.SHA256 {
+			return nil, fmt.Errorf("%s does not match the manifest", f.Name)
+		}
+	}
+	return &m, nil
+}
+
+func hashFile(path string) (File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
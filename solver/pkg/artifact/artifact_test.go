@@ -0,0 +1,175 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/education"
+	"github.com/ChicagoDave/cityplanner/pkg/employment"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/scene2d"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+func testResult() *pipeline.Result {
+	g := scene.NewGraph()
+	g.Metadata.GeneratedAt = pipeline.DeterministicTimestamp
+	g.Entities = append(g.Entities, scene.Entity{ID: "bldg_1", Type: scene.EntityBuilding})
+	report := validation.NewReport()
+	report.AddWarning(validation.Result{Level: validation.LevelAnalytical, Message: "test warning"})
+	return &pipeline.Result{
+		Params:  &analytics.ResolvedParameters{},
+		Cost:    &cost.Report{},
+		Report:  report,
+		Graph:   g,
+		Scene2D: &scene2d.Scene2D{},
+	}
+}
+
+func fileNames(m *Manifest) []string {
+	var names []string
+	for _, f := range m.Files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestWriteAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Write(dir, testResult(), Options{Project: "test", SceneFormats: []Format{FormatJSON, FormatBinary}})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := []string{"parameters.json", "cost.json", "validation.json", "scene.json", "scene.bin", "scene2d.json"}
+	got := fileNames(m)
+	if len(got) != len(want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("files = %v, want %v", got, want)
+		}
+	}
+	if m.Validation.Warnings != 1 || !m.Validation.Valid {
+		t.Errorf("validation = %+v, want valid with 1 warning", m.Validation)
+	}
+	if m.GeneratedAt != pipeline.DeterministicTimestamp {
+		t.Errorf("generated_at = %q, want the scene's timestamp", m.GeneratedAt)
+	}
+
+	g, err := scene.ReadFile(filepath.Join(dir, "scene.bin"))
+	if err != nil {
+		t.Fatalf("reading binary scene: %v", err)
+	}
+	if len(g.Entities) != 1 {
+		t.Errorf("binary scene has %d entities, want 1", len(g.Entities))
+	}
+
+	if _, err := Verify(dir); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cost.json"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(dir); err == nil {
+		t.Error("Verify accepted a modified cost.json")
+	}
+}
+
+func TestWriteRemovesStaleFormats(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Write(dir, testResult(), Options{SceneFormats: []Format{FormatBinary}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	m, err := Write(dir, testResult(), Options{Compact: true})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scene.bin")); err == nil {
+		t.Error("scene.bin from the previous build was kept")
+	}
+	for _, name := range fileNames(m) {
+		if name == "scene.bin" {
+			t.Error("manifest lists scene.bin")
+		}
+	}
+}
+
+func TestWriteSolar(t *testing.T) {
+	dir := t.TempDir()
+	r := testResult()
+	r.Solar = &layout.SolarAnalysis{Latitude: 35}
+	m, err := Write(dir, r, Options{})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if names := fileNames(m); names[len(names)-1] != "solar.json" {
+		t.Errorf("files = %v, want solar.json last", names)
+	}
+
+	m, err = Write(dir, testResult(), Options{})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "solar.json")); err == nil {
+		t.Error("solar.json from the previous build was kept")
+	}
+	for _, name := range fileNames(m) {
+		if name == "solar.json" {
+			t.Error("manifest lists solar.json without a solar analysis")
+		}
+	}
+}
+
+func TestWriteIncompleteResult(t *testing.T) {
+	r := testResult()
+	r.Scene2D = nil
+	if _, err := Write(t.TempDir(), r, Options{}); err == nil {
+		t.Error("Write accepted a result without a 2D scene")
+	}
+}
+
+func TestWriteEmployment(t *testing.T) {
+	dir := t.TempDir()
+	r := testResult()
+	r.Employment = &employment.Employment{City: employment.Balance{Name: "city", Jobs: 10}}
+	m, err := Write(dir, r, Options{})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if names := fileNames(m); names[len(names)-1] != "employment.json" {
+		t.Errorf("files = %v, want employment.json last", names)
+	}
+
+	if _, err := Write(dir, testResult(), Options{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "employment.json")); err == nil {
+		t.Error("employment.json from the previous build was kept")
+	}
+}
+
+func TestWriteEducation(t *testing.T) {
+	dir := t.TempDir()
+	r := testResult()
+	r.Education = &education.Plan{Secondary: []education.Catchment{{School: "secondary_01", Seats: 800}}}
+	m, err := Write(dir, r, Options{})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if names := fileNames(m); names[len(names)-1] != "education.json" {
+		t.Errorf("files = %v, want education.json last", names)
+	}
+
+	if _, err := Write(dir, testResult(), Options{}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "education.json")); err == nil {
+		t.Error("education.json from the previous build was kept")
+	}
+}
@@ -0,0 +1,276 @@
+// Package compare sets two solved city configurations side by side: the
+// resolved parameters, cost breakdown, validation counts, per-ring
+// statistics and per-pod building totals, each as a list of metric deltas.
+package compare
+
+import (
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// Delta compares one metric between configurations A and B.
+type Delta struct {
+	Metric string   `json:"metric"`
+	A      float64  `json:"a"`
+	B      float64  `json:"b"`
+	Change float64  `json:"change"`
+	Pct    *float64 `json:"pct,omitempty"` // change relative to A; absent when A is 0
+}
+
+// Changed reports whether the metric differs between A and B beyond
+// floating-point noise.
+func (d Delta) Changed() bool {
+	return math.Abs(d.B-d.A) > 1e-9*math.Max(math.Abs(d.A), math.Abs(d.B))
+}
+
+// Group is a named set of deltas, such as one ring or one pod.
+type Group struct {
+	Name   string  `json:"name"`
+	Deltas []Delta `json:"deltas"`
+}
+
+// Comparison is the side-by-side result for configurations A and B.
+type Comparison struct {
+	A string `json:"a"`
+	B string `json:"b"`
+
+	// SpecChanges lists the dotted spec paths at which B differs from A.
+	SpecChanges []string `json:"spec_changes"`
+
+	Parameters []Delta `json:"parameters"`
+	Cost       []Delta `json:"cost"`
+	Validation []Delta `json:"validation"`
+	Rings      []Group `json:"rings"`
+	Pods       []Group `json:"pods"`
+}
+
+// Options returns the pipeline options for solving a configuration to be
+// compared. Trees and scene assembly do not contribute to any compared
+// metric and are skipped. The run is lenient so a configuration with
+// validation errors can still be compared; its errors are counted.
+func Options() pipeline.Options {
+	return pipeline.Options{
+		Lenient: true,
+		Skip:    []pipeline.Stage{pipeline.StageTrees, pipeline.StageScene, pipeline.StageScene2D},
+	}
+}
+
+// Solve runs the pipeline on s with Options.
+func Solve(s *spec.CitySpec) (*pipeline.Result, error) {
+	return pipeline.Run(s, Options())
+}
+
+// Compare compares two solved configurations named a and b.
+func Compare(a string, ra *pipeline.Result, b string, rb *pipeline.Result) *Comparison {
+	c := &Comparison{A: a, B: b}
+	if ra.Spec != nil && rb.Spec != nil {
+		c.SpecChanges = spec.Diff(ra.Spec, rb.Spec)
+	}
+	c.Parameters = deltas(flatten(ra.Params), flatten(rb.Params))
+	c.Cost = deltas(flatten(ra.Cost), flatten(rb.Cost))
+	c.Validation = deltas(reportCounts(ra), reportCounts(rb))
+	c.Rings = groups(ringStats(ra), ringStats(rb))
+	c.Pods = groups(podTotals(ra), podTotals(rb))
+	return c
+}
+
+// Changed returns a copy of c holding only the deltas that differ, and only
+// the groups with at least one such delta.
+func (c *Comparison) Changed() *Comparison {
+	out := *c
+	out.Parameters = changed(c.Parameters)
+	out.Cost = changed(c.Cost)
+	out.Validation = changed(c.Validation)
+	out.Rings = changedGroups(c.Rings)
+	out.Pods = changedGroups(c.Pods)
+	return &out
+}
+
+func changed(ds []Delta) []Delta {
+	out := []Delta{}
+	for _, d := range ds {
+		if d.Changed() {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func changedGroups(gs []Group) []Group {
+	out := []Group{}
+	for _, g := range gs {
+		if ds := changed(g.Deltas); len(ds) > 0 {
+			out = append(out, Group{Name: g.Name, Deltas: ds})
+		}
+	}
+	return out
+}
+
+// metrics maps metric names to values, remembering insertion order.
+type metrics struct {
+	names  []string
+	values map[string]float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{values: map[string]float64{}}
+}
+
+func (m *metrics) set(name string, v float64) {
+	if _, ok := m.values[name]; !ok {
+		m.names = append(m.names, name)
+	}
+	m.values[name] = v
+}
+
+// deltas pairs the metrics of a and b. Metrics appear in a's order, then
+// any only b has; a metric missing on one side counts as zero.
+func deltas(a, b *metrics) []Delta {
+	names := append([]string(nil), a.names...)
+	for _, n := range b.names {
+		if _, ok := a.values[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	out := make([]Delta, 0, len(names))
+	for _, n := range names {
+		d := Delta{Metric: n, A: a.values[n], B: b.values[n]}
+		d.Change = d.B - d.A
+		if d.A != 0 {
+			pct := d.Change / math.Abs(d.A) * 100
+			d.Pct = &pct
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// named is a metric set for one ring or pod.
+type named struct {
+	name string
+	m    *metrics
+}
+
+// groups pairs named metric sets. Names keep a's order, then any only b
+// has; a set missing on one side compares against zeros.
+func groups(a, b []named) []Group {
+	bByName := map[string]*metrics{}
+	for _, n := range b {
+		bByName[n.name] = n.m
+	}
+	out := make([]Group, 0, len(a))
+	seen := map[string]bool{}
+	for _, n := range a {
+		mb := bByName[n.name]
+		if mb == nil {
+			mb = newMetrics()
+		}
+		out = append(out, Group{Name: n.name, Deltas: deltas(n.m, mb)})
+		seen[n.name] = true
+	}
+	for _, n := range b {
+		if !seen[n.name] {
+			out = append(out, Group{Name: n.name, Deltas: deltas(newMetrics(), n.m)})
+		}
+	}
+	return out
+}
+
+// flatten collects the numeric fields of v, recursing into structs and
+// pointers, under dotted JSON field names such as "areas.residential_ha".
+// Slices are skipped; lists that deserve comparison are handled separately.
+func flatten(v any) *metrics {
+	m := newMetrics()
+	flattenValue(reflect.ValueOf(v), "", m)
+	return m
+}
+
+func flattenValue(v reflect.Value, prefix string, m *metrics) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			flattenValue(v.Elem(), prefix, m)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flattenValue(v.Field(i), name, m)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		m.set(prefix, float64(v.Int()))
+	case reflect.Float32, reflect.Float64:
+		m.set(prefix, v.Float())
+	}
+}
+
+func reportCounts(r *pipeline.Result) *metrics {
+	m := newMetrics()
+	if r.Report == nil {
+		return m
+	}
+	m.set("errors", float64(len(r.Report.Errors)))
+	m.set("warnings", float64(len(r.Report.Warnings)))
+	m.set("info", float64(len(r.Report.Info)))
+	return m
+}
+
+func ringStats(r *pipeline.Result) []named {
+	if r.Params == nil {
+		return nil
+	}
+	out := make([]named, 0, len(r.Params.Rings))
+	for _, ring := range r.Params.Rings {
+		out = append(out, named{ring.Name, flatten(ring)})
+	}
+	return out
+}
+
+// podTotals sums each pod's buildings. Pods without buildings are listed
+// with zero totals so a pod that lost all its buildings still shows up.
+func podTotals(r *pipeline.Result) []named {
+	out := make([]named, 0, len(r.Pods))
+	byID := map[string]*metrics{}
+	for _, p := range r.Pods {
+		m := newMetrics()
+		m.set("target_population", float64(p.TargetPopulation))
+		for _, name := range podMetrics {
+			m.set(name, 0)
+		}
+		out = append(out, named{p.ID, m})
+		byID[p.ID] = m
+	}
+	for _, b := range r.Buildings {
+		if m := byID[b.PodID]; m != nil {
+			addBuilding(m, b)
+		}
+	}
+	return out
+}
+
+var podMetrics = []string{"buildings", "dwelling_units", "commercial_sqm", "floor_area_sqm"}
+
+func addBuilding(m *metrics, b layout.Building) {
+	m.values["buildings"]++
+	m.values["dwelling_units"] += float64(b.DwellingUnits)
+	m.values["commercial_sqm"] += b.CommercialSqM
+	m.values["floor_area_sqm"] += b.Footprint[0] * b.Footprint[1] * float64(b.Stories)
+}
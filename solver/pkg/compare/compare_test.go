@@ -0,0 +1,112 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/project"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+func find(ds []Delta, metric string) (Delta, bool) {
+	for _, d := range ds {
+		if d.Metric == metric {
+			return d, true
+		}
+	}
+	return Delta{}, false
+}
+
+func TestCompareSynthetic(t *testing.T) {
+	ra := &pipeline.Result{
+		Params: &analytics.ResolvedParameters{
+			TotalPopulation: 1000,
+			Areas:           analytics.AreaBreakdown{ResidentialHa: 10},
+			Rings:           []analytics.RingData{{Name: "center", Population: 1000}},
+		},
+		Report: validation.NewReport(),
+		Pods:   []layout.Pod{{ID: "pod_00", TargetPopulation: 1000}},
+		Buildings: []layout.Building{
+			{PodID: "pod_00", DwellingUnits: 10, Footprint: [2]float64{10, 20}, Stories: 2},
+		},
+	}
+	rb := &pipeline.Result{
+		Params: &analytics.ResolvedParameters{
+			TotalPopulation: 1500,
+			Areas:           analytics.AreaBreakdown{ResidentialHa: 10},
+			Rings:           []analytics.RingData{{Name: "center", Population: 1500}},
+		},
+		Report: validation.NewReport(),
+		Pods:   []layout.Pod{{ID: "pod_00", TargetPopulation: 1500}, {ID: "pod_01"}},
+	}
+	rb.Report.AddWarning(validation.Result{Message: "w"})
+
+	c := Compare("a", ra, "b", rb)
+	d, ok := find(c.Parameters, "total_population")
+	if !ok || d.A != 1000 || d.B != 1500 || d.Change != 500 || d.Pct == nil || *d.Pct != 50 {
+		t.Errorf("total_population delta = %+v", d)
+	}
+	if d, ok := find(c.Parameters, "areas.residential_ha"); !ok || d.Changed() {
+		t.Errorf("areas.residential_ha delta = %+v, %v", d, ok)
+	}
+	if d, _ := find(c.Validation, "warnings"); d.Change != 1 {
+		t.Errorf("warnings delta = %+v", d)
+	}
+	if len(c.Rings) != 1 || c.Rings[0].Name != "center" {
+		t.Fatalf("rings = %+v", c.Rings)
+	}
+	if len(c.Pods) != 2 || c.Pods[1].Name != "pod_01" {
+		t.Fatalf("pods = %+v", c.Pods)
+	}
+	if d, _ := find(c.Pods[0].Deltas, "floor_area_sqm"); d.A != 400 || d.B != 0 {
+		t.Errorf("pod_00 floor area delta = %+v", d)
+	}
+
+	ch := c.Changed()
+	if _, ok := find(ch.Parameters, "areas.residential_ha"); ok {
+		t.Error("Changed kept an unchanged metric")
+	}
+	if len(ch.Pods) != 1 || ch.Pods[0].Name != "pod_00" {
+		t.Errorf("Changed pods = %+v", ch.Pods)
+	}
+}
+
+func TestCompareScenario(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves two cities")
+	}
+	proj, err := project.Open("../../../examples/default-city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := proj.Scenarios()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) < 2 || names[0] != project.Baseline {
+		t.Fatalf("Scenarios = %v, want baseline and at least one overlay", names)
+	}
+
+	results := map[string]*pipeline.Result{}
+	for _, name := range names[:2] {
+		s, err := proj.LoadScenario(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[name], err = Solve(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := Compare(names[0], results[names[0]], names[1], results[names[1]])
+	if len(c.SpecChanges) == 0 {
+		t.Error("scenario does not change the spec")
+	}
+	if len(c.Changed().Parameters) == 0 {
+		t.Error("scenario does not change any resolved parameter")
+	}
+	if len(c.Pods) == 0 {
+		t.Error("no pod totals compared")
+	}
+}
@@ -0,0 +1,433 @@
+// Package education plans school capacity for the placed city: it
+// estimates the school-age children each pod houses, assigns pods to the
+// catchments of the elementary schools placed in them, sites secondary
+// schools where they balance students' travel, and reports catchments
+// short of seats.
+package education
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+const (
+	LevelElementary = "elementary" // kindergarten to grade 8
+	LevelSecondary  = "secondary"  // grades 9 to 12
+
+	elementaryService = "elementary_school"
+	secondaryService  = "secondary_school"
+
+	// Seats per school when the analytical service thresholds omit one.
+	defaultElementarySeats = 500
+	defaultSecondarySeats  = 800
+
+	// Shares of each family cohort's children at each level. Young
+	// families' other children are under school age; teen families'
+	// are all at school.
+	youngElementaryShare = 0.6
+	teenElementaryShare  = 0.3
+	teenSecondaryShare   = 0.7
+
+	// siteIterations bounds the refinement of secondary school sites.
+	siteIterations = 20
+	podsShown      = 5
+)
+
+// Catchment is a school and the pods whose students it serves.
+type Catchment struct {
+	School   string     `json:"school"` // building ID, or a planned school's ID
+	Level    string     `json:"level"`
+	PodID    string     `json:"pod_id"` // the pod the school stands in
+	Position [2]float64 `json:"position"`
+	Pods     []string   `json:"pods"`
+	Seats    int        `json:"seats"`
+	Students int        `json:"students"`
+	// Shortfall is the students beyond the school's seats.
+	Shortfall int `json:"shortfall"`
+	// MeanDistanceM is the students' mean straight-line distance from
+	// their pod's center to the school; MaxDistanceM the farthest pod's.
+	MeanDistanceM float64 `json:"mean_distance_m"`
+	MaxDistanceM  float64 `json:"max_distance_m"`
+}
+
+// PodStudents are the school-age children a pod houses and the schools
+// whose catchments it falls in, empty when there is none.
+type PodStudents struct {
+	PodID            string `json:"pod_id"`
+	Ring             string `json:"ring"`
+	Elementary       int    `json:"elementary"`
+	Secondary        int    `json:"secondary"`
+	ElementarySchool string `json:"elementary_school,omitempty"`
+	SecondarySchool  string `json:"secondary_school,omitempty"`
+}
+
+// Plan is the city's school catchments and the students of every pod.
+type Plan struct {
+	Elementary []Catchment   `json:"elementary"`
+	Secondary  []Catchment   `json:"secondary"`
+	Pods       []PodStudents `json:"pods"`
+}
+
+// site is a school location that catchments are drawn around.
+type site struct {
+	id, podID string
+	pos       [2]float64
+	seats     float64
+}
+
+// PlanSchools estimates each pod's school-age children from its units'
+// bedroom counts — three-bed units house young families and four-bed
+// units teen families, with the cohorts' children per household — and
+// draws school catchments. Elementary catchments form around the
+// elementary schools layout placed; secondary schools are planned, as
+// many as the secondary-age students fill, at the pods that minimize
+// students' travel and sized so none is over capacity. Pods join the
+// nearest school with seats left, and the report warns about every
+// elementary catchment whose students outnumber its seats.
+func PlanSchools(params *analytics.ResolvedParameters, pods []layout.Pod, buildings []layout.Building) (*Plan, *validation.Report) {
+	elemSeats := float64(seatsPerSchool(params, elementaryService, defaultElementarySeats))
+	secSeats := float64(seatsPerSchool(params, secondaryService, defaultSecondarySeats))
+
+	youngKids, teenKids := childrenPerHousehold(params)
+	index := make(map[string]int, len(pods))
+	for i, p := range pods {
+		index[p.ID] = i
+	}
+	elem := make([]float64, len(pods))
+	sec := make([]float64, len(pods))
+	var schools []site
+	for _, b := range buildings {
+		i, ok := index[b.PodID]
+		if !ok {
+			continue
+		}
+		if b.UnitMix != nil {
+			young := float64(b.UnitMix.ThreeBed) * youngKids
+			teen := float64(b.UnitMix.FourBed) * teenKids
+			elem[i] += young*youngElementaryShare + teen*teenElementaryShare
+			sec[i] += teen * teenSecondaryShare
+		}
+		if b.ServiceType == elementaryService {
+			schools = append(schools, site{id: b.ID, podID: b.PodID, pos: [2]float64{b.Position[0], b.Position[2]}, seats: elemSeats})
+		}
+	}
+
+	plan := &Plan{}
+	elemOf := assign(pods, elem, schools)
+	plan.Elementary = catchments(LevelElementary, pods, elem, schools, elemOf)
+
+	secondary := planSites(pods, sec, secSeats)
+	secOf := assign(pods, sec, secondary)
+	plan.Secondary = catchments(LevelSecondary, pods, sec, secondary, secOf)
+
+	for i, p := range pods {
+		ps := PodStudents{
+			PodID:      p.ID,
+			Ring:       p.Ring,
+			Elementary: int(math.Round(elem[i])),
+			Secondary:  int(math.Round(sec[i])),
+		}
+		if k := elemOf[i]; k >= 0 {
+			ps.ElementarySchool = schools[k].id
+		}
+		if k := secOf[i]; k >= 0 {
+			ps.SecondarySchool = secondary[k].id
+		}
+		plan.Pods = append(plan.Pods, ps)
+	}
+	return plan, plan.validate()
+}
+
+// seatsPerSchool returns the analytical students per school of a service.
+func seatsPerSchool(params *analytics.ResolvedParameters, service string, fallback int) int {
+	for _, sc := range params.Services {
+		if sc.Service == service && sc.Threshold > 0 {
+			return sc.Threshold
+		}
+	}
+	return fallback
+}
+
+// childrenPerHousehold returns the children per household of the young
+// and teen family cohorts.
+func childrenPerHousehold(params *analytics.ResolvedParameters) (young, teen float64) {
+	for _, c := range params.Cohorts {
+		if c.Households == 0 {
+			continue
+		}
+		perHH := float64(c.Children) / float64(c.Households)
+		switch c.Name {
+		case "families_young":
+			young = perHH
+		case "families_teen":
+			teen = perHH
+		}
+	}
+	return young, teen
+}
+
+// distance returns the distance from the pod's center to the site.
+func distance(p layout.Pod, s site) float64 {
+	return math.Hypot(p.Center[0]-s.pos[0], p.Center[1]-s.pos[1])
+}
+
+// assign returns the index of the site each pod's students attend, or -1
+// without sites. Pods are taken whole, nearest pairs first, by sites with
+// seats left for them; pods that fit nowhere join their nearest site,
+// overfilling it.
+func assign(pods []layout.Pod, students []float64, sites []site) []int {
+	of := make([]int, len(pods))
+	for i := range of {
+		of[i] = -1
+	}
+	if len(sites) == 0 {
+		return of
+	}
+	type pair struct {
+		pod, site int
+		d         float64
+	}
+	pairs := make([]pair, 0, len(pods)*len(sites))
+	for i, p := range pods {
+		for k, s := range sites {
+			pairs = append(pairs, pair{i, k, distance(p, s)})
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].d < pairs[b].d })
+
+	left := make([]float64, len(sites))
+	for k, s := range sites {
+		left[k] = s.seats
+	}
+	for _, pr := range pairs {
+		if of[pr.pod] >= 0 || students[pr.pod] > left[pr.site] {
+			continue
+		}
+		of[pr.pod] = pr.site
+		left[pr.site] -= students[pr.pod]
+	}
+	for _, pr := range pairs {
+		if of[pr.pod] < 0 {
+			of[pr.pod] = pr.site
+		}
+	}
+	return of
+}
+
+// planSites sites secondary schools at pod centers, as many as the students
+// fill and more if need be: pods are taken whole, so sites are added until
+// every pod fits in a school with seats left for it. A pod with more
+// students than a school holds gets a school sized to its catchment.
+func planSites(pods []layout.Pod, students []float64, seats float64) []site {
+	total := 0.0
+	for _, n := range students {
+		total += n
+	}
+	if total <= 0 || len(pods) == 0 {
+		return nil
+	}
+
+	var out []site
+	for n := min(len(pods), int(math.Ceil(total/seats-1e-9))); ; n++ {
+		out = placeSites(pods, students, seats, n)
+		load := loads(students, len(out), assign(pods, students, out))
+		full := false
+		for k := range out {
+			full = full || load[k] > out[k].seats
+		}
+		if !full {
+			break
+		}
+		if n >= len(pods) {
+			for k := range out {
+				out[k].seats = math.Max(out[k].seats, math.Ceil(load[k]))
+			}
+			break
+		}
+	}
+	for k := range out {
+		out[k].id = fmt.Sprintf("secondary_%02d", k+1)
+	}
+	return out
+}
+
+// placeSites sites n schools at pod centers. The first goes to the pod
+// nearest all students, each further one to the pod whose students are
+// farthest from a school, and the sites then move to the pod nearest all
+// students of their catchment until they settle.
+func placeSites(pods []layout.Pod, students []float64, seats float64, n int) []site {
+	at := func(i int) site { return site{pos: pods[i].Center, podID: pods[i].ID, seats: seats} }
+	// medoid returns the pod of members nearest all their students.
+	medoid := func(members []int) int {
+		best, bestCost := members[0], math.Inf(1)
+		for _, c := range members {
+			cost := 0.0
+			for _, m := range members {
+				cost += students[m] * distance(pods[m], at(c))
+			}
+			if cost < bestCost {
+				best, bestCost = c, cost
+			}
+		}
+		return best
+	}
+
+	all := make([]int, len(pods))
+	for i := range all {
+		all[i] = i
+	}
+	sitePods := []int{medoid(all)}
+	for len(sitePods) < n {
+		far, farCost := -1, -1.0
+		for i, p := range pods {
+			d := math.Inf(1)
+			for _, k := range sitePods {
+				d = math.Min(d, distance(p, at(k)))
+			}
+			if cost := students[i] * d; cost > farCost {
+				far, farCost = i, cost
+			}
+		}
+		sitePods = append(sitePods, far)
+	}
+
+	sites := func() []site {
+		out := make([]site, len(sitePods))
+		for k, i := range sitePods {
+			out[k] = at(i)
+		}
+		return out
+	}
+	for it := 0; it < siteIterations; it++ {
+		of := assign(pods, students, sites())
+		moved := false
+		for k := range sitePods {
+			var members []int
+			for i, s := range of {
+				if s == k {
+					members = append(members, i)
+				}
+			}
+			if len(members) == 0 {
+				continue
+			}
+			if m := medoid(members); m != sitePods[k] {
+				sitePods[k] = m
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+	return sites()
+}
+
+// loads returns the students assigned to each of n sites.
+func loads(students []float64, n int, of []int) []float64 {
+	load := make([]float64, n)
+	for i, k := range of {
+		if k >= 0 {
+			load[k] += students[i]
+		}
+	}
+	return load
+}
+
+// catchments summarizes each site's catchment.
+func catchments(level string, pods []layout.Pod, students []float64, sites []site, of []int) []Catchment {
+	out := make([]Catchment, len(sites))
+	sum := make([]float64, len(sites))
+	weighted := make([]float64, len(sites))
+	for k, s := range sites {
+		out[k] = Catchment{School: s.id, Level: level, PodID: s.podID, Position: s.pos, Seats: int(s.seats)}
+	}
+	for i, k := range of {
+		if k < 0 {
+			continue
+		}
+		c := &out[k]
+		d := distance(pods[i], sites[k])
+		c.Pods = append(c.Pods, pods[i].ID)
+		sum[k] += students[i]
+		weighted[k] += students[i] * d
+		c.MaxDistanceM = math.Max(c.MaxDistanceM, d)
+	}
+	for k := range out {
+		c := &out[k]
+		c.Students = int(math.Round(sum[k]))
+		c.Shortfall = max(0, c.Students-c.Seats)
+		if sum[k] > 0 {
+			c.MeanDistanceM = weighted[k] / sum[k]
+		}
+	}
+	return out
+}
+
+// validate summarizes the plan and warns about elementary catchments short
+// of seats and students without a school. Planned secondary schools are
+// sized to their catchments and are never short.
+func (p *Plan) validate() *validation.Report {
+	report := validation.NewReport()
+	var elem, sec int
+	var unserved []string
+	for _, ps := range p.Pods {
+		elem += ps.Elementary
+		sec += ps.Secondary
+		if ps.Elementary > 0 && ps.ElementarySchool == "" {
+			unserved = append(unserved, ps.PodID)
+		}
+	}
+	report.AddInfo(validation.Result{
+		Level: validation.LevelSpatial,
+		Message: fmt.Sprintf("%d elementary and %d secondary students: %d elementary schools placed, %d secondary schools planned (mean trip %.0f m)",
+			elem, sec, len(p.Elementary), len(p.Secondary), meanDistance(p.Secondary)),
+	})
+
+	if len(unserved) > 0 {
+		report.AddWarning(validation.Result{
+			Level:       validation.LevelSpatial,
+			Message:     fmt.Sprintf("no elementary schools are placed for %d elementary students", elem),
+			SpecPath:    "pods.ring_assignments",
+			EntityIDs:   unserved,
+			Suggestions: []string{"Add elementary_school to the required_services of the rings where families live"},
+		})
+	}
+	for _, c := range p.Elementary {
+		if c.Shortfall == 0 {
+			continue
+		}
+		shown := c.Pods
+		if len(shown) > podsShown {
+			shown = append(shown[:podsShown:podsShown], "...")
+		}
+		report.AddWarning(validation.Result{
+			Level: validation.LevelSpatial,
+			Message: fmt.Sprintf("%s: %s school %s is %d seats short: %d students from %s for %d seats",
+				c.PodID, c.Level, c.School, c.Shortfall, c.Students, strings.Join(shown, ", "), c.Seats),
+			ActualValue: c.Students,
+			Expected:    fmt.Sprintf("<= %d", c.Seats),
+			EntityIDs:   append([]string{c.School}, c.Pods...),
+		})
+	}
+	return report
+}
+
+// meanDistance returns the students' mean distance to their schools.
+func meanDistance(cs []Catchment) float64 {
+	students, weighted := 0.0, 0.0
+	for _, c := range cs {
+		students += float64(c.Students)
+		weighted += float64(c.Students) * c.MeanDistanceM
+	}
+	if students == 0 {
+		return 0
+	}
+	return weighted / students
+}
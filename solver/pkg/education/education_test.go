@@ -0,0 +1,137 @@
+package education
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+)
+
+// params gives young families 1.5 and teen families 2 children per
+// household, and schools 100 elementary or 200 secondary seats.
+var params = &analytics.ResolvedParameters{
+	Cohorts: []analytics.CohortBreakdown{
+		{Name: "families_young", Households: 100, Children: 150},
+		{Name: "families_teen", Households: 100, Children: 200},
+	},
+	Services: []analytics.ServiceCount{
+		{Service: "elementary_school", Threshold: 100},
+		{Service: "secondary_school", Threshold: 200},
+	},
+}
+
+func pod(id string, x, z float64) layout.Pod {
+	return layout.Pod{ID: id, Ring: "ring", Center: [2]float64{x, z}}
+}
+
+func homes(podID string, threeBed, fourBed int) layout.Building {
+	mix := layout.UnitMix{ThreeBed: threeBed, FourBed: fourBed}
+	return layout.Building{ID: podID + "_homes", PodID: podID, Type: "residential", DwellingUnits: mix.Total(), UnitMix: &mix}
+}
+
+func school(id, podID string, x, z float64) layout.Building {
+	return layout.Building{ID: id, PodID: podID, Type: "civic", ServiceType: "elementary_school", Position: [3]float64{x, 0, z}}
+}
+
+func TestPlanSchoolsStudents(t *testing.T) {
+	pods := []layout.Pod{pod("a", 0, 0)}
+	// 40 three-bed units: 60 children, 36 at elementary age; 25 four-bed
+	// units: 50 children, 15 elementary and 35 secondary.
+	plan, _ := PlanSchools(params, pods, []layout.Building{homes("a", 40, 25)})
+	ps := plan.Pods[0]
+	if ps.Elementary != 51 || ps.Secondary != 35 {
+		t.Errorf("pod students = %d elementary, %d secondary, want 51 and 35", ps.Elementary, ps.Secondary)
+	}
+	if len(plan.Secondary) != 1 || plan.Secondary[0].PodID != "a" || ps.SecondarySchool != plan.Secondary[0].School {
+		t.Errorf("want one secondary school in pod a, got %+v", plan.Secondary)
+	}
+}
+
+func TestPlanSchoolsElementaryCatchments(t *testing.T) {
+	pods := []layout.Pod{pod("west", -1000, 0), pod("mid", -600, 0), pod("east", 1000, 0), pod("edge", 1200, 0)}
+	// 90 elementary students in west, mid and edge. Edge fills the east
+	// school past room for mid, which fits nowhere and overfills its
+	// nearest school.
+	buildings := []layout.Building{
+		homes("west", 100, 0), homes("mid", 100, 0), homes("edge", 100, 0),
+		school("school_w", "west", -1000, 0), school("school_e", "east", 1000, 0),
+	}
+
+	plan, report := PlanSchools(params, pods, buildings)
+	if len(plan.Elementary) != 2 {
+		t.Fatalf("got %d elementary catchments, want 2", len(plan.Elementary))
+	}
+	w, e := plan.Elementary[0], plan.Elementary[1]
+	if len(w.Pods) != 2 || w.Pods[1] != "mid" || w.Students != 180 || w.Shortfall != 80 {
+		t.Errorf("west catchment = %+v, want pods west and mid, 80 seats short", w)
+	}
+	if w.MaxDistanceM != 400 || math.Abs(w.MeanDistanceM-200) > 1e-9 {
+		t.Errorf("west distances = mean %.0f, max %.0f; want 200 and 400", w.MeanDistanceM, w.MaxDistanceM)
+	}
+	if len(e.Pods) != 2 || e.Pods[1] != "edge" || e.Students != 90 || e.Shortfall != 0 {
+		t.Errorf("east catchment = %+v, want pods east and edge without shortfall", e)
+	}
+
+	var short bool
+	for _, warn := range report.Warnings {
+		short = short || (len(warn.EntityIDs) == 3 && warn.EntityIDs[0] == "school_w" && warn.ActualValue == 180)
+	}
+	if !short || len(report.Warnings) != 1 {
+		t.Errorf("want one shortfall warning for school_w, got %+v", report.Warnings)
+	}
+}
+
+func TestPlanSchoolsNoElementarySchools(t *testing.T) {
+	plan, report := PlanSchools(params, []layout.Pod{pod("a", 0, 0)}, []layout.Building{homes("a", 10, 0)})
+	if plan.Pods[0].ElementarySchool != "" || len(report.Warnings) != 1 || report.Warnings[0].SpecPath != "pods.ring_assignments" {
+		t.Errorf("want an unserved pod and one warning, got %+v, %+v", plan.Pods[0], report.Warnings)
+	}
+}
+
+func TestPlanSitesBalancesTravel(t *testing.T) {
+	// Two clusters of 150 secondary students each need two schools, one
+	// in each cluster, at the pod nearest the cluster's students.
+	pods := []layout.Pod{
+		pod("w1", -2000, 0), pod("w2", -1800, 0), pod("w3", -1600, 0),
+		pod("e1", 1600, 0), pod("e2", 1800, 0), pod("e3", 2000, 0),
+	}
+	students := []float64{50, 50, 50, 50, 50, 50}
+
+	sites := planSites(pods, students, 200)
+	if len(sites) != 2 {
+		t.Fatalf("got %d sites, want 2", len(sites))
+	}
+	got := map[string]bool{sites[0].podID: true, sites[1].podID: true}
+	if !got["w2"] || !got["e2"] {
+		t.Errorf("sites in %s and %s, want w2 and e2", sites[0].podID, sites[1].podID)
+	}
+	for k, s := range assign(pods, students, sites) {
+		if (k < 3) != (sites[s].podID == "w2") {
+			t.Errorf("pod %s goes to the school in %s", pods[k].ID, sites[s].podID)
+		}
+	}
+}
+
+func TestPlanSitesKeepsSchoolsWithinSeats(t *testing.T) {
+	// 360 students fill two 200-seat schools, but the pods are taken
+	// whole and no two fit in one school, so each needs its own.
+	pods := []layout.Pod{pod("a", -1000, 0), pod("b", 0, 0), pod("c", 1000, 0)}
+	students := []float64{120, 120, 120}
+	sites := planSites(pods, students, 200)
+	if len(sites) != 3 {
+		t.Fatalf("got %d sites, want 3", len(sites))
+	}
+	load := loads(students, len(sites), assign(pods, students, sites))
+	for k, s := range sites {
+		if load[k] > s.seats {
+			t.Errorf("%s in pod %s has %.0f students for %.0f seats", s.id, s.podID, load[k], s.seats)
+		}
+	}
+
+	// A pod larger than a school gets a school sized to it.
+	sites = planSites([]layout.Pod{pod("a", 0, 0)}, []float64{300}, 200)
+	if len(sites) != 1 || sites[0].seats != 300 {
+		t.Errorf("sites = %+v, want one school of 300 seats", sites)
+	}
+}
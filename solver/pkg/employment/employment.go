@@ -0,0 +1,325 @@
+// Package employment models where the city's residents work: the jobs
+// each pod's buildings hold, the labor force its housing holds, the
+// balance of the two per pod and ring, and the commutes between them.
+package employment
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+const (
+	// commuteDecayM is the distance over which the pull of a pod's jobs
+	// falls by a factor of e.
+	commuteDecayM = 1500.0
+	// dormitoryRatio is the jobs per resident worker below which a ring
+	// counts as dormitory-only.
+	dormitoryRatio = 0.25
+	// minCityRatio is the city-wide jobs per worker below which too many
+	// residents must work outside the city.
+	minCityRatio = 0.8
+	// balanceIterations bounds the balancing of commute rows and columns.
+	balanceIterations = 100
+	balanceTol        = 1e-6
+)
+
+// Balance is the jobs-housing balance of one pod, ring or the city.
+// Commuter counts are in workers.
+type Balance struct {
+	Name     string  `json:"name"`           // pod ID, ring name or "city"
+	Ring     string  `json:"ring,omitempty"` // pods only
+	Jobs     int     `json:"jobs"`
+	Workers  int     `json:"workers"`         // resident labor force
+	Ratio    float64 `json:"jobs_per_worker"` // zero without workers
+	Local    int     `json:"local"`           // residents working where they live
+	Outbound int     `json:"outbound"`        // residents working elsewhere in the city
+	Inbound  int     `json:"inbound"`         // jobs held by residents of elsewhere in the city
+	// ExternalOut are residents working outside the city, for want of
+	// jobs in it; ExternalIn are jobs held by workers from outside.
+	ExternalOut int `json:"external_out"`
+	ExternalIn  int `json:"external_in"`
+}
+
+// Matrix holds commuters between zones, by home zone then work zone.
+// Row sums are a zone's outbound commuters plus those working locally,
+// column sums its inbound commuters plus locals.
+type Matrix struct {
+	Zones []string    `json:"zones"`
+	Trips [][]float64 `json:"trips"`
+}
+
+// Outbound returns the commuters from zone i to every other zone.
+func (m Matrix) Outbound(i int) float64 {
+	sum := 0.0
+	for j, t := range m.Trips[i] {
+		if j != i {
+			sum += t
+		}
+	}
+	return sum
+}
+
+// Between returns the commuters from the zone named from to the zone named
+// to, and whether both are in the matrix. Transit and accessibility
+// analyses read commute demand between pods or rings through it.
+func (m Matrix) Between(from, to string) (float64, bool) {
+	i, j := -1, -1
+	for k, z := range m.Zones {
+		if z == from {
+			i = k
+		}
+		if z == to {
+			j = k
+		}
+	}
+	if i < 0 || j < 0 {
+		return 0, false
+	}
+	return m.Trips[i][j], true
+}
+
+// Inbound returns the commuters to zone j from every other zone.
+func (m Matrix) Inbound(j int) float64 {
+	sum := 0.0
+	for i, row := range m.Trips {
+		if i != j {
+			sum += row[j]
+		}
+	}
+	return sum
+}
+
+// Employment is the city's jobs, labor force and commutes.
+type Employment struct {
+	City  Balance   `json:"city"`
+	Rings []Balance `json:"rings"`
+	Pods  []Balance `json:"pods"`
+	// PodCommutes and RingCommutes are the commutes within the city
+	// between pods and between rings.
+	PodCommutes  Matrix `json:"pod_commutes"`
+	RingCommutes Matrix `json:"ring_commutes"`
+}
+
+// Model counts each pod's jobs from its buildings' floor area and its
+// labor force from its dwelling units, at the city's workers per
+// household, and distributes commutes between pods with a gravity model
+// whose pull decays with distance. Commutes fill the city's jobs up to its
+// labor force; any surplus of workers works outside the city and any
+// surplus of jobs is filled from outside. The report warns about
+// dormitory-only rings and a city short of jobs.
+func Model(s *spec.CitySpec, params *analytics.ResolvedParameters, pods []layout.Pod, buildings []layout.Building) (*Employment, *validation.Report) {
+	index := make(map[string]int, len(pods))
+	for i, p := range pods {
+		index[p.ID] = i
+	}
+	jobs := make([]float64, len(pods))
+	units := make([]float64, len(pods))
+	totalUnits := 0.0
+	for _, b := range buildings {
+		i, ok := index[b.PodID]
+		if !ok {
+			continue
+		}
+		jobs[i] += b.Jobs()
+		units[i] += float64(b.DwellingUnits)
+		totalUnits += float64(b.DwellingUnits)
+	}
+	workers := make([]float64, len(pods))
+	if totalUnits > 0 {
+		for i := range pods {
+			workers[i] = float64(params.LaborForce) * units[i] / totalUnits
+		}
+	}
+
+	trips := commutes(pods, workers, jobs)
+	e := &Employment{PodCommutes: Matrix{Trips: trips}}
+	for _, p := range pods {
+		e.PodCommutes.Zones = append(e.PodCommutes.Zones, p.ID)
+	}
+
+	// Rings in spec order, then aggregate pods into them.
+	ringIndex := make(map[string]int)
+	for _, r := range s.CityZones.Rings {
+		ringIndex[r.Name] = len(e.RingCommutes.Zones)
+		e.RingCommutes.Zones = append(e.RingCommutes.Zones, r.Name)
+	}
+	for _, p := range pods {
+		if _, ok := ringIndex[p.Ring]; !ok {
+			ringIndex[p.Ring] = len(e.RingCommutes.Zones)
+			e.RingCommutes.Zones = append(e.RingCommutes.Zones, p.Ring)
+		}
+	}
+	nr := len(e.RingCommutes.Zones)
+	e.RingCommutes.Trips = make([][]float64, nr)
+	for i := range e.RingCommutes.Trips {
+		e.RingCommutes.Trips[i] = make([]float64, nr)
+	}
+	ringJobs, ringWorkers := make([]float64, nr), make([]float64, nr)
+	for i, p := range pods {
+		ri := ringIndex[p.Ring]
+		ringJobs[ri] += jobs[i]
+		ringWorkers[ri] += workers[i]
+		for j, q := range pods {
+			e.RingCommutes.Trips[ri][ringIndex[q.Ring]] += trips[i][j]
+		}
+	}
+
+	for i, p := range pods {
+		b := balance(p.ID, i, jobs[i], workers[i], e.PodCommutes)
+		b.Ring = p.Ring
+		e.Pods = append(e.Pods, b)
+	}
+	cityJobs, cityWorkers := 0.0, 0.0
+	for i, name := range e.RingCommutes.Zones {
+		if ringJobs[i] == 0 && ringWorkers[i] == 0 {
+			continue
+		}
+		e.Rings = append(e.Rings, balance(name, i, ringJobs[i], ringWorkers[i], e.RingCommutes))
+		cityJobs += ringJobs[i]
+		cityWorkers += ringWorkers[i]
+	}
+	e.City = balance("city", -1, cityJobs, cityWorkers, Matrix{})
+	e.City.ExternalOut = int(math.Round(math.Max(0, cityWorkers-cityJobs)))
+	e.City.ExternalIn = int(math.Round(math.Max(0, cityJobs-cityWorkers)))
+
+	return e, e.validate()
+}
+
+// balance summarizes zone i of m, or a zone outside m if i is negative.
+func balance(name string, i int, jobs, workers float64, m Matrix) Balance {
+	b := Balance{
+		Name:    name,
+		Jobs:    int(math.Round(jobs)),
+		Workers: int(math.Round(workers)),
+	}
+	if workers > 0 {
+		b.Ratio = jobs / workers
+	}
+	if i < 0 {
+		return b
+	}
+	local := m.Trips[i][i]
+	out, in := m.Outbound(i), m.Inbound(i)
+	b.Local = int(math.Round(local))
+	b.Outbound = int(math.Round(out))
+	b.Inbound = int(math.Round(in))
+	b.ExternalOut = int(math.Round(math.Max(0, workers-local-out)))
+	b.ExternalIn = int(math.Round(math.Max(0, jobs-local-in)))
+	return b
+}
+
+// commutes returns the doubly constrained gravity model of commutes from
+// each pod's workers to each pod's jobs. Rows sum to the workers and
+// columns to the jobs, both scaled down to the smaller of the city's
+// totals.
+func commutes(pods []layout.Pod, workers, jobs []float64) [][]float64 {
+	n := len(pods)
+	trips := make([][]float64, n)
+	for i := range trips {
+		trips[i] = make([]float64, n)
+	}
+	totalW, totalJ := 0.0, 0.0
+	for i := range pods {
+		totalW += workers[i]
+		totalJ += jobs[i]
+	}
+	if totalW == 0 || totalJ == 0 {
+		return trips
+	}
+	filled := math.Min(totalW, totalJ)
+	rows, cols := make([]float64, n), make([]float64, n)
+	for i := range pods {
+		rows[i] = workers[i] * filled / totalW
+		cols[i] = jobs[i] * filled / totalJ
+	}
+
+	for i, p := range pods {
+		for j, q := range pods {
+			d := p.CenterPoint().Distance(q.CenterPoint())
+			if i == j {
+				// The mean distance between two points of a disk of
+				// the pod's area: 128/45π of its radius.
+				d = 128 / (45 * math.Pi) * math.Sqrt(p.AreaHa*10000/math.Pi)
+			}
+			trips[i][j] = rows[i] * cols[j] * math.Exp(-d/commuteDecayM)
+		}
+	}
+	for it := 0; it < balanceIterations; it++ {
+		worst := 0.0
+		for i := range trips {
+			sum := 0.0
+			for _, t := range trips[i] {
+				sum += t
+			}
+			if sum > 0 {
+				f := rows[i] / sum
+				for j := range trips[i] {
+					trips[i][j] *= f
+				}
+			}
+		}
+		for j := 0; j < n; j++ {
+			sum := 0.0
+			for i := range trips {
+				sum += trips[i][j]
+			}
+			if sum > 0 {
+				f := cols[j] / sum
+				worst = math.Max(worst, math.Abs(f-1))
+				for i := range trips {
+					trips[i][j] *= f
+				}
+			}
+		}
+		if worst < balanceTol {
+			break
+		}
+	}
+	return trips
+}
+
+// validate summarizes the city's balance and warns about dormitory-only
+// rings and a city short of jobs.
+func (e *Employment) validate() *validation.Report {
+	report := validation.NewReport()
+	c := e.City
+	report.AddInfo(validation.Result{
+		Level: validation.LevelSpatial,
+		Message: fmt.Sprintf("%d jobs for a resident labor force of %d (%.2f jobs per worker)",
+			c.Jobs, c.Workers, c.Ratio),
+	})
+	if c.Workers > 0 && c.Ratio < minCityRatio {
+		report.AddWarning(validation.Result{
+			Level:       validation.LevelSpatial,
+			Message:     fmt.Sprintf("the city has %.2f jobs per resident worker; %d residents must work outside it", c.Ratio, c.ExternalOut),
+			ActualValue: c.Ratio,
+			Expected:    fmt.Sprintf(">= %.2f", minCityRatio),
+			Suggestions: []string{"Add commercial or coworking floor space, or civic services"},
+		})
+	}
+	for _, r := range e.Rings {
+		if r.Workers == 0 || r.Ratio >= dormitoryRatio {
+			continue
+		}
+		var ids []string
+		for _, p := range e.Pods {
+			if p.Ring == r.Name {
+				ids = append(ids, p.Name)
+			}
+		}
+		report.AddWarning(validation.Result{
+			Level: validation.LevelSpatial,
+			Message: fmt.Sprintf("ring %s is dormitory-only: %d jobs for %d resident workers, %d of whom commute out",
+				r.Name, r.Jobs, r.Workers, r.Outbound+r.ExternalOut),
+			ActualValue: r.Ratio,
+			Expected:    fmt.Sprintf(">= %.2f jobs per worker", dormitoryRatio),
+			EntityIDs:   ids,
+		})
+	}
+	return report
+}
@@ -0,0 +1,126 @@
+package employment
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+func pod(id, ring string, x float64) layout.Pod {
+	return layout.Pod{ID: id, Ring: ring, Center: [2]float64{x, 0}, AreaHa: 10}
+}
+
+func housing(id, podID string, units int) layout.Building {
+	return layout.Building{ID: id, PodID: podID, DwellingUnits: units,
+		Floors: []layout.FloorUse{{Use: layout.UseResidential, Stories: 4, GrossAreaM2: 100 * float64(units), Units: units}}}
+}
+
+func offices(id, podID string, m2 float64) layout.Building {
+	// Coworking floors hold one job per 15 m² of their 80% usable area.
+	return layout.Building{ID: id, PodID: podID,
+		Floors: []layout.FloorUse{{Use: layout.UseCoworking, Stories: 4, GrossAreaM2: m2}}}
+}
+
+func TestModel(t *testing.T) {
+	s := &spec.CitySpec{CityZones: spec.CityZones{Rings: []spec.RingDef{
+		{Name: "center", RadiusFrom: 0, RadiusTo: 500},
+		{Name: "edge", RadiusFrom: 500, RadiusTo: 2000},
+	}}}
+	params := &analytics.ResolvedParameters{LaborForce: 1000}
+	pods := []layout.Pod{pod("core", "center", 0), pod("north", "edge", 1500), pod("south", "edge", -1500)}
+	buildings := []layout.Building{
+		housing("core_h", "core", 200),
+		offices("core_o", "core", 15000), // 800 jobs
+		housing("north_h", "north", 400),
+		housing("south_h", "south", 400),
+	}
+
+	e, report := Model(s, params, pods, buildings)
+
+	if e.City.Jobs != 800 || e.City.Workers != 1000 || e.City.ExternalOut != 200 || e.City.ExternalIn != 0 {
+		t.Errorf("city = %+v, want 800 jobs, 1000 workers and 200 working outside", e.City)
+	}
+	// Every job in the city is filled, and workers commute out in
+	// proportion to their numbers.
+	m := e.PodCommutes
+	for j, id := range m.Zones {
+		in := 0.0
+		for i := range m.Trips {
+			in += m.Trips[i][j]
+		}
+		want := 0.0
+		if id == "core" {
+			want = 800
+		}
+		if math.Abs(in-want) > 1e-3 {
+			t.Errorf("pod %s: %.3f commuters in, want %.0f", id, in, want)
+		}
+	}
+	for i, w := range []float64{200, 400, 400} {
+		out := 0.0
+		for _, trips := range m.Trips[i] {
+			out += trips
+		}
+		if math.Abs(out-w*0.8) > 1e-3 {
+			t.Errorf("pod %s: %.3f commuters out, want %.0f", m.Zones[i], out, w*0.8)
+		}
+	}
+
+	if len(e.Rings) != 2 {
+		t.Fatalf("got %d rings, want 2", len(e.Rings))
+	}
+	core, edge := e.Rings[0], e.Rings[1]
+	if core.Local != 160 || core.Inbound != 640 || core.ExternalOut != 40 {
+		t.Errorf("center ring = %+v, want 160 local, 640 inbound, 40 working outside", core)
+	}
+	if edge.Jobs != 0 || edge.Outbound != 640 || edge.ExternalOut != 160 {
+		t.Errorf("edge ring = %+v, want no jobs, 640 outbound, 160 working outside", edge)
+	}
+	if rm := e.RingCommutes; rm.Zones[1] != "edge" || math.Abs(rm.Trips[1][0]-640) > 1e-3 {
+		t.Errorf("ring commutes = %+v, want 640 from edge to center", rm)
+	}
+	if trips, ok := e.RingCommutes.Between("edge", "center"); !ok || math.Abs(trips-640) > 1e-3 {
+		t.Errorf("Between(edge, center) = %.3f, %v; want 640", trips, ok)
+	}
+	if trips, ok := e.PodCommutes.Between("north", "core"); !ok || math.Abs(trips-320) > 1e-3 {
+		t.Errorf("Between(north, core) = %.3f, %v; want 320", trips, ok)
+	}
+	if _, ok := e.PodCommutes.Between("north", "nowhere"); ok {
+		t.Error("Between found a pod that is not in the matrix")
+	}
+
+	// The edge ring has no jobs, and the city 0.8 jobs per worker.
+	var dormitory bool
+	for _, w := range report.Warnings {
+		if len(w.EntityIDs) == 2 && w.EntityIDs[0] == "north" && w.EntityIDs[1] == "south" {
+			dormitory = true
+		}
+	}
+	if !dormitory || len(report.Warnings) != 1 {
+		t.Errorf("want one dormitory warning for the edge pods, got %+v", report.Warnings)
+	}
+}
+
+func TestModelPrefersNearJobs(t *testing.T) {
+	s := &spec.CitySpec{}
+	params := &analytics.ResolvedParameters{LaborForce: 200}
+	pods := []layout.Pod{pod("home", "a", 0), pod("near", "a", 500), pod("far", "a", 5000)}
+	buildings := []layout.Building{
+		housing("h", "home", 100),
+		offices("near_o", "near", 1875), // 100 jobs each
+		offices("far_o", "far", 1875),
+		housing("far_h", "far", 100),
+	}
+
+	e, _ := Model(s, params, pods, buildings)
+	trips := e.PodCommutes.Trips
+	if trips[0][1] <= trips[0][2] {
+		t.Errorf("home sends %.1f to the near pod and %.1f to the far one, want more to the near", trips[0][1], trips[0][2])
+	}
+	if trips[2][2] <= trips[2][1] {
+		t.Errorf("far pod keeps %.1f and sends %.1f to the near pod, want most kept", trips[2][2], trips[2][1])
+	}
+}
@@ -1,6 +1,9 @@
 package geo
 
-import "math"
+import (
+	"math"
+	"sort"
+)
 
 // ApproximateCircle returns a polygon approximating a circle with the given
 // center, radius, and number of segments. Vertices are in CCW order.
@@ -22,6 +25,67 @@ func ApproximateCircle(center Point2D, radius float64, segments int) Polygon {
 // circleSegments is the default resolution for circle approximation.
 const circleSegments = 64
 
+// AnnularSector returns the region between innerR and outerR spanning the
+// angles a0 to a1 (radians, a0 < a1) around center, with arcs divided into
+// segments no longer than maxSeg. The first edge runs radially outward at
+// a0. An innerR of zero gives a pie slice.
+func AnnularSector(center Point2D, innerR, outerR, a0, a1, maxSeg float64) Polygon {
+	arc := func(r float64, from, to float64) []Point2D {
+		n := max(1, int(math.Ceil(r*math.Abs(to-from)/maxSeg)))
+		pts := make([]Point2D, 0, n+1)
+		for i := 0; i <= n; i++ {
+			a := from + (to-from)*float64(i)/float64(n)
+			pts = append(pts, center.Add(Pt(math.Cos(a), math.Sin(a)).Scale(r)))
+		}
+		return pts
+	}
+	var pts []Point2D
+	if innerR > 0.01 {
+		pts = append(pts, center.Add(Pt(math.Cos(a0), math.Sin(a0)).Scale(innerR)))
+	} else {
+		pts = append(pts, center)
+	}
+	pts = append(pts, arc(outerR, a0, a1)...)
+	if innerR > 0.01 {
+		inner := arc(innerR, a1, a0)
+		pts = append(pts, inner[:len(inner)-1]...)
+	}
+	return Polygon{Vertices: pts}
+}
+
+// ConvexHull returns the convex hull of the points, counterclockwise, by
+// Andrew's monotone chain.
+func ConvexHull(points []Point2D) Polygon {
+	pts := make([]Point2D, len(points))
+	copy(pts, points)
+	sort.Slice(pts, func(i, j int) bool {
+		if pts[i].X != pts[j].X {
+			return pts[i].X < pts[j].X
+		}
+		return pts[i].Z < pts[j].Z
+	})
+	if len(pts) < 3 {
+		return Polygon{Vertices: pts}
+	}
+	turn := func(o, a, b Point2D) float64 { return a.Sub(o).Cross(b.Sub(o)) }
+	hull := make([]Point2D, 0, 2*len(pts))
+	for _, p := range pts {
+		for len(hull) >= 2 && turn(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(pts) - 2; i >= 0; i-- {
+		p := pts[i]
+		for len(hull) >= lower && turn(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return Polygon{Vertices: hull[:len(hull)-1]}
+}
+
 // ClipToConvex clips the subject polygon to a convex clip polygon using
 // the Sutherland-Hodgman algorithm. Returns the intersection polygon.
 func ClipToConvex(subject, clipper Polygon) Polygon {
@@ -130,6 +194,31 @@ func ClipToOutsideConvex(subject, inner Polygon) Polygon {
 	return Polygon{Vertices: result}
 }
 
+// SubtractConvex returns the parts of a convex subject polygon outside a
+// convex hole, as disjoint convex polygons: for each edge of the hole, the
+// part of subject beyond that edge but within the edges before it. A
+// subject clear of the hole is returned whole; one inside it yields none.
+func SubtractConvex(subject, hole Polygon) []Polygon {
+	if subject.IsEmpty() {
+		return nil
+	}
+	if hole.IsEmpty() {
+		return []Polygon{subject}
+	}
+	hole = hole.EnsureCCW()
+	var parts []Polygon
+	rest := subject
+	n := len(hole.Vertices)
+	for i := 0; i < n && !rest.IsEmpty(); i++ {
+		a, b := hole.Edge(i)
+		if part := clipToHalfPlane(rest, b, a); !part.IsEmpty() && part.Area() > 1e-9 {
+			parts = append(parts, part)
+		}
+		rest = clipToHalfPlane(rest, a, b)
+	}
+	return parts
+}
+
 // ClipToAnnulus clips a polygon to the annular region between innerR and outerR
 // centered at center. Returns the clipped polygon (may have curved sections
 // approximated by line segments).
@@ -162,7 +251,35 @@ func clipOutsideCircle(subject Polygon, center Point2D, radius float64) Polygon
 	n := len(subject.Vertices)
 	result := make([]Point2D, 0, n*2)
 
-	for i := 0; i < n; i++ {
+	// The detour around the circle keeps the polygon's interior on the
+	// same side as its edges: clockwise around a counterclockwise polygon.
+	arc := func(from, to Point2D) []Point2D { return arcBetween(center, radius, from, to) }
+	if subject.IsCounterClockwise() {
+		arc = func(from, to Point2D) []Point2D {
+			pts := arcBetween(center, radius, to, from)
+			for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+				pts[i], pts[j] = pts[j], pts[i]
+			}
+			return pts
+		}
+	}
+
+	// Walk from a vertex outside the circle so every entry into it is
+	// closed by its exit within the walk.
+	start := -1
+	for i, v := range subject.Vertices {
+		if center.Distance(v) >= radius-0.01 {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return Polygon{}
+	}
+
+	crossed := false
+	for k := 0; k < n; k++ {
+		i := (start + k) % n
 		curr := subject.Vertices[i]
 		next := subject.Vertices[(i+1)%n]
 		currDist := center.Distance(curr)
@@ -176,9 +293,10 @@ func clipOutsideCircle(subject Polygon, center Point2D, radius float64) Polygon
 				// Find entry and exit points.
 				pts := lineCircleIntersections(curr, next, center, radius)
 				if len(pts) == 2 {
+					crossed = true
 					result = append(result, pts[0])
 					// Add arc along circle from pts[0] to pts[1].
-					arcPts := arcBetween(center, radius, pts[0], pts[1])
+					arcPts := arc(pts[0], pts[1])
 					result = append(result, arcPts...)
 					result = append(result, pts[1])
 				}
@@ -186,6 +304,7 @@ func clipOutsideCircle(subject Polygon, center Point2D, radius float64) Polygon
 			result = append(result, next)
 		} else if currOutside && !nextOutside {
 			// Entering circle.
+			crossed = true
 			if pt, ok := lineCircleIntersectionFirst(curr, next, center, radius); ok {
 				result = append(result, pt)
 			}
@@ -195,7 +314,7 @@ func clipOutsideCircle(subject Polygon, center Point2D, radius float64) Polygon
 				// Add arc from previous entry to this exit.
 				if len(result) > 0 {
 					lastPt := result[len(result)-1]
-					arcPts := arcBetween(center, radius, lastPt, pt)
+					arcPts := arc(lastPt, pt)
 					result = append(result, arcPts...)
 				}
 				result = append(result, pt)
@@ -205,12 +324,43 @@ func clipOutsideCircle(subject Polygon, center Point2D, radius float64) Polygon
 		// Both inside: skip, will be replaced by arc.
 	}
 
+	if !crossed && subject.Contains(center) {
+		return keyhole(subject, center, radius)
+	}
 	if len(result) < 3 {
 		return Polygon{}
 	}
 	return Polygon{Vertices: result}
 }
 
+// keyhole cuts a circle lying wholly inside the subject out of it. The
+// hole is joined to the subject's boundary by a zero-width slit from the
+// nearest vertex, and runs against the boundary's direction so the result
+// has the area of the subject less the circle.
+func keyhole(subject Polygon, center Point2D, radius float64) Polygon {
+	n := len(subject.Vertices)
+	k := 0
+	for i, v := range subject.Vertices {
+		if center.Distance(v) < center.Distance(subject.Vertices[k]) {
+			k = i
+		}
+	}
+	result := make([]Point2D, 0, n+circleSegments+2)
+	for i := 0; i <= n; i++ {
+		result = append(result, subject.Vertices[(k+i)%n])
+	}
+	a0 := subject.Vertices[k].Sub(center).Angle()
+	dir := -1.0 // clockwise inside a counterclockwise boundary
+	if !subject.IsCounterClockwise() {
+		dir = 1
+	}
+	for i := 0; i <= circleSegments; i++ {
+		a := a0 + dir*2*math.Pi*float64(i)/circleSegments
+		result = append(result, center.Add(Pt(math.Cos(a), math.Sin(a)).Scale(radius)))
+	}
+	return Polygon{Vertices: result}
+}
+
 // isInsideEdge returns true if the point is on the inside (left) of the
 // directed edge from edgeStart to edgeEnd.
 func isInsideEdge(p, edgeStart, edgeEnd Point2D) bool {
@@ -97,6 +97,19 @@ func TestPolygonContains(t *testing.T) {
 	}
 }
 
+func TestPolygonDistanceTo(t *testing.T) {
+	sq := NewPolygon(Pt(0, 0), Pt(10, 0), Pt(10, 10), Pt(0, 10))
+	if d := sq.DistanceTo(Pt(5, 5)); d != 0 {
+		t.Errorf("expected 0 inside square, got %f", d)
+	}
+	if d := sq.DistanceTo(Pt(13, 14)); !approxEqual(d, 5, tolerance) {
+		t.Errorf("expected 5 to the corner, got %f", d)
+	}
+	if d := sq.DistanceTo(Pt(5, -2)); !approxEqual(d, 2, tolerance) {
+		t.Errorf("expected 2 to the edge, got %f", d)
+	}
+}
+
 func TestPolygonBoundingBox(t *testing.T) {
 	sq := NewPolygon(Pt(-5, -3), Pt(10, 0), Pt(7, 12))
 	mn, mx := sq.BoundingBox()
@@ -154,6 +167,38 @@ func TestClipToConvexNoOverlap(t *testing.T) {
 	}
 }
 
+func TestSubtractConvex(t *testing.T) {
+	block := NewPolygon(Pt(0, 0), Pt(40, 0), Pt(40, 60), Pt(0, 60))
+	// A 6 m strip across the block, clockwise, leaves two 40x27 pieces.
+	strip := NewPolygon(Pt(-10, 27), Pt(-10, 33), Pt(50, 33), Pt(50, 27))
+	parts := SubtractConvex(block, strip)
+	if len(parts) != 2 || !approxEqual(parts[0].Area()+parts[1].Area(), 2160, tolerance) {
+		t.Errorf("strip: got %d parts, want two of 1080 m²", len(parts))
+	}
+	for _, p := range parts {
+		if !approxEqual(p.Area(), 1080, tolerance) || p.Contains(Pt(20, 30)) {
+			t.Errorf("strip: part of %f m² at %v", p.Area(), p.Centroid())
+		}
+	}
+
+	// A strip ending inside the block leaves the block around its end.
+	stub := NewPolygon(Pt(-10, 27), Pt(20, 27), Pt(20, 33), Pt(-10, 33))
+	area := 0.0
+	for _, p := range SubtractConvex(block, stub) {
+		area += p.Area()
+	}
+	if !approxEqual(area, 2400-120, tolerance) {
+		t.Errorf("stub: parts cover %f m², want 2280", area)
+	}
+
+	if parts := SubtractConvex(block, NewPolygon(Pt(50, 0), Pt(60, 0), Pt(60, 10))); len(parts) != 1 || !approxEqual(parts[0].Area(), 2400, tolerance) {
+		t.Error("expected the whole block back from a hole clear of it")
+	}
+	if parts := SubtractConvex(block, NewPolygon(Pt(-1, -1), Pt(41, -1), Pt(41, 61), Pt(-1, 61))); len(parts) != 0 {
+		t.Errorf("expected nothing left of a covered block, got %d parts", len(parts))
+	}
+}
+
 func TestClipToAnnulus(t *testing.T) {
 	// A large square clipped to an annulus should have area ≈ π(R²-r²).
 	sq := NewPolygon(Pt(-1000, -1000), Pt(1000, -1000), Pt(1000, 1000), Pt(-1000, 1000))
@@ -166,6 +211,67 @@ func TestClipToAnnulus(t *testing.T) {
 	}
 }
 
+func TestClipToAnnulusSector(t *testing.T) {
+	// A counterclockwise wedge crossing the inner circle keeps only the
+	// quarter annulus, not a detour around the far side of the circle.
+	wedge := NewPolygon(Origin, Pt(1000, 0), Pt(1000, 1000), Pt(0, 1000))
+	clipped := ClipToAnnulus(wedge, Origin, 100, 500)
+	expectedArea := math.Pi * (500*500 - 100*100) / 4
+	if !approxEqual(clipped.Area(), expectedArea, expectedArea*0.05) {
+		t.Errorf("expected quarter annulus area ~%f, got %f", expectedArea, clipped.Area())
+	}
+}
+
+func TestClipToAnnulusBandAroundCenter(t *testing.T) {
+	// A square around the center loses the inner disc as a keyhole, in
+	// either winding, rather than becoming a disc itself.
+	ccw := NewPolygon(Pt(-300, -300), Pt(300, -300), Pt(300, 300), Pt(-300, 300))
+	cw := NewPolygon(Pt(-300, -300), Pt(-300, 300), Pt(300, 300), Pt(300, -300))
+	expectedArea := 600*600 - math.Pi*100*100
+	for name, sq := range map[string]Polygon{"counterclockwise": ccw, "clockwise": cw} {
+		clipped := ClipToAnnulus(sq, Origin, 100, 1000)
+		if !approxEqual(clipped.Area(), expectedArea, expectedArea*0.01) {
+			t.Errorf("%s: expected area ~%f, got %f", name, expectedArea, clipped.Area())
+		}
+		if clipped.Contains(Pt(10, 20)) || !clipped.Contains(Pt(200, 150)) {
+			t.Errorf("%s: expected the inner disc cut out and the band kept", name)
+		}
+	}
+}
+
+func TestAnnularSector(t *testing.T) {
+	s := AnnularSector(Origin, 100, 160, 0, math.Pi/2, 5)
+	expectedArea := math.Pi * (160*160 - 100*100) / 4
+	if !approxEqual(s.Area(), expectedArea, expectedArea*0.01) {
+		t.Errorf("expected sector area ~%f, got %f", expectedArea, s.Area())
+	}
+	if !s.IsCounterClockwise() {
+		t.Error("expected counterclockwise sector")
+	}
+	if a, b := s.Edge(0); !approxEqual(a.Distance(Pt(100, 0)), 0, tolerance) || !approxEqual(b.Distance(Pt(160, 0)), 0, tolerance) {
+		t.Errorf("expected first edge radial at angle 0, got %v to %v", a, b)
+	}
+
+	pie := AnnularSector(Origin, 0, 100, 0, math.Pi, 5)
+	if !approxEqual(pie.Area(), math.Pi*100*100/2, 100) {
+		t.Errorf("expected half disc area, got %f", pie.Area())
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	pts := []Point2D{Pt(0, 0), Pt(10, 0), Pt(5, 3), Pt(10, 10), Pt(0, 10), Pt(4, 6)}
+	hull := ConvexHull(pts)
+	if len(hull.Vertices) != 4 {
+		t.Fatalf("expected 4 hull vertices, got %d", len(hull.Vertices))
+	}
+	if !hull.IsCounterClockwise() {
+		t.Error("expected counterclockwise hull")
+	}
+	if !approxEqual(hull.Area(), 100, tolerance) {
+		t.Errorf("expected hull area 100, got %f", hull.Area())
+	}
+}
+
 // --- Voronoi tests ---
 
 func TestVoronoiTwoPoints(t *testing.T) {
@@ -0,0 +1,92 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// GridIndex is a spatial index of axis-aligned boxes on a uniform grid.
+// Items are numbered in insertion order.
+type GridIndex struct {
+	cellSize float64
+	cells    map[[2]int][]int
+	boxes    [][2]Point2D
+}
+
+// NewGridIndex returns an empty index with square cells of the given size.
+func NewGridIndex(cellSize float64) *GridIndex {
+	return &GridIndex{cellSize: cellSize, cells: make(map[[2]int][]int)}
+}
+
+// Insert adds the box from min to max and returns its item number.
+func (g *GridIndex) Insert(min, max Point2D) int {
+	id := len(g.boxes)
+	g.boxes = append(g.boxes, [2]Point2D{min, max})
+	g.eachCell(min, max, func(c [2]int) {
+		g.cells[c] = append(g.cells[c], id)
+	})
+	return id
+}
+
+// Move replaces item id's box with the box from min to max.
+func (g *GridIndex) Move(id int, min, max Point2D) {
+	old := g.boxes[id]
+	g.eachCell(old[0], old[1], func(c [2]int) {
+		items := g.cells[c]
+		for i, item := range items {
+			if item == id {
+				g.cells[c] = append(items[:i], items[i+1:]...)
+				break
+			}
+		}
+	})
+	g.boxes[id] = [2]Point2D{min, max}
+	g.eachCell(min, max, func(c [2]int) {
+		g.cells[c] = append(g.cells[c], id)
+	})
+}
+
+// Query returns the items whose boxes intersect the box from min to max,
+// in ascending order.
+func (g *GridIndex) Query(min, max Point2D) []int {
+	var out []int
+	g.Visit(min, max, func(id int) {
+		out = append(out, id)
+	})
+	sort.Ints(out)
+	return out
+}
+
+// Visit calls fn once for every item whose box intersects the box from
+// min to max, in no particular order.
+func (g *GridIndex) Visit(min, max Point2D, fn func(id int)) {
+	g.eachCell(min, max, func(c [2]int) {
+		for _, id := range g.cells[c] {
+			b := g.boxes[id]
+			if b[0].X > max.X || min.X > b[1].X || b[0].Z > max.Z || min.Z > b[1].Z {
+				continue
+			}
+			// An item spanning several cells is visited only from the
+			// cell holding the lower corner of its overlap with the query.
+			lo := Pt(math.Max(b[0].X, min.X), math.Max(b[0].Z, min.Z))
+			if g.cell(lo) == c {
+				fn(id)
+			}
+		}
+	})
+}
+
+// eachCell calls fn for every grid cell the box from min to max touches.
+func (g *GridIndex) eachCell(min, max Point2D, fn func(c [2]int)) {
+	lo, hi := g.cell(min), g.cell(max)
+	for x := lo[0]; x <= hi[0]; x++ {
+		for z := lo[1]; z <= hi[1]; z++ {
+			fn([2]int{x, z})
+		}
+	}
+}
+
+// cell returns the grid cell containing p.
+func (g *GridIndex) cell(p Point2D) [2]int {
+	return [2]int{int(math.Floor(p.X / g.cellSize)), int(math.Floor(p.Z / g.cellSize))}
+}
@@ -176,6 +176,21 @@ func (p Polygon) MaxDistanceTo(pt Point2D) float64 {
 	return maxDist
 }
 
+// DistanceTo returns the distance from the given point to the polygon,
+// zero if the point lies inside it.
+func (p Polygon) DistanceTo(pt Point2D) float64 {
+	n := len(p.Vertices)
+	if n == 0 || p.Contains(pt) {
+		return 0
+	}
+	minDist := math.Inf(1)
+	for i := 0; i < n; i++ {
+		_, d := nearestPointOnSegment(pt, p.Vertices[i], p.Vertices[(i+1)%n])
+		minDist = math.Min(minDist, d)
+	}
+	return minDist
+}
+
 // FarthestVertexFrom returns the vertex farthest from the given point.
 func (p Polygon) FarthestVertexFrom(pt Point2D) Point2D {
 	maxDist := 0.0
@@ -0,0 +1,146 @@
+package geo
+
+import "math"
+
+// Rect is an oriented rectangle: Width runs along the direction Rotation
+// (radians from +X toward +Z) and Depth across it.
+type Rect struct {
+	Center   Point2D
+	Width    float64
+	Depth    float64
+	Rotation float64
+}
+
+// SegmentRect returns the rectangle covering a segment from a to b with
+// the given width.
+func SegmentRect(a, b Point2D, width float64) Rect {
+	d := b.Sub(a)
+	return Rect{
+		Center:   MidPoint(a, b),
+		Width:    d.Length(),
+		Depth:    width,
+		Rotation: math.Atan2(d.Z, d.X),
+	}
+}
+
+// Axes returns the unit vectors along the rectangle's width and depth.
+func (r Rect) Axes() (u, v Point2D) {
+	u = Pt(math.Cos(r.Rotation), math.Sin(r.Rotation))
+	return u, u.Perp()
+}
+
+// Corners returns the rectangle's corners in order around it.
+func (r Rect) Corners() [4]Point2D {
+	u, v := r.Axes()
+	hu, hv := u.Scale(r.Width/2), v.Scale(r.Depth/2)
+	return [4]Point2D{
+		r.Center.Sub(hu).Sub(hv),
+		r.Center.Add(hu).Sub(hv),
+		r.Center.Add(hu).Add(hv),
+		r.Center.Sub(hu).Add(hv),
+	}
+}
+
+// Polygon returns the rectangle as a polygon.
+func (r Rect) Polygon() Polygon {
+	c := r.Corners()
+	return NewPolygon(c[:]...)
+}
+
+// Bounds returns the rectangle's axis-aligned bounding box.
+func (r Rect) Bounds() (min, max Point2D) {
+	c := r.Corners()
+	min, max = c[0], c[0]
+	for _, p := range c[1:] {
+		min = Pt(math.Min(min.X, p.X), math.Min(min.Z, p.Z))
+		max = Pt(math.Max(max.X, p.X), math.Max(max.Z, p.Z))
+	}
+	return min, max
+}
+
+// Translate returns the rectangle moved by d.
+func (r Rect) Translate(d Point2D) Rect {
+	r.Center = r.Center.Add(d)
+	return r
+}
+
+// Overlaps reports whether the interiors of the rectangles intersect by
+// more than tol along every separating axis, so rectangles that only
+// touch do not overlap.
+func (r Rect) Overlaps(o Rect, tol float64) bool {
+	ru, rv := r.Axes()
+	ou, ov := o.Axes()
+	rc, oc := r.Corners(), o.Corners()
+	for _, axis := range [4]Point2D{ru, rv, ou, ov} {
+		rMin, rMax := project(rc, axis)
+		oMin, oMax := project(oc, axis)
+		if math.Min(rMax, oMax)-math.Max(rMin, oMin) <= tol {
+			return false
+		}
+	}
+	return true
+}
+
+// Distance returns the clearance between the rectangles, or zero if they
+// overlap or touch.
+func (r Rect) Distance(o Rect) float64 {
+	if r.Overlaps(o, 0) {
+		return 0
+	}
+	rc, oc := r.Corners(), o.Corners()
+	best := math.MaxFloat64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			_, d := nearestPointOnSegment(rc[i], oc[j], oc[(j+1)%4])
+			best = math.Min(best, d)
+			_, d = nearestPointOnSegment(oc[i], rc[j], rc[(j+1)%4])
+			best = math.Min(best, d)
+		}
+	}
+	return best
+}
+
+// Contains reports whether p lies inside the rectangle or on its edge.
+func (r Rect) Contains(p Point2D) bool {
+	u, v := r.Axes()
+	d := p.Sub(r.Center)
+	return math.Abs(d.Dot(u)) <= r.Width/2 && math.Abs(d.Dot(v)) <= r.Depth/2
+}
+
+// RayInterval returns the range of t over which the ray p + t·dir, t ≥ 0,
+// is inside the rectangle, and false if the ray misses it.
+func (r Rect) RayInterval(p, dir Point2D) (tIn, tOut float64, ok bool) {
+	u, v := r.Axes()
+	d := p.Sub(r.Center)
+	tIn, tOut = 0, math.MaxFloat64
+	for _, slab := range [2]struct{ pos, dir, half float64 }{
+		{d.Dot(u), dir.Dot(u), r.Width / 2},
+		{d.Dot(v), dir.Dot(v), r.Depth / 2},
+	} {
+		if math.Abs(slab.dir) < 1e-12 {
+			if math.Abs(slab.pos) > slab.half {
+				return 0, 0, false
+			}
+			continue
+		}
+		t0, t1 := (-slab.half-slab.pos)/slab.dir, (slab.half-slab.pos)/slab.dir
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		tIn, tOut = math.Max(tIn, t0), math.Min(tOut, t1)
+		if tIn > tOut {
+			return 0, 0, false
+		}
+	}
+	return tIn, tOut, true
+}
+
+// project returns the range of the points' projections onto axis.
+func project(pts [4]Point2D, axis Point2D) (min, max float64) {
+	min, max = math.MaxFloat64, -math.MaxFloat64
+	for _, p := range pts {
+		d := p.Dot(axis)
+		min, max = math.Min(min, d), math.Max(max, d)
+	}
+	return min, max
+}
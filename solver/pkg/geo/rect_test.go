@@ -0,0 +1,94 @@
+package geo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRectOverlaps(t *testing.T) {
+	a := Rect{Center: Pt(0, 0), Width: 10, Depth: 4}
+	tests := []struct {
+		name string
+		b    Rect
+		want bool
+	}{
+		{"crossing", Rect{Center: Pt(0, 0), Width: 10, Depth: 4, Rotation: math.Pi / 2}, true},
+		{"touching", Rect{Center: Pt(10, 0), Width: 10, Depth: 4}, false},
+		{"apart", Rect{Center: Pt(0, 10), Width: 10, Depth: 4}, false},
+		// Rotated 45°, its corner reaches 5√2 ≈ 7.07 m from its center.
+		{"corner in", Rect{Center: Pt(11, 0), Width: 10, Depth: 10, Rotation: math.Pi / 4}, true},
+		{"corner out", Rect{Center: Pt(13, 0), Width: 10, Depth: 10, Rotation: math.Pi / 4}, false},
+	}
+	for _, tt := range tests {
+		if got := a.Overlaps(tt.b, 0.01); got != tt.want {
+			t.Errorf("%s: Overlaps = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRectDistance(t *testing.T) {
+	a := Rect{Center: Pt(0, 0), Width: 10, Depth: 4}
+	if d := a.Distance(Rect{Center: Pt(0, 7), Width: 10, Depth: 4}); !approxEqual(d, 3, tolerance) {
+		t.Errorf("expected 3 m between parallel rectangles, got %f", d)
+	}
+	corner := Rect{Center: Pt(13, 0), Width: 10, Depth: 10, Rotation: math.Pi / 4}
+	if d := a.Distance(corner); !approxEqual(d, 8-5*math.Sqrt2, tolerance) {
+		t.Errorf("expected %f m to the rotated corner, got %f", 8-5*math.Sqrt2, d)
+	}
+	if d := a.Distance(Rect{Center: Pt(2, 1), Width: 3, Depth: 3}); d != 0 {
+		t.Errorf("expected 0 for overlapping rectangles, got %f", d)
+	}
+}
+
+func TestSegmentRect(t *testing.T) {
+	r := SegmentRect(Pt(0, 0), Pt(0, 20), 4)
+	min, max := r.Bounds()
+	if !approxEqual(min.X, -2, tolerance) || !approxEqual(max.X, 2, tolerance) ||
+		!approxEqual(min.Z, 0, tolerance) || !approxEqual(max.Z, 20, tolerance) {
+		t.Errorf("unexpected segment bounds %v to %v", min, max)
+	}
+}
+
+func TestRectRayInterval(t *testing.T) {
+	r := Rect{Center: Pt(10, 0), Width: 4, Depth: 2}
+	tIn, tOut, ok := r.RayInterval(Pt(0, 0), Pt(1, 0))
+	if !ok || !approxEqual(tIn, 8, tolerance) || !approxEqual(tOut, 12, tolerance) {
+		t.Errorf("RayInterval = %f, %f, %v; want 8, 12, true", tIn, tOut, ok)
+	}
+	if _, _, ok := r.RayInterval(Pt(0, 0), Pt(-1, 0)); ok {
+		t.Error("ray pointing away from the rectangle hit it")
+	}
+	if _, _, ok := r.RayInterval(Pt(0, 3), Pt(1, 0)); ok {
+		t.Error("ray passing beside the rectangle hit it")
+	}
+	tIn, tOut, ok = r.RayInterval(Pt(10, 0), Pt(0, 1))
+	if !ok || tIn != 0 || !approxEqual(tOut, 1, tolerance) {
+		t.Errorf("ray from inside: %f, %f, %v; want 0, 1, true", tIn, tOut, ok)
+	}
+	if !r.Contains(Pt(12, 1)) || r.Contains(Pt(12.1, 0)) {
+		t.Error("Contains disagrees with the rectangle's edges")
+	}
+}
+
+func TestGridIndexQuery(t *testing.T) {
+	g := NewGridIndex(10)
+	a := g.Insert(Pt(0, 0), Pt(5, 5))
+	b := g.Insert(Pt(20, 20), Pt(45, 25))
+	c := g.Insert(Pt(-30, -30), Pt(-25, -25))
+
+	if got := g.Query(Pt(4, 4), Pt(30, 21)); !reflect.DeepEqual(got, []int{a, b}) {
+		t.Errorf("Query = %v, want [%d %d]", got, a, b)
+	}
+	if got := g.Query(Pt(100, 100), Pt(110, 110)); len(got) != 0 {
+		t.Errorf("expected no items far away, got %v", got)
+	}
+
+	g.Move(c, Pt(40, 40), Pt(42, 42))
+	if got := g.Query(Pt(-30, -30), Pt(-20, -20)); len(got) != 0 {
+		t.Errorf("moved item still found at its old box: %v", got)
+	}
+	if got := g.Query(Pt(41, 41), Pt(41, 41)); !reflect.DeepEqual(got, []int{c}) {
+		t.Errorf("Query after move = %v, want [%d]", got, c)
+	}
+}
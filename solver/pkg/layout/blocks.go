@@ -3,6 +3,7 @@ package layout
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/ChicagoDave/cityplanner/pkg/geo"
 )
@@ -16,89 +17,259 @@ type Block struct {
 	AreaM2   float64     `json:"area_m2"`
 }
 
-// SubdivideIntoBlocks creates a grid of city blocks within a zone.
-// Blocks are approximately blockW x blockD meters, with pathGap meter
-// corridors between them. The grid is aligned to the zone's local
-// orientation (radial + perpendicular to city center).
-func SubdivideIntoBlocks(zone Zone, podCenter geo.Point2D) []Block {
-	const (
-		blockW  = 60.0 // block width along radial axis (m)
-		blockD  = 40.0 // block depth along perpendicular axis (m)
-		pathGap = 3.0  // path corridor between blocks (m)
-		minArea = 200.0 // minimum block area (m²)
-	)
+const (
+	blockDepth   = 60.0  // block depth along the radius (m)
+	blockWidth   = 40.0  // block width along the ring arc (m)
+	blockPathGap = 3.0   // path corridor between blocks (m)
+	blockMinArea = 200.0 // smallest block kept on its own (m²)
+	blockArcSeg  = 10.0  // longest chord approximating a block's curved edges (m)
+	// sliverFraction of a full block's area is the size below which a
+	// clipped block is merged into its neighbor in the row.
+	sliverFraction = 0.5
+)
 
+// SubdivideIntoBlocks divides a zone into city blocks. Blocks sit in
+// curved rows that follow the ring arcs around the city center, each row
+// about blockDepth deep and split along the arc into blocks about
+// blockWidth wide, with blockPathGap corridors between them. Each block
+// is clipped to the zone, and slivers left where the zone boundary cuts a
+// row are merged into their neighbors. A block's first edge runs radially
+// outward where the zone boundary allows.
+func SubdivideIntoBlocks(zone Zone, podCenter geo.Point2D) []Block {
 	zonePoly := zone.Polygon
 	if zonePoly.IsEmpty() {
 		return nil
 	}
 
-	// Local coordinate system: outward from city center.
-	centroid := zonePoly.Centroid()
-	outward := centroid.Normalize()
-	if centroid.Length() < 1 {
-		outward = geo.Pt(1, 0)
+	// The zone is a convex pod clipped to an annulus, so it is convex
+	// except along its inner arc. Rows lie outside that arc, and clipping
+	// them to the zone's hull is exact.
+	hull := geo.ConvexHull(zonePoly.Vertices)
+	rMin, rMax, aMin, aMax := polarExtent(zonePoly)
+	if rMax <= rMin {
+		return nil
 	}
-	perp := outward.Perp()
 
-	// Bounding box in local coordinates.
-	minU, maxU := math.MaxFloat64, -math.MaxFloat64
-	minV, maxV := math.MaxFloat64, -math.MaxFloat64
-	for _, v := range zonePoly.Vertices {
-		rel := v.Sub(centroid)
-		u := rel.Dot(outward)
-		vv := rel.Dot(perp)
-		if u < minU {
-			minU = u
+	nRows := max(1, int(math.Round((rMax-rMin+blockPathGap)/(blockDepth+blockPathGap))))
+	rowStep := (rMax - rMin + blockPathGap) / float64(nRows)
+
+	var blocks []Block
+	for row := 0; row < nRows; row++ {
+		r0 := rMin + float64(row)*rowStep
+		r1 := r0 + rowStep - blockPathGap
+		if row == 0 && rMin == 0 {
+			r0 = 0
 		}
-		if u > maxU {
-			maxU = u
+		for _, poly := range subdivideRow(hull, r0, r1, aMin, aMax) {
+			blocks = append(blocks, Block{
+				ID:       fmt.Sprintf("%s_block_%d", zone.ID, len(blocks)),
+				PodID:    zone.PodID,
+				ZoneType: zone.Type,
+				Polygon:  poly,
+				AreaM2:   poly.Area(),
+			})
 		}
-		if vv < minV {
-			minV = vv
+	}
+	return blocks
+}
+
+// polarExtent returns the range of distances and angles the polygon spans
+// around the city center. The angles run the long way round between the
+// two vertices either side of the widest angular gap, so ranges crossing
+// ±π stay contiguous. A polygon containing the center, or ringing it with
+// no gap wider than fullCircleGap, spans the full circle.
+func polarExtent(poly geo.Polygon) (rMin, rMax, aMin, aMax float64) {
+	for _, v := range poly.Vertices {
+		rMax = math.Max(rMax, v.Length())
+	}
+	if poly.Contains(geo.Origin) {
+		return 0, rMax, -math.Pi, math.Pi
+	}
+
+	ring := geo.NewPolyline(append(append([]geo.Point2D{}, poly.Vertices...), poly.Vertices[0])...)
+	_, rMin = ring.NearestPoint(geo.Origin)
+
+	angles := make([]float64, len(poly.Vertices))
+	for i, v := range poly.Vertices {
+		angles[i] = v.Angle()
+	}
+	sort.Float64s(angles)
+	gap, after := angles[0]+2*math.Pi-angles[len(angles)-1], 0
+	for i := 1; i < len(angles); i++ {
+		if g := angles[i] - angles[i-1]; g > gap {
+			gap, after = g, i
 		}
-		if vv > maxV {
-			maxV = vv
+	}
+	if gap < fullCircleGap {
+		return rMin, rMax, -math.Pi, math.Pi
+	}
+	aMin = angles[after]
+	aMax = aMin + 2*math.Pi - gap
+	return rMin, rMax, aMin, aMax
+}
+
+// fullCircleGap is the widest angular gap between a polygon's vertices, as
+// seen from the city center, for the polygon to count as ringing it.
+const fullCircleGap = math.Pi / 8
+
+// rowCell is a run of consecutive slots k0..k1 of a row merged into one
+// block, and its clipped shape.
+type rowCell struct {
+	k0, k1 int
+	poly   geo.Polygon
+	area   float64
+}
+
+// subdivideRow splits the row between radii r0 and r1 into blocks across
+// the angles aMin to aMax, clipped to hull, and merges slivers.
+func subdivideRow(hull geo.Polygon, r0, r1, aMin, aMax float64) []geo.Polygon {
+	rMid := (r0 + r1) / 2
+	if rMid <= 0 || r1 <= r0 {
+		return nil
+	}
+	wraps := aMax-aMin >= 2*math.Pi-1e-9
+	gapA := blockPathGap / rMid
+	span := aMax - aMin
+	if !wraps {
+		span += gapA
+	}
+	n := max(1, int(math.Round(rMid*span/(blockWidth+blockPathGap))))
+	if wraps {
+		// Keep slices of the center disc narrower than a half-turn.
+		n = max(n, 3)
+	}
+	step := span / float64(n)
+
+	// clip shapes slots k0..k1; k1 may run past n where a full ring wraps.
+	clip := func(k0, k1 int) rowCell {
+		a0 := aMin + float64(k0)*step
+		a1 := aMin + float64(k1+1)*step - gapA
+		poly := geo.ClipToConvex(geo.AnnularSector(geo.Origin, r0, r1, a0, a1, blockArcSeg), hull)
+		return rowCell{k0: k0, k1: k1, poly: poly, area: poly.Area()}
+	}
+	var cells []rowCell
+	for k := 0; k < n; k++ {
+		if c := clip(k, k); !c.poly.IsEmpty() {
+			cells = append(cells, c)
 		}
 	}
+	sliver := sliverFraction * (r1 - r0) * (step - gapA) * rMid
+	cells = mergeSlivers(cells, n, wraps, clip, sliver)
 
-	// Grid step sizes (block + gap).
-	stepU := blockW + pathGap
-	stepV := blockD + pathGap
+	var out []geo.Polygon
+	for _, c := range cells {
+		if c.area >= blockMinArea {
+			out = append(out, c.poly)
+		}
+	}
+	return out
+}
 
-	var blocks []Block
-	blockIdx := 0
-
-	// Generate grid of candidate blocks.
-	for u := minU; u+blockW <= maxU; u += stepU {
-		for v := minV; v+blockD <= maxV; v += stepV {
-			// Block corners in world coordinates.
-			p1 := centroid.Add(outward.Scale(u)).Add(perp.Scale(v))
-			p2 := centroid.Add(outward.Scale(u + blockW)).Add(perp.Scale(v))
-			p3 := centroid.Add(outward.Scale(u + blockW)).Add(perp.Scale(v + blockD))
-			p4 := centroid.Add(outward.Scale(u)).Add(perp.Scale(v + blockD))
-			blockPoly := geo.NewPolygon(p1, p2, p3, p4)
-
-			// Check if block center is inside the zone.
-			blockCenter := blockPoly.Centroid()
-			if !zonePoly.Contains(blockCenter) {
-				continue
+// mergeSlivers repeatedly merges the smallest cell under minArea into its
+// smaller neighbor in the row, reclipping the combined slots, until every
+// cell is at least minArea or has no neighbor. When wraps is set the row
+// is a full ring of n slots, so its last and first cells are neighbors.
+func mergeSlivers(cells []rowCell, n int, wraps bool, clip func(k0, k1 int) rowCell, minArea float64) []rowCell {
+	for len(cells) > 1 {
+		order := make([]int, len(cells))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(x, y int) bool { return cells[order[x]].area < cells[order[y]].area })
+
+		merged := false
+		for _, i := range order {
+			if cells[i].area >= minArea {
+				break
+			}
+			prev, next := -1, -1
+			if i > 0 && cells[i-1].k1+1 == cells[i].k0 {
+				prev = i - 1
 			}
-			area := blockPoly.Area()
-			if area < minArea {
+			if i+1 < len(cells) && cells[i].k1+1 == cells[i+1].k0 {
+				next = i + 1
+			}
+			last := len(cells) - 1
+			wrapPair := wraps && len(cells) > 2 && cells[0].k0 == 0 && cells[last].k1 == n-1
+			if wrapPair && i == 0 {
+				prev = last
+			}
+			if wrapPair && i == last {
+				next = 0
+			}
+			j := prev
+			if j < 0 || (next >= 0 && cells[next].area < cells[prev].area) {
+				j = next
+			}
+			if j < 0 {
 				continue
 			}
 
-			blocks = append(blocks, Block{
-				ID:       fmt.Sprintf("%s_block_%d", zone.ID, blockIdx),
-				PodID:    zone.PodID,
-				ZoneType: zone.Type,
-				Polygon:  blockPoly,
-				AreaM2:   area,
-			})
-			blockIdx++
+			if wrapPair && (i == 0 && j == last || i == last && j == 0) {
+				// The last cell's slots run on into the first's.
+				cells[last] = clip(cells[last].k0, cells[0].k1+n)
+				cells = cells[1:]
+			} else {
+				lo, hi := min(i, j), max(i, j)
+				cells[lo] = clip(cells[lo].k0, cells[hi].k1)
+				cells = append(cells[:hi], cells[hi+1:]...)
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			break
 		}
 	}
+	return cells
+}
+
+// BlockCoverage returns the share of the zone's area covered by blocks.
+func BlockCoverage(zone Zone, blocks []Block) float64 {
+	area := zone.Polygon.Area()
+	if area <= 0 {
+		return 0
+	}
+	covered := 0.0
+	for _, b := range blocks {
+		covered += b.AreaM2
+	}
+	return covered / area
+}
 
+// clearOfPaths cuts the corridors of the pod's paths out of a zone's
+// blocks, each path widened by the clearance buildings keep from it. A
+// block a path crosses becomes the pieces either side of it, pieces
+// smaller than blockMinArea are dropped, and the blocks are renumbered
+// within the zone.
+func clearOfPaths(zoneID string, blocks []Block, paths []PathSegment) []Block {
+	for _, p := range paths {
+		if p.Start.Distance(p.End) == 0 {
+			continue
+		}
+		r := geo.SegmentRect(p.Start, p.End, p.WidthM+2*featureSetbackM)
+		r.Width += 2 * featureSetbackM
+		corridor := r.Polygon()
+		min, max := r.Bounds()
+
+		kept := blocks[:0:0]
+		for _, b := range blocks {
+			bMin, bMax := b.Polygon.BoundingBox()
+			if bMax.X < min.X || bMin.X > max.X || bMax.Z < min.Z || bMin.Z > max.Z {
+				kept = append(kept, b)
+				continue
+			}
+			for _, part := range geo.SubtractConvex(b.Polygon, corridor) {
+				if area := part.Area(); area >= blockMinArea {
+					b.Polygon, b.AreaM2 = part, area
+					kept = append(kept, b)
+				}
+			}
+		}
+		blocks = kept
+	}
+	for i := range blocks {
+		blocks[i].ID = fmt.Sprintf("%s_block_%d", zoneID, i)
+	}
 	return blocks
 }
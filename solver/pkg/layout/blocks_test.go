@@ -0,0 +1,202 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+)
+
+// sectorZone returns a residential zone covering the annular sector
+// between radii r0 and r1 and angles a0 to a1.
+func sectorZone(r0, r1, a0, a1 float64) Zone {
+	poly := geo.AnnularSector(geo.Origin, r0, r1, a0, a1, 5)
+	return Zone{
+		ID:      "test_zone",
+		PodID:   "test_pod",
+		Type:    ZoneResidential,
+		Polygon: poly,
+		AreaHa:  poly.Area() / 10000,
+	}
+}
+
+// outsideBy returns how far p lies outside poly, or zero if it is inside.
+func outsideBy(p geo.Point2D, poly geo.Polygon) float64 {
+	if poly.Contains(p) {
+		return 0
+	}
+	ring := geo.NewPolyline(append(append([]geo.Point2D{}, poly.Vertices...), poly.Vertices[0])...)
+	_, d := ring.NearestPoint(p)
+	return d
+}
+
+func TestSubdivideIntoBlocksFollowsRingArcs(t *testing.T) {
+	zone := sectorZone(300, 423, 0, 0.4)
+	blocks := SubdivideIntoBlocks(zone, geo.Pt(360, 70))
+	if len(blocks) == 0 {
+		t.Fatal("expected blocks")
+	}
+
+	rows := map[int]int{}
+	for _, b := range blocks {
+		if b.AreaM2 < blockMinArea {
+			t.Errorf("block %s of %.0f m² is below the minimum", b.ID, b.AreaM2)
+		}
+		rMin, rMax := math.MaxFloat64, 0.0
+		for _, v := range b.Polygon.Vertices {
+			if d := outsideBy(v, zone.Polygon); d > 0.5 {
+				t.Errorf("block %s extends %.1f m outside its zone", b.ID, d)
+			}
+			rMin, rMax = math.Min(rMin, v.Length()), math.Max(rMax, v.Length())
+		}
+		// Curved rows: every block lies between two ring arcs 60 m apart.
+		if rMax-rMin > blockDepth+1 {
+			t.Errorf("block %s spans %.0f m radially", b.ID, rMax-rMin)
+		}
+		rows[int(math.Round(rMin))]++
+	}
+	if len(rows) != 2 {
+		t.Errorf("expected 2 rows of blocks, got radii %v", rows)
+	}
+	if c := BlockCoverage(zone, blocks); c < 0.85 {
+		t.Errorf("block coverage %.0f%%, want at least 85%%", c*100)
+	}
+}
+
+func TestSubdivideIntoBlocksMergesSlivers(t *testing.T) {
+	// Cut the sector with a chord so the ends of each row are slivers.
+	sector := sectorZone(300, 423, 0, 0.4)
+	cut := geo.NewPolygon(geo.Pt(0, 0), geo.Pt(1000, 10), geo.Pt(1000, 1000), geo.Pt(0, 1000))
+	zone := sector
+	zone.Polygon = geo.ClipToConvex(sector.Polygon, cut)
+
+	blocks := SubdivideIntoBlocks(zone, geo.Pt(360, 70))
+	nominal := blockDepth * blockWidth
+	for _, b := range blocks {
+		if b.AreaM2 < sliverFraction*nominal*0.5 {
+			t.Errorf("block %s of %.0f m² is a sliver", b.ID, b.AreaM2)
+		}
+		for _, v := range b.Polygon.Vertices {
+			if d := outsideBy(v, zone.Polygon); d > 0.5 {
+				t.Errorf("block %s extends %.1f m outside its zone", b.ID, d)
+			}
+		}
+	}
+	if c := BlockCoverage(zone, blocks); c < 0.8 {
+		t.Errorf("block coverage %.0f%%, want at least 80%%", c*100)
+	}
+}
+
+func TestSubdivideIntoBlocksCoversPodZones(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, _, _ := LayoutPods(s, params)
+	for _, pod := range pods {
+		var radii [2]float64
+		for _, r := range s.CityZones.Rings {
+			if r.Name == pod.Ring {
+				radii = [2]float64{r.RadiusFrom, r.RadiusTo}
+			}
+		}
+		character := s.Pods.RingAssignments[pod.Ring].Character
+		for _, zone := range AllocateZones(pod, character, radii[0], radii[1]) {
+			if zone.Type != ZoneResidential && zone.Type != ZoneCommercial {
+				continue
+			}
+			blocks := SubdivideIntoBlocks(zone, pod.CenterPoint())
+			for _, b := range blocks {
+				if d := outsideBy(b.Polygon.Centroid(), zone.Polygon); d > 0 {
+					t.Errorf("block %s centroid lies %.1f m outside its zone", b.ID, d)
+				}
+			}
+			if c := BlockCoverage(zone, blocks); c < 0.8 {
+				t.Errorf("zone %s block coverage %.0f%%, want at least 80%%", zone.ID, c*100)
+			}
+		}
+	}
+}
+
+func TestPlaceBuildingsReportsBlockCoverage(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	_, _, report := PlaceBuildings(s, pods, adjacency, params)
+	for _, r := range report.Info {
+		if strings.HasPrefix(r.Message, "block coverage: residential") {
+			return
+		}
+	}
+	t.Error("placement report has no block coverage")
+}
+
+func TestPlaceCommercialOnBlockStaysInBlock(t *testing.T) {
+	zone := sectorZone(300, 423, 0, 0.4)
+	zone.Type = ZoneCommercial
+	env := defaultSpec().HeightEnvelope()
+	placed := 0
+	for _, block := range SubdivideIntoBlocks(zone, geo.Pt(360, 70)) {
+		idx := 0
+		for _, b := range placeCommercialOnBlock(block, Pod{ID: "test_pod"}, env, &idx) {
+			placed++
+			for _, c := range BuildingRect(b).Corners() {
+				if d := outsideBy(c, block.Polygon); d > 1e-6 {
+					t.Errorf("building %s corner lies %.1f m outside block %s", b.ID, d, block.ID)
+				}
+			}
+		}
+	}
+	if placed == 0 {
+		t.Fatal("expected commercial buildings")
+	}
+}
+
+func TestClearOfPathsCutsCorridors(t *testing.T) {
+	block := Block{ID: "z_block_0", Polygon: geo.NewPolygon(geo.Pt(0, 0), geo.Pt(40, 0), geo.Pt(40, 60), geo.Pt(0, 60)), AreaM2: 2400}
+	aside := Block{ID: "z_block_1", Polygon: geo.NewPolygon(geo.Pt(100, 0), geo.Pt(140, 0), geo.Pt(140, 60), geo.Pt(100, 60)), AreaM2: 2400}
+	path := PathSegment{ID: "p", Start: geo.Pt(-20, 30), End: geo.Pt(60, 30), WidthM: 4}
+
+	blocks := clearOfPaths("z", []Block{block, aside}, []PathSegment{path})
+	if len(blocks) != 3 {
+		t.Fatalf("expected the crossed block split in two and the other kept, got %d blocks", len(blocks))
+	}
+	clear := path.WidthM/2 + featureSetbackM
+	for i, b := range blocks {
+		if want := fmt.Sprintf("z_block_%d", i); b.ID != want {
+			t.Errorf("block %d has ID %s, want %s", i, b.ID, want)
+		}
+		if math.Abs(b.AreaM2-b.Polygon.Area()) > 1e-6 {
+			t.Errorf("block %s area %.0f m² does not match its polygon", b.ID, b.AreaM2)
+		}
+		for _, v := range b.Polygon.Vertices {
+			if math.Abs(v.Z-30) < clear-1e-6 {
+				t.Errorf("block %s vertex %v lies in the path corridor", b.ID, v)
+			}
+		}
+	}
+}
+
+func TestGeneratePathsStayInPod(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	centers := map[string]geo.Point2D{}
+	for _, pod := range pods {
+		centers[pod.ID] = pod.CenterPoint()
+	}
+	for _, pod := range pods {
+		adj := map[string]geo.Point2D{}
+		for _, id := range adjacency[pod.ID] {
+			adj[id] = centers[id]
+		}
+		poly := pod.BoundaryPolygon()
+		for _, p := range GeneratePaths(pod, nil, adj) {
+			for _, end := range []geo.Point2D{p.Start, p.End} {
+				if d := outsideBy(end, poly); d > 0.5 {
+					t.Errorf("path %s ends %.1f m outside pod %s", p.ID, d, pod.ID)
+				}
+			}
+		}
+	}
+}
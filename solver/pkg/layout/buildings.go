@@ -1,8 +1,10 @@
 package layout
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"strings"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 	"github.com/ChicagoDave/cityplanner/pkg/geo"
@@ -15,11 +17,16 @@ type Building struct {
 	ID            string     `json:"id"`
 	PodID         string     `json:"pod_id"`
 	Type          string     `json:"type"` // residential, commercial, civic, service
-	Position      [3]float64 `json:"position"`
-	Footprint     [2]float64 `json:"footprint"` // [width, depth] in meters
+	Typology      string     `json:"typology,omitempty"`
+	Position      [3]float64 `json:"position"`           // footprint center; Y is the base elevation
+	Footprint     [2]float64 `json:"footprint"`          // [width, depth] in meters
+	Rotation      float64    `json:"rotation,omitempty"` // radians; angle of the width axis
 	Stories       int        `json:"stories"`
-	DwellingUnits int        `json:"dwelling_units,omitempty"`
-	CommercialSqM float64    `json:"commercial_sqm,omitempty"`
+	Floors        []FloorUse `json:"floors"`                   // bottom up; the figures below derive from them
+	DwellingUnits int        `json:"dwelling_units,omitempty"` // sum of residential floor units
+	UnitMix       *UnitMix   `json:"unit_mix,omitempty"`       // residential: dwelling units by bedroom count
+	CommercialSqM float64    `json:"commercial_sqm,omitempty"` // usable retail and coworking area
+	ServiceSqM    float64    `json:"service_sqm,omitempty"`    // usable civic area
 	ServiceType   string     `json:"service_type,omitempty"`
 }
 
@@ -54,118 +61,129 @@ var serviceFootprints = map[string][2]float64{
 
 // PlaceBuildings generates building placements within laid-out pods.
 // Orchestrates: zones → paths → blocks → building placement.
+// Pods are placed concurrently; IDs are assigned in pod order afterwards,
+// so the output is identical to a serial run.
 // Returns buildings, path segments, and a validation report.
 func PlaceBuildings(s *spec.CitySpec, pods []Pod, adjacency map[string][]string, params *analytics.ResolvedParameters) ([]Building, []PathSegment, *validation.Report) {
+	buildings, paths, report, _ := placeBuildingsWith(context.Background(), s, pods, adjacency, params, 0, nil)
+	return buildings, paths, report
+}
+
+// PlaceBuildingsContext is PlaceBuildings that stops placing pods once ctx
+// is done, returning ctx's error and no placements.
+func PlaceBuildingsContext(ctx context.Context, s *spec.CitySpec, pods []Pod, adjacency map[string][]string, params *analytics.ResolvedParameters) ([]Building, []PathSegment, *validation.Report, error) {
+	return placeBuildingsWith(ctx, s, pods, adjacency, params, 0, nil)
+}
+
+// podPlacement holds the buildings and paths generated for one pod.
+// Building IDs are numbered from zero within the pod; seq records each
+// building's local index (commercial truncation leaves gaps) and idxUsed
+// how many indices the pod consumed.
+type podPlacement struct {
+	buildings []Building
+	seq       []int
+	idxUsed   int
+	paths     []PathSegment
+	du        int
+	noZones   bool
+	// blockArea and zoneArea sum the block and zone areas (m²) of the
+	// pod's subdivided zones by type, for the coverage report.
+	blockArea map[ZoneType]float64
+	zoneArea  map[ZoneType]float64
+}
+
+// placeBuildingsWith places pods on up to workers goroutines, reusing
+// placements from cache when it is non-nil. A canceled run leaves the
+// cache untouched.
+func placeBuildingsWith(ctx context.Context, s *spec.CitySpec, pods []Pod, adjacency map[string][]string, params *analytics.ResolvedParameters, workers int, cache *PlacementCache) ([]Building, []PathSegment, *validation.Report, error) {
 	report := validation.NewReport()
 
 	// Global unit mix for the entire city.
 	cityMix := DistributeUnits(params.TotalHouseholds, params.Cohorts)
 
-	rings := s.CityZones.Rings
-
-	var allBuildings []Building
-	var allPaths []PathSegment
-	buildingIdx := 0
-	totalDU := 0
-
 	// Build a pod center lookup for inter-pod paths.
 	podCenterMap := make(map[string]geo.Point2D)
 	for _, p := range pods {
 		podCenterMap[p.ID] = p.CenterPoint()
 	}
 
+	for _, ref := range unknownTypologies(s) {
+		report.AddWarning(validation.Result{
+			Level:    validation.LevelSchema,
+			Message:  fmt.Sprintf("unknown building typology %s; ignored", ref),
+			SpecPath: "pods.building_typologies",
+			Expected: fmt.Sprintf("one of %v", TypologyNames()),
+		})
+	}
+
 	// Build a ring radii lookup from spec rings.
-	ringRadii := make(map[string][2]float64, len(rings))
-	for _, ring := range rings {
+	ringRadii := make(map[string][2]float64, len(s.CityZones.Rings))
+	for _, ring := range s.CityZones.Rings {
 		ringRadii[ring.Name] = [2]float64{ring.RadiusFrom, ring.RadiusTo}
 	}
 
-	for _, pod := range pods {
-		// Determine ring character for zone proportions.
-		ringChar := ""
-		if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
-			ringChar = pr.Character
-		}
-
-		// 1. Zone allocation using radial bands.
-		radii := ringRadii[pod.Ring]
-		zones := AllocateZones(pod, ringChar, radii[0], radii[1])
-		if len(zones) == 0 {
-			report.AddWarning(validation.Result{
-				Level:   validation.LevelSpatial,
-				Message: fmt.Sprintf("pod %s: no zones allocated", pod.ID),
-			})
-			continue
-		}
-
-		// 2. Path network.
+	placements := make([]podPlacement, len(pods))
+	keys := make([]podKey, len(pods))
+	reused := make([]bool, len(pods))
+	err := parallelFor(ctx, len(pods), workers, func(i int) {
+		pod := pods[i]
 		adjCenters := make(map[string]geo.Point2D)
 		for _, adjID := range adjacency[pod.ID] {
 			if c, ok := podCenterMap[adjID]; ok {
 				adjCenters[adjID] = c
 			}
 		}
-		paths := GeneratePaths(pod, zones, adjCenters)
-		allPaths = append(allPaths, paths...)
-
-		// 3. Scale unit mix proportionally to this pod's population.
-		popFraction := float64(pod.TargetPopulation) / float64(params.TotalPopulation)
-		podMix := ScaleUnitMix(cityMix, popFraction)
-		podDUTarget := podMix.Total()
-		podDU := 0
-
-		// 4. Process each zone.
-		for _, zone := range zones {
-			// Subdivide zone into blocks.
-			blocks := SubdivideIntoBlocks(zone, pod.CenterPoint())
-
-			switch zone.Type {
-			case ZoneResidential:
-				for _, block := range blocks {
-					if podDU >= podDUTarget {
-						break
-					}
-					buildings, du := placeResidentialOnBlock(block, pod, rings, &buildingIdx)
-					allBuildings = append(allBuildings, buildings...)
-					podDU += du
-				}
-
-			case ZoneCommercial:
-				// Cap commercial: ~1 per 500 residents in the pod.
-				comTarget := pod.TargetPopulation / 500
-				if comTarget < 2 {
-					comTarget = 2
-				}
-				comPlaced := 0
-				for _, block := range blocks {
-					if comPlaced >= comTarget {
-						break
-					}
-					buildings := placeCommercialOnBlock(block, pod, rings, &buildingIdx)
-					remaining := comTarget - comPlaced
-					if len(buildings) > remaining {
-						buildings = buildings[:remaining]
-					}
-					allBuildings = append(allBuildings, buildings...)
-					comPlaced += len(buildings)
-				}
-
-			case ZoneCivic:
-				// Place service buildings directly within the civic zone.
-				// Bypass block subdivision since civic zones can be narrow.
-				if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
-					for si, svc := range pr.RequiredServices {
-						b := placeServiceAtZone(zone, pod, svc, si, rings, &buildingIdx)
-						allBuildings = append(allBuildings, b)
-					}
-				}
-
-			case ZoneGreen:
-				// Green space: no buildings placed. Parks tracked as zones.
+		if cache != nil {
+			keys[i] = newPodKey(s, pod, adjCenters, ringRadii, params)
+			if pp, ok := cache.lookupPod(keys[i]); ok {
+				placements[i], reused[i] = pp, true
+				return
 			}
 		}
+		placements[i] = placePod(s, pod, adjCenters, ringRadii, cityMix, params)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if cache != nil {
+		entries := make(map[string]cachedPod, len(pods))
+		n := 0
+		for i, pod := range pods {
+			entries[pod.ID] = cachedPod{key: keys[i], placement: placements[i]}
+			if reused[i] {
+				n++
+			}
+		}
+		cache.storePods(entries, n)
+	}
 
-		totalDU += podDU
+	var allBuildings []Building
+	var allPaths []PathSegment
+	buildingIdx := 0
+	totalDU := 0
+	blockArea := map[ZoneType]float64{}
+	zoneArea := map[ZoneType]float64{}
+	for i, pp := range placements {
+		if pp.noZones {
+			report.AddWarning(validation.Result{
+				Level:   validation.LevelSpatial,
+				Message: fmt.Sprintf("pod %s: no zones allocated", pods[i].ID),
+			})
+			continue
+		}
+		for k, b := range pp.buildings {
+			b.ID = fmt.Sprintf("bldg_%05d", buildingIdx+pp.seq[k])
+			allBuildings = append(allBuildings, b)
+		}
+		buildingIdx += pp.idxUsed
+		allPaths = append(allPaths, pp.paths...)
+		totalDU += pp.du
+		for zt, a := range pp.blockArea {
+			blockArea[zt] += a
+		}
+		for zt, a := range pp.zoneArea {
+			zoneArea[zt] += a
+		}
 	}
 
 	// Validation.
@@ -177,90 +195,148 @@ func PlaceBuildings(s *spec.CitySpec, pods []Pod, adjacency map[string][]string,
 		})
 	}
 
+	var coverage []string
+	for _, zt := range []ZoneType{ZoneResidential, ZoneCommercial} {
+		if zoneArea[zt] > 0 {
+			coverage = append(coverage, fmt.Sprintf("%s %.0f%%", zt, blockArea[zt]/zoneArea[zt]*100))
+		}
+	}
+	if len(coverage) > 0 {
+		report.AddInfo(validation.Result{
+			Level:   validation.LevelSpatial,
+			Message: "block coverage: " + strings.Join(coverage, ", "),
+		})
+	}
+
 	report.AddInfo(validation.Result{
 		Level:   validation.LevelSpatial,
 		Message: fmt.Sprintf("placed %d buildings (%d dwelling units) and %d path segments", len(allBuildings), totalDU, len(allPaths)),
 	})
 
-	return allBuildings, allPaths, report
+	return allBuildings, allPaths, report, nil
 }
 
-// placeResidentialOnBlock places residential buildings on a block using a
-// courtyard pattern: buildings around the perimeter with open center.
-func placeResidentialOnBlock(block Block, pod Pod, rings []spec.RingDef, idx *int) ([]Building, int) {
-	const (
-		buildingW = 20.0 // width (m)
-		buildingD = 15.0 // depth (m)
-		spacing   = 2.0  // gap between buildings (m)
-		setback   = 3.0  // setback from block edge (m)
-		floorH    = 3.0  // story height (m)
-		unitArea  = 75.0 // average unit area (m²)
-	)
+// placePod runs zone allocation, path generation and building placement
+// for a single pod. It only reads shared inputs, so pods can run concurrently.
+func placePod(
+	s *spec.CitySpec,
+	pod Pod,
+	adjCenters map[string]geo.Point2D,
+	ringRadii map[string][2]float64,
+	cityMix UnitMix,
+	params *analytics.ResolvedParameters,
+) podPlacement {
+	var pp podPlacement
+	env := s.HeightEnvelope()
+	buildingIdx := 0
+	add := func(bs []Building, first int) {
+		for k, b := range bs {
+			pp.buildings = append(pp.buildings, b)
+			pp.seq = append(pp.seq, first+k)
+		}
+	}
 
-	centroid := block.Polygon.Centroid()
-	dist := centroid.Distance(geo.Origin)
-	stories := MaxStoriesFromRings(dist, rings)
-	unitsPerFloor := int(math.Max(1, math.Floor(buildingW*buildingD/unitArea)))
-	unitsPerBuilding := unitsPerFloor * stories
-
-	// Determine local axes.
-	outward := centroid.Normalize()
-	if centroid.Length() < 1 {
-		outward = geo.Pt(1, 0)
+	// Determine ring character for zone proportions.
+	ringChar := ""
+	if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
+		ringChar = pr.Character
 	}
-	perp := outward.Perp()
 
-	// Bounding box extent.
-	bbMin, bbMax := block.Polygon.BoundingBox()
-	extentU := bbMax.X - bbMin.X
-	extentV := bbMax.Z - bbMin.Z
+	// 1. Zone allocation using radial bands.
+	radii := ringRadii[pod.Ring]
+	zones := AllocateZones(pod, ringChar, radii[0], radii[1])
+	if len(zones) == 0 {
+		pp.noZones = true
+		return pp
+	}
 
-	// Place buildings in a grid within the block.
-	stepU := buildingW + spacing
-	stepV := buildingD + spacing
-	availU := extentU - 2*setback
-	availV := extentV - 2*setback
+	// 2. Path network.
+	pp.paths = GeneratePaths(pod, zones, adjCenters)
 
-	var buildings []Building
-	totalDU := 0
+	typs := typologiesFor(s, ringChar)
 
-	numU := int(math.Max(1, math.Floor(availU/stepU)))
-	numV := int(math.Max(1, math.Floor(availV/stepV)))
+	// 3. Scale unit mix proportionally to this pod's population. Units are
+	// sized to the mix's average floor area.
+	popFraction := float64(pod.TargetPopulation) / float64(params.TotalPopulation)
+	podMix := ScaleUnitMix(cityMix, popFraction)
+	podDUTarget := podMix.Total()
+	targets := PlacementTargets{
+		UnitAreaM2:        AvgUnitSizeM2(podMix),
+		GroundFloorRetail: groundFloorRetailCharacters[ringChar],
+	}
+	podDU := 0
+
+	// 4. Process each zone.
+	for _, zone := range zones {
+		// Subdivide zone into blocks, leaving the paths clear.
+		blocks := clearOfPaths(zone.ID, SubdivideIntoBlocks(zone, pod.CenterPoint()), pp.paths)
+		if zone.Type == ZoneResidential || zone.Type == ZoneCommercial {
+			if pp.blockArea == nil {
+				pp.blockArea, pp.zoneArea = map[ZoneType]float64{}, map[ZoneType]float64{}
+			}
+			for _, b := range blocks {
+				pp.blockArea[zone.Type] += b.AreaM2
+			}
+			pp.zoneArea[zone.Type] += zone.Polygon.Area()
+		}
 
-	for iu := 0; iu < numU; iu++ {
-		for iv := 0; iv < numV; iv++ {
-			bx := bbMin.X + setback + float64(iu)*stepU + buildingW/2
-			bz := bbMin.Z + setback + float64(iv)*stepV + buildingD/2
-			pos := geo.Pt(bx, bz)
+		switch zone.Type {
+		case ZoneResidential:
+			for _, block := range blocks {
+				if podDU >= podDUTarget {
+					break
+				}
+				first := buildingIdx
+				buildings, du := placeResidentialOnBlock(block, pod, env, typs, targets, &buildingIdx)
+				add(buildings, first)
+				podDU += du
+			}
 
-			// Verify position is inside the block.
-			if !block.Polygon.Contains(pos) {
-				continue
+		case ZoneCommercial:
+			// Cap commercial: ~1 per 500 residents in the pod.
+			comTarget := pod.TargetPopulation / 500
+			if comTarget < 2 {
+				comTarget = 2
+			}
+			comPlaced := 0
+			for _, block := range blocks {
+				if comPlaced >= comTarget {
+					break
+				}
+				first := buildingIdx
+				buildings := placeCommercialOnBlock(block, pod, env, &buildingIdx)
+				remaining := comTarget - comPlaced
+				if len(buildings) > remaining {
+					buildings = buildings[:remaining]
+				}
+				add(buildings, first)
+				comPlaced += len(buildings)
 			}
 
-			_ = floorH
-			_ = perp
-			_ = outward
-
-			buildings = append(buildings, Building{
-				ID:            fmt.Sprintf("bldg_%05d", *idx),
-				PodID:         pod.ID,
-				Type:          "residential",
-				Position:      [3]float64{pos.X, 0, pos.Z},
-				Footprint:     [2]float64{buildingW, buildingD},
-				Stories:       stories,
-				DwellingUnits: unitsPerBuilding,
-			})
-			totalDU += unitsPerBuilding
-			*idx++
+		case ZoneCivic:
+			if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
+				first := buildingIdx
+				add(placeServicesInZone(zone, pod, pr.RequiredServices, pp.paths, pp.buildings, env, &buildingIdx), first)
+			}
+
+		case ZoneGreen:
+			// Green space: no buildings placed. Parks tracked as zones.
 		}
 	}
 
-	return buildings, totalDU
+	// 5. Every pod houses its share of each cohort, whatever number of
+	// units its blocks held.
+	allocateUnitMix(pp.buildings, cityMix)
+
+	pp.du = podDU
+	pp.idxUsed = buildingIdx
+	return pp
 }
 
-// placeCommercialOnBlock places commercial buildings on a block.
-func placeCommercialOnBlock(block Block, pod Pod, rings []spec.RingDef, idx *int) []Building {
+// placeCommercialOnBlock places commercial buildings on a block, in rows
+// square to the block's frame. Buildings reaching outside the block are
+// left out.
+func placeCommercialOnBlock(block Block, pod Pod, env spec.HeightEnvelope, idx *int) []Building {
 	const (
 		buildingW     = 25.0
 		buildingD     = 20.0
@@ -269,42 +345,30 @@ func placeCommercialOnBlock(block Block, pod Pod, rings []spec.RingDef, idx *int
 		maxComStories = 6
 	)
 
-	centroid := block.Polygon.Centroid()
-	dist := centroid.Distance(geo.Origin)
-	stories := MaxStoriesFromRings(dist, rings)
+	stories := blockMaxStories(env, block.Polygon)
 	if stories > maxComStories {
 		stories = maxComStories
 	}
 
-	bbMin, bbMax := block.Polygon.BoundingBox()
-	availU := (bbMax.X - bbMin.X) - 2*setback
-	availV := (bbMax.Z - bbMin.Z) - 2*setback
-
-	stepU := buildingW + spacing
-	stepV := buildingD + spacing
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(setback)
+	numU := max(1, rowCount(lenU, buildingW, spacing))
+	numV := max(1, rowCount(lenV, buildingD, spacing))
 
 	var buildings []Building
-	numU := int(math.Max(1, math.Floor(availU/stepU)))
-	numV := int(math.Max(1, math.Floor(availV/stepV)))
-
 	for iu := 0; iu < numU; iu++ {
 		for iv := 0; iv < numV; iv++ {
-			bx := bbMin.X + setback + float64(iu)*stepU + buildingW/2
-			bz := bbMin.Z + setback + float64(iv)*stepV + buildingD/2
-			pos := geo.Pt(bx, bz)
-			if !block.Polygon.Contains(pos) {
+			u := u0 + float64(iu)*(buildingW+spacing) + buildingW/2
+			v := v0 + float64(iv)*(buildingD+spacing) + buildingD/2
+			b, ok := f.building(block, u, v, buildingW, buildingD, stories)
+			if !ok {
 				continue
 			}
-			sqm := buildingW * buildingD * float64(stories) * 0.80 // 80% usable
-			buildings = append(buildings, Building{
-				ID:            fmt.Sprintf("bldg_%05d", *idx),
-				PodID:         pod.ID,
-				Type:          "commercial",
-				Position:      [3]float64{pos.X, 0, pos.Z},
-				Footprint:     [2]float64{buildingW, buildingD},
-				Stories:       stories,
-				CommercialSqM: sqm,
-			})
+			b.ID = fmt.Sprintf("bldg_%05d", *idx)
+			b.PodID = pod.ID
+			b.Type = "commercial"
+			b.setFloors(commercialFloors(buildingW, buildingD, stories)...)
+			buildings = append(buildings, b)
 			*idx++
 		}
 	}
@@ -312,64 +376,136 @@ func placeCommercialOnBlock(block Block, pod Pod, rings []spec.RingDef, idx *int
 	return buildings
 }
 
-// placeServiceAtZone places a service building within a zone when no blocks
-// are available, using the zone centroid with an offset for each service.
-func placeServiceAtZone(zone Zone, pod Pod, serviceType string, index int, rings []spec.RingDef, idx *int) Building {
-	fp, ok := serviceFootprints[serviceType]
-	if !ok {
-		fp = [2]float64{25, 20}
+// serviceSearchStepM is the step along the civic zone's arc at which
+// service building positions are tried.
+const serviceSearchStepM = 2.0
+
+// placeServicesInZone places a pod's service buildings in the civic zone,
+// bypassing block subdivision since civic zones can be narrow. Each
+// building stands on the arc through the middle of the zone, its width
+// along the arc, at the position nearest the middle of the zone that keeps
+// it inside the pod and clear of the paths and of the buildings placed so
+// far, services included. Failing that it only keeps clear of the paths
+// and buildings, and then only of the buildings, searching the whole arc.
+func placeServicesInZone(zone Zone, pod Pod, services []string, paths []PathSegment, placed []Building, env spec.HeightEnvelope, idx *int) []Building {
+	rMin, rMax, aMin, aMax := polarExtent(zone.Polygon)
+	radius := (rMin + rMax) / 2
+	if radius < 1 {
+		return nil
 	}
+	mid := (aMin + aMax) / 2
+	podPoly := pod.BoundaryPolygon()
 
-	centroid := zone.Polygon.Centroid()
-	// Offset each service building to avoid overlap.
-	offset := float64(index) * 40.0
-	outward := centroid.Normalize()
-	if centroid.Length() < 1 {
-		outward = geo.Pt(1, 0)
+	var pathRects, buildingRects []geo.Rect
+	for _, p := range paths {
+		if p.Start.Distance(p.End) > 0 {
+			pathRects = append(pathRects, geo.SegmentRect(p.Start, p.End, p.WidthM))
+		}
 	}
-	pos := centroid.Add(outward.Perp().Scale(offset - float64(index)*20))
-
-	dist := pos.Distance(geo.Origin)
-	stories := MaxStoriesFromRings(dist, rings)
-
-	switch serviceType {
-	case "hospital":
-		// Use full height.
-	case "elementary_school", "secondary_school":
-		if stories > 3 {
-			stories = 3
+	for _, b := range placed {
+		buildingRects = append(buildingRects, BuildingRect(b))
+	}
+	clearOf := func(r geo.Rect, others []geo.Rect, setback float64) bool {
+		for _, o := range others {
+			if r.Overlaps(o, 0) || r.Distance(o) < setback+contactTolM {
+				return false
+			}
 		}
-	case "playground":
-		stories = 1
-	default:
-		if stories > 4 {
-			stories = 4
+		return true
+	}
+	inPod := func(r geo.Rect) bool {
+		for _, c := range r.Corners() {
+			if !podPoly.Contains(c) {
+				return false
+			}
 		}
+		return true
+	}
+	rectAt := func(a, w, d float64) geo.Rect {
+		return geo.Rect{Center: geo.Pt(radius*math.Cos(a), radius*math.Sin(a)), Width: w, Depth: d, Rotation: a + math.Pi/2}
 	}
 
-	b := Building{
-		ID:          fmt.Sprintf("bldg_%05d", *idx),
-		PodID:       pod.ID,
-		Type:        "civic",
-		Position:    [3]float64{pos.X, 0, pos.Z},
-		Footprint:   [2]float64{fp[0], fp[1]},
-		Stories:     stories,
-		ServiceType: serviceType,
+	var out []Building
+	for _, svc := range services {
+		fp, ok := serviceFootprints[svc]
+		if !ok {
+			fp = [2]float64{25, 20}
+		}
+		// The zone's arc first, then the rest of the circle.
+		zoneSteps := int((aMax - aMin) / 2 * radius / serviceSearchStepM)
+		allSteps := int(math.Pi * radius / serviceSearchStepM)
+		var rect geo.Rect
+		found := false
+		for pass := 0; pass < 3 && !found; pass++ {
+			steps := zoneSteps
+			if pass == 2 {
+				steps = allSteps
+			}
+			for k := 0; k <= 2*steps && !found; k++ {
+				// Offsets 0, +1, -1, +2, -2, … steps along the arc.
+				off := float64((k+1)/2) * serviceSearchStepM
+				if k%2 == 0 {
+					off = -off
+				}
+				r := rectAt(mid+off/radius, fp[0], fp[1])
+				if !clearOf(r, buildingRects, buildingSetbackM) {
+					continue
+				}
+				if pass < 2 && !clearOf(r, pathRects, featureSetbackM) {
+					continue
+				}
+				if pass == 0 && !inPod(r) {
+					continue
+				}
+				rect, found = r, true
+			}
+		}
+		if !found {
+			continue
+		}
+		buildingRects = append(buildingRects, rect)
+
+		stories := env.MaxStories(rect.Center.Length())
+		switch svc {
+		case "hospital":
+			// Use full height.
+		case "elementary_school", "secondary_school":
+			if stories > 3 {
+				stories = 3
+			}
+		case "playground":
+			stories = 1
+		default:
+			if stories > 4 {
+				stories = 4
+			}
+		}
+
+		b := Building{
+			ID:          fmt.Sprintf("bldg_%05d", *idx),
+			PodID:       pod.ID,
+			Type:        "civic",
+			Position:    [3]float64{rect.Center.X, 0, rect.Center.Z},
+			Footprint:   [2]float64{fp[0], fp[1]},
+			Rotation:    rect.Rotation,
+			ServiceType: svc,
+		}
+		b.setFloors(floorRun(UseCivic, fp[0], fp[1], stories))
+		out = append(out, b)
+		*idx++
 	}
-	*idx++
-	return b
+	return out
 }
 
 // placeServiceBuilding places a civic/service building on a block.
-func placeServiceBuilding(block Block, pod Pod, serviceType string, rings []spec.RingDef, idx *int) Building {
+func placeServiceBuilding(block Block, pod Pod, serviceType string, env spec.HeightEnvelope, idx *int) Building {
 	fp, ok := serviceFootprints[serviceType]
 	if !ok {
 		fp = [2]float64{25, 20}
 	}
 
 	centroid := block.Polygon.Centroid()
-	dist := centroid.Distance(geo.Origin)
-	stories := MaxStoriesFromRings(dist, rings)
+	stories := env.MaxStories(centroid.Distance(geo.Origin))
 
 	// Service buildings are typically shorter.
 	switch serviceType {
@@ -393,9 +529,9 @@ func placeServiceBuilding(block Block, pod Pod, serviceType string, rings []spec
 		Type:        "civic",
 		Position:    [3]float64{centroid.X, 0, centroid.Z},
 		Footprint:   [2]float64{fp[0], fp[1]},
-		Stories:     stories,
 		ServiceType: serviceType,
 	}
+	b.setFloors(floorRun(UseCivic, fp[0], fp[1], stories))
 	*idx++
 	return b
 }
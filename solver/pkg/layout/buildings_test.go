@@ -1,7 +1,12 @@
 package layout
 
 import (
+	"context"
+	"math"
+	"reflect"
 	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 )
 
 func TestPlaceBuildingsProducesResults(t *testing.T) {
@@ -94,29 +99,6 @@ func TestPlaceBuildingsAllPodsHaveBuildings(t *testing.T) {
 	}
 }
 
-func TestEnvelopeMaxStories(t *testing.T) {
-	// With contiguous rings, each ring returns its flat max_stories value.
-	// center (0-300): 20, middle (300-600): 10, edge (600-900): 4
-	tests := []struct {
-		dist     float64
-		expected int
-	}{
-		{0, 20},
-		{150, 20},
-		{300, 20},
-		{450, 10},
-		{600, 10},
-		{750, 4},
-		{900, 4},
-	}
-	for _, tt := range tests {
-		got := MaxStories(tt.dist, 20, 10, 4)
-		if got != tt.expected {
-			t.Errorf("MaxStories(%f) = %d, want %d", tt.dist, got, tt.expected)
-		}
-	}
-}
-
 func TestDistributeUnits(t *testing.T) {
 	params := defaultParams()
 	mix := DistributeUnits(params.TotalHouseholds, params.Cohorts)
@@ -124,3 +106,97 @@ func TestDistributeUnits(t *testing.T) {
 		t.Errorf("unit mix total %d != target %d", mix.Total(), params.TotalHouseholds)
 	}
 }
+
+func TestAllocateUnitMix(t *testing.T) {
+	share := UnitMix{Studios: 10, OneBed: 30, TwoBed: 15, ThreeBed: 25, FourBed: 20}
+	buildings := []Building{
+		{Type: "residential", DwellingUnits: 7},
+		{Type: "commercial"},
+		{Type: "residential", DwellingUnits: 1},
+		{Type: "residential", DwellingUnits: 40},
+		{Type: "residential", DwellingUnits: 1},
+		{Type: "residential", DwellingUnits: 13},
+	}
+	allocateUnitMix(buildings, share)
+
+	var sum UnitMix
+	totalDU := 0
+	for _, b := range buildings {
+		if b.Type != "residential" {
+			if b.UnitMix != nil {
+				t.Errorf("%s building has a unit mix", b.Type)
+			}
+			continue
+		}
+		if b.UnitMix == nil || b.UnitMix.Total() != b.DwellingUnits {
+			t.Fatalf("building with %d units has mix %+v", b.DwellingUnits, b.UnitMix)
+		}
+		// Families are spread by size, not collected in one building.
+		want := float64(b.DwellingUnits) * float64(share.Family()) / float64(share.Total())
+		if math.Abs(float64(b.UnitMix.Family())-want) > 2 {
+			t.Errorf("building with %d units has %d family units, want about %.1f", b.DwellingUnits, b.UnitMix.Family(), want)
+		}
+		sum = sum.Add(*b.UnitMix)
+		totalDU += b.DwellingUnits
+	}
+	got, wantMix := sum.counts(), share.counts()
+	for i := range got {
+		want := float64(totalDU) * float64(wantMix[i]) / float64(share.Total())
+		if math.Abs(float64(got[i])-want) > 1 {
+			t.Errorf("unit type %d: %d units, want %.1f", i, got[i], want)
+		}
+	}
+}
+
+func TestPlaceBuildingsUnitMixPerPod(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	params.Cohorts = []analytics.CohortBreakdown{
+		{Name: "singles", Households: 5000},
+		{Name: "couples", Households: 4000},
+		{Name: "families_young", Households: 5000},
+		{Name: "families_teen", Households: 3000},
+		{Name: "empty_nest", Households: 2296},
+		{Name: "retirees", Households: 2000},
+	}
+	cityMix := DistributeUnits(params.TotalHouseholds, params.Cohorts)
+	familyShare := float64(cityMix.Family()) / float64(cityMix.Total())
+
+	pods, adjacency, _ := LayoutPods(s, params)
+	buildings, _, _ := PlaceBuildings(s, pods, adjacency, params)
+
+	byPod := map[string]UnitMix{}
+	for _, b := range buildings {
+		if b.Type != "residential" {
+			continue
+		}
+		if b.UnitMix == nil || b.UnitMix.Total() != b.DwellingUnits {
+			t.Fatalf("building %s with %d units has mix %+v", b.ID, b.DwellingUnits, b.UnitMix)
+		}
+		byPod[b.PodID] = byPod[b.PodID].Add(*b.UnitMix)
+	}
+	for id, mix := range byPod {
+		got := float64(mix.Family()) / float64(mix.Total())
+		if math.Abs(got-familyShare) > 0.01 {
+			t.Errorf("%s: family share %.3f, want %.3f", id, got, familyShare)
+		}
+	}
+}
+
+func TestPlaceBuildingsParallelMatchesSerial(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+
+	sb, sp, sr, _ := placeBuildingsWith(context.Background(), s, pods, adjacency, params, 1, nil)
+	pb, pp, pr, _ := placeBuildingsWith(context.Background(), s, pods, adjacency, params, 8, nil)
+	if !reflect.DeepEqual(sb, pb) {
+		t.Error("parallel buildings differ from serial")
+	}
+	if !reflect.DeepEqual(sp, pp) {
+		t.Error("parallel paths differ from serial")
+	}
+	if !reflect.DeepEqual(sr, pr) {
+		t.Error("parallel report differs from serial")
+	}
+}
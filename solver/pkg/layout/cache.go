@@ -0,0 +1,211 @@
+package layout
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// PlacementCache remembers per-pod building placements and per-source tree
+// placements between solves, so pods whose inputs did not change are not
+// placed again. The zero value is not usable; call NewPlacementCache.
+// A cache may be shared by sequential solves but not concurrent ones.
+type PlacementCache struct {
+	mu    sync.Mutex
+	pods  map[string]cachedPod
+	trees map[string]cachedTrees
+
+	podsReused, podsPlaced int
+}
+
+// cachedPod pairs a pod placement with the inputs that produced it.
+type cachedPod struct {
+	key       podKey
+	placement podPlacement
+}
+
+// cachedTrees pairs the trees of one zone, path or plaza with its source.
+type cachedTrees struct {
+	source any
+	trees  []Tree
+}
+
+// podKey captures every input placePod reads for one pod.
+type podKey struct {
+	pod             Pod
+	envelope        spec.HeightEnvelope // restricted to rings that reach the pod
+	ringRadii       [2]float64
+	assignment      spec.PodRing
+	hasAssignment   bool
+	typologies      []string
+	adjCenters      map[string]geo.Point2D
+	totalPopulation int
+	// households and cohortHouseholds are what DistributeUnits reads:
+	// they set the pod's share of the city mix and the unit mix of each
+	// of its buildings.
+	households       int
+	cohortHouseholds map[string]int
+}
+
+// NewPlacementCache returns an empty cache.
+func NewPlacementCache() *PlacementCache {
+	return &PlacementCache{
+		pods:  make(map[string]cachedPod),
+		trees: make(map[string]cachedTrees),
+	}
+}
+
+// PodStats reports how many pods the last PlaceBuildingsCached call reused
+// from the cache and how many it placed from scratch.
+func (c *PlacementCache) PodStats() (reused, placed int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.podsReused, c.podsPlaced
+}
+
+// PlaceBuildingsCached is PlaceBuildings with per-pod reuse: a pod whose
+// inputs match its cached placement is not placed again. Entries for pods
+// not seen in this call are dropped. Output is identical to PlaceBuildings.
+func PlaceBuildingsCached(s *spec.CitySpec, pods []Pod, adjacency map[string][]string, params *analytics.ResolvedParameters, cache *PlacementCache) ([]Building, []PathSegment, *validation.Report) {
+	buildings, paths, report, _ := placeBuildingsWith(context.Background(), s, pods, adjacency, params, 0, cache)
+	return buildings, paths, report
+}
+
+// PlaceBuildingsCachedContext is PlaceBuildingsCached that stops once ctx
+// is done, returning ctx's error and leaving the cache unchanged.
+func PlaceBuildingsCachedContext(ctx context.Context, s *spec.CitySpec, pods []Pod, adjacency map[string][]string, params *analytics.ResolvedParameters, cache *PlacementCache) ([]Building, []PathSegment, *validation.Report, error) {
+	return placeBuildingsWith(ctx, s, pods, adjacency, params, 0, cache)
+}
+
+// PlaceTreesCached is PlaceTrees with reuse of the trees generated for each
+// unchanged green zone, path and plaza. Output is identical to PlaceTrees.
+func PlaceTreesCached(
+	pods []Pod,
+	greenZones []Zone,
+	paths []PathSegment,
+	bikePaths []BikePath,
+	plazas []Plaza,
+	cache *PlacementCache,
+) ([]Tree, *validation.Report) {
+	trees, report, _ := placeTreesWith(context.Background(), pods, greenZones, paths, bikePaths, plazas, 0, cache)
+	return trees, report
+}
+
+// PlaceTreesCachedContext is PlaceTreesCached that stops once ctx is done,
+// returning ctx's error and leaving the cache unchanged.
+func PlaceTreesCachedContext(
+	ctx context.Context,
+	pods []Pod,
+	greenZones []Zone,
+	paths []PathSegment,
+	bikePaths []BikePath,
+	plazas []Plaza,
+	cache *PlacementCache,
+) ([]Tree, *validation.Report, error) {
+	return placeTreesWith(ctx, pods, greenZones, paths, bikePaths, plazas, 0, cache)
+}
+
+// newPodKey builds the cache key for one pod.
+func newPodKey(s *spec.CitySpec, pod Pod, adjCenters map[string]geo.Point2D, ringRadii map[string][2]float64, params *analytics.ResolvedParameters) podKey {
+	k := podKey{
+		pod:              pod,
+		envelope:         s.HeightEnvelope(),
+		ringRadii:        ringRadii[pod.Ring],
+		adjCenters:       adjCenters,
+		totalPopulation:  params.TotalPopulation,
+		households:       params.TotalHouseholds,
+		cohortHouseholds: make(map[string]int, len(params.Cohorts)),
+	}
+	for _, c := range params.Cohorts {
+		k.cohortHouseholds[c.Name] += c.Households
+	}
+	// A step envelope only reads nearby rings; other profiles are one
+	// curve across the city.
+	k.envelope.Rings = envelopeRings(pod, k.envelope.Rings)
+	k.assignment, k.hasAssignment = s.Pods.RingAssignments[pod.Ring]
+	k.typologies = typologyNames(s, k.assignment.Character)
+	return k
+}
+
+// envelopeRings returns the rings a step envelope can consult for any
+// point of the pod: rings overlapping the pod's radial extent, both sides of
+// any gap it spans, and the outermost ring if the pod reaches outside them.
+// The extent is taken from the pod's circumscribing circle, which may
+// include a ring too many but never one too few.
+func envelopeRings(pod Pod, rings []spec.RingDef) []spec.RingDef {
+	if len(rings) == 0 {
+		return nil
+	}
+	c := pod.CenterPoint()
+	dist := math.Hypot(c.X, c.Z)
+	reach := 0.0
+	for _, b := range pod.Boundary {
+		reach = math.Max(reach, math.Hypot(b[0]-c.X, b[1]-c.Z))
+	}
+	lo, hi := math.Max(0, dist-reach), dist+reach
+
+	use := make([]bool, len(rings))
+	for i, r := range rings {
+		if r.RadiusFrom <= hi && r.RadiusTo >= lo {
+			use[i] = true
+		}
+		if i+1 < len(rings) && r.RadiusTo < hi && rings[i+1].RadiusFrom > lo {
+			use[i], use[i+1] = true, true
+		}
+	}
+	// Distances outside every ring and gap fall back to the outermost ring.
+	if lo < rings[0].RadiusFrom || hi > rings[len(rings)-1].RadiusTo {
+		use[len(rings)-1] = true
+	}
+
+	var out []spec.RingDef
+	for i, r := range rings {
+		if use[i] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// lookupPod returns the cached placement for a pod if its key matches.
+func (c *PlacementCache) lookupPod(key podKey) (podPlacement, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.pods[key.pod.ID]
+	if !ok || !reflect.DeepEqual(e.key, key) {
+		return podPlacement{}, false
+	}
+	return e.placement, true
+}
+
+// storePods replaces the pod cache with this call's entries.
+func (c *PlacementCache) storePods(entries map[string]cachedPod, reused int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pods = entries
+	c.podsReused, c.podsPlaced = reused, len(entries)-reused
+}
+
+// lookupTrees returns the cached trees for a source if it is unchanged.
+func (c *PlacementCache) lookupTrees(key string, source any) ([]Tree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.trees[key]
+	if !ok || !reflect.DeepEqual(e.source, source) {
+		return nil, false
+	}
+	return e.trees, true
+}
+
+// storeTrees replaces the tree cache with this call's entries.
+func (c *PlacementCache) storeTrees(entries map[string]cachedTrees) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trees = entries
+}
@@ -0,0 +1,93 @@
+package layout
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlaceBuildingsCachedMatchesUncached(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	cache := NewPlacementCache()
+
+	PlaceBuildingsCached(s, pods, adjacency, params, cache)
+	if reused, placed := cache.PodStats(); reused != 0 || placed != len(pods) {
+		t.Errorf("cold cache: reused %d placed %d, want 0 and %d", reused, placed, len(pods))
+	}
+
+	b, p, _ := PlaceBuildingsCached(s, pods, adjacency, params, cache)
+	if reused, _ := cache.PodStats(); reused != len(pods) {
+		t.Errorf("warm cache reused %d pods, want %d", reused, len(pods))
+	}
+	wb, wp, _ := PlaceBuildings(s, pods, adjacency, params)
+	if !reflect.DeepEqual(b, wb) || !reflect.DeepEqual(p, wp) {
+		t.Fatal("cached placement differs from uncached")
+	}
+
+	// Raising the edge ring's height limit must re-place edge pods only.
+	s.CityZones.Rings[2].MaxStories = 6
+	b, p, _ = PlaceBuildingsCached(s, pods, adjacency, params, cache)
+	wb, wp, _ = PlaceBuildings(s, pods, adjacency, params)
+	if !reflect.DeepEqual(b, wb) || !reflect.DeepEqual(p, wp) {
+		t.Fatal("placement after ring change differs from uncached")
+	}
+	reused, placed := cache.PodStats()
+	if reused == 0 || placed == 0 {
+		t.Errorf("after ring change reused %d placed %d, want both non-zero", reused, placed)
+	}
+}
+
+func TestPlaceTreesCachedMatchesUncached(t *testing.T) {
+	pods, adjacency, rings := bikeTestPods(t)
+	s := defaultBikeSpec()
+
+	greenZones := CollectGreenZones(s, pods)
+	_, paths, _ := PlaceBuildings(s, pods, adjacency, defaultBikeParams())
+	bikePaths, _ := GenerateBikePaths(pods, adjacency, rings)
+	plazas, _ := GeneratePlazas(pods, s)
+	cache := NewPlacementCache()
+
+	PlaceTreesCached(pods, greenZones, paths, bikePaths, plazas, cache)
+	paths = paths[1:]
+	got, _ := PlaceTreesCached(pods, greenZones, paths, bikePaths, plazas, cache)
+	want, _ := PlaceTrees(pods, greenZones, paths, bikePaths, plazas)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal("cached trees differ from uncached after removing a path")
+	}
+}
+
+func TestEnvelopeRings(t *testing.T) {
+	rings := defaultSpec().CityZones.Rings
+	pod := Pod{Center: [2]float64{750, 0}, Boundary: [][2]float64{{700, -50}, {800, -50}, {800, 50}, {700, 50}}}
+	got := envelopeRings(pod, rings)
+	if len(got) != 1 || got[0].Name != "edge" {
+		t.Errorf("edge pod envelope = %v, want only edge ring", got)
+	}
+
+	pod = Pod{Center: [2]float64{300, 0}, Boundary: [][2]float64{{250, 0}, {350, 0}}}
+	got = envelopeRings(pod, rings)
+	if len(got) != 2 {
+		t.Errorf("boundary pod envelope = %v, want center and middle", got)
+	}
+}
+
+func TestPlaceBuildingsCanceledLeavesCache(t *testing.T) {
+	s := defaultSpec()
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	cache := NewPlacementCache()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b, _, _, err := PlaceBuildingsCachedContext(ctx, s, pods, adjacency, params, cache)
+	if !errors.Is(err, context.Canceled) || b != nil {
+		t.Fatalf("canceled placement = %d buildings, %v; want none, context.Canceled", len(b), err)
+	}
+	PlaceBuildingsCached(s, pods, adjacency, params, cache)
+	if reused, placed := cache.PodStats(); reused != 0 || placed != len(pods) {
+		t.Errorf("after a canceled run: reused %d placed %d, want 0 and %d", reused, placed, len(pods))
+	}
+}
@@ -0,0 +1,370 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// Conflict kinds found by CheckPlacement.
+const (
+	ConflictOverlap  = "overlap"    // footprints intersect
+	ConflictSetback  = "setback"    // closer than the required clearance
+	ConflictOutOfPod = "out_of_pod" // footprint leaves the building's pod
+)
+
+const (
+	buildingSetbackM = 2.0  // clearance between detached buildings
+	featureSetbackM  = 1.0  // clearance from paths, plazas and sports fields
+	contactTolM      = 0.01 // overlap or gap small enough to count as touching
+	nudgeStepM       = 1.0  // fix-up moves buildings in steps of this size
+	maxNudgeM        = 6.0  // and no further than this
+	indexCellM       = 50.0 // spatial index grid size
+)
+
+// Conflict is a building that overlaps or crowds another entity, or leaves
+// its pod.
+type Conflict struct {
+	Kind  string `json:"kind"`
+	PodID string `json:"pod_id"` // the building's pod
+	// EntityIDs are the building's ID and the ID of the entity it
+	// conflicts with: a building, path, plaza, sports field or pod.
+	EntityIDs  []string `json:"entity_ids"`
+	ClearanceM float64  `json:"clearance_m"` // zero for overlaps and out-of-pod buildings
+}
+
+// footprint is an entity in the placement index. Ground features have
+// building -1 and no height.
+type footprint struct {
+	id       string
+	rect     geo.Rect
+	building int
+	y0, y1   float64
+	setback  float64
+	removed  bool
+}
+
+// placementIndex indexes building footprints and the ground features
+// they must keep clear of.
+type placementIndex struct {
+	grid  *geo.GridIndex
+	items []footprint
+	pods  map[string]geo.Polygon
+	// byBuilding maps a building index to its item, and supports to the
+	// buildings standing on it, such as towers on a podium.
+	byBuilding []int
+	supports   map[int][]int
+}
+
+// BuildingRect returns a building's footprint as an oriented rectangle.
+func BuildingRect(b Building) geo.Rect {
+	return geo.Rect{
+		Center:   geo.Pt(b.Position[0], b.Position[2]),
+		Width:    b.Footprint[0],
+		Depth:    b.Footprint[1],
+		Rotation: b.Rotation,
+	}
+}
+
+func newPlacementIndex(pods []Pod, buildings []Building, paths []PathSegment, plazas []Plaza, fields []SportsField) *placementIndex {
+	ix := &placementIndex{
+		grid:       geo.NewGridIndex(indexCellM),
+		pods:       make(map[string]geo.Polygon, len(pods)),
+		byBuilding: make([]int, len(buildings)),
+		supports:   make(map[int][]int),
+	}
+	for _, p := range pods {
+		ix.pods[p.ID] = p.BoundaryPolygon()
+	}
+	for i, b := range buildings {
+		top := b.Position[1] + float64(b.Stories)*storyHeightM
+		ix.byBuilding[i] = ix.add(footprint{id: b.ID, rect: BuildingRect(b), building: i, y0: b.Position[1], y1: top, setback: buildingSetbackM})
+	}
+	for _, p := range paths {
+		if p.Start.Distance(p.End) > 0 {
+			ix.add(footprint{id: p.ID, rect: geo.SegmentRect(p.Start, p.End, p.WidthM), building: -1, setback: featureSetbackM})
+		}
+	}
+	for _, p := range plazas {
+		ix.add(footprint{id: p.ID, rect: geo.Rect{Center: p.Position, Width: p.Width, Depth: p.Depth, Rotation: p.Rotation}, building: -1, setback: featureSetbackM})
+	}
+	for _, f := range fields {
+		ix.add(footprint{id: f.ID, rect: geo.Rect{Center: f.Position, Width: f.Dimensions[0], Depth: f.Dimensions[1], Rotation: f.Rotation}, building: -1, setback: featureSetbackM})
+	}
+
+	// A raised building stands on the building whose roof is at its base.
+	for i := range buildings {
+		item := ix.items[ix.byBuilding[i]]
+		if item.y0 <= 0 {
+			continue
+		}
+		for _, j := range ix.query(item.rect, 0) {
+			other := ix.items[j]
+			if other.building >= 0 && other.building != i && math.Abs(other.y1-item.y0) < contactTolM && item.rect.Overlaps(other.rect, contactTolM) {
+				ix.supports[other.building] = append(ix.supports[other.building], i)
+				break
+			}
+		}
+	}
+	return ix
+}
+
+func (ix *placementIndex) add(f footprint) int {
+	min, max := f.rect.Bounds()
+	ix.grid.Insert(min, max)
+	ix.items = append(ix.items, f)
+	return len(ix.items) - 1
+}
+
+// query returns the items whose bounds come within margin of r.
+func (ix *placementIndex) query(r geo.Rect, margin float64) []int {
+	min, max := r.Bounds()
+	m := geo.Pt(margin, margin)
+	return ix.grid.Query(min.Sub(m), max.Add(m))
+}
+
+// conflicts returns building i's conflicts. With pairwise set, conflicts
+// with other buildings are only returned from the lower-numbered building,
+// so each is found once.
+func (ix *placementIndex) conflicts(i int, podID string, pairwise bool) []Conflict {
+	item := ix.items[ix.byBuilding[i]]
+	if item.removed {
+		return nil
+	}
+	var out []Conflict
+	if pod, ok := ix.pods[podID]; ok {
+		for _, c := range item.rect.Corners() {
+			if !pod.Contains(c) {
+				out = append(out, Conflict{Kind: ConflictOutOfPod, PodID: podID, EntityIDs: []string{item.id, podID}})
+				break
+			}
+		}
+	}
+	for _, j := range ix.query(item.rect, buildingSetbackM) {
+		other := ix.items[j]
+		if other.removed || other.building == i {
+			continue
+		}
+		if other.building >= 0 {
+			if pairwise && other.building < i {
+				continue
+			}
+			// Buildings at different heights, such as towers on their
+			// podium, do not conflict.
+			if item.y0 >= other.y1-contactTolM || other.y0 >= item.y1-contactTolM {
+				continue
+			}
+		} else if item.y0 > 0 {
+			continue // raised above ground features
+		}
+
+		if item.rect.Overlaps(other.rect, contactTolM) {
+			out = append(out, Conflict{Kind: ConflictOverlap, PodID: podID, EntityIDs: []string{item.id, other.id}})
+			continue
+		}
+		d := item.rect.Distance(other.rect)
+		// Buildings that touch share a wall, as the wings of a perimeter
+		// block do.
+		attached := other.building >= 0 && d < contactTolM
+		if !attached && d < other.setback-contactTolM {
+			out = append(out, Conflict{Kind: ConflictSetback, PodID: podID, EntityIDs: []string{item.id, other.id}, ClearanceM: d})
+		}
+	}
+	return out
+}
+
+// move shifts building i and the buildings standing on it by d.
+func (ix *placementIndex) move(i int, d geo.Point2D) {
+	k := ix.byBuilding[i]
+	ix.items[k].rect = ix.items[k].rect.Translate(d)
+	min, max := ix.items[k].rect.Bounds()
+	ix.grid.Move(k, min, max)
+	for _, up := range ix.supports[i] {
+		ix.move(up, d)
+	}
+}
+
+// remove drops building i and the buildings standing on it.
+func (ix *placementIndex) remove(i int) {
+	ix.items[ix.byBuilding[i]].removed = true
+	for _, up := range ix.supports[i] {
+		ix.remove(up)
+	}
+}
+
+// stack returns building i and the buildings standing on it.
+func (ix *placementIndex) stack(i int) []int {
+	out := []int{i}
+	for _, up := range ix.supports[i] {
+		out = append(out, ix.stack(up)...)
+	}
+	return out
+}
+
+// CheckPlacement checks every building against the others, the paths,
+// plazas and sports fields, and its pod's boundary, and reports each
+// conflict as a spatial warning naming the entities involved.
+func CheckPlacement(pods []Pod, buildings []Building, paths []PathSegment, plazas []Plaza, fields []SportsField) ([]Conflict, *validation.Report) {
+	ix := newPlacementIndex(pods, buildings, paths, plazas, fields)
+	var conflicts []Conflict
+	for i, b := range buildings {
+		conflicts = append(conflicts, ix.conflicts(i, b.PodID, true)...)
+	}
+	return conflicts, conflictReport(conflicts)
+}
+
+// FixPlacement resolves conflicts by nudging each offending building, with
+// anything standing on it, up to maxNudgeM along its own axes to the
+// nearest clear position, or removing it if there is none. It returns the
+// fixed buildings and a report of what moved and what was removed, along
+// with any conflicts left.
+func FixPlacement(pods []Pod, buildings []Building, paths []PathSegment, plazas []Plaza, fields []SportsField) ([]Building, *validation.Report) {
+	ix := newPlacementIndex(pods, buildings, paths, plazas, fields)
+	offsets := nudgeOffsets()
+	moved := make(map[int]geo.Point2D)
+	removed := make(map[int]bool)
+
+	clear := func(stack []int) bool {
+		for _, k := range stack {
+			if len(ix.conflicts(k, buildings[k].PodID, false)) > 0 {
+				return false
+			}
+		}
+		return true
+	}
+	var movedIDs, removedIDs []string
+	removedDU := 0
+	for i, b := range buildings {
+		// Raised buildings move and go with the building they stand on.
+		if removed[i] || b.Position[1] > 0 || len(ix.conflicts(i, b.PodID, false)) == 0 {
+			continue
+		}
+		stack := ix.stack(i)
+		u, v := BuildingRect(b).Axes()
+		fixed := false
+		for _, o := range offsets {
+			d := u.Scale(o[0]).Add(v.Scale(o[1]))
+			ix.move(i, d)
+			if clear(stack) {
+				for _, k := range stack {
+					moved[k] = d
+				}
+				movedIDs = append(movedIDs, b.ID)
+				fixed = true
+				break
+			}
+			ix.move(i, d.Scale(-1))
+		}
+		if !fixed {
+			ix.remove(i)
+			for _, k := range stack {
+				removed[k] = true
+				removedIDs = append(removedIDs, buildings[k].ID)
+				removedDU += buildings[k].DwellingUnits
+			}
+		}
+	}
+
+	out := make([]Building, 0, len(buildings)-len(removed))
+	for i, b := range buildings {
+		if removed[i] {
+			continue
+		}
+		if d, ok := moved[i]; ok {
+			b.Position[0] += d.X
+			b.Position[2] += d.Z
+		}
+		out = append(out, b)
+	}
+
+	report := validation.NewReport()
+	if len(movedIDs) > 0 {
+		report.AddInfo(validation.Result{
+			Level:     validation.LevelSpatial,
+			Message:   fmt.Sprintf("collision fix-up moved %d buildings up to %.0f m", len(movedIDs), maxNudgeM),
+			EntityIDs: movedIDs,
+		})
+	}
+	if len(removedIDs) > 0 {
+		report.AddWarning(validation.Result{
+			Level:     validation.LevelSpatial,
+			Message:   fmt.Sprintf("collision fix-up removed %d buildings (%d dwelling units) with no clear position nearby", len(removedIDs), removedDU),
+			EntityIDs: removedIDs,
+		})
+	}
+	// A removed service leaves its pod without it, so each is named.
+	for i, b := range buildings {
+		if removed[i] && b.ServiceType != "" {
+			report.AddWarning(validation.Result{
+				Level:     validation.LevelSpatial,
+				Message:   fmt.Sprintf("%s: collision fix-up removed %s %s", b.PodID, b.ServiceType, b.ID),
+				EntityIDs: []string{b.ID},
+			})
+		}
+	}
+	_, rep := CheckPlacement(pods, out, paths, plazas, fields)
+	report.Merge(rep)
+	return out, report
+}
+
+// nudgeOffsets returns the moves fix-up tries along a building's width
+// and depth axes, nearest first.
+func nudgeOffsets() [][2]float64 {
+	var out [][2]float64
+	for d := nudgeStepM; d <= maxNudgeM+1e-9; d += nudgeStepM {
+		out = append(out, [2]float64{0, d}, [2]float64{0, -d}, [2]float64{d, 0}, [2]float64{-d, 0})
+	}
+	return out
+}
+
+// conflictReport reports the conflicts as one warning per pod and kind,
+// naming the buildings and the entities they conflict with.
+func conflictReport(conflicts []Conflict) *validation.Report {
+	type group struct {
+		podID, kind string
+		buildings   []string
+		others      []string
+		seen        map[string]bool
+	}
+	var groups []*group
+	byKey := make(map[[2]string]*group)
+	for _, c := range conflicts {
+		key := [2]string{c.PodID, c.Kind}
+		g := byKey[key]
+		if g == nil {
+			g = &group{podID: c.PodID, kind: c.Kind, seen: make(map[string]bool)}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		if id := c.EntityIDs[0]; !g.seen[id] {
+			g.seen[id] = true
+			g.buildings = append(g.buildings, id)
+		}
+		if id := c.EntityIDs[1]; !g.seen[id] && c.Kind != ConflictOutOfPod {
+			g.seen[id] = true
+			g.others = append(g.others, id)
+		}
+	}
+
+	report := validation.NewReport()
+	for _, g := range groups {
+		var msg string
+		switch g.kind {
+		case ConflictOverlap:
+			msg = fmt.Sprintf("%s: %d buildings overlap %d other entities", g.podID, len(g.buildings), len(g.others))
+		case ConflictSetback:
+			msg = fmt.Sprintf("%s: %d buildings are within the setback of %d other entities (%.0f m from buildings, %.0f m from paths, plazas and sports fields)",
+				g.podID, len(g.buildings), len(g.others), buildingSetbackM, featureSetbackM)
+		case ConflictOutOfPod:
+			msg = fmt.Sprintf("%s: %d buildings extend outside the pod", g.podID, len(g.buildings))
+		}
+		report.AddWarning(validation.Result{
+			Level:     validation.LevelSpatial,
+			Message:   msg,
+			EntityIDs: append(g.buildings, g.others...),
+		})
+	}
+	return report
+}
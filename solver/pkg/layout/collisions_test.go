@@ -0,0 +1,130 @@
+package layout
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+)
+
+// squarePod returns a pod covering the square of the given half-size
+// around the origin.
+func squarePod(half float64) Pod {
+	return Pod{
+		ID:       "pod_test",
+		Boundary: [][2]float64{{-half, -half}, {half, -half}, {half, half}, {-half, half}},
+	}
+}
+
+func testBuilding(id string, x, z, w, d float64, stories int) Building {
+	return Building{
+		ID:        id,
+		PodID:     "pod_test",
+		Type:      "residential",
+		Position:  [3]float64{x, 0, z},
+		Footprint: [2]float64{w, d},
+		Stories:   stories,
+	}
+}
+
+func conflictKinds(conflicts []Conflict) map[string][][]string {
+	out := map[string][][]string{}
+	for _, c := range conflicts {
+		out[c.Kind] = append(out[c.Kind], c.EntityIDs)
+	}
+	return out
+}
+
+func TestCheckPlacementFindsConflicts(t *testing.T) {
+	tower := testBuilding("tower", 0, 0, 10, 10, 10)
+	tower.Position[1] = 6
+	buildings := []Building{
+		testBuilding("a", 0, 0, 20, 10, 2),      // podium under the tower
+		tower,                                   // stands on a: no conflict
+		testBuilding("b", 12, 0, 10, 10, 2),     // overlaps a
+		testBuilding("c", -5, 6.5, 8, 2, 2),     // 0.5 m from a: setback
+		testBuilding("d", 0, -10, 20, 10, 2),    // touches a: attached
+		testBuilding("e", 0, 60, 10, 10, 2),     // across the path
+		testBuilding("f", 95, 60, 20, 10, 2),    // leaves the pod
+		testBuilding("g", -60, -60, 10, 10, 2),  // clear
+		testBuilding("h", -60, -68.5, 10, 4, 2), // 1.5 m from g: setback
+	}
+	paths := []PathSegment{{ID: "path", Start: geo.Pt(-20, 60), End: geo.Pt(20, 60), WidthM: 3}}
+
+	conflicts, report := CheckPlacement([]Pod{squarePod(100)}, buildings, paths, nil, nil)
+	kinds := conflictKinds(conflicts)
+	want := map[string][][]string{
+		ConflictOverlap:  {{"a", "b"}, {"e", "path"}},
+		ConflictSetback:  {{"a", "c"}, {"g", "h"}},
+		ConflictOutOfPod: {{"f", "pod_test"}},
+	}
+	for kind, pairs := range want {
+		if len(kinds[kind]) != len(pairs) {
+			t.Errorf("%s conflicts = %v, want %v", kind, kinds[kind], pairs)
+			continue
+		}
+		for i, p := range pairs {
+			if kinds[kind][i][0] != p[0] || kinds[kind][i][1] != p[1] {
+				t.Errorf("%s conflict %d = %v, want %v", kind, i, kinds[kind][i], p)
+			}
+		}
+	}
+	if len(report.Warnings) != 3 {
+		t.Errorf("expected one warning per conflict kind, got %d", len(report.Warnings))
+	}
+	for _, w := range report.Warnings {
+		if len(w.EntityIDs) == 0 {
+			t.Errorf("warning %q names no entities", w.Message)
+		}
+		if !strings.HasPrefix(w.Message, "pod_test: ") {
+			t.Errorf("warning %q does not start with the pod ID", w.Message)
+		}
+	}
+}
+
+func TestFixPlacementNudgesAndRemoves(t *testing.T) {
+	tower := testBuilding("tower", 0, 62, 6, 6, 10)
+	tower.Position[1] = 6
+	buildings := []Building{
+		testBuilding("podium", 0, 62, 12, 6, 2), // 1.5 m onto the path
+		tower,
+		testBuilding("big", -60, 0, 10, 10, 2), // the path runs through it
+		testBuilding("clear", 60, -60, 10, 10, 2),
+		testBuilding("clinic", -60, 25, 10, 10, 2), // also on the path
+	}
+	buildings[4].Type, buildings[4].ServiceType = "civic", "medical_clinic"
+	paths := []PathSegment{
+		{ID: "path", Start: geo.Pt(-20, 58), End: geo.Pt(20, 58), WidthM: 2},
+		{ID: "cross", Start: geo.Pt(-60, -40), End: geo.Pt(-60, 40), WidthM: 8},
+	}
+	pods := []Pod{squarePod(100)}
+
+	fixed, report := FixPlacement(pods, buildings, paths, nil, nil)
+	byID := map[string]Building{}
+	for _, b := range fixed {
+		byID[b.ID] = b
+	}
+	if _, ok := byID["big"]; ok {
+		t.Error("building cut through by a path was not removed")
+	}
+	podium, tw := byID["podium"], byID["tower"]
+	if podium.Position[2] <= 62 {
+		t.Errorf("podium not nudged off the path: %v", podium.Position)
+	}
+	if math.Abs(tw.Position[2]-podium.Position[2]) > 1e-9 || tw.Position[0] != podium.Position[0] {
+		t.Errorf("tower at %v did not move with its podium at %v", tw.Position, podium.Position)
+	}
+	if byID["clear"].Position != buildings[3].Position {
+		t.Error("building without conflicts was moved")
+	}
+	if conflicts, _ := CheckPlacement(pods, fixed, paths, nil, nil); len(conflicts) != 0 {
+		t.Errorf("conflicts remain after fix-up: %v", conflicts)
+	}
+	if len(report.Info) == 0 || len(report.Warnings) != 2 {
+		t.Fatalf("expected a move note, a removal warning and one naming the clinic, got %d info and %d warnings", len(report.Info), len(report.Warnings))
+	}
+	if w := report.Warnings[1]; !strings.Contains(w.Message, "medical_clinic clinic") || len(w.EntityIDs) != 1 || w.EntityIDs[0] != "clinic" {
+		t.Errorf("removed service warning = %q %v", w.Message, w.EntityIDs)
+	}
+}
@@ -1,44 +1,36 @@
 package layout
 
 import (
+	"fmt"
 	"math"
 
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
 )
 
-// MaxStoriesFromRings computes the maximum allowed building height at a given
-// distance from the city center, using ring definitions for the height envelope.
+// Breach is a building that rises above the height envelope.
+type Breach struct {
+	BuildingID string `json:"building_id"`
+	PodID      string `json:"pod_id"`
+	TopStory   int    `json:"top_story"`   // counted from the ground, including any podium
+	MaxStories int    `json:"max_stories"` // the envelope at the building's center
+}
+
+// MaxStoriesFromRings returns the maximum allowed building height at a
+// distance from the city center under the rings' step envelope: the
+// containing ring's max_stories, interpolated across gaps between rings.
 //
-// For distances within a ring, returns that ring's max_stories.
-// For distances between ring boundaries, linearly interpolates.
-// For distances beyond all rings, returns the outermost ring's max_stories.
+// Deprecated: use spec.HeightEnvelope.MaxStories, which also covers the
+// other height profiles.
 func MaxStoriesFromRings(distFromCenter float64, rings []spec.RingDef) int {
-	if len(rings) == 0 {
-		return 1
-	}
-
-	// Find which ring the distance falls in.
-	for _, ring := range rings {
-		if distFromCenter >= ring.RadiusFrom && distFromCenter <= ring.RadiusTo {
-			return ring.MaxStories
-		}
-	}
-
-	// Between rings: interpolate between the two adjacent rings.
-	for i := 0; i < len(rings)-1; i++ {
-		if distFromCenter > rings[i].RadiusTo && distFromCenter < rings[i+1].RadiusFrom {
-			t := (distFromCenter - rings[i].RadiusTo) / (rings[i+1].RadiusFrom - rings[i].RadiusTo)
-			stories := float64(rings[i].MaxStories) + t*(float64(rings[i+1].MaxStories)-float64(rings[i].MaxStories))
-			return int(math.Max(1, math.Floor(stories)))
-		}
-	}
-
-	// Beyond all rings: use outermost ring's value.
-	return rings[len(rings)-1].MaxStories
+	return spec.HeightEnvelope{Profile: spec.ProfileStep, Rings: rings}.MaxStories(distFromCenter)
 }
 
 // MaxStories computes the maximum allowed building height using legacy
-// center/middle/edge parameters. Kept for backward compatibility with tests.
+// center/middle/edge parameters over rings of 300 m each.
+//
+// Deprecated: use spec.HeightEnvelope.MaxStories.
 func MaxStories(distFromCenter float64, maxCenter, maxMiddle, maxEdge int) int {
 	rings := []spec.RingDef{
 		{Name: "center", RadiusFrom: 0, RadiusTo: 300, MaxStories: maxCenter},
@@ -48,8 +40,57 @@ func MaxStories(distFromCenter float64, maxCenter, maxMiddle, maxEdge int) int {
 	return MaxStoriesFromRings(distFromCenter, rings)
 }
 
-// MaxStoriesFromSpec computes maximum stories using the zone definitions
-// from the city spec. Uses linear interpolation between ring boundaries.
+// MaxStoriesFromSpec is MaxStories.
+//
+// Deprecated: use spec.HeightEnvelope.MaxStories.
 func MaxStoriesFromSpec(distFromCenter float64, centerMax, middleMax, edgeMax int) int {
 	return MaxStories(distFromCenter, centerMax, middleMax, edgeMax)
 }
+
+// blockMaxStories returns the lowest envelope height over the block's
+// radial extent, so that no building placed on it breaches the envelope.
+func blockMaxStories(env spec.HeightEnvelope, poly geo.Polygon) int {
+	return env.MinStories(poly.DistanceTo(geo.Origin), poly.MaxDistanceTo(geo.Origin))
+}
+
+// topStory returns the story a building's roof reaches above the ground.
+func topStory(b Building) int {
+	return int(math.Round(b.Position[1]/storyHeightM)) + b.Stories
+}
+
+// CheckEnvelope finds the buildings that rise above the height envelope
+// at their center and reports them as one warning per pod.
+func CheckEnvelope(env spec.HeightEnvelope, buildings []Building) ([]Breach, *validation.Report) {
+	var breaches []Breach
+	for _, b := range buildings {
+		limit := env.MaxStories(math.Hypot(b.Position[0], b.Position[2]))
+		if top := topStory(b); top > limit {
+			breaches = append(breaches, Breach{BuildingID: b.ID, PodID: b.PodID, TopStory: top, MaxStories: limit})
+		}
+	}
+
+	report := validation.NewReport()
+	var pods []string
+	byPod := make(map[string][]Breach)
+	for _, br := range breaches {
+		if _, ok := byPod[br.PodID]; !ok {
+			pods = append(pods, br.PodID)
+		}
+		byPod[br.PodID] = append(byPod[br.PodID], br)
+	}
+	for _, id := range pods {
+		var ids []string
+		worst := 0
+		for _, br := range byPod[id] {
+			ids = append(ids, br.BuildingID)
+			worst = max(worst, br.TopStory-br.MaxStories)
+		}
+		report.AddWarning(validation.Result{
+			Level:     validation.LevelSpatial,
+			Message:   fmt.Sprintf("%s: %d buildings rise above the %s height envelope, by up to %d stories", id, len(ids), env.Profile, worst),
+			SpecPath:  "city.height_profile",
+			EntityIDs: ids,
+		})
+	}
+	return breaches, report
+}
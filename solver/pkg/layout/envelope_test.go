@@ -0,0 +1,105 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+func TestEnvelopeMaxStories(t *testing.T) {
+	// With contiguous rings, each ring returns its flat max_stories value.
+	// center (0-300): 20, middle (300-600): 10, edge (600-900): 4
+	tests := []struct {
+		dist     float64
+		expected int
+	}{
+		{0, 20},
+		{150, 20},
+		{300, 20},
+		{450, 10},
+		{600, 10},
+		{750, 4},
+		{900, 4},
+		{1200, 4},
+	}
+	rings := []spec.RingDef{
+		{Name: "center", RadiusFrom: 0, RadiusTo: 300, MaxStories: 20},
+		{Name: "middle", RadiusFrom: 300, RadiusTo: 600, MaxStories: 10},
+		{Name: "edge", RadiusFrom: 600, RadiusTo: 900, MaxStories: 4},
+	}
+	env := spec.HeightEnvelope{Profile: spec.ProfileStep, Rings: rings}
+	for _, tt := range tests {
+		if got := env.MaxStories(tt.dist); got != tt.expected {
+			t.Errorf("step envelope at %f = %d, want %d", tt.dist, got, tt.expected)
+		}
+		if got := MaxStories(tt.dist, 20, 10, 4); got != tt.expected {
+			t.Errorf("MaxStories(%f) = %d, want %d", tt.dist, got, tt.expected)
+		}
+	}
+}
+
+func TestEnvelopeMaxStoriesAcrossGap(t *testing.T) {
+	// Between rings the step profile interpolates and floors, as the ring
+	// envelope always has.
+	rings := []spec.RingDef{
+		{Name: "center", RadiusFrom: 0, RadiusTo: 300, MaxStories: 20},
+		{Name: "outer", RadiusFrom: 400, RadiusTo: 600, MaxStories: 9},
+	}
+	for dist, want := range map[float64]int{299: 20, 325: 17, 350: 14, 399: 9, 500: 9, 700: 9} {
+		if got := MaxStoriesFromRings(dist, rings); got != want {
+			t.Errorf("MaxStoriesFromRings(%f) = %d, want %d", dist, got, want)
+		}
+	}
+	if got := MaxStoriesFromRings(100, nil); got != 1 {
+		t.Errorf("MaxStoriesFromRings without rings = %d, want 1", got)
+	}
+}
+
+func TestBlockMaxStories(t *testing.T) {
+	env := defaultSpec().HeightEnvelope()
+	// Centered in the center ring but reaching 10 m into the middle ring.
+	straddling := geo.NewPolygon(geo.Pt(250, -20), geo.Pt(310, -20), geo.Pt(310, 20), geo.Pt(250, 20))
+	if got := blockMaxStories(env, straddling); got != 10 {
+		t.Errorf("straddling block = %d stories, want the middle ring's 10", got)
+	}
+	inside := geo.NewPolygon(geo.Pt(100, -20), geo.Pt(160, -20), geo.Pt(160, 20), geo.Pt(100, 20))
+	if got := blockMaxStories(env, inside); got != 20 {
+		t.Errorf("center block = %d stories, want 20", got)
+	}
+}
+
+func TestCheckEnvelope(t *testing.T) {
+	s := defaultSpec()
+	s.City.HeightProfile = spec.ProfileBowl
+	env := s.HeightEnvelope() // 20 stories at the center to 4 at 900 m
+	low := testBuilding("low", 450, 0, 20, 20, 12)
+	high := testBuilding("high", 450, 0, 20, 20, 13)
+	podium := testBuilding("podium", 0, 450, 40, 40, 2)
+	tower := testBuilding("tower", 0, 450, 20, 20, 11)
+	tower.Position[1] = 6
+
+	breaches, report := CheckEnvelope(env, []Building{low, high, podium, tower})
+	if len(breaches) != 2 || breaches[0].BuildingID != "high" || breaches[1].BuildingID != "tower" {
+		t.Fatalf("breaches = %+v, want high and tower", breaches)
+	}
+	if b := breaches[1]; b.TopStory != 13 || b.MaxStories != 12 {
+		t.Errorf("tower reaches story %d under a limit of %d, want 13 and 12", b.TopStory, b.MaxStories)
+	}
+	if len(report.Warnings) != 1 || len(report.Warnings[0].EntityIDs) != 2 {
+		t.Errorf("want one warning naming both buildings, got %v", report.Warnings)
+	}
+}
+
+func TestPlaceBuildingsWithinEnvelope(t *testing.T) {
+	for _, profile := range []string{spec.ProfileStep, spec.ProfileBowl, spec.ProfileCosine} {
+		s := defaultSpec()
+		s.City.HeightProfile = profile
+		params := defaultParams()
+		pods, adjacency, _ := LayoutPods(s, params)
+		buildings, _, _ := PlaceBuildings(s, pods, adjacency, params)
+		if breaches, _ := CheckEnvelope(s.HeightEnvelope(), buildings); len(breaches) > 0 {
+			t.Errorf("%s profile: %d of %d buildings breach the envelope, first %+v", profile, len(breaches), len(buildings), breaches[0])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package layout
+
+// Floor uses. A building's floors are stacked from its base up in runs of
+// consecutive stories given to one use.
+const (
+	UseResidential = "residential"
+	UseRetail      = "retail"
+	UseCoworking   = "coworking"
+	UseCivic       = "civic"
+)
+
+// usableFraction is the share of gross floor area that is usable space.
+const usableFraction = 0.80
+
+// FloorUse is a run of consecutive floors given to one use.
+type FloorUse struct {
+	Use         string  `json:"use"`
+	Stories     int     `json:"stories"`
+	GrossAreaM2 float64 `json:"gross_area_m2"`
+	Units       int     `json:"units,omitempty"` // dwelling units, residential only
+}
+
+// UsableAreaM2 returns the run's usable floor area.
+func (f FloorUse) UsableAreaM2() float64 {
+	return f.GrossAreaM2 * usableFraction
+}
+
+// groundFloorRetailCharacters are the ring characters whose mid- and
+// high-rise housing stands on a retail ground floor.
+var groundFloorRetailCharacters = map[string]bool{
+	"civic_commercial": true,
+	"high_density":     true,
+}
+
+// floorRun returns stories floors of a w × d footprint given to use.
+func floorRun(use string, w, d float64, stories int) FloorUse {
+	return FloorUse{Use: use, Stories: stories, GrossAreaM2: w * d * float64(stories)}
+}
+
+// residentialRun returns stories floors of housing sized to unitArea.
+func residentialRun(w, d float64, stories int, unitArea float64) FloorUse {
+	f := floorRun(UseResidential, w, d, stories)
+	f.Units = unitsFor(w, d, stories, unitArea)
+	return f
+}
+
+// housingFloors stacks a w × d housing block: all residential, or with a
+// retail ground floor where the targets call for one and the building has
+// at least three stories.
+func housingFloors(w, d float64, stories int, t PlacementTargets) []FloorUse {
+	if t.GroundFloorRetail && stories >= 3 {
+		return []FloorUse{
+			floorRun(UseRetail, w, d, 1),
+			residentialRun(w, d, stories-1, t.UnitAreaM2),
+		}
+	}
+	return []FloorUse{residentialRun(w, d, stories, t.UnitAreaM2)}
+}
+
+// commercialFloors stacks a w × d commercial building: retail at street
+// level and coworking above.
+func commercialFloors(w, d float64, stories int) []FloorUse {
+	floors := []FloorUse{floorRun(UseRetail, w, d, 1)}
+	if stories > 1 {
+		floors = append(floors, floorRun(UseCoworking, w, d, stories-1))
+	}
+	return floors
+}
+
+// setFloors stacks floors on the building and derives its stories,
+// dwelling units and commercial and service floor space from them.
+func (b *Building) setFloors(floors ...FloorUse) {
+	b.Floors = floors
+	b.Stories, b.DwellingUnits = 0, 0
+	b.CommercialSqM, b.ServiceSqM = 0, 0
+	for _, f := range floors {
+		b.Stories += f.Stories
+		switch f.Use {
+		case UseResidential:
+			b.DwellingUnits += f.Units
+		case UseRetail, UseCoworking:
+			b.CommercialSqM += f.UsableAreaM2()
+		case UseCivic:
+			b.ServiceSqM += f.UsableAreaM2()
+		}
+	}
+}
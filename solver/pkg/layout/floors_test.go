@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetFloorsDerivesFigures(t *testing.T) {
+	var b Building
+	b.setFloors(
+		floorRun(UseRetail, 20, 10, 1),
+		floorRun(UseCoworking, 20, 10, 2),
+		residentialRun(20, 10, 5, 50),
+	)
+	if b.Stories != 8 {
+		t.Errorf("stories = %d, want 8", b.Stories)
+	}
+	if b.DwellingUnits != 20 {
+		t.Errorf("dwelling units = %d, want 20", b.DwellingUnits)
+	}
+	if want := 600 * usableFraction; b.CommercialSqM != want {
+		t.Errorf("commercial sqm = %.0f, want %.0f", b.CommercialSqM, want)
+	}
+	if b.ServiceSqM != 0 {
+		t.Errorf("service sqm = %.0f, want 0", b.ServiceSqM)
+	}
+}
+
+func TestBuildingJobs(t *testing.T) {
+	var b Building
+	b.setFloors(
+		floorRun(UseRetail, 20, 15, 1),    // 240 m² usable at 30 m² a job
+		floorRun(UseCoworking, 20, 15, 2), // 480 m² at 15
+		residentialRun(20, 15, 4, 60),
+	)
+	if got := b.Jobs(); math.Abs(got-40) > 1e-9 {
+		t.Errorf("mixed-use jobs = %f, want 40", got)
+	}
+
+	school := Building{ServiceType: "elementary_school"}
+	school.setFloors(floorRun(UseCivic, 30, 25, 2)) // 1200 m² at 60
+	if got := school.Jobs(); math.Abs(got-20) > 1e-9 {
+		t.Errorf("school jobs = %f, want 20", got)
+	}
+	playground := Building{ServiceType: "playground"}
+	playground.setFloors(floorRun(UseCivic, 30, 30, 1))
+	if got := playground.Jobs(); got != 0 {
+		t.Errorf("playground jobs = %f, want 0", got)
+	}
+}
+
+func TestHousingFloorsGroundFloorRetail(t *testing.T) {
+	t6 := PlacementTargets{UnitAreaM2: defaultUnitAreaM2, GroundFloorRetail: true}
+	floors := housingFloors(30, 14, 6, t6)
+	if len(floors) != 2 || floors[0].Use != UseRetail || floors[0].Stories != 1 || floors[1].Use != UseResidential {
+		t.Errorf("6-story mixed-use floors = %+v, want retail under housing", floors)
+	}
+	if floors := housingFloors(30, 14, 2, t6); len(floors) != 1 || floors[0].Use != UseResidential {
+		t.Errorf("2-story floors = %+v, want housing only", floors)
+	}
+	t6.GroundFloorRetail = false
+	if floors := housingFloors(30, 14, 6, t6); len(floors) != 1 {
+		t.Errorf("floors without ground-floor retail = %+v, want housing only", floors)
+	}
+}
+
+func TestPlaceBuildingsStacksFloors(t *testing.T) {
+	s := defaultSpec()
+	s.Pods.BuildingTypologies = map[string][]string{"civic_commercial": {"slab"}}
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	buildings, _, _ := PlaceBuildings(s, pods, adjacency, params)
+
+	mixedUse := 0
+	for _, b := range buildings {
+		stories := 0
+		for _, f := range b.Floors {
+			stories += f.Stories
+		}
+		if len(b.Floors) == 0 || stories != b.Stories {
+			t.Fatalf("building %s: %d stories but floors %+v", b.ID, b.Stories, b.Floors)
+		}
+		if b.Type == "residential" && b.CommercialSqM > 0 {
+			mixedUse++
+		}
+		if b.Type == "civic" && b.ServiceSqM <= 0 {
+			t.Errorf("civic building %s has no service space", b.ID)
+		}
+	}
+	// The civic_commercial center puts retail under its slabs.
+	if mixedUse == 0 {
+		t.Error("no housing with ground-floor retail was placed")
+	}
+}
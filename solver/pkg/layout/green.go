@@ -1,10 +1,21 @@
 package layout
 
-import "github.com/ChicagoDave/cityplanner/pkg/spec"
+import (
+	"context"
+
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
 
 // CollectGreenZones re-computes zone allocation for all pods and returns
 // only the green zones. Used by scene graph assembly for park entities.
 func CollectGreenZones(s *spec.CitySpec, pods []Pod) []Zone {
+	greens, _ := CollectGreenZonesContext(context.Background(), s, pods)
+	return greens
+}
+
+// CollectGreenZonesContext is CollectGreenZones that stops between pods
+// once ctx is done, returning ctx's error and no zones.
+func CollectGreenZonesContext(ctx context.Context, s *spec.CitySpec, pods []Pod) ([]Zone, error) {
 	ringRadii := make(map[string][2]float64, len(s.CityZones.Rings))
 	for _, ring := range s.CityZones.Rings {
 		ringRadii[ring.Name] = [2]float64{ring.RadiusFrom, ring.RadiusTo}
@@ -12,6 +23,9 @@ func CollectGreenZones(s *spec.CitySpec, pods []Pod) []Zone {
 
 	var greens []Zone
 	for _, pod := range pods {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		ringChar := ""
 		if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
 			ringChar = pr.Character
@@ -24,5 +38,5 @@ func CollectGreenZones(s *spec.CitySpec, pods []Pod) []Zone {
 			}
 		}
 	}
-	return greens
+	return greens, nil
 }
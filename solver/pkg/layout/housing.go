@@ -2,6 +2,7 @@ package layout
 
 import (
 	"math"
+	"sort"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 )
@@ -15,11 +16,38 @@ type UnitMix struct {
 	FourBed  int `json:"four_bed"`
 }
 
+// unitSizeM2 is the floor area of each unit type, studios first.
+var unitSizeM2 = [5]float64{35, 50, 75, 100, 120}
+
 // Total returns the sum of all unit types.
 func (u UnitMix) Total() int {
 	return u.Studios + u.OneBed + u.TwoBed + u.ThreeBed + u.FourBed
 }
 
+// Family returns the units sized for families: three and four bedrooms.
+func (u UnitMix) Family() int {
+	return u.ThreeBed + u.FourBed
+}
+
+// Add returns the sum of two mixes.
+func (u UnitMix) Add(o UnitMix) UnitMix {
+	return UnitMix{
+		Studios:  u.Studios + o.Studios,
+		OneBed:   u.OneBed + o.OneBed,
+		TwoBed:   u.TwoBed + o.TwoBed,
+		ThreeBed: u.ThreeBed + o.ThreeBed,
+		FourBed:  u.FourBed + o.FourBed,
+	}
+}
+
+func (u UnitMix) counts() [5]int {
+	return [5]int{u.Studios, u.OneBed, u.TwoBed, u.ThreeBed, u.FourBed}
+}
+
+func mixFromCounts(c [5]int) UnitMix {
+	return UnitMix{Studios: c[0], OneBed: c[1], TwoBed: c[2], ThreeBed: c[3], FourBed: c[4]}
+}
+
 // DistributeUnits maps demographic cohorts to unit types and computes
 // the required unit counts.
 //
@@ -71,10 +99,63 @@ func ScaleUnitMix(mix UnitMix, fraction float64) UnitMix {
 func AvgUnitSizeM2(mix UnitMix) float64 {
 	total := mix.Total()
 	if total == 0 {
-		return 75
+		return defaultUnitAreaM2
+	}
+	weighted := 0.0
+	for i, n := range mix.counts() {
+		weighted += float64(n) * unitSizeM2[i]
 	}
-	weighted := float64(mix.Studios)*35 + float64(mix.OneBed)*50 +
-		float64(mix.TwoBed)*75 + float64(mix.ThreeBed)*100 +
-		float64(mix.FourBed)*120
 	return weighted / float64(total)
 }
+
+// allocateUnitMix gives each residential building a unit mix of its
+// dwelling units in the proportions of share. Every building houses the
+// city's blend of cohorts, so family units are spread through each pod
+// instead of collecting in a few buildings (community.clustering_prevention
+// in the technical spec). Rounding error carries from one building to the
+// next, keeping the totals within a unit of share's proportions.
+func allocateUnitMix(buildings []Building, share UnitMix) {
+	total := share.Total()
+	if total <= 0 {
+		return
+	}
+	var frac, carry [5]float64
+	for i, n := range share.counts() {
+		frac[i] = float64(n) / float64(total)
+	}
+
+	for bi := range buildings {
+		b := &buildings[bi]
+		if b.Type != "residential" || b.DwellingUnits <= 0 {
+			continue
+		}
+		var ideal [5]float64
+		var got [5]int
+		sum := 0
+		for i := range ideal {
+			ideal[i] = float64(b.DwellingUnits)*frac[i] + carry[i]
+			got[i] = max(0, int(math.Floor(ideal[i])))
+			sum += got[i]
+		}
+		// Settle the remainder on the types furthest from their ideal.
+		order := [5]int{0, 1, 2, 3, 4}
+		sort.SliceStable(order[:], func(x, y int) bool {
+			return ideal[order[x]]-float64(got[order[x]]) > ideal[order[y]]-float64(got[order[y]])
+		})
+		for k := 0; sum < b.DwellingUnits; k = (k + 1) % len(order) {
+			got[order[k]]++
+			sum++
+		}
+		for k := len(order) - 1; sum > b.DwellingUnits; k = (k + len(order) - 1) % len(order) {
+			if got[order[k]] > 0 {
+				got[order[k]]--
+				sum--
+			}
+		}
+		for i := range carry {
+			carry[i] = ideal[i] - float64(got[i])
+		}
+		mix := mixFromCounts(got)
+		b.UnitMix = &mix
+	}
+}
@@ -0,0 +1,53 @@
+package layout
+
+// m2PerJobByUse is the usable floor area per job for each floor use.
+// Housing has no jobs.
+var m2PerJobByUse = map[string]float64{
+	UseRetail:    30,
+	UseCoworking: 15,
+	UseCivic:     40,
+}
+
+// m2PerJobByService overrides the civic density for service buildings.
+// Playgrounds employ no one.
+var m2PerJobByService = map[string]float64{
+	"hospital":          30,
+	"elementary_school": 60,
+	"secondary_school":  60,
+	"library":           90,
+	"grocery":           35,
+	"medical_clinic":    25,
+	"performing_arts":   100,
+	"city_hall":         20,
+	"coworking_hub":     12,
+	"coworking":         15,
+	"retail":            30,
+	"restaurant":        20,
+	"pediatric_clinic":  25,
+	"daycare":           25,
+	"playground":        0,
+}
+
+// JobDensity returns the jobs per m² of usable floor area of a floor use
+// in a building of the given service type, which may be empty.
+func JobDensity(use, serviceType string) float64 {
+	m2 := m2PerJobByUse[use]
+	if use == UseCivic && serviceType != "" {
+		if sm2, ok := m2PerJobByService[serviceType]; ok {
+			m2 = sm2
+		}
+	}
+	if m2 <= 0 {
+		return 0
+	}
+	return 1 / m2
+}
+
+// Jobs returns the jobs the building's floors hold.
+func (b Building) Jobs() float64 {
+	jobs := 0.0
+	for _, f := range b.Floors {
+		jobs += f.UsableAreaM2() * JobDensity(f.Use, b.ServiceType)
+	}
+	return jobs
+}
@@ -0,0 +1,54 @@
+package layout
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// parallelFor calls fn(i) for every i in [0, n) using up to workers
+// goroutines. Values of workers below 1 mean runtime.GOMAXPROCS(0); a value
+// of 1 runs serially on the calling goroutine. Callers write results into
+// per-index slots so output order never depends on scheduling. Once ctx is
+// done no further indices start and ctx's error is returned; the slots are
+// then incomplete.
+func parallelFor(ctx context.Context, n, workers int, fn func(i int)) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			fn(i)
+		}
+		return ctx.Err()
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+	return ctx.Err()
+}
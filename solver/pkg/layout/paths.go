@@ -3,21 +3,35 @@ package layout
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"github.com/ChicagoDave/cityplanner/pkg/geo"
 )
 
+// pathEdgeGapM is how far short of the pod boundary a path cut by it
+// ends, clear of buildings in the neighboring pod.
+const pathEdgeGapM = 4.0
+
 // GeneratePaths creates the pedestrian/bicycle path network for a pod.
 //
 // Three path types:
 //   - Spine: main path from pod center through each zone (4m wide)
 //   - Connectors: perpendicular to spine at regular intervals (3m wide)
 //   - Inter-pod: from pod center toward each adjacent pod center (4m wide)
+//
+// Paths stay within the pod: one that would cross its boundary ends
+// pathEdgeGapM short of it.
 func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D) []PathSegment {
 	var paths []PathSegment
 	center := pod.CenterPoint()
 	podPoly := pod.BoundaryPolygon()
 	pathIdx := 0
+	add := func(p PathSegment) {
+		if start, end, ok := insideSpan(podPoly, p.Start, p.End, pathEdgeGapM); ok {
+			p.Start, p.End = start, end
+			paths = append(paths, p)
+		}
+	}
 
 	// 1. Spine paths: from pod center outward in the radial direction
 	// and inward toward city center.
@@ -41,7 +55,7 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 
 	// Outward spine.
 	spineOut := center.Add(outward.Scale(maxProj * 0.95))
-	paths = append(paths, PathSegment{
+	add(PathSegment{
 		ID:     fmt.Sprintf("%s_spine_%d", pod.ID, pathIdx),
 		PodID:  pod.ID,
 		Start:  center,
@@ -53,7 +67,7 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 
 	// Inward spine.
 	spineIn := center.Add(outward.Scale(minProj * 0.95))
-	paths = append(paths, PathSegment{
+	add(PathSegment{
 		ID:     fmt.Sprintf("%s_spine_%d", pod.ID, pathIdx),
 		PodID:  pod.ID,
 		Start:  center,
@@ -88,7 +102,7 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 		connCenter := center.Add(outward.Scale(pos))
 		connStart := connCenter.Add(perp.Scale(-perpExtent))
 		connEnd := connCenter.Add(perp.Scale(perpExtent))
-		paths = append(paths, PathSegment{
+		add(PathSegment{
 			ID:     fmt.Sprintf("%s_conn_%d", pod.ID, pathIdx),
 			PodID:  pod.ID,
 			Start:  connStart,
@@ -100,7 +114,14 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 	}
 
 	// 3. Inter-pod connectors: from pod center toward each adjacent pod center.
-	for adjID, adjCenter := range adjacentCenters {
+	// Sorted so path IDs do not depend on map iteration order.
+	adjIDs := make([]string, 0, len(adjacentCenters))
+	for adjID := range adjacentCenters {
+		adjIDs = append(adjIDs, adjID)
+	}
+	sort.Strings(adjIDs)
+	for _, adjID := range adjIDs {
+		adjCenter := adjacentCenters[adjID]
 		dir := adjCenter.Sub(center)
 		if dir.Length() < 1 {
 			continue
@@ -115,7 +136,7 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 			}
 		}
 		endPt := center.Add(dirNorm.Scale(maxT * 0.95))
-		paths = append(paths, PathSegment{
+		add(PathSegment{
 			ID:     fmt.Sprintf("%s_inter_%s_%d", pod.ID, adjID, pathIdx),
 			PodID:  pod.ID,
 			Start:  center,
@@ -128,3 +149,54 @@ func GeneratePaths(pod Pod, zones []Zone, adjacentCenters map[string]geo.Point2D
 
 	return paths
 }
+
+// insideSpan clips the segment from a to b to the polygon, keeping its
+// longest stretch inside, and pulls each end the boundary cut back by gap.
+// It reports false if nothing is left.
+func insideSpan(poly geo.Polygon, a, b geo.Point2D, gap float64) (geo.Point2D, geo.Point2D, bool) {
+	d := b.Sub(a)
+	length := d.Length()
+	if length == 0 || poly.IsEmpty() {
+		return a, b, false
+	}
+	ts := []float64{0, 1}
+	for i := range poly.Vertices {
+		p, q := poly.Edge(i)
+		e := q.Sub(p)
+		den := d.Cross(e)
+		if den == 0 {
+			continue
+		}
+		w := p.Sub(a)
+		t, u := w.Cross(e)/den, w.Cross(d)/den
+		if t > 0 && t < 1 && u >= 0 && u <= 1 {
+			ts = append(ts, t)
+		}
+	}
+	sort.Float64s(ts)
+
+	// Runs of consecutive inside pieces, and the longest of them.
+	best0, best1, run0 := 0.0, 0.0, -1.0
+	for i := 0; i+1 < len(ts); i++ {
+		if !poly.Contains(a.Add(d.Scale((ts[i] + ts[i+1]) / 2))) {
+			run0 = -1
+			continue
+		}
+		if run0 < 0 {
+			run0 = ts[i]
+		}
+		if ts[i+1]-run0 > best1-best0 {
+			best0, best1 = run0, ts[i+1]
+		}
+	}
+	if best0 > 0 {
+		best0 += gap / length
+	}
+	if best1 < 1 {
+		best1 -= gap / length
+	}
+	if best1 <= best0 {
+		return a, b, false
+	}
+	return a.Add(d.Scale(best0)), a.Add(d.Scale(best1)), true
+}
@@ -83,7 +83,14 @@ func LayoutPods(s *spec.CitySpec, params *analytics.ResolvedParameters) ([]Pod,
 	cityBounds := geo.ApproximateCircle(geo.Origin, outerRadius, 128)
 	cells := geo.Voronoi(seeds, cityBounds)
 
-	// 3. Clip each cell to its ring boundary and validate walk radius.
+	// 3. Clip each pod's share of its ring to the ring boundary and
+	// validate walk radius. The city-wide cells give adjacency.
+	ringOf := make([]int, len(seedMeta))
+	for i, meta := range seedMeta {
+		ringOf[i] = meta.ringIndex
+	}
+	shares := ringCells(seeds, ringOf, cityBounds)
+
 	pods := make([]Pod, len(cells))
 	walkRadius := s.Pods.WalkRadius
 
@@ -92,7 +99,7 @@ func LayoutPods(s *spec.CitySpec, params *analytics.ResolvedParameters) ([]Pod,
 		ring := params.Rings[meta.ringIndex]
 
 		// Clip to ring annulus.
-		clipped := geo.ClipToAnnulus(cell.Polygon, geo.Origin, ring.RadiusFrom, ring.RadiusTo)
+		clipped := geo.ClipToAnnulus(shares[i], geo.Origin, ring.RadiusFrom, ring.RadiusTo)
 		if clipped.IsEmpty() {
 			report.AddError(validation.Result{
 				Level:   validation.LevelSpatial,
@@ -158,3 +165,27 @@ func LayoutPods(s *spec.CitySpec, params *analytics.ResolvedParameters) ([]Pod,
 
 	return pods, adjacency, report
 }
+
+// ringCells divides the city among the seeds ring by ring: cell i is the
+// part of bounds nearer seeds[i] than any other seed of ring ringOf[i].
+// Clipped to its annulus, each ring's cells tile the ring. City-wide cells
+// do not: a seed's city-wide cell loses the parts of its ring nearer a
+// seed of a neighboring ring, which left a third of the default city
+// outside every pod.
+func ringCells(seeds []geo.Point2D, ringOf []int, bounds geo.Polygon) []geo.Polygon {
+	byRing := map[int][]int{}
+	for i, r := range ringOf {
+		byRing[r] = append(byRing[r], i)
+	}
+	cells := make([]geo.Polygon, len(seeds))
+	for _, idx := range byRing {
+		ringSeeds := make([]geo.Point2D, len(idx))
+		for k, i := range idx {
+			ringSeeds[k] = seeds[i]
+		}
+		for k, cell := range geo.Voronoi(ringSeeds, bounds) {
+			cells[idx[k]] = cell.Polygon
+		}
+	}
+	return cells
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 )
 
@@ -14,6 +15,7 @@ func defaultSpec() *spec.CitySpec {
 		City: spec.CityDef{
 			Population:      50000,
 			ExcavationDepth: 8,
+			HeightProfile:   spec.ProfileStep,
 			MaxHeightCenter: 20,
 			MaxHeightEdge:   4,
 		},
@@ -198,3 +200,61 @@ func TestLayoutPodsBoundaryVertices(t *testing.T) {
 		}
 	}
 }
+
+func TestLayoutPodsTileRings(t *testing.T) {
+	s := defaultSpec()
+	pods, _, _ := LayoutPods(s, defaultParams())
+
+	// Each ring is shared among its own pods, so their areas sum to the
+	// ring's and no more.
+	area := map[string]float64{}
+	for _, p := range pods {
+		area[p.Ring] += p.AreaHa
+	}
+	for _, ring := range s.CityZones.Rings {
+		want := math.Pi * (ring.RadiusTo*ring.RadiusTo - ring.RadiusFrom*ring.RadiusFrom) / 10000
+		if math.Abs(area[ring.Name]-want) > want*0.02 {
+			t.Errorf("ring %s: pods cover %.2f ha, want the ring's %.2f ha", ring.Name, area[ring.Name], want)
+		}
+	}
+
+	// Every point of the city lies in exactly one pod of its own ring.
+	for x := -880.0; x <= 880; x += 40 {
+		for z := -880.0; z <= 880; z += 40 {
+			pt := geo.Pt(x+7, z+3) // off the axes pod edges may follow
+			d := pt.Distance(geo.Origin)
+			if d > 890 || math.Abs(d-300) < 5 || math.Abs(d-600) < 5 {
+				continue
+			}
+			var in []string
+			for _, p := range pods {
+				if p.BoundaryPolygon().Contains(pt) {
+					in = append(in, p.ID)
+				}
+			}
+			if len(in) != 1 {
+				t.Errorf("point %v lies in pods %v, want exactly one", pt, in)
+			}
+		}
+	}
+}
+
+func TestRingCellsShareRingAmongItsSeeds(t *testing.T) {
+	bounds := geo.ApproximateCircle(geo.Origin, 900, 128)
+	// Ring 0 has one seed at the center; ring 1 has two either side of it.
+	seeds := []geo.Point2D{geo.Pt(0, 0), geo.Pt(450, 0), geo.Pt(-450, 0)}
+	cells := ringCells(seeds, []int{0, 1, 1}, bounds)
+
+	// The only seed of its ring gets the whole city, though (300, 0) is
+	// nearer a seed of the other ring.
+	if !cells[0].Contains(geo.Pt(300, 0)) || math.Abs(cells[0].Area()-bounds.Area()) > 1 {
+		t.Errorf("ring 0 cell covers %.0f m² of %.0f", cells[0].Area(), bounds.Area())
+	}
+	// The two seeds of ring 1 split the city between them.
+	if got := cells[1].Area() + cells[2].Area(); math.Abs(got-bounds.Area()) > 1 {
+		t.Errorf("ring 1 cells cover %.0f m², want %.0f", got, bounds.Area())
+	}
+	if !cells[1].Contains(geo.Pt(10, 300)) || cells[2].Contains(geo.Pt(10, 300)) {
+		t.Error("(10, 300) is not in the cell of the nearer ring 1 seed")
+	}
+}
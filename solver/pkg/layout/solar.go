@@ -0,0 +1,465 @@
+package layout
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// Surface kinds analyzed for solar access.
+const (
+	SurfacePark  = "park"
+	SurfacePlaza = "plaza"
+	SurfaceRoof  = "roof"
+)
+
+const (
+	sunDayStep       = 30   // days between sampled dates
+	sunHourStep      = 1.0  // hours between sampled sun positions
+	sunMinElevation  = 5.0  // degrees; lower sun is treated as blocked by the skyline
+	groundCellM      = 10.0 // spacing of park and plaza sample points
+	roofCellM        = 8.0  // spacing of rooftop sample points
+	minPublicSunH    = 4.0  // parks and plazas with less direct sun a day are reported
+	pvRoofFraction   = 0.6  // share of open roof area usable for panels
+	pvEfficiency     = 0.2  // panel conversion efficiency
+	sunPointIndexM   = 25.0 // sample point index grid size
+	roofStackTolM    = 0.01 // height difference treated as level
+	obliquityDegrees = 23.44
+)
+
+// SunAccess is the direct sun one park, plaza or rooftop receives.
+type SunAccess struct {
+	ID     string  `json:"id"` // green zone, plaza or building ID
+	Kind   string  `json:"kind"`
+	PodID  string  `json:"pod_id"`
+	AreaM2 float64 `json:"area_m2"` // for roofs, the part not built over
+	// SunHours is the mean daily hours of direct sun within the analysis
+	// hours, averaged over the surface and the sampled dates.
+	SunHours float64 `json:"sun_hours"`
+	// Exposure is the share of the clear-sky irradiance on a horizontal
+	// surface that reaches it over the whole day.
+	Exposure float64 `json:"exposure"`
+}
+
+// SolarAnalysis is the solar access of every park, plaza and rooftop and
+// the rooftop PV yield it allows.
+type SolarAnalysis struct {
+	Latitude float64 `json:"latitude"`
+	Days     []int   `json:"days"` // days of the year sampled
+	HourFrom float64 `json:"hour_from"`
+	HourTo   float64 `json:"hour_to"`
+	// DaylightHours is the mean daily hours the sun is up within the
+	// analysis hours, the most any surface can receive.
+	DaylightHours  float64     `json:"daylight_hours"`
+	Surfaces       []SunAccess `json:"surfaces"`
+	RoofAreaM2     float64     `json:"roof_area_m2"`
+	RooftopPVAvgMW float64     `json:"rooftop_pv_avg_mw"`
+}
+
+// sunSample is one sampled sun position: the horizontal direction toward
+// the sun, the tangent and sine of its elevation, and whether it falls
+// within the analysis hours.
+type sunSample struct {
+	dir     geo.Point2D
+	tanAlt  float64
+	sinAlt  float64
+	inHours bool
+}
+
+// sunPoint is a sample point on a surface at height y.
+type sunPoint struct {
+	pos     geo.Point2D
+	y       float64
+	surface int
+}
+
+// SunPosition returns the horizontal direction toward the sun and the sine
+// of its elevation at a latitude in degrees, on a day of the year and at an
+// hour of local solar time. The city frame has +X east and +Z south.
+func SunPosition(latitude float64, day int, hour float64) (dir geo.Point2D, sinAlt float64) {
+	phi := latitude * math.Pi / 180
+	decl := obliquityDegrees * math.Pi / 180 * math.Sin(2*math.Pi*float64(284+day)/365)
+	h := (hour - 12) * 15 * math.Pi / 180
+
+	east := -math.Cos(decl) * math.Sin(h)
+	north := math.Cos(phi)*math.Sin(decl) - math.Sin(phi)*math.Cos(decl)*math.Cos(h)
+	up := math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Cos(h)
+	return geo.Pt(east, -north).Normalize(), up
+}
+
+// AnalyzeSolarAccess casts shadows from the buildings onto the parks,
+// plazas and rooftops over the spec's solar access dates and hours, and
+// estimates rooftop PV yield. It returns nil if the spec does not request
+// the analysis.
+func AnalyzeSolarAccess(s *spec.CitySpec, buildings []Building, greens []Zone, plazas []Plaza) (*SolarAnalysis, *validation.Report) {
+	a, rep, _ := AnalyzeSolarAccessContext(context.Background(), s, buildings, greens, plazas)
+	return a, rep
+}
+
+// AnalyzeSolarAccessContext is AnalyzeSolarAccess that stops between dates
+// once ctx is done, returning ctx's error and no analysis.
+func AnalyzeSolarAccessContext(ctx context.Context, s *spec.CitySpec, buildings []Building, greens []Zone, plazas []Plaza) (*SolarAnalysis, *validation.Report, error) {
+	site := s.Site
+	if !site.SolarAccess.Enabled() {
+		return nil, nil, nil
+	}
+	report := validation.NewReport()
+	from, to, err := site.SolarAccess.DayRange()
+	if err != nil {
+		// Schema validation reports the bad date.
+		return nil, report, nil
+	}
+	a := &SolarAnalysis{Latitude: site.Latitude, Days: sampleDays(from, to)}
+	a.HourFrom, a.HourTo = site.SolarAccess.Hours()
+
+	points := sunSurfaces(a, buildings, greens, plazas)
+	index := newPointGrid(points, sunPointIndexM)
+	rects := make([]geo.Rect, len(buildings))
+	for i, b := range buildings {
+		rects[i] = BuildingRect(b)
+	}
+
+	// Each date accumulates into its own slots so the sums do not depend
+	// on scheduling.
+	type dayTotals struct {
+		sunHours, irradiance []float64
+		daylight, clearSky   float64
+	}
+	days := make([]dayTotals, len(a.Days))
+	err = parallelFor(ctx, len(a.Days), 0, func(d int) {
+		t := dayTotals{sunHours: make([]float64, len(points)), irradiance: make([]float64, len(points))}
+		shaded := make([]bool, len(points))
+		for _, sample := range daySamples(a, a.Days[d]) {
+			if sample.inHours {
+				t.daylight += sunHourStep
+			}
+			t.clearSky += sample.sinAlt
+			for i := range shaded {
+				shaded[i] = false
+			}
+			castShadows(sample, buildings, rects, points, index, shaded)
+			for i, sh := range shaded {
+				if sh {
+					continue
+				}
+				if sample.inHours {
+					t.sunHours[i] += sunHourStep
+				}
+				t.irradiance[i] += sample.sinAlt
+			}
+		}
+		days[d] = t
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counts := make([]int, len(a.Surfaces))
+	var clearSky float64
+	for _, t := range days {
+		a.DaylightHours += t.daylight / float64(len(days))
+		clearSky += t.clearSky
+		for i, p := range points {
+			sf := &a.Surfaces[p.surface]
+			sf.SunHours += t.sunHours[i] / float64(len(days))
+			sf.Exposure += t.irradiance[i]
+		}
+	}
+	for _, p := range points {
+		counts[p.surface]++
+	}
+	for i := range a.Surfaces {
+		sf := &a.Surfaces[i]
+		if counts[i] == 0 {
+			continue
+		}
+		sf.SunHours /= float64(counts[i])
+		if clearSky > 0 {
+			sf.Exposure /= clearSky * float64(counts[i])
+		}
+		if sf.Kind == SurfaceRoof {
+			a.RoofAreaM2 += sf.AreaM2
+			// kWh per day over 24 hours, in MW.
+			a.RooftopPVAvgMW += sf.AreaM2 * pvRoofFraction * pvEfficiency * site.SolarIrradiance * sf.Exposure / 24 / 1000
+		}
+	}
+	solarReport(a, s, report)
+	return a, report, nil
+}
+
+// sampleDays returns the days of the year from from to to, wrapping past
+// the year end, every sunDayStep days.
+func sampleDays(from, to int) []int {
+	span := to - from
+	if span < 0 {
+		span += 365
+	}
+	var days []int
+	for d := 0; d <= span; d += sunDayStep {
+		days = append(days, (from+d-1)%365+1)
+	}
+	return days
+}
+
+// daySamples returns the sun positions sampled through a day with the sun
+// above sunMinElevation.
+func daySamples(a *SolarAnalysis, day int) []sunSample {
+	minSin := math.Sin(sunMinElevation * math.Pi / 180)
+	var out []sunSample
+	for h := sunHourStep / 2; h < 24; h += sunHourStep {
+		dir, sinAlt := SunPosition(a.Latitude, day, h)
+		if sinAlt < minSin {
+			continue
+		}
+		out = append(out, sunSample{
+			dir:     dir,
+			tanAlt:  sinAlt / math.Sqrt(1-sinAlt*sinAlt),
+			sinAlt:  sinAlt,
+			inHours: h >= a.HourFrom && h < a.HourTo,
+		})
+	}
+	return out
+}
+
+// sunSurfaces adds a surface to a for every park, plaza and building roof
+// and returns their sample points. Parts of a roof built over, such as a
+// podium under its tower, get no points and do not count toward its area.
+func sunSurfaces(a *SolarAnalysis, buildings []Building, greens []Zone, plazas []Plaza) []sunPoint {
+	var points []sunPoint
+	add := func(sf SunAccess, pts []geo.Point2D, y float64) {
+		for _, p := range pts {
+			points = append(points, sunPoint{pos: p, y: y, surface: len(a.Surfaces)})
+		}
+		a.Surfaces = append(a.Surfaces, sf)
+	}
+	for _, z := range greens {
+		add(SunAccess{ID: z.ID, Kind: SurfacePark, PodID: z.PodID, AreaM2: z.Polygon.Area()}, polygonSamples(z.Polygon, groundCellM), 0)
+	}
+	for _, p := range plazas {
+		r := geo.Rect{Center: p.Position, Width: p.Width, Depth: p.Depth, Rotation: p.Rotation}
+		add(SunAccess{ID: p.ID, Kind: SurfacePlaza, PodID: p.PodID, AreaM2: p.Width * p.Depth}, rectSamples(r, groundCellM), 0)
+	}
+
+	index := geo.NewGridIndex(indexCellM)
+	rects := make([]geo.Rect, len(buildings))
+	for i, b := range buildings {
+		rects[i] = BuildingRect(b)
+		min, max := rects[i].Bounds()
+		index.Insert(min, max)
+	}
+	for i, b := range buildings {
+		top := buildingTop(b)
+		all := rectSamples(rects[i], roofCellM)
+		var open []geo.Point2D
+		for _, p := range all {
+			covered := false
+			for _, j := range index.Query(p, p) {
+				o := buildings[j]
+				if j != i && o.Position[1] <= top+roofStackTolM && buildingTop(o) > top+roofStackTolM && rects[j].Contains(p) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				open = append(open, p)
+			}
+		}
+		area := b.Footprint[0] * b.Footprint[1] * float64(len(open)) / float64(len(all))
+		add(SunAccess{ID: b.ID, Kind: SurfaceRoof, PodID: b.PodID, AreaM2: area}, open, top)
+	}
+	return points
+}
+
+// castShadows marks the points that some building blocks from the sun.
+// A point is blocked by a building if the ray toward the sun passes
+// through the building's footprint between its base and roof heights.
+func castShadows(sample sunSample, buildings []Building, rects []geo.Rect, points []sunPoint, index *pointGrid, shaded []bool) {
+	for i, b := range buildings {
+		base, top := b.Position[1], buildingTop(b)
+		test := func(k int) {
+			p := points[k]
+			if shaded[k] || p.y >= top {
+				return
+			}
+			tIn, tOut, ok := rects[i].RayInterval(p.pos, sample.dir)
+			if ok && p.y+tIn*sample.tanAlt < top && p.y+tOut*sample.tanAlt > base {
+				shaded[k] = true
+			}
+		}
+		// The shadow reaches from the footprint away from the sun as far
+		// as the roof height allows at ground level. Long shadows are
+		// searched in pieces so the boxes searched stay narrow.
+		tail := sample.dir.Scale(-top / sample.tanAlt)
+		n := max(1, int(math.Ceil(tail.Length()/sunPointIndexM)))
+		for j := 0; j < n; j++ {
+			lo, hi := rects[i].Translate(tail.Scale(float64(j) / float64(n))).Bounds()
+			elo, ehi := rects[i].Translate(tail.Scale(float64(j+1) / float64(n))).Bounds()
+			lo = geo.Pt(math.Min(lo.X, elo.X), math.Min(lo.Z, elo.Z))
+			hi = geo.Pt(math.Max(hi.X, ehi.X), math.Max(hi.Z, ehi.Z))
+			index.visit(lo, hi, test)
+		}
+	}
+}
+
+// pointGrid buckets sample points on a dense grid over their bounds.
+type pointGrid struct {
+	origin     geo.Point2D
+	cell       float64
+	cols, rows int
+	cells      [][]int
+}
+
+func newPointGrid(points []sunPoint, cell float64) *pointGrid {
+	g := &pointGrid{cell: cell}
+	if len(points) == 0 {
+		return g
+	}
+	lo, hi := points[0].pos, points[0].pos
+	for _, p := range points {
+		lo = geo.Pt(math.Min(lo.X, p.pos.X), math.Min(lo.Z, p.pos.Z))
+		hi = geo.Pt(math.Max(hi.X, p.pos.X), math.Max(hi.Z, p.pos.Z))
+	}
+	g.origin = lo
+	g.cols = int((hi.X-lo.X)/cell) + 1
+	g.rows = int((hi.Z-lo.Z)/cell) + 1
+	g.cells = make([][]int, g.cols*g.rows)
+	for i, p := range points {
+		c := int((p.pos.X-lo.X)/cell)*g.rows + int((p.pos.Z-lo.Z)/cell)
+		g.cells[c] = append(g.cells[c], i)
+	}
+	return g
+}
+
+// visit calls fn for every point in the cells the box from lo to hi
+// touches, which may include points just outside the box.
+func (g *pointGrid) visit(lo, hi geo.Point2D, fn func(k int)) {
+	x0 := max(0, int(math.Floor((lo.X-g.origin.X)/g.cell)))
+	x1 := min(g.cols-1, int(math.Floor((hi.X-g.origin.X)/g.cell)))
+	z0 := max(0, int(math.Floor((lo.Z-g.origin.Z)/g.cell)))
+	z1 := min(g.rows-1, int(math.Floor((hi.Z-g.origin.Z)/g.cell)))
+	if z0 > z1 {
+		return
+	}
+	for x := x0; x <= x1; x++ {
+		for _, c := range g.cells[x*g.rows+z0 : x*g.rows+z1+1] {
+			for _, k := range c {
+				fn(k)
+			}
+		}
+	}
+}
+
+func buildingTop(b Building) float64 {
+	return b.Position[1] + float64(b.Stories)*storyHeightM
+}
+
+// polygonSamples returns the centers of the grid cells inside poly, or its
+// centroid if the polygon is smaller than a cell.
+func polygonSamples(poly geo.Polygon, cell float64) []geo.Point2D {
+	min, max := poly.BoundingBox()
+	var out []geo.Point2D
+	for x := min.X + cell/2; x < max.X; x += cell {
+		for z := min.Z + cell/2; z < max.Z; z += cell {
+			if p := geo.Pt(x, z); poly.Contains(p) {
+				out = append(out, p)
+			}
+		}
+	}
+	if len(out) == 0 && !poly.IsEmpty() {
+		out = append(out, poly.Centroid())
+	}
+	return out
+}
+
+// rectSamples returns the centers of a grid of cells about cell meters
+// across covering r.
+func rectSamples(r geo.Rect, cell float64) []geo.Point2D {
+	u, v := r.Axes()
+	nu := max(1, int(math.Round(r.Width/cell)))
+	nv := max(1, int(math.Round(r.Depth/cell)))
+	out := make([]geo.Point2D, 0, nu*nv)
+	for i := 0; i < nu; i++ {
+		for j := 0; j < nv; j++ {
+			du := (float64(i)+0.5)/float64(nu) - 0.5
+			dv := (float64(j)+0.5)/float64(nv) - 0.5
+			out = append(out, r.Center.Add(u.Scale(du*r.Width)).Add(v.Scale(dv*r.Depth)))
+		}
+	}
+	return out
+}
+
+// solarReport summarizes the analysis: mean sun hours by surface kind,
+// parks and plazas short of minPublicSunH, and rooftop PV against the
+// spec's building-integrated solar.
+func solarReport(a *SolarAnalysis, s *spec.CitySpec, report *validation.Report) {
+	type kindSum struct {
+		area, weighted float64
+		short          []string
+	}
+	sums := map[string]*kindSum{SurfacePark: {}, SurfacePlaza: {}, SurfaceRoof: {}}
+	// Short hours windows cannot give four hours of sun anywhere.
+	minSun := math.Min(minPublicSunH, a.DaylightHours/2)
+	for _, sf := range a.Surfaces {
+		k := sums[sf.Kind]
+		k.area += sf.AreaM2
+		k.weighted += sf.AreaM2 * sf.SunHours
+		if sf.Kind != SurfaceRoof && sf.SunHours < minSun {
+			k.short = append(k.short, sf.ID)
+		}
+	}
+	mean := func(kind string) float64 {
+		if k := sums[kind]; k.area > 0 {
+			return k.weighted / k.area
+		}
+		return 0
+	}
+
+	report.AddInfo(validation.Result{
+		Level: validation.LevelSpatial,
+		Message: fmt.Sprintf("solar access over %d dates, %s to %s solar time (%.1f h of sun possible a day): parks average %.1f h of direct sun a day, plazas %.1f h, rooftops %.1f h",
+			len(a.Days), clockTime(a.HourFrom), clockTime(a.HourTo), a.DaylightHours, mean(SurfacePark), mean(SurfacePlaza), mean(SurfaceRoof)),
+	})
+	for _, kind := range []string{SurfacePark, SurfacePlaza} {
+		if short := sums[kind].short; len(short) > 0 {
+			report.AddWarning(validation.Result{
+				Level:     validation.LevelSpatial,
+				Message:   fmt.Sprintf("%d %ss get under %.1f h of direct sun a day", len(short), kind, minSun),
+				EntityIDs: short,
+			})
+		}
+	}
+
+	target := s.Infrastructure.Electrical.SolarIntegratedAvgMW
+	if s.Site.SolarIrradiance <= 0 {
+		report.AddWarning(validation.Result{
+			Level:    validation.LevelSpatial,
+			Message:  "rooftop PV yield not estimated: site solar irradiance is not set",
+			SpecPath: "site_requirements.solar_irradiance_kwh_m2_day",
+		})
+		return
+	}
+	report.AddInfo(validation.Result{
+		Level: validation.LevelSpatial,
+		Message: fmt.Sprintf("rooftop PV on %.0f ha of open roof could average %.1f MW (%.0f%% of roof area usable, %.0f%% efficient panels)",
+			a.RoofAreaM2/10000, a.RooftopPVAvgMW, pvRoofFraction*100, pvEfficiency*100),
+	})
+	if a.RooftopPVAvgMW < target {
+		report.AddWarning(validation.Result{
+			Level:       validation.LevelSpatial,
+			Message:     fmt.Sprintf("rooftop PV could average %.1f MW, short of the %.1f MW of building-integrated solar the spec counts on", a.RooftopPVAvgMW, target),
+			SpecPath:    "infrastructure.electrical.solar_integrated_avg_mw",
+			ActualValue: a.RooftopPVAvgMW,
+			Expected:    fmt.Sprintf(">= %.1f", target),
+		})
+	}
+}
+
+// clockTime formats an hour of the day as HH:MM.
+func clockTime(h float64) string {
+	m := int(math.Round(h * 60))
+	return fmt.Sprintf("%02d:%02d", m/60, m%60)
+}
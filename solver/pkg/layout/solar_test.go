@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+func TestSunPosition(t *testing.T) {
+	// Midsummer noon at 35°N: the sun stands 78.4° up, due south (+Z).
+	dir, sinAlt := SunPosition(35, 172, 12)
+	if alt := math.Asin(sinAlt) * 180 / math.Pi; math.Abs(alt-78.4) > 0.2 {
+		t.Errorf("noon elevation = %.2f°, want 78.4°", alt)
+	}
+	if math.Abs(dir.X) > 1e-9 || dir.Z <= 0 {
+		t.Errorf("noon sun direction = %v, want due south", dir)
+	}
+	if dir, _ := SunPosition(35, 172, 9); dir.X <= 0 {
+		t.Errorf("morning sun direction = %v, want east of south", dir)
+	}
+	if _, sinAlt := SunPosition(35, 355, 0); sinAlt >= 0 {
+		t.Error("sun is up at midnight")
+	}
+}
+
+func solarSpec() *spec.CitySpec {
+	s := &spec.CitySpec{}
+	s.Site.Latitude = 35
+	s.Site.SolarIrradiance = 4.5
+	s.Site.SolarAccess = spec.SolarAccessDef{DateFrom: "01-01", DateTo: "12-31"}
+	s.Infrastructure.Electrical.SolarIntegratedAvgMW = 1
+	return s
+}
+
+func TestAnalyzeSolarAccessShadows(t *testing.T) {
+	podium := testBuilding("podium", 300, 0, 48, 48, 2)
+	tower := testBuilding("tower", 300, 0, 16, 16, 28)
+	tower.Position[1] = 6
+	slab := testBuilding("slab", 0, 0, 200, 20, 20)
+	buildings := []Building{podium, tower, slab}
+	plazas := []Plaza{
+		{ID: "north", PodID: "pod_test", Position: geo.Pt(0, -20), Width: 40, Depth: 20},
+		{ID: "south", PodID: "pod_test", Position: geo.Pt(0, 20), Width: 40, Depth: 20},
+	}
+	greens := []Zone{{
+		ID: "park", PodID: "pod_test", Type: ZoneGreen,
+		Polygon: geo.NewPolygon(geo.Pt(-500, 500), geo.Pt(-400, 500), geo.Pt(-400, 600), geo.Pt(-500, 600)),
+	}}
+
+	a, report := AnalyzeSolarAccess(solarSpec(), buildings, greens, plazas)
+	if a == nil {
+		t.Fatal("no analysis for a spec with solar_access dates")
+	}
+	sun := map[string]SunAccess{}
+	for _, sf := range a.Surfaces {
+		sun[sf.ID] = sf
+	}
+	if park := sun["park"]; math.Abs(park.SunHours-a.DaylightHours) > 1e-9 || math.Abs(park.Exposure-1) > 1e-9 {
+		t.Errorf("open park gets %.2f h and %.2f exposure, want all %.2f h of daylight", park.SunHours, park.Exposure, a.DaylightHours)
+	}
+	if n, s := sun["north"].SunHours, sun["south"].SunHours; n >= s-2 {
+		t.Errorf("plaza north of the slab gets %.2f h of sun, south plaza %.2f h", n, s)
+	}
+	if got := sun["podium"].AreaM2; math.Abs(got-2048) > 1e-9 {
+		t.Errorf("podium roof area = %.0f m², want 2048 m² around the tower", got)
+	}
+	if sun["podium"].Exposure >= sun["tower"].Exposure {
+		t.Errorf("podium roof exposure %.2f not below the tower's %.2f", sun["podium"].Exposure, sun["tower"].Exposure)
+	}
+	// Unshaded roofs would yield the site's whole irradiance.
+	full := a.RoofAreaM2 * pvRoofFraction * pvEfficiency * 4.5 / 24 / 1000
+	if a.RooftopPVAvgMW <= full/2 || a.RooftopPVAvgMW >= full {
+		t.Errorf("rooftop PV = %.3f MW, want most of the unshaded %.3f MW", a.RooftopPVAvgMW, full)
+	}
+
+	var shortPV, shortPlazas bool
+	for _, w := range report.Warnings {
+		shortPV = shortPV || w.SpecPath == "infrastructure.electrical.solar_integrated_avg_mw"
+		shortPlazas = shortPlazas || (len(w.EntityIDs) == 1 && w.EntityIDs[0] == "north")
+	}
+	if !shortPV {
+		t.Error("no warning that rooftop PV falls short of solar_integrated_avg_mw")
+	}
+	if !shortPlazas {
+		t.Errorf("no warning naming the shaded plaza: %v", report.Warnings)
+	}
+}
+
+func TestAnalyzeSolarAccessDisabled(t *testing.T) {
+	s := solarSpec()
+	s.Site.SolarAccess = spec.SolarAccessDef{}
+	if a, _ := AnalyzeSolarAccess(s, nil, nil, nil); a != nil {
+		t.Error("analysis ran without solar_access dates")
+	}
+}
+
+func TestSampleDaysWrap(t *testing.T) {
+	days := sampleDays(350, 40)
+	if days[0] != 350 || days[1] != 15 || len(days) != 2 {
+		t.Errorf("sampleDays(350, 40) = %v, want [350 15]", days)
+	}
+}
@@ -89,13 +89,10 @@ func IdentifyBufferZones(pods []Pod, adjacency map[string][]string) []BufferZone
 	var buffers []BufferZone
 	bufIdx := 0
 
-	for podID, neighbors := range adjacency {
-		pod1, ok1 := podMap[podID]
-		if !ok1 {
-			continue
-		}
-
-		for _, adjID := range neighbors {
+	// Walk pods in layout order so buffer IDs are deterministic.
+	for _, pod1 := range pods {
+		podID := pod1.ID
+		for _, adjID := range adjacency[podID] {
 			// Create canonical pair key.
 			pairKey := podID + "|" + adjID
 			if podID > adjID {
@@ -1,6 +1,7 @@
 package layout
 
 import (
+	"context"
 	"fmt"
 	"math"
 
@@ -26,6 +27,8 @@ const (
 )
 
 // PlaceTrees generates trees in three contexts: parks, paths, and plazas.
+// Each zone, path and plaza is filled concurrently; IDs are numbered in
+// input order afterwards, so the output is identical to a serial run.
 func PlaceTrees(
 	pods []Pod,
 	greenZones []Zone,
@@ -33,27 +36,94 @@ func PlaceTrees(
 	bikePaths []BikePath,
 	plazas []Plaza,
 ) ([]Tree, *validation.Report) {
+	trees, report, _ := placeTreesWith(context.Background(), pods, greenZones, paths, bikePaths, plazas, 0, nil)
+	return trees, report
+}
+
+// PlaceTreesContext is PlaceTrees that stops once ctx is done, returning
+// ctx's error and no trees.
+func PlaceTreesContext(
+	ctx context.Context,
+	pods []Pod,
+	greenZones []Zone,
+	paths []PathSegment,
+	bikePaths []BikePath,
+	plazas []Plaza,
+) ([]Tree, *validation.Report, error) {
+	return placeTreesWith(ctx, pods, greenZones, paths, bikePaths, plazas, 0, nil)
+}
+
+// placeTreesWith fills sources on up to workers goroutines, reusing the
+// trees of unchanged sources from cache when it is non-nil. A canceled run
+// leaves the cache untouched.
+func placeTreesWith(
+	ctx context.Context,
+	_ []Pod,
+	greenZones []Zone,
+	paths []PathSegment,
+	_ []BikePath,
+	plazas []Plaza,
+	workers int,
+	cache *PlacementCache,
+) ([]Tree, *validation.Report, error) {
 	report := validation.NewReport()
-	var trees []Tree
-	idx := 0
 
-	// 1. Park trees: grid fill within green zone polygons.
-	for _, z := range greenZones {
-		parkTrees := placeParkTrees(z, &idx)
-		trees = append(trees, parkTrees...)
+	// One slot per source, in order: green zones, then paths, then plazas.
+	nZones, nPaths := len(greenZones), len(paths)
+	groups := make([][]Tree, nZones+nPaths+len(plazas))
+	sources := make([]any, len(groups))
+	keys := make([]string, len(groups))
+	for i := range groups {
+		switch {
+		case i < nZones:
+			sources[i], keys[i] = greenZones[i], "park/"+greenZones[i].ID
+		case i < nZones+nPaths:
+			sources[i], keys[i] = paths[i-nZones], "path/"+paths[i-nZones].ID
+		default:
+			sources[i], keys[i] = plazas[i-nZones-nPaths], "plaza/"+plazas[i-nZones-nPaths].ID
+		}
+	}
+	err := parallelFor(ctx, len(groups), workers, func(i int) {
+		if cache != nil {
+			if trees, ok := cache.lookupTrees(keys[i], sources[i]); ok {
+				groups[i] = trees
+				return
+			}
+		}
+		local := 0
+		switch {
+		case i < nZones:
+			// 1. Park trees: grid fill within green zone polygons.
+			groups[i] = placeParkTrees(greenZones[i], &local)
+		case i < nZones+nPaths:
+			// 2. Path trees: along pedestrian paths (ground level only; bike
+			//    paths are elevated so ground-level trees aren't placed beside them).
+			groups[i] = placePathTrees(paths[i-nZones], &local)
+		default:
+			// 3. Plaza perimeter trees.
+			groups[i] = plazaPerimeterTrees(plazas[i-nZones-nPaths], &local)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// 2. Path trees: along pedestrian paths (ground level only; bike paths
-	//    are elevated so ground-level trees aren't placed beside them).
-	for _, p := range paths {
-		pathTrees := placePathTrees(p, &idx)
-		trees = append(trees, pathTrees...)
+	if cache != nil {
+		entries := make(map[string]cachedTrees, len(groups))
+		for i, g := range groups {
+			entries[keys[i]] = cachedTrees{source: sources[i], trees: g}
+		}
+		cache.storeTrees(entries)
 	}
 
-	// 3. Plaza perimeter trees.
-	for _, pl := range plazas {
-		plTrees := plazaPerimeterTrees(pl, &idx)
-		trees = append(trees, plTrees...)
+	var trees []Tree
+	idx := 0
+	for _, g := range groups {
+		for _, t := range g {
+			t.ID = fmt.Sprintf("tree_%s_%05d", t.Context, idx)
+			trees = append(trees, t)
+			idx++
+		}
 	}
 
 	report.AddInfo(validation.Result{
@@ -61,7 +131,7 @@ func PlaceTrees(
 		Message: fmt.Sprintf("placed %d trees (park: green zones, path: %d segments, plaza: %d perimeters)",
 			len(trees), len(paths), len(plazas)),
 	})
-	return trees, report
+	return trees, report, nil
 }
 
 // placeParkTrees fills a green zone polygon with trees on a 10m grid.
@@ -1,6 +1,10 @@
 package layout
 
-import "testing"
+import (
+	"context"
+	"reflect"
+	"testing"
+)
 
 func TestPlaceTreesProducesOutput(t *testing.T) {
 	pods, adjacency, rings := bikeTestPods(t)
@@ -89,3 +93,19 @@ func TestTreesAreDeterministic(t *testing.T) {
 		}
 	}
 }
+
+func TestPlaceTreesParallelMatchesSerial(t *testing.T) {
+	pods, adjacency, rings := bikeTestPods(t)
+	s := defaultBikeSpec()
+
+	greenZones := CollectGreenZones(s, pods)
+	_, paths, _ := PlaceBuildings(s, pods, adjacency, defaultBikeParams())
+	bikePaths, _ := GenerateBikePaths(pods, adjacency, rings)
+	plazas, _ := GeneratePlazas(pods, s)
+
+	serial, _, _ := placeTreesWith(context.Background(), pods, greenZones, paths, bikePaths, plazas, 1, nil)
+	parallel, _, _ := placeTreesWith(context.Background(), pods, greenZones, paths, bikePaths, plazas, 8, nil)
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Fatal("parallel tree placement differs from serial")
+	}
+}
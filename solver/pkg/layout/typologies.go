@@ -0,0 +1,317 @@
+package layout
+
+import "math"
+
+// The built-in typologies. Dimensions are meters; u is the block's long
+// (radial) axis and v runs across it.
+func init() {
+	RegisterTypology(courtyard{})
+	RegisterTypology(towerOnPodium{})
+	RegisterTypology(perimeterBlock{})
+	RegisterTypology(slab{})
+	RegisterTypology(townhouseRow{})
+	RegisterTypology(detachedCluster{})
+}
+
+// courtyard is a grid of 20 × 15 m mid-rises built to the height envelope
+// around open ground. Nearly any block fits it, which makes it the
+// fallback for every ring character.
+type courtyard struct{}
+
+func (courtyard) Name() string { return "courtyard" }
+
+func (courtyard) Fits(block Block, _ PlacementTargets) bool {
+	return len(block.Polygon.Vertices) >= 3
+}
+
+func (courtyard) Place(block Block, t PlacementTargets) []Building {
+	const (
+		buildingW = 20.0
+		buildingD = 15.0
+		spacing   = 2.0
+		setback   = 3.0
+	)
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(setback)
+	numU := max(1, rowCount(lenU, buildingW, spacing))
+	numV := max(1, rowCount(lenV, buildingD, spacing))
+
+	var out []Building
+	for iu := 0; iu < numU; iu++ {
+		for iv := 0; iv < numV; iv++ {
+			u := u0 + float64(iu)*(buildingW+spacing) + buildingW/2
+			v := v0 + float64(iv)*(buildingD+spacing) + buildingD/2
+			b, ok := f.building(block, u, v, buildingW, buildingD, t.MaxStories)
+			if !ok {
+				continue
+			}
+			b.setFloors(housingFloors(buildingW, buildingD, t.MaxStories, t)...)
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// towerOnPodium is slender residential towers standing on a podium that
+// covers the block, the form of the center and ring 4: the podium holds
+// street retail with coworking above, its roof is a shared terrace and the
+// towers rise to the height envelope.
+type towerOnPodium struct{}
+
+const (
+	podiumSetback    = 3.0
+	towerInset       = 4.0  // from the podium edge
+	towerMaxSide     = 22.0 // tower footprint side
+	towerMinSide     = 15.0
+	towerSeparation  = 12.0
+	towerMinStories  = 8 // envelope needed for towers above the podium
+	towerMinAbovePod = 4
+)
+
+func (towerOnPodium) Name() string { return "tower_on_podium" }
+
+func (towerOnPodium) Fits(block Block, t PlacementTargets) bool {
+	if t.MaxStories < towerMinStories {
+		return false
+	}
+	_, _, lenU, lenV := newBlockFrame(block.Polygon).inset(podiumSetback)
+	return math.Min(lenU, lenV)-2*towerInset >= towerMinSide
+}
+
+func (towerOnPodium) Place(block Block, t PlacementTargets) []Building {
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(podiumSetback)
+	podiumStories := 2
+	if t.MaxStories >= 16 {
+		podiumStories = 3
+	}
+	towerStories := t.MaxStories - podiumStories
+	if towerStories < towerMinAbovePod {
+		return nil
+	}
+
+	podium, ok := f.building(block, u0+lenU/2, v0+lenV/2, lenU, lenV, podiumStories)
+	if !ok {
+		return nil
+	}
+	podium.setFloors(commercialFloors(lenU, lenV, podiumStories)...)
+	out := []Building{podium}
+
+	// Towers on a grid centered on the podium.
+	side := math.Min(towerMaxSide, math.Min(lenU, lenV)-2*towerInset)
+	numU := rowCount(lenU-2*towerInset, side, towerSeparation)
+	numV := rowCount(lenV-2*towerInset, side, towerSeparation)
+	spanU := float64(numU)*side + float64(numU-1)*towerSeparation
+	spanV := float64(numV)*side + float64(numV-1)*towerSeparation
+	for iu := 0; iu < numU; iu++ {
+		for iv := 0; iv < numV; iv++ {
+			u := u0 + (lenU-spanU)/2 + float64(iu)*(side+towerSeparation) + side/2
+			v := v0 + (lenV-spanV)/2 + float64(iv)*(side+towerSeparation) + side/2
+			b, ok := f.building(block, u, v, side, side, towerStories)
+			if !ok {
+				continue
+			}
+			b.Position[1] = float64(podiumStories) * storyHeightM
+			b.setFloors(residentialRun(side, side, towerStories, t.UnitAreaM2))
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// perimeterBlock is four wings along the block edges enclosing a shared
+// courtyard, the European block of rings 2 and 3.
+type perimeterBlock struct{}
+
+const (
+	perimeterSetback    = 3.0
+	perimeterWingDepth  = 12.0
+	perimeterMinCourt   = 8.0
+	perimeterMaxStories = 8
+)
+
+func (perimeterBlock) Name() string { return "perimeter_block" }
+
+func (perimeterBlock) Fits(block Block, t PlacementTargets) bool {
+	if t.MaxStories < 2 {
+		return false
+	}
+	_, _, lenU, lenV := newBlockFrame(block.Polygon).inset(perimeterSetback)
+	minSide := 2*perimeterWingDepth + perimeterMinCourt
+	return lenU >= minSide && lenV >= minSide
+}
+
+func (perimeterBlock) Place(block Block, t PlacementTargets) []Building {
+	const d = perimeterWingDepth
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(perimeterSetback)
+	stories := min(t.MaxStories, perimeterMaxStories)
+
+	// Long wings run the full length of the block; the end wings close
+	// the courtyard between them.
+	wings := [][4]float64{ // center u, center v, width, depth
+		{u0 + lenU/2, v0 + d/2, lenU, d},
+		{u0 + lenU/2, v0 + lenV - d/2, lenU, d},
+		{u0 + d/2, v0 + lenV/2, d, lenV - 2*d},
+		{u0 + lenU - d/2, v0 + lenV/2, d, lenV - 2*d},
+	}
+	var out []Building
+	for _, w := range wings {
+		b, ok := f.building(block, w[0], w[1], w[2], w[3], stories)
+		if !ok {
+			continue
+		}
+		b.setFloors(housingFloors(w[2], w[3], stories, t)...)
+		out = append(out, b)
+	}
+	return out
+}
+
+// slab is parallel linear bars along the block, spaced for daylight in
+// proportion to their height.
+type slab struct{}
+
+const (
+	slabSetback    = 3.0
+	slabDepth      = 14.0
+	slabMinLength  = 24.0
+	slabMaxStories = 12
+)
+
+func (slab) Name() string { return "slab" }
+
+func (slab) Fits(block Block, t PlacementTargets) bool {
+	_, _, lenU, lenV := newBlockFrame(block.Polygon).inset(slabSetback)
+	return t.MaxStories >= 2 && lenU >= slabMinLength && lenV >= slabDepth
+}
+
+func (slab) Place(block Block, t PlacementTargets) []Building {
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(slabSetback)
+	stories := min(t.MaxStories, slabMaxStories)
+	gap := math.Max(12, 0.5*float64(stories)*storyHeightM)
+	n := rowCount(lenV, slabDepth, gap)
+	span := float64(n)*slabDepth + float64(n-1)*gap
+
+	var out []Building
+	for i := 0; i < n; i++ {
+		v := v0 + (lenV-span)/2 + float64(i)*(slabDepth+gap) + slabDepth/2
+		b, ok := f.building(block, u0+lenU/2, v, lenU, slabDepth, stories)
+		if !ok {
+			continue
+		}
+		b.setFloors(housingFloors(lenU, slabDepth, stories, t)...)
+		out = append(out, b)
+	}
+	return out
+}
+
+// townhouseRow is rows of attached houses with back gardens, in runs of
+// at most eight: the garden-village form of ring 1. Each house is one
+// dwelling unit.
+type townhouseRow struct{}
+
+const (
+	townhouseSetback    = 3.0
+	townhouseWidth      = 6.0
+	townhouseDepth      = 10.0
+	townhouseGarden     = 8.0 // between rows
+	townhousePassage    = 4.0 // between runs in a row
+	townhouseMinRun     = 3
+	townhouseMaxRun     = 8
+	townhouseMaxStories = 3
+)
+
+func (townhouseRow) Name() string { return "townhouse_row" }
+
+func (townhouseRow) Fits(block Block, _ PlacementTargets) bool {
+	_, _, lenU, lenV := newBlockFrame(block.Polygon).inset(townhouseSetback)
+	return lenU >= townhouseMinRun*townhouseWidth && lenV >= townhouseDepth
+}
+
+func (townhouseRow) Place(block Block, t PlacementTargets) []Building {
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(townhouseSetback)
+	stories := min(t.MaxStories, townhouseMaxStories)
+	rows := rowCount(lenV, townhouseDepth, townhouseGarden)
+
+	// Split each row into runs separated by passages.
+	houses := int(math.Floor(lenU / townhouseWidth))
+	runs := int(math.Ceil(float64(houses) / townhouseMaxRun))
+	for runs > 1 && float64(houses)*townhouseWidth+float64(runs-1)*townhousePassage > lenU {
+		houses--
+	}
+	var out []Building
+	for r := 0; r < rows; r++ {
+		v := v0 + float64(r)*(townhouseDepth+townhouseGarden) + townhouseDepth/2
+		u := u0
+		for k := 0; k < runs; k++ {
+			n := houses / runs
+			if k < houses%runs {
+				n++
+			}
+			if n < townhouseMinRun {
+				continue
+			}
+			w := float64(n) * townhouseWidth
+			b, ok := f.building(block, u+w/2, v, w, townhouseDepth, stories)
+			u += w + townhousePassage
+			if !ok {
+				continue
+			}
+			house := floorRun(UseResidential, w, townhouseDepth, stories)
+			house.Units = n
+			b.setFloors(house)
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// detachedCluster is detached houses on staggered rows around shared
+// green, the lowest-density form. Each house is one dwelling unit.
+type detachedCluster struct{}
+
+const (
+	detachedSetback    = 3.0
+	detachedWidth      = 10.0
+	detachedDepth      = 9.0
+	detachedSpacing    = 6.0
+	detachedMaxStories = 2
+)
+
+func (detachedCluster) Name() string { return "detached_cluster" }
+
+func (detachedCluster) Fits(block Block, _ PlacementTargets) bool {
+	_, _, lenU, lenV := newBlockFrame(block.Polygon).inset(detachedSetback)
+	return lenU >= detachedWidth && lenV >= detachedDepth
+}
+
+func (detachedCluster) Place(block Block, t PlacementTargets) []Building {
+	f := newBlockFrame(block.Polygon)
+	u0, v0, lenU, lenV := f.inset(detachedSetback)
+	stories := min(t.MaxStories, detachedMaxStories)
+	rows := rowCount(lenV, detachedDepth, detachedSpacing)
+	stepU := detachedWidth + detachedSpacing
+
+	var out []Building
+	for r := 0; r < rows; r++ {
+		v := v0 + float64(r)*(detachedDepth+detachedSpacing) + detachedDepth/2
+		// Odd rows shift by half a house so gardens open diagonally.
+		shift := 0.0
+		if r%2 == 1 {
+			shift = stepU / 2
+		}
+		for u := u0 + shift + detachedWidth/2; u+detachedWidth/2 <= u0+lenU+1e-9; u += stepU {
+			b, ok := f.building(block, u, v, detachedWidth, detachedDepth, stories)
+			if !ok {
+				continue
+			}
+			house := floorRun(UseResidential, detachedWidth, detachedDepth, stories)
+			house.Units = 1
+			b.setFloors(house)
+			out = append(out, b)
+		}
+	}
+	return out
+}
@@ -0,0 +1,319 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// storyHeightM is the floor-to-floor height used to stack buildings.
+const storyHeightM = 3.0
+
+// BuildingTypology is a residential building form. Each residential block
+// is built with the first typology of its ring character that fits it.
+type BuildingTypology interface {
+	// Name identifies the typology in specs and scene metadata.
+	Name() string
+	// Fits reports whether the typology can be built on the block.
+	Fits(block Block, t PlacementTargets) bool
+	// Place lays out the typology's buildings on the block and stacks
+	// their floors. The caller assigns IDs, pod IDs and types.
+	Place(block Block, t PlacementTargets) []Building
+}
+
+// PlacementTargets are the limits and goals a typology builds to on one
+// block.
+type PlacementTargets struct {
+	MaxStories        int     // height envelope at the block
+	UnitAreaM2        float64 // gross floor area per dwelling unit
+	GroundFloorRetail bool    // housing of three or more stories has a retail ground floor
+}
+
+// defaultUnitAreaM2 is the gross floor area of an average dwelling unit.
+const defaultUnitAreaM2 = 75.0
+
+var (
+	typologyMu sync.RWMutex
+	typologies = map[string]BuildingTypology{}
+)
+
+// RegisterTypology makes a typology available by name to specs and to the
+// default assignments. It panics if the name is already registered.
+func RegisterTypology(t BuildingTypology) {
+	typologyMu.Lock()
+	defer typologyMu.Unlock()
+	if _, dup := typologies[t.Name()]; dup {
+		panic("layout: typology registered twice: " + t.Name())
+	}
+	typologies[t.Name()] = t
+}
+
+// LookupTypology returns the registered typology with the given name.
+func LookupTypology(name string) (BuildingTypology, bool) {
+	typologyMu.RLock()
+	defer typologyMu.RUnlock()
+	t, ok := typologies[name]
+	return t, ok
+}
+
+// TypologyNames returns the registered typology names, sorted.
+func TypologyNames() []string {
+	typologyMu.RLock()
+	defer typologyMu.RUnlock()
+	names := make([]string, 0, len(typologies))
+	for name := range typologies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fallbackTypology builds blocks whose ring character has no typology
+// that fits.
+const fallbackTypology = "courtyard"
+
+// defaultTypologies lists each ring character's typologies in order of
+// preference (pod-form-factors.html): towers in the center, perimeter
+// blocks mid-ring, townhouses at the edge. Specs override these with
+// pods.building_typologies.
+var defaultTypologies = map[string][]string{
+	"civic_commercial":  {"tower_on_podium", "slab"},
+	"high_density":      {"tower_on_podium", "slab"},
+	"urban_midrise":     {"perimeter_block", "slab"},
+	"mixed_residential": {"perimeter_block", "townhouse_row"},
+	"low_density":       {"townhouse_row", "detached_cluster"},
+}
+
+// typologyNames returns the typology names a ring character builds with:
+// the spec's list if it has one, else the default, always ending with the
+// fallback.
+func typologyNames(s *spec.CitySpec, character string) []string {
+	names, ok := s.Pods.BuildingTypologies[character]
+	if !ok {
+		names = defaultTypologies[character]
+	}
+	out := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		if name != fallbackTypology {
+			out = append(out, name)
+		}
+	}
+	return append(out, fallbackTypology)
+}
+
+// typologiesFor resolves a ring character's typologies, skipping names
+// that are not registered.
+func typologiesFor(s *spec.CitySpec, character string) []BuildingTypology {
+	var out []BuildingTypology
+	for _, name := range typologyNames(s, character) {
+		if t, ok := LookupTypology(name); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// unknownTypologies lists the spec's typology references that name no
+// registered typology, as "character: name".
+func unknownTypologies(s *spec.CitySpec) []string {
+	characters := make([]string, 0, len(s.Pods.BuildingTypologies))
+	for character := range s.Pods.BuildingTypologies {
+		characters = append(characters, character)
+	}
+	sort.Strings(characters)
+	var out []string
+	for _, character := range characters {
+		for _, name := range s.Pods.BuildingTypologies[character] {
+			if _, ok := LookupTypology(name); !ok {
+				out = append(out, fmt.Sprintf("%s: %s", character, name))
+			}
+		}
+	}
+	return out
+}
+
+// blockFrame is a block's local frame: u runs along the block's long axis
+// and v across it. The rectangle [minU, maxU] × [minV, maxV] lies within
+// the block (for the convex and gently curved shapes blocks have).
+type blockFrame struct {
+	origin     geo.Point2D
+	u, v       geo.Point2D
+	minU, maxU float64
+	minV, maxV float64
+}
+
+// newBlockFrame picks the frame with the largest inscribed rectangle from
+// the block's radial direction and the directions of its edges, so curved
+// and clipped blocks are built square to their longest straight sides.
+func newBlockFrame(poly geo.Polygon) blockFrame {
+	origin := poly.Vertices[0]
+	dirs := []geo.Point2D{poly.Centroid().Normalize()}
+	for i := range poly.Vertices {
+		a, b := poly.Edge(i)
+		if a.Distance(b) >= 5 {
+			dirs = append(dirs, b.Sub(a).Normalize())
+		}
+	}
+
+	var best blockFrame
+	bestArea := -1.0
+	for _, u := range dirs {
+		if u.Length() == 0 {
+			continue
+		}
+		f := inscribedFrame(poly, origin, u)
+		if area := (f.maxU - f.minU) * (f.maxV - f.minV); area > bestArea+1e-6 {
+			best, bestArea = f, area
+		}
+	}
+	if bestArea < 0 {
+		best = inscribedFrame(poly, origin, geo.Pt(1, 0))
+	}
+	if best.maxV-best.minV > best.maxU-best.minU {
+		// Turn the frame a quarter so u is the long axis.
+		best.u, best.v = best.v, best.v.Perp()
+		best.minU, best.maxU, best.minV, best.maxV = best.minV, best.maxV, -best.maxU, -best.minU
+	}
+	return best
+}
+
+// inscribedFrame returns the frame along u with the largest rectangle
+// found by trimming the polygon's extent along u in steps from each end and
+// narrowing v to the span the polygon covers across the whole trimmed
+// range.
+func inscribedFrame(poly geo.Polygon, origin, u geo.Point2D) blockFrame {
+	f := blockFrame{origin: origin, u: u, v: u.Perp()}
+	lowU, highU := math.MaxFloat64, -math.MaxFloat64
+	local := make([][2]float64, len(poly.Vertices))
+	for i, p := range poly.Vertices {
+		rel := p.Sub(origin)
+		local[i] = [2]float64{rel.Dot(f.u), rel.Dot(f.v)}
+		lowU, highU = math.Min(lowU, local[i][0]), math.Max(highU, local[i][0])
+	}
+
+	const (
+		trimSteps = 6  // trims tried at each end
+		trimStep  = 20 // the extent along u divided by this is one trim
+		samples   = 7  // lines across the range where the span is taken
+	)
+	step := (highU - lowU) / trimStep
+	bestArea := -1.0
+	for i := 0; i < trimSteps; i++ {
+		for j := 0; j < trimSteps; j++ {
+			u0, u1 := lowU+float64(i)*step, highU-float64(j)*step
+			v0, v1 := -math.MaxFloat64, math.MaxFloat64
+			for k := 0; k < samples; k++ {
+				lo, hi, ok := polygonSpan(local, 0, u0+(u1-u0)*float64(k)/(samples-1))
+				if !ok {
+					lo, hi = 0, 0
+				}
+				v0, v1 = math.Max(v0, lo), math.Min(v1, hi)
+			}
+			if area := (u1 - u0) * math.Max(0, v1-v0); area > bestArea {
+				bestArea = area
+				f.minU, f.maxU, f.minV, f.maxV = u0, u1, v0, math.Max(v0, v1)
+			}
+		}
+	}
+	return f
+}
+
+// polygonSpan returns the extent of the polygon along the line where
+// coordinate axis equals at, as the range of the other coordinate.
+func polygonSpan(pts [][2]float64, axis int, at float64) (lo, hi float64, ok bool) {
+	other := 1 - axis
+	lo, hi = math.MaxFloat64, -math.MaxFloat64
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		if (a[axis] < at) == (b[axis] < at) {
+			continue
+		}
+		t := (at - a[axis]) / (b[axis] - a[axis])
+		x := a[other] + t*(b[other]-a[other])
+		lo, hi, ok = math.Min(lo, x), math.Max(hi, x), true
+	}
+	return lo, hi, ok
+}
+
+// inset returns the frame's extent shrunk by setback on every side, as
+// its lower corner and size.
+func (f blockFrame) inset(setback float64) (u0, v0, lenU, lenV float64) {
+	return f.minU + setback, f.minV + setback, f.maxU - f.minU - 2*setback, f.maxV - f.minV - 2*setback
+}
+
+func (f blockFrame) world(u, v float64) geo.Point2D {
+	return f.origin.Add(f.u.Scale(u)).Add(f.v.Scale(v))
+}
+
+// rotation is the yaw that aligns a footprint's width with u.
+func (f blockFrame) rotation() float64 {
+	return math.Atan2(f.u.Z, f.u.X)
+}
+
+// building returns a w × d building centered at (u, v), its width along
+// u, or false if any corner falls outside the block.
+func (f blockFrame) building(block Block, u, v, w, d float64, stories int) (Building, bool) {
+	for _, c := range [4][2]float64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		if !block.Polygon.Contains(f.world(u+c[0]*w/2, v+c[1]*d/2)) {
+			return Building{}, false
+		}
+	}
+	pos := f.world(u, v)
+	return Building{
+		Position:  [3]float64{pos.X, 0, pos.Z},
+		Footprint: [2]float64{w, d},
+		Rotation:  f.rotation(),
+		Stories:   stories,
+	}, true
+}
+
+// unitsFor sizes a building's dwelling units to its gross floor area, at
+// least one per floor.
+func unitsFor(w, d float64, stories int, unitArea float64) int {
+	perFloor := math.Max(1, math.Floor(w*d/unitArea))
+	return int(perFloor) * stories
+}
+
+// rowCount returns how many items of size n with gaps of gap fit in length.
+func rowCount(length, n, gap float64) int {
+	if length < n {
+		return 0
+	}
+	return int(math.Floor((length+gap)/(n+gap) + 1e-9))
+}
+
+// placeResidentialOnBlock builds a block with the first of typs that fits
+// it and places any buildings. Buildings without housing, such as tower
+// podiums, are typed commercial.
+func placeResidentialOnBlock(block Block, pod Pod, env spec.HeightEnvelope, typs []BuildingTypology, t PlacementTargets, idx *int) ([]Building, int) {
+	t.MaxStories = blockMaxStories(env, block.Polygon)
+
+	for _, typ := range typs {
+		if !typ.Fits(block, t) {
+			continue
+		}
+		placed := typ.Place(block, t)
+		if len(placed) == 0 {
+			continue
+		}
+		totalDU := 0
+		for i := range placed {
+			b := &placed[i]
+			b.ID = fmt.Sprintf("bldg_%05d", *idx)
+			b.PodID = pod.ID
+			b.Type = "residential"
+			if b.DwellingUnits == 0 {
+				b.Type = "commercial"
+			}
+			b.Typology = typ.Name()
+			totalDU += b.DwellingUnits
+			*idx++
+		}
+		return placed, totalDU
+	}
+	return nil, 0
+}
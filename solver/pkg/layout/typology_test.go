@@ -0,0 +1,165 @@
+package layout
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// testBlock returns a 60 × 40 m block centered at c with its long side
+// along angle.
+func testBlock(c geo.Point2D, angle float64) Block {
+	u := geo.Pt(math.Cos(angle), math.Sin(angle))
+	v := u.Perp()
+	corner := func(a, b float64) geo.Point2D { return c.Add(u.Scale(a)).Add(v.Scale(b)) }
+	poly := geo.NewPolygon(corner(-30, -20), corner(30, -20), corner(30, 20), corner(-30, 20))
+	return Block{ID: "test_block", Polygon: poly, AreaM2: poly.Area()}
+}
+
+func TestTypologiesStayInBlockWithoutOverlap(t *testing.T) {
+	block := testBlock(geo.Pt(500, 300), 0.5)
+	f := newBlockFrame(block.Polygon)
+
+	for _, name := range TypologyNames() {
+		typ, _ := LookupTypology(name)
+		for _, stories := range []int{2, 4, 8, 16, 32} {
+			targets := PlacementTargets{MaxStories: stories, UnitAreaM2: defaultUnitAreaM2}
+			if !typ.Fits(block, targets) {
+				continue
+			}
+			placed := typ.Place(block, targets)
+			if len(placed) == 0 {
+				t.Errorf("%s at %d stories: fits but placed nothing", name, stories)
+				continue
+			}
+
+			type rect struct{ u0, u1, v0, v1, y0, y1 float64 }
+			var rects []rect
+			for _, b := range placed {
+				if b.DwellingUnits < 1 && b.CommercialSqM <= 0 {
+					t.Errorf("%s: building with neither dwelling units nor commercial space", name)
+				}
+				top := b.Position[1] + float64(b.Stories)*storyHeightM
+				if b.Stories < 1 || top > float64(stories)*storyHeightM+1e-9 {
+					t.Errorf("%s: building from %.0f m with %d stories exceeds the %d-story envelope", name, b.Position[1], b.Stories, stories)
+				}
+				rel := geo.Pt(b.Position[0], b.Position[2]).Sub(f.origin)
+				cu, cv := rel.Dot(f.u), rel.Dot(f.v)
+				rects = append(rects, rect{
+					cu - b.Footprint[0]/2, cu + b.Footprint[0]/2,
+					cv - b.Footprint[1]/2, cv + b.Footprint[1]/2,
+					b.Position[1], top,
+				})
+			}
+			const eps = 1e-6
+			for i := range rects {
+				a := rects[i]
+				if a.u0 < f.minU-eps || a.u1 > f.maxU+eps || a.v0 < f.minV-eps || a.v1 > f.maxV+eps {
+					t.Errorf("%s: building %d extends outside the block", name, i)
+				}
+				for j := i + 1; j < len(rects); j++ {
+					b := rects[j]
+					if a.u0 < b.u1-eps && b.u0 < a.u1-eps && a.v0 < b.v1-eps && b.v0 < a.v1-eps &&
+						a.y0 < b.y1-eps && b.y0 < a.y1-eps {
+						t.Errorf("%s at %d stories: buildings %d and %d overlap", name, stories, i, j)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTypologyFitsByHeight(t *testing.T) {
+	block := testBlock(geo.Pt(0, 400), 0)
+	tower, _ := LookupTypology("tower_on_podium")
+	if tower.Fits(block, PlacementTargets{MaxStories: 4, UnitAreaM2: defaultUnitAreaM2}) {
+		t.Error("tower_on_podium fits under a 4-story envelope")
+	}
+	if !tower.Fits(block, PlacementTargets{MaxStories: 16, UnitAreaM2: defaultUnitAreaM2}) {
+		t.Error("tower_on_podium does not fit a 60 × 40 m block at 16 stories")
+	}
+}
+
+func TestPlaceBuildingsDefaultTypologies(t *testing.T) {
+	s := defaultSpec()
+	// Map the test rings onto characters with default typologies.
+	s.Pods.RingAssignments["center"] = withCharacter(s, "center", "civic_commercial")
+	s.Pods.RingAssignments["edge"] = withCharacter(s, "edge", "low_density")
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	buildings, _, _ := PlaceBuildings(s, pods, adjacency, params)
+
+	byRing := map[string]map[string]int{}
+	for _, b := range buildings {
+		if b.Type != "residential" {
+			continue
+		}
+		ring := strings.Split(b.PodID, "_")[1]
+		if byRing[ring] == nil {
+			byRing[ring] = map[string]int{}
+		}
+		byRing[ring][b.Typology]++
+	}
+	if byRing["center"]["tower_on_podium"] == 0 {
+		t.Errorf("center typologies = %v, want tower_on_podium", byRing["center"])
+	}
+	if byRing["edge"]["townhouse_row"] == 0 {
+		t.Errorf("edge typologies = %v, want townhouse_row", byRing["edge"])
+	}
+	if byRing["middle"]["courtyard"] == 0 || len(byRing["middle"]) != 1 {
+		t.Errorf("middle typologies = %v, want only the courtyard fallback", byRing["middle"])
+	}
+}
+
+func TestPlaceBuildingsSpecTypologies(t *testing.T) {
+	s := defaultSpec()
+	s.Pods.BuildingTypologies = map[string][]string{
+		"mixed": {"no_such_typology", "slab"},
+	}
+	params := defaultParams()
+	pods, adjacency, _ := LayoutPods(s, params)
+	buildings, _, report := PlaceBuildings(s, pods, adjacency, params)
+
+	slabs := 0
+	for _, b := range buildings {
+		if b.Type == "residential" && strings.HasPrefix(b.PodID, "pod_middle") {
+			if b.Typology != "slab" && b.Typology != fallbackTypology {
+				t.Errorf("middle building %s has typology %q", b.ID, b.Typology)
+			}
+			if b.Typology == "slab" {
+				slabs++
+			}
+		}
+	}
+	if slabs == 0 {
+		t.Error("spec typology slab was not used in the middle ring")
+	}
+
+	warned := false
+	for _, w := range report.Warnings {
+		if strings.Contains(w.Message, "no_such_typology") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("unknown typology was not reported")
+	}
+}
+
+func TestRegisterTypologyTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate typology did not panic")
+		}
+	}()
+	RegisterTypology(slab{})
+}
+
+func withCharacter(s *spec.CitySpec, ring, character string) spec.PodRing {
+	pr := s.Pods.RingAssignments[ring]
+	pr.Character = character
+	return pr
+}
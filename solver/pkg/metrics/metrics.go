@@ -0,0 +1,286 @@
+// Package metrics measures the placed city per pod, ring and city: floor
+// area ratio, ground coverage, dwelling, resident and job densities, green
+// space per capita and impervious surface, and compares the densities with
+// the analytical targets computed before any geometry existed.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+const (
+	m2PerHa            = 10000
+	maxDeviation       = 0.25 // measured density off its target by more than this fraction warns
+	groundTolM         = 0.01 // buildings based higher stand on another building
+	cityName           = "city"
+	deviationPodsShown = 5
+)
+
+// Metrics are the measured land-use figures for one pod, ring or the
+// whole city. Areas are of the pods' land, so rings and the city exclude
+// land between pods.
+type Metrics struct {
+	Name   string  `json:"name"`           // pod ID, ring name or "city"
+	Ring   string  `json:"ring,omitempty"` // pods only
+	AreaHa float64 `json:"area_ha"`
+
+	FAR            float64 `json:"far"`             // gross floor area / land area
+	GroundCoverage float64 `json:"ground_coverage"` // footprints on the ground / land area
+
+	DwellingUnits  int     `json:"dwelling_units"`
+	DUPerHa        float64 `json:"du_per_ha"` // per hectare of residential zone
+	Residents      int     `json:"residents"` // dwelling units × the ring's household size
+	ResidentsPerHa float64 `json:"residents_per_ha"`
+	Jobs           int     `json:"jobs"` // from floor area by use and service type
+	JobsPerHa      float64 `json:"jobs_per_ha"`
+
+	GreenM2PerCapita   float64 `json:"green_m2_per_capita"`
+	ImperviousFraction float64 `json:"impervious_fraction"` // buildings, paths and plazas / land area
+
+	// TargetDUPerHa is analytics' required density on residential land,
+	// and TargetResidentsPerHa the pods' target population over their land.
+	TargetDUPerHa        float64 `json:"target_du_per_ha"`
+	TargetResidentsPerHa float64 `json:"target_residents_per_ha"`
+}
+
+// Report holds the metrics of every pod, every ring with pods, and the city.
+type Report struct {
+	City  Metrics   `json:"city"`
+	Rings []Metrics `json:"rings"`
+	Pods  []Metrics `json:"pods"`
+}
+
+// Pod returns the metrics of the pod with the given ID, or nil.
+func (r *Report) Pod(id string) *Metrics {
+	for i := range r.Pods {
+		if r.Pods[i].Name == id {
+			return &r.Pods[i]
+		}
+	}
+	return nil
+}
+
+// totals accumulates the measured quantities behind Metrics.
+type totals struct {
+	landM2, residentialM2    float64
+	floorM2, groundM2        float64
+	imperviousM2, greenM2    float64
+	du                       int
+	residents, jobs          float64
+	targetPop, targetDUPerHa float64
+	householdSize            float64 // pods only
+}
+
+func (t *totals) add(o totals) {
+	t.landM2 += o.landM2
+	t.residentialM2 += o.residentialM2
+	t.floorM2 += o.floorM2
+	t.groundM2 += o.groundM2
+	t.imperviousM2 += o.imperviousM2
+	t.greenM2 += o.greenM2
+	t.du += o.du
+	t.residents += o.residents
+	t.jobs += o.jobs
+	t.targetPop += o.targetPop
+}
+
+func (t totals) metrics(name string) Metrics {
+	m := Metrics{
+		Name:          name,
+		AreaHa:        t.landM2 / m2PerHa,
+		DwellingUnits: t.du,
+		Residents:     int(math.Round(t.residents)),
+		Jobs:          int(math.Round(t.jobs)),
+		TargetDUPerHa: t.targetDUPerHa,
+	}
+	if t.landM2 > 0 {
+		ha := t.landM2 / m2PerHa
+		m.FAR = t.floorM2 / t.landM2
+		m.GroundCoverage = math.Min(1, t.groundM2/t.landM2)
+		m.ResidentsPerHa = t.residents / ha
+		m.JobsPerHa = t.jobs / ha
+		m.ImperviousFraction = math.Min(1, t.imperviousM2/t.landM2)
+		m.TargetResidentsPerHa = t.targetPop / ha
+	}
+	if t.residentialM2 > 0 {
+		m.DUPerHa = float64(t.du) / (t.residentialM2 / m2PerHa)
+	}
+	if t.residents > 0 {
+		m.GreenM2PerCapita = t.greenM2 / t.residents
+	}
+	return m
+}
+
+// Measure computes the metrics of the placed city. Pods are measured over
+// their boundary, with residential land from their zone allocation, and
+// residents are estimated from dwelling units at the ring's household
+// size. The report warns about rings whose measured dwelling or resident
+// density is more than 25% off the analytical target.
+func Measure(
+	s *spec.CitySpec,
+	params *analytics.ResolvedParameters,
+	pods []layout.Pod,
+	buildings []layout.Building,
+	paths []layout.PathSegment,
+	greenZones []layout.Zone,
+	plazas []layout.Plaza,
+) (*Report, *validation.Report) {
+	rings := make(map[string]analytics.RingData, len(params.Rings))
+	for _, rd := range params.Rings {
+		rings[rd.Name] = rd
+	}
+
+	byPod := make(map[string]*totals, len(pods))
+	for _, pod := range pods {
+		byPod[pod.ID] = &totals{
+			landM2:        pod.AreaHa * m2PerHa,
+			residentialM2: residentialM2(s, pod),
+			targetPop:     float64(pod.TargetPopulation),
+			targetDUPerHa: rings[pod.Ring].RequiredDensity,
+			householdSize: rings[pod.Ring].AvgHouseholdSize,
+		}
+	}
+
+	for _, b := range buildings {
+		t := byPod[b.PodID]
+		if t == nil {
+			continue
+		}
+		footprint := b.Footprint[0] * b.Footprint[1]
+		if b.Position[1] < groundTolM {
+			t.groundM2 += footprint
+			t.imperviousM2 += footprint
+		}
+		for _, f := range b.Floors {
+			t.floorM2 += f.GrossAreaM2
+		}
+		t.jobs += b.Jobs()
+		t.du += b.DwellingUnits
+		t.residents += float64(b.DwellingUnits) * t.householdSize
+	}
+	for _, p := range paths {
+		if t := byPod[p.PodID]; t != nil {
+			t.imperviousM2 += p.Start.Distance(p.End) * p.WidthM
+		}
+	}
+	for _, p := range plazas {
+		if t := byPod[p.PodID]; t != nil {
+			t.imperviousM2 += p.Width * p.Depth
+		}
+	}
+	for _, z := range greenZones {
+		if t := byPod[z.PodID]; t != nil {
+			t.greenM2 += z.Polygon.Area()
+		}
+	}
+
+	r := &Report{}
+	var city totals
+	ringTotals := make(map[string]*totals)
+	for _, pod := range pods {
+		t := byPod[pod.ID]
+		m := t.metrics(pod.ID)
+		m.Ring = pod.Ring
+		r.Pods = append(r.Pods, m)
+
+		rt := ringTotals[pod.Ring]
+		if rt == nil {
+			rt = &totals{targetDUPerHa: t.targetDUPerHa}
+			ringTotals[pod.Ring] = rt
+		}
+		rt.add(*t)
+		city.add(*t)
+	}
+	for _, ring := range s.CityZones.Rings {
+		if rt := ringTotals[ring.Name]; rt != nil {
+			r.Rings = append(r.Rings, rt.metrics(ring.Name))
+		}
+	}
+	city.targetDUPerHa = params.RequiredDensityDUHa
+	r.City = city.metrics(cityName)
+
+	return r, r.validate()
+}
+
+// residentialM2 returns the area of the pod's residential zones.
+func residentialM2(s *spec.CitySpec, pod layout.Pod) float64 {
+	ringChar := ""
+	if pr, ok := s.Pods.RingAssignments[pod.Ring]; ok {
+		ringChar = pr.Character
+	}
+	var radii [2]float64
+	if ring := s.CityZones.RingByName(pod.Ring); ring != nil {
+		radii = [2]float64{ring.RadiusFrom, ring.RadiusTo}
+	}
+	area := 0.0
+	for _, z := range layout.AllocateZones(pod, ringChar, radii[0], radii[1]) {
+		if z.Type == layout.ZoneResidential {
+			area += z.Polygon.Area()
+		}
+	}
+	return area
+}
+
+// validate summarizes the city's metrics and warns about rings whose
+// densities stray from their targets, naming the pods that stray most.
+func (r *Report) validate() *validation.Report {
+	report := validation.NewReport()
+	c := r.City
+	report.AddInfo(validation.Result{
+		Level: validation.LevelSpatial,
+		Message: fmt.Sprintf("measured FAR %.2f, ground coverage %.0f%%, %.0f du/ha residential, %.0f residents/ha, %.0f jobs/ha, %.0f m² green per resident, %.0f%% impervious",
+			c.FAR, c.GroundCoverage*100, c.DUPerHa, c.ResidentsPerHa, c.JobsPerHa, c.GreenM2PerCapita, c.ImperviousFraction*100),
+	})
+
+	checks := []struct {
+		label            string
+		measured, target func(Metrics) float64
+	}{
+		{"du/ha on residential land", func(m Metrics) float64 { return m.DUPerHa }, func(m Metrics) float64 { return m.TargetDUPerHa }},
+		{"residents/ha", func(m Metrics) float64 { return m.ResidentsPerHa }, func(m Metrics) float64 { return m.TargetResidentsPerHa }},
+	}
+	for _, ring := range r.Rings {
+		for _, ck := range checks {
+			got, want := ck.measured(ring), ck.target(ring)
+			if !deviates(got, want) {
+				continue
+			}
+			var ids []string
+			for _, p := range r.Pods {
+				if p.Ring == ring.Name && deviates(ck.measured(p), ck.target(p)) {
+					ids = append(ids, p.Name)
+				}
+			}
+			msg := fmt.Sprintf("ring %s: measured %.0f %s is %+.0f%% off the analytical %.0f",
+				ring.Name, got, ck.label, (got-want)/want*100, want)
+			if len(ids) > 0 {
+				shown := ids
+				if len(shown) > deviationPodsShown {
+					shown = append(shown[:deviationPodsShown:deviationPodsShown], "...")
+				}
+				msg += " in pods " + strings.Join(shown, ", ")
+			}
+			report.AddWarning(validation.Result{
+				Level:       validation.LevelSpatial,
+				Message:     msg,
+				ActualValue: got,
+				Expected:    fmt.Sprintf("%.0f ± %.0f%%", want, maxDeviation*100),
+				EntityIDs:   ids,
+			})
+		}
+	}
+	return report
+}
+
+// deviates reports whether got is off a positive want by more than
+// maxDeviation.
+func deviates(got, want float64) bool {
+	return want > 0 && math.Abs(got-want) > maxDeviation*want
+}
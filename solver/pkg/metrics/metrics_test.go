@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/geo"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+func building(id string, w, d, y float64, floors ...layout.FloorUse) layout.Building {
+	b := layout.Building{ID: id, PodID: "pod_a", Position: [3]float64{150, y, 0}, Footprint: [2]float64{w, d}, Floors: floors}
+	for _, f := range floors {
+		b.Stories += f.Stories
+		b.DwellingUnits += f.Units
+	}
+	return b
+}
+
+func TestMeasure(t *testing.T) {
+	s := &spec.CitySpec{
+		CityZones: spec.CityZones{Rings: []spec.RingDef{{Name: "center", RadiusFrom: 0, RadiusTo: 300, MaxStories: 10}}},
+		Pods:      spec.PodsDef{RingAssignments: map[string]spec.PodRing{"center": {Character: "civic_commercial"}}},
+	}
+	params := &analytics.ResolvedParameters{
+		RequiredDensityDUHa: 40,
+		Rings:               []analytics.RingData{{Name: "center", AvgHouseholdSize: 2, RequiredDensity: 40}},
+	}
+	pod := layout.Pod{
+		ID: "pod_a", Ring: "center", Center: [2]float64{150, 0},
+		Boundary: [][2]float64{{100, -50}, {200, -50}, {200, 50}, {100, 50}},
+		AreaHa:   1, TargetPopulation: 100,
+	}
+	buildings := []layout.Building{
+		building("housing", 20, 20, 0, layout.FloorUse{Use: layout.UseResidential, Stories: 4, GrossAreaM2: 1600, Units: 20}),
+		building("offices", 25, 20, 0,
+			layout.FloorUse{Use: layout.UseRetail, Stories: 1, GrossAreaM2: 500},
+			layout.FloorUse{Use: layout.UseCoworking, Stories: 2, GrossAreaM2: 1000}),
+		building("podium", 20, 20, 0, layout.FloorUse{Use: layout.UseCivic, Stories: 2, GrossAreaM2: 800}),
+		// The tower stands on the podium, so its footprint is not ground.
+		building("tower", 10, 10, 6, layout.FloorUse{Use: layout.UseResidential, Stories: 10, GrossAreaM2: 1000, Units: 10}),
+	}
+	paths := []layout.PathSegment{{PodID: "pod_a", Start: geo.Pt(100, 0), End: geo.Pt(200, 0), WidthM: 3}}
+	plazas := []layout.Plaza{{PodID: "pod_a", Width: 10, Depth: 10}}
+	greens := []layout.Zone{{PodID: "pod_a", Polygon: geo.NewPolygon(geo.Pt(100, 20), geo.Pt(130, 20), geo.Pt(130, 40), geo.Pt(100, 40))}}
+
+	r, report := Measure(s, params, []layout.Pod{pod}, buildings, paths, greens, plazas)
+	m := r.Pod("pod_a")
+	if m == nil {
+		t.Fatal("no metrics for pod_a")
+	}
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"FAR", m.FAR, 4900.0 / 10000},
+		{"ground coverage", m.GroundCoverage, 1300.0 / 10000},
+		{"residents", float64(m.Residents), 60},
+		{"residents/ha", m.ResidentsPerHa, 60},
+		{"jobs", float64(m.Jobs), 83}, // 400 m² retail / 30 + 800 m² coworking / 15 + 640 m² civic / 40
+		{"green m²/capita", m.GreenM2PerCapita, 10},
+		{"impervious fraction", m.ImperviousFraction, (1300.0 + 300 + 100) / 10000},
+		{"target residents/ha", m.TargetResidentsPerHa, 100},
+		{"du/ha", m.DUPerHa, 30 / (residentialM2(s, pod) / m2PerHa)},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %f, want %f", c.name, c.got, c.want)
+		}
+	}
+	if len(r.Rings) != 1 || r.Rings[0].FAR != m.FAR || r.City.DwellingUnits != 30 {
+		t.Errorf("ring and city totals do not match the single pod: %+v, %+v", r.Rings, r.City)
+	}
+
+	// 60 residents/ha is 40% under the target of 100.
+	var flagged bool
+	for _, w := range report.Warnings {
+		flagged = flagged || (len(w.EntityIDs) == 1 && w.EntityIDs[0] == "pod_a" && w.ActualValue == 60.0)
+	}
+	if !flagged {
+		t.Errorf("no warning naming the under-populated pod: %v", report.Warnings)
+	}
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// Cache carries stage outputs from one run to the next for incremental
+// re-solves. Runs sharing a cache are serialized. Results of incremental runs
+// share reused outputs with the cache and with earlier results, so callers
+// must treat them as read-only.
+type Cache struct {
+	mu         sync.Mutex
+	spec       *spec.CitySpec // copy of the spec of the last successful run
+	result     *Result
+	placements *layout.PlacementCache
+}
+
+// Reuse summarizes what a run took from its cache.
+type Reuse struct {
+	// SpecChanges lists the spec paths that differ from the cached run.
+	SpecChanges []string `json:"spec_changes"`
+	Reused      []Stage  `json:"reused"`
+	Recomputed  []Stage  `json:"recomputed"`
+	PodsReused  int      `json:"pods_reused"`
+	PodsPlaced  int      `json:"pods_placed"`
+}
+
+// NewCache returns an empty cache; the first run using it is a full solve.
+func NewCache() *Cache {
+	return &Cache{placements: layout.NewPlacementCache()}
+}
+
+// Reset drops all cached outputs.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spec, c.result = nil, nil
+	c.placements = layout.NewPlacementCache()
+}
+
+// begin attaches the cache to r and returns the previous result, or nil if
+// there is none.
+func (c *Cache) begin(r *Result) *Result {
+	r.placements = c.placements
+	// Scenes stamped differently are not interchangeable.
+	if c.result == nil || c.result.generatedAt != r.generatedAt {
+		return nil
+	}
+	r.Reuse.SpecChanges = spec.Diff(c.spec, r.Spec)
+	return c.result
+}
+
+// commit records r as the basis for the next run.
+func (c *Cache) commit(r *Result) {
+	c.spec = cloneSpec(r.Spec)
+	c.result = r
+}
+
+// cloneSpec deep-copies a spec so later edits by the caller are seen as
+// changes. CitySpec is plain data, so a JSON round trip cannot fail.
+func cloneSpec(s *spec.CitySpec) *spec.CitySpec {
+	data, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	var out spec.CitySpec
+	if err := json.Unmarshal(data, &out); err != nil {
+		panic(err)
+	}
+	return &out
+}
+
+// specAffects reports whether any change touches a path the stage reads.
+func specAffects(st stageDef, changes []string) bool {
+	for _, c := range changes {
+		for _, read := range st.reads {
+			if spec.PathAffects(c, read) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyOutputs sets the stage's output fields of dst to those of src.
+func copyOutputs(st stageDef, dst, src *Result) {
+	d, s := st.outputs(dst), st.outputs(src)
+	for i := range d {
+		reflect.ValueOf(d[i]).Elem().Set(reflect.ValueOf(s[i]).Elem())
+	}
+}
+
+// sameOutputs reports whether the stage produced identical outputs in a and b.
+func sameOutputs(st stageDef, a, b *Result) bool {
+	x, y := st.outputs(a), st.outputs(b)
+	for i := range x {
+		if !reflect.DeepEqual(reflect.ValueOf(x[i]).Elem().Interface(), reflect.ValueOf(y[i]).Elem().Interface()) {
+			return false
+		}
+	}
+	return true
+}
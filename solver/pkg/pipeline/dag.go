@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// selectStages returns the stages to run in canonical order: those needed
+// by opts.Targets (all if empty), up to opts.StopAfter, minus opts.Skip and
+// anything depending on a skipped stage.
+func selectStages(all []stageDef, opts Options) ([]stageDef, error) {
+	byName := make(map[Stage]stageDef, len(all))
+	for _, st := range all {
+		byName[st.name] = st
+	}
+	check := func(name Stage) error {
+		if _, ok := byName[name]; !ok {
+			return fmt.Errorf("unknown stage %q", name)
+		}
+		return nil
+	}
+
+	var needed map[Stage]bool
+	if len(opts.Targets) > 0 {
+		needed = make(map[Stage]bool)
+		var visit func(name Stage)
+		visit = func(name Stage) {
+			if needed[name] {
+				return
+			}
+			needed[name] = true
+			for _, dep := range byName[name].deps {
+				visit(dep)
+			}
+		}
+		for _, t := range opts.Targets {
+			if err := check(t); err != nil {
+				return nil, err
+			}
+			visit(t)
+		}
+	}
+	if opts.StopAfter != "" {
+		if err := check(opts.StopAfter); err != nil {
+			return nil, err
+		}
+	}
+	skipped := make(map[Stage]bool, len(opts.Skip))
+	for _, name := range opts.Skip {
+		if err := check(name); err != nil {
+			return nil, err
+		}
+		skipped[name] = true
+	}
+
+	var plan []stageDef
+	for _, st := range all {
+		for _, dep := range st.deps {
+			if skipped[dep] {
+				skipped[st.name] = true
+			}
+		}
+		if !skipped[st.name] && (needed == nil || needed[st.name]) {
+			plan = append(plan, st)
+		}
+		if st.name == opts.StopAfter {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// execute runs plan as a dependency graph with up to opts.Workers stages in
+// flight. A stage starts once all of its dependencies have finished; after
+// the first failure no further stages start. Under opts.Lenient validation
+// errors are recorded in the report but do not stop the run. Dependencies
+// left out of plan are treated as already satisfied.
+//
+// With a previous result, a stage whose spec reads are untouched and whose
+// dependencies produced unchanged outputs copies its outputs from prev
+// instead of running. A recomputed stage that reproduces prev's outputs
+// counts as unchanged, so its dependents can still be reused.
+//
+// No stage starts once ctx is done; a running stage sees ctx and may stop
+// early. Either way the run fails with ctx's error.
+func execute(ctx context.Context, plan []stageDef, r, prev *Result, opts Options) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Outputs of a stage nothing downstream reads are never compared.
+	hasDependents := make(map[Stage]bool)
+	for _, st := range plan {
+		for _, dep := range st.deps {
+			hasDependents[dep] = true
+		}
+	}
+
+	// Stages write disjoint Result fields; the report, duration and change
+	// maps are shared.
+	var mu sync.Mutex
+	changed := make(map[Stage]bool, len(plan))
+	reused := make(map[Stage]bool, len(plan))
+	canReuse := func(st stageDef) bool {
+		if prev == nil || specAffects(st, r.Reuse.SpecChanges) {
+			return false
+		}
+		if _, ran := prev.durations[st.name]; !ran {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, dep := range st.deps {
+			if changed[dep] {
+				return false
+			}
+		}
+		return true
+	}
+
+	run := func(st stageDef) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, hook := range opts.Before {
+			if err := hook(st.name, r); err != nil {
+				return fmt.Errorf("stage %s: before hook: %w", st.name, err)
+			}
+		}
+		var (
+			rep   *validation.Report
+			err   error
+			reuse = canReuse(st)
+			start = time.Now()
+		)
+		if reuse {
+			copyOutputs(st, r, prev)
+			rep = prev.reports[st.name]
+		} else {
+			rep, err = st.run(ctx, r)
+		}
+		elapsed := time.Since(start)
+		differs := !reuse
+		if !reuse && prev != nil && hasDependents[st.name] {
+			if _, ran := prev.durations[st.name]; ran {
+				differs = !sameOutputs(st, r, prev)
+			}
+		}
+
+		mu.Lock()
+		if rep != nil {
+			r.reports[st.name] = rep
+		}
+		r.durations[st.name] = elapsed
+		changed[st.name] = differs
+		reused[st.name] = reuse
+		mu.Unlock()
+		var verr *ValidationError
+		if err != nil && !(opts.Lenient && errors.As(err, &verr)) {
+			return err
+		}
+		for _, hook := range opts.After {
+			if err := hook(st.name, r); err != nil {
+				return fmt.Errorf("stage %s: after hook: %w", st.name, err)
+			}
+		}
+		return nil
+	}
+	defer func() {
+		for _, st := range plan {
+			if _, ran := r.durations[st.name]; !ran {
+				continue
+			}
+			if reused[st.name] {
+				r.Reuse.Reused = append(r.Reuse.Reused, st.name)
+			} else {
+				r.Reuse.Recomputed = append(r.Reuse.Recomputed, st.name)
+			}
+		}
+		if _, ran := r.durations[StageBuildings]; ran {
+			switch {
+			case reused[StageBuildings]:
+				r.Reuse.PodsReused = len(r.Pods)
+			case r.placements != nil:
+				r.Reuse.PodsReused, r.Reuse.PodsPlaced = r.placements.PodStats()
+			default:
+				r.Reuse.PodsPlaced = len(r.Pods)
+			}
+		}
+	}()
+
+	if workers == 1 {
+		for _, st := range plan {
+			if err := run(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	done := make(map[Stage]chan struct{}, len(plan))
+	for _, st := range plan {
+		done[st.name] = make(chan struct{})
+	}
+	var (
+		once     sync.Once
+		firstErr error
+		failed   = make(chan struct{})
+		sem      = make(chan struct{}, workers)
+		wg       sync.WaitGroup
+	)
+	for _, st := range plan {
+		wg.Add(1)
+		go func(st stageDef) {
+			defer wg.Done()
+			for _, dep := range st.deps {
+				ch, ok := done[dep]
+				if !ok {
+					continue
+				}
+				select {
+				case <-ch:
+				case <-failed:
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-failed:
+				return
+			}
+			err := run(st)
+			<-sem
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					close(failed)
+				})
+				return
+			}
+			close(done[st.name])
+		}(st)
+	}
+	wg.Wait()
+	return firstErr
+}
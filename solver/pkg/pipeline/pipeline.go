@@ -0,0 +1,521 @@
+// Package pipeline runs the solver stages — schema validation, analytical
+// resolution, cost estimation, spatial generation and scene assembly — as a
+// dependency graph. Independent stages run concurrently; every stage writes
+// only its own fields of Result, and reports are merged in a fixed stage
+// order, so output is identical to a serial run.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/education"
+	"github.com/ChicagoDave/cityplanner/pkg/employment"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
+	"github.com/ChicagoDave/cityplanner/pkg/routing"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/scene2d"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// Version identifies the solver's algorithms. Bump it whenever the same spec
+// would produce different output, so cached artifacts are regenerated;
+// the golden snapshot test fails when output changes under the same version.
+const Version = "0.8.0"
+
+// DeterministicTimestamp is the generated_at value of scene graphs produced
+// with Options.Deterministic.
+const DeterministicTimestamp = "1970-01-01T00:00:00Z"
+
+// Stage names a pipeline step.
+type Stage string
+
+const (
+	StageSchema     Stage = "schema"
+	StageAnalytics  Stage = "analytics"
+	StageCost       Stage = "cost"
+	StagePods       Stage = "pods"
+	StageSports     Stage = "sports"
+	StagePlazas     Stage = "plazas"
+	StageBuildings  Stage = "buildings"
+	StageRouting    Stage = "routing"
+	StageBikePaths  Stage = "bike_paths"
+	StageShuttle    Stage = "shuttle"
+	StageGreen      Stage = "green"
+	StageTrees      Stage = "trees"
+	StageSolar      Stage = "solar"
+	StageMetrics    Stage = "metrics"
+	StageEmployment Stage = "employment"
+	StageEducation  Stage = "education"
+	StageScene      Stage = "scene"
+	StageScene2D    Stage = "scene2d"
+)
+
+// Options controls pipeline execution.
+type Options struct {
+	// Workers caps how many stages run at once. Values below 1 mean
+	// runtime.GOMAXPROCS(0); 1 runs stages serially in declaration order.
+	Workers int
+
+	// Targets limits the run to these stages and their dependencies.
+	// Empty runs every stage.
+	Targets []Stage
+
+	// StopAfter ends the run after the named stage: later stages in
+	// canonical order (see Stages) do not run.
+	StopAfter Stage
+
+	// Skip lists stages not to run. Stages that depend on a skipped stage
+	// are skipped as well.
+	Skip []Stage
+
+	// Lenient keeps generating after schema or analytical errors instead of
+	// returning a *ValidationError, so a partially valid city still renders.
+	Lenient bool
+
+	// Before and After hooks run around every stage, on the goroutine that
+	// runs it. A hook error aborts the pipeline. Hooks for independent
+	// stages may run concurrently; a hook should only read the fields of its
+	// stage and that stage's dependencies.
+	Before []Hook
+	After  []Hook
+
+	// Deterministic stamps generated scenes with DeterministicTimestamp
+	// instead of the current time, so the same spec always produces
+	// byte-identical output (ADR-009).
+	Deterministic bool
+
+	// Cache, when set, makes the run incremental: stages whose spec reads
+	// and inputs are unchanged since the cache's last successful run reuse
+	// their cached outputs, and building and tree placement reuse unchanged
+	// pods. The cache is updated after every successful run.
+	Cache *Cache
+}
+
+// Hook is called before or after a stage with the result so far.
+type Hook func(stage Stage, r *Result) error
+
+// StageTiming records how long one stage took, excluding hooks.
+type StageTiming struct {
+	Stage    Stage         `json:"stage"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Result holds every stage output of a pipeline run.
+type Result struct {
+	Spec   *spec.CitySpec
+	Params *analytics.ResolvedParameters
+	Cost   *cost.Report
+
+	// Report merges the schema, analytical and spatial reports in stage order.
+	Report *validation.Report
+
+	Pods          []layout.Pod
+	Adjacency     map[string][]string
+	Buildings     []layout.Building
+	Paths         []layout.PathSegment
+	Segments      []routing.Segment
+	BikePaths     []layout.BikePath
+	ShuttleRoutes []layout.ShuttleRoute
+	Stations      []layout.Station
+	SportsFields  []layout.SportsField
+	GreenZones    []layout.Zone
+	Plazas        []layout.Plaza
+	Trees         []layout.Tree
+
+	// Solar is the solar access analysis, nil unless the spec requests it.
+	Solar *layout.SolarAnalysis
+
+	// Metrics are the land-use figures measured after placement.
+	Metrics *metrics.Report
+
+	// Employment is the jobs-housing balance and the commutes between pods.
+	Employment *employment.Employment
+
+	// Education is the school catchments and planned secondary schools.
+	Education *education.Plan
+
+	Graph   *scene.Graph
+	Scene2D *scene2d.Scene2D
+
+	// Timings lists every stage that ran, in canonical order.
+	Timings []StageTiming
+
+	// Reuse reports what the run took from Options.Cache.
+	Reuse Reuse
+
+	// resolved is the analytics output before cost fills in Params.
+	resolved   *analytics.ResolvedParameters
+	placements *layout.PlacementCache
+	reports    map[Stage]*validation.Report
+	durations  map[Stage]time.Duration
+
+	// generatedAt, when set, replaces the assembly time in generated scenes.
+	generatedAt string
+}
+
+// StageReport returns the validation report produced by a single stage,
+// or nil if the stage did not run or produces no report.
+func (r *Result) StageReport(stage Stage) *validation.Report {
+	return r.reports[stage]
+}
+
+// ValidationError is returned when the spec fails schema or analytical
+// validation and spatial generation cannot proceed.
+type ValidationError struct {
+	Stage  Stage
+	Report *validation.Report
+}
+
+func (e *ValidationError) Error() string {
+	switch e.Stage {
+	case StageSchema:
+		return "spec has validation errors"
+	case StageAnalytics:
+		return "analytical validation failed"
+	default:
+		return fmt.Sprintf("%s validation failed", e.Stage)
+	}
+}
+
+// RunProject loads city.yaml from a project directory and runs the pipeline.
+func RunProject(projectPath string, opts Options) (*Result, error) {
+	citySpec, err := spec.LoadProject(projectPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading spec: %w", err)
+	}
+	return Run(citySpec, opts)
+}
+
+// Stages returns every stage name in canonical order.
+func Stages() []Stage {
+	all := stages()
+	names := make([]Stage, len(all))
+	for i, st := range all {
+		names[i] = st.name
+	}
+	return names
+}
+
+// Run executes the pipeline on a parsed spec. On a stage failure it returns
+// the partial result (Report and Timings are always set) with the error; a
+// failed validation stage yields a *ValidationError.
+func Run(s *spec.CitySpec, opts Options) (*Result, error) {
+	return RunContext(context.Background(), s, opts)
+}
+
+// RunContext is Run with cancellation. No stage starts once ctx is done, and
+// the layout and routing stages that take long enough to matter stop part
+// way; the run then returns ctx's error with the partial result. A canceled
+// run does not update opts.Cache.
+func RunContext(ctx context.Context, s *spec.CitySpec, opts Options) (*Result, error) {
+	r := &Result{
+		Spec:      s,
+		reports:   make(map[Stage]*validation.Report),
+		durations: make(map[Stage]time.Duration),
+	}
+	if opts.Deterministic {
+		r.generatedAt = DeterministicTimestamp
+	}
+	plan, err := selectStages(stages(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *Result
+	if opts.Cache != nil {
+		opts.Cache.mu.Lock()
+		defer opts.Cache.mu.Unlock()
+		prev = opts.Cache.begin(r)
+	}
+	err = execute(ctx, plan, r, prev, opts)
+
+	// A run stopped before cost still exposes the analytical parameters.
+	if r.Params == nil {
+		r.Params = r.resolved
+	}
+	r.Report = validation.NewReport()
+	for _, st := range stages() {
+		if rep := r.reports[st.name]; rep != nil {
+			r.Report.Merge(rep)
+		}
+		if d, ok := r.durations[st.name]; ok {
+			r.Timings = append(r.Timings, StageTiming{Stage: st.name, Duration: d})
+		}
+	}
+	if opts.Cache != nil && err == nil {
+		opts.Cache.commit(r)
+	}
+	return r, err
+}
+
+// stageDef declares one pipeline stage: its dependencies, the spec paths it
+// reads (see spec.PathAffects), the Result fields it writes, and the function
+// that reads dependency outputs from the result, writes its own fields and
+// returns its validation report (nil if it produces none). A stage that
+// reads Params depends on StageCost, which writes them: the spec paths
+// behind Params are only visible to incremental runs through its output.
+type stageDef struct {
+	name    Stage
+	deps    []Stage
+	reads   []string
+	outputs func(r *Result) []any
+	run     func(ctx context.Context, r *Result) (*validation.Report, error)
+}
+
+// stages returns the pipeline in canonical order. Every stage follows its
+// dependencies, and reports are merged in this order, which matches the
+// historical serial pipeline.
+func stages() []stageDef {
+	return []stageDef{
+		{
+			name:    StageSchema,
+			reads:   []string{"*"},
+			outputs: func(r *Result) []any { return nil },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				rep := validation.ValidateSchema(r.Spec)
+				if !rep.Valid {
+					return rep, &ValidationError{Stage: StageSchema, Report: rep}
+				}
+				return rep, nil
+			},
+		},
+		{
+			name:    StageAnalytics,
+			deps:    []Stage{StageSchema},
+			reads:   []string{"city", "city_zones", "pods", "demographics", "infrastructure.electrical", "site_requirements"},
+			outputs: func(r *Result) []any { return []any{&r.resolved} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				params, rep := analytics.Resolve(r.Spec)
+				r.resolved = params
+				if !rep.Valid {
+					return rep, &ValidationError{Stage: StageAnalytics, Report: rep}
+				}
+				return rep, nil
+			},
+		},
+		{
+			name:    StageCost,
+			deps:    []Stage{StageAnalytics},
+			reads:   []string{"city", "city_zones", "pods.walk_radius", "infrastructure.electrical", "revenue"},
+			outputs: func(r *Result) []any { return []any{&r.Cost, &r.Params} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				r.Cost = cost.Estimate(r.Spec, r.resolved)
+				params := *r.resolved
+				params.PerCapitaCost = r.Cost.Summary.PerCapita
+				params.BreakEvenRent = r.Cost.Summary.BreakEvenMonthlyRent
+				r.Params = &params
+				return nil, nil
+			},
+		},
+		{
+			name:    StagePods,
+			deps:    []Stage{StageCost},
+			reads:   []string{"pods.walk_radius"},
+			outputs: func(r *Result) []any { return []any{&r.Pods, &r.Adjacency} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				pods, adj, rep := layout.LayoutPods(r.Spec, r.Params)
+				r.Pods, r.Adjacency = pods, adj
+				return rep, nil
+			},
+		},
+		{
+			name:    StageSports,
+			deps:    []Stage{StagePods},
+			reads:   []string{"city_zones.rings.*.radius_from"},
+			outputs: func(r *Result) []any { return []any{&r.SportsFields} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				fields, rep := layout.PlaceSportsFields(r.Pods, r.Adjacency, r.Spec.CityZones.Rings)
+				r.SportsFields = fields
+				return rep, nil
+			},
+		},
+		{
+			name:    StagePlazas,
+			deps:    []Stage{StagePods},
+			reads:   []string{"pods.ring_assignments"},
+			outputs: func(r *Result) []any { return []any{&r.Plazas} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				plazas, rep := layout.GeneratePlazas(r.Pods, r.Spec)
+				r.Plazas = plazas
+				return rep, nil
+			},
+		},
+		{
+			// Placed buildings are checked against the paths, plazas and
+			// sports fields, so placement follows those stages.
+			name: StageBuildings,
+			deps: []Stage{StageCost, StagePods, StageSports, StagePlazas},
+			reads: []string{
+				"city_zones.rings", "city.population", "demographics", "city.height_profile", "city.height_control_points", "city.max_height_center", "city.max_height_edge",
+				"pods.ring_assignments", "pods.building_typologies", "pods.fix_collisions",
+			},
+			outputs: func(r *Result) []any { return []any{&r.Buildings, &r.Paths} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				var (
+					rep *validation.Report
+					err error
+				)
+				if r.placements != nil {
+					r.Buildings, r.Paths, rep, err = layout.PlaceBuildingsCachedContext(ctx, r.Spec, r.Pods, r.Adjacency, r.Params, r.placements)
+				} else {
+					r.Buildings, r.Paths, rep, err = layout.PlaceBuildingsContext(ctx, r.Spec, r.Pods, r.Adjacency, r.Params)
+				}
+				if err != nil {
+					return rep, err
+				}
+				var check *validation.Report
+				if r.Spec.Pods.FixCollisions {
+					r.Buildings, check = layout.FixPlacement(r.Pods, r.Buildings, r.Paths, r.Plazas, r.SportsFields)
+				} else {
+					_, check = layout.CheckPlacement(r.Pods, r.Buildings, r.Paths, r.Plazas, r.SportsFields)
+				}
+				rep.Merge(check)
+				_, check = layout.CheckEnvelope(r.Spec.HeightEnvelope(), r.Buildings)
+				rep.Merge(check)
+				return rep, nil
+			},
+		},
+		{
+			// RouteInfrastructure does not use building placements yet, so
+			// routing depends only on pods and survives height changes.
+			name:    StageRouting,
+			deps:    []Stage{StagePods},
+			reads:   []string{"city_zones.rings.*.radius_to", "vehicles"},
+			outputs: func(r *Result) []any { return []any{&r.Segments} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				segs, rep, err := routing.RouteInfrastructureContext(ctx, r.Spec, r.Pods, nil)
+				r.Segments = segs
+				return rep, err
+			},
+		},
+		{
+			name:    StageBikePaths,
+			deps:    []Stage{StagePods},
+			reads:   []string{"city_zones.rings.*.name", "city_zones.rings.*.radius_from", "city_zones.rings.*.radius_to"},
+			outputs: func(r *Result) []any { return []any{&r.BikePaths} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				bp, rep := layout.GenerateBikePaths(r.Pods, r.Adjacency, r.Spec.CityZones.Rings)
+				r.BikePaths = bp
+				return rep, nil
+			},
+		},
+		{
+			name:    StageShuttle,
+			deps:    []Stage{StageBikePaths},
+			outputs: func(r *Result) []any { return []any{&r.ShuttleRoutes, &r.Stations} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				routes, stations, rep := layout.GenerateShuttleRoutes(r.BikePaths, r.Pods)
+				r.ShuttleRoutes, r.Stations = routes, stations
+				return rep, nil
+			},
+		},
+		{
+			name:    StageGreen,
+			deps:    []Stage{StagePods},
+			reads:   []string{"city_zones.rings.*.name", "city_zones.rings.*.radius_from", "city_zones.rings.*.radius_to", "pods.ring_assignments"},
+			outputs: func(r *Result) []any { return []any{&r.GreenZones} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				zones, err := layout.CollectGreenZonesContext(ctx, r.Spec, r.Pods)
+				r.GreenZones = zones
+				return nil, err
+			},
+		},
+		{
+			name:    StageTrees,
+			deps:    []Stage{StageBuildings, StageBikePaths, StageGreen, StagePlazas},
+			outputs: func(r *Result) []any { return []any{&r.Trees} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				var (
+					rep *validation.Report
+					err error
+				)
+				if r.placements != nil {
+					r.Trees, rep, err = layout.PlaceTreesCachedContext(ctx, r.Pods, r.GreenZones, r.Paths, r.BikePaths, r.Plazas, r.placements)
+				} else {
+					r.Trees, rep, err = layout.PlaceTreesContext(ctx, r.Pods, r.GreenZones, r.Paths, r.BikePaths, r.Plazas)
+				}
+				return rep, err
+			},
+		},
+		{
+			name:    StageSolar,
+			deps:    []Stage{StageBuildings, StageGreen, StagePlazas},
+			reads:   []string{"site_requirements", "infrastructure.electrical.solar_integrated_avg_mw"},
+			outputs: func(r *Result) []any { return []any{&r.Solar} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				solar, rep, err := layout.AnalyzeSolarAccessContext(ctx, r.Spec, r.Buildings, r.GreenZones, r.Plazas)
+				r.Solar = solar
+				return rep, err
+			},
+		},
+		{
+			name:    StageMetrics,
+			deps:    []Stage{StageCost, StageBuildings, StageGreen, StagePlazas},
+			reads:   []string{"city_zones.rings", "pods.ring_assignments"},
+			outputs: func(r *Result) []any { return []any{&r.Metrics} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				m, rep := metrics.Measure(r.Spec, r.Params, r.Pods, r.Buildings, r.Paths, r.GreenZones, r.Plazas)
+				r.Metrics = m
+				return rep, nil
+			},
+		},
+		{
+			// The labor force follows the population and each cohort's
+			// participation.
+			name:    StageEmployment,
+			deps:    []Stage{StageCost, StageBuildings},
+			reads:   []string{"city_zones.rings", "city.population", "demographics"},
+			outputs: func(r *Result) []any { return []any{&r.Employment} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				e, rep := employment.Model(r.Spec, r.Params, r.Pods, r.Buildings)
+				r.Employment = e
+				return rep, nil
+			},
+		},
+		{
+			name:    StageEducation,
+			deps:    []Stage{StageCost, StageBuildings},
+			reads:   []string{"demographics"},
+			outputs: func(r *Result) []any { return []any{&r.Education} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				plan, rep := education.PlanSchools(r.Params, r.Pods, r.Buildings)
+				r.Education = plan
+				return rep, nil
+			},
+		},
+		{
+			name:    StageScene,
+			deps:    []Stage{StageBuildings, StageRouting, StageShuttle, StageSports, StageTrees},
+			reads:   []string{"spec_version", "infrastructure.electrical.battery_capacity_mwh"},
+			outputs: func(r *Result) []any { return []any{&r.Graph} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				r.Graph = scene.Assemble(r.Spec, r.Pods, r.Buildings, r.Paths, r.Segments, r.GreenZones,
+					r.BikePaths, r.ShuttleRoutes, r.Stations, r.SportsFields, r.Plazas, r.Trees)
+				if r.generatedAt != "" {
+					r.Graph.Metadata.GeneratedAt = r.generatedAt
+				}
+				return nil, nil
+			},
+		},
+		{
+			name:    StageScene2D,
+			deps:    []Stage{StageCost, StageShuttle, StageSports, StageTrees, StageMetrics},
+			reads:   []string{"city.population", "city_zones", "pods.ring_assignments"},
+			outputs: func(r *Result) []any { return []any{&r.Scene2D} },
+			run: func(ctx context.Context, r *Result) (*validation.Report, error) {
+				r.Scene2D = scene2d.Assemble2D(r.Spec, r.Params, r.Pods, r.Buildings, r.Paths, r.GreenZones,
+					r.BikePaths, r.ShuttleRoutes, r.Stations, r.SportsFields, r.Plazas, r.Trees, r.Metrics)
+				if r.generatedAt != "" {
+					r.Scene2D.Metadata.GeneratedAt = r.generatedAt
+				}
+				return nil, nil
+			},
+		},
+	}
+}
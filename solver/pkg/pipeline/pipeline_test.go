@@ -0,0 +1,452 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+const exampleProject = "../../../examples/default-city"
+
+func loadExample(t *testing.T) *spec.CitySpec {
+	t.Helper()
+	s, err := spec.LoadProject(exampleProject)
+	if err != nil {
+		t.Fatalf("LoadProject: %v", err)
+	}
+	return s
+}
+
+// digest hashes the JSON encoding of every output with generation
+// timestamps cleared, without holding the full encoding in memory.
+func digest(t *testing.T, r *Result) [sha256.Size]byte {
+	t.Helper()
+	if r.Graph != nil {
+		r.Graph.Metadata.GeneratedAt = ""
+	}
+	if r.Scene2D != nil {
+		r.Scene2D.Metadata.GeneratedAt = ""
+	}
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []any{r.Params, r.Cost, r.Report, r.Buildings, r.Solar, r.Metrics, r.Employment, r.Education, r.Graph, r.Scene2D} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("encoding result: %v", err)
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func TestRunParallelMatchesSerial(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city twice")
+	}
+	serial, err := Run(loadExample(t), Options{Workers: 1})
+	if err != nil {
+		t.Fatalf("serial run: %v", err)
+	}
+	parallel, err := Run(loadExample(t), Options{Workers: 8})
+	if err != nil {
+		t.Fatalf("parallel run: %v", err)
+	}
+	if digest(t, serial) != digest(t, parallel) {
+		t.Fatal("parallel pipeline output differs from serial")
+	}
+}
+
+func TestRunTargetsSkipsUnneededStages(t *testing.T) {
+	r, err := Run(loadExample(t), Options{Targets: []Stage{StageSports}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(r.SportsFields) == 0 {
+		t.Error("expected sports fields")
+	}
+	if r.Buildings != nil || r.Graph != nil || r.Scene2D != nil {
+		t.Error("stages outside the target's dependencies ran")
+	}
+	if r.StageReport(StagePods) == nil {
+		t.Error("missing pods report")
+	}
+}
+
+func TestRunUnknownTarget(t *testing.T) {
+	if _, err := Run(loadExample(t), Options{Targets: []Stage{"nope"}}); err == nil {
+		t.Error("expected error for unknown stage")
+	}
+}
+
+func TestRunStopsOnSchemaErrors(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		s := loadExample(t)
+		s.City.Population = 0
+
+		r, err := Run(s, Options{Workers: workers})
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Stage != StageSchema {
+			t.Fatalf("workers=%d: err = %v, want schema ValidationError", workers, err)
+		}
+		if r.Report.Valid {
+			t.Errorf("workers=%d: report should be invalid", workers)
+		}
+		if r.Pods != nil {
+			t.Errorf("workers=%d: spatial stages ran after schema failure", workers)
+		}
+	}
+}
+
+func TestRunStopAfter(t *testing.T) {
+	r, err := Run(loadExample(t), Options{StopAfter: StagePods})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(r.Pods) == 0 {
+		t.Error("expected pods")
+	}
+	if r.Buildings != nil || r.BikePaths != nil {
+		t.Error("stages after stop_after ran")
+	}
+	want := []Stage{StageSchema, StageAnalytics, StageCost, StagePods}
+	if len(r.Timings) != len(want) {
+		t.Fatalf("timings = %v, want stages %v", r.Timings, want)
+	}
+	for i, tm := range r.Timings {
+		if tm.Stage != want[i] {
+			t.Errorf("timings[%d] = %s, want %s", i, tm.Stage, want[i])
+		}
+	}
+}
+
+func TestRunSkipCascades(t *testing.T) {
+	r, err := Run(loadExample(t), Options{
+		Targets: []Stage{StageShuttle, StageSports},
+		Skip:    []Stage{StageBikePaths},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if r.BikePaths != nil || r.ShuttleRoutes != nil {
+		t.Error("skipped stage or its dependent ran")
+	}
+	if len(r.SportsFields) == 0 {
+		t.Error("independent stage did not run")
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	var mu sync.Mutex
+	var before, after []Stage
+	opts := Options{
+		Targets: []Stage{StagePods},
+		Before: []Hook{func(stage Stage, r *Result) error {
+			mu.Lock()
+			defer mu.Unlock()
+			before = append(before, stage)
+			if stage == StagePods && r.Params == nil {
+				return errors.New("pods started before analytics")
+			}
+			return nil
+		}},
+		After: []Hook{func(stage Stage, r *Result) error {
+			mu.Lock()
+			defer mu.Unlock()
+			after = append(after, stage)
+			if stage == StagePods && len(r.Pods) == 0 {
+				return errors.New("no pods after pods stage")
+			}
+			return nil
+		}},
+	}
+	if _, err := Run(loadExample(t), opts); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(before) != 4 || len(after) != 4 {
+		t.Errorf("hooks ran before=%v after=%v, want 4 stages each", before, after)
+	}
+
+	sentinel := errors.New("custom check failed")
+	r, err := Run(loadExample(t), Options{
+		After: []Hook{func(stage Stage, _ *Result) error {
+			if stage == StageCost {
+				return sentinel
+			}
+			return nil
+		}},
+	})
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("err = %v, want hook error", err)
+	}
+	if r.Pods != nil {
+		t.Error("stages ran after a failing hook")
+	}
+}
+
+func TestRunIncrementalMatchesFullSolve(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city three times")
+	}
+	cache := NewCache()
+	if _, err := Run(loadExample(t), Options{Cache: cache}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// Dropping a service from the outer ring only touches that ring's pods.
+	edited := loadExample(t)
+	outer := edited.CityZones.Rings[len(edited.CityZones.Rings)-1].Name
+	assignment := edited.Pods.RingAssignments[outer]
+	assignment.RequiredServices = assignment.RequiredServices[1:]
+	edited.Pods.RingAssignments[outer] = assignment
+	inc, err := Run(edited, Options{Cache: cache})
+	if err != nil {
+		t.Fatalf("incremental run: %v", err)
+	}
+
+	if len(inc.Reuse.SpecChanges) != 1 {
+		t.Errorf("spec changes = %v, want one", inc.Reuse.SpecChanges)
+	}
+	reused := make(map[Stage]bool)
+	for _, st := range inc.Reuse.Reused {
+		reused[st] = true
+	}
+	for _, st := range []Stage{StageCost, StagePods, StageRouting, StageBikePaths, StageShuttle, StageSports} {
+		if !reused[st] {
+			t.Errorf("stage %s was recomputed; reused %v", st, inc.Reuse.Reused)
+		}
+	}
+	if inc.Reuse.PodsReused == 0 || inc.Reuse.PodsPlaced == 0 {
+		t.Errorf("pods reused %d placed %d, want both non-zero", inc.Reuse.PodsReused, inc.Reuse.PodsPlaced)
+	}
+
+	full, err := Run(edited, Options{})
+	if err != nil {
+		t.Fatalf("full run: %v", err)
+	}
+	if digest(t, inc) != digest(t, full) {
+		t.Fatal("incremental output differs from a full solve")
+	}
+}
+
+func TestRunIncrementalMatchesFullSolvePerSection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city twice per spec section")
+	}
+	// Each edit builds on the ones before it, so every incremental run
+	// starts from the previous edit's cache.
+	edits := []struct {
+		name string
+		edit func(s *spec.CitySpec)
+	}{
+		{"demographics", func(s *spec.CitySpec) {
+			s.Demographics.Singles += 0.05
+			s.Demographics.Retirees -= 0.05
+		}},
+		{"city", func(s *spec.CitySpec) { s.City.Population += 2000 }},
+		{"city_zones", func(s *spec.CitySpec) { s.CityZones.Rings[1].MaxStories-- }},
+		{"pods", func(s *spec.CitySpec) { s.Pods.FixCollisions = true }},
+		{"infrastructure", func(s *spec.CitySpec) { s.Infrastructure.Electrical.PeakDemandKWPer += 0.5 }},
+		{"vehicles", func(s *spec.CitySpec) { s.Vehicles.ArterialWidthM++ }},
+		{"logistics", func(s *spec.CitySpec) { s.Logistics.DailyPackagesPerCapita++ }},
+		{"ownership", func(s *spec.CitySpec) { s.Ownership.Model = "mixed" }},
+		{"revenue", func(s *spec.CitySpec) { s.Revenue.InterestRate += 0.01 }},
+		{"site_requirements", func(s *spec.CitySpec) { s.Site.Latitude += 10 }},
+	}
+
+	cache := NewCache()
+	edited := loadExample(t)
+	if _, err := Run(edited, Options{Cache: cache}); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	for _, e := range edits {
+		e.edit(edited)
+		inc, err := Run(edited, Options{Cache: cache})
+		if err != nil {
+			t.Fatalf("%s: incremental run: %v", e.name, err)
+		}
+		if len(inc.Reuse.SpecChanges) == 0 {
+			t.Errorf("%s: edit produced no spec changes", e.name)
+		}
+		full, err := Run(cloneSpec(edited), Options{})
+		if err != nil {
+			t.Fatalf("%s: full run: %v", e.name, err)
+		}
+		if digest(t, inc) != digest(t, full) {
+			t.Errorf("%s: incremental output differs from a full solve; reused %v", e.name, inc.Reuse.Reused)
+		}
+	}
+}
+
+func TestRunIncrementalCohortEditChangesUnitMix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city twice")
+	}
+	cache := NewCache()
+	opts := Options{Cache: cache, Targets: []Stage{StageBuildings}}
+	before, err := Run(loadExample(t), opts)
+	if err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	mix := func(r *Result) layout.UnitMix {
+		var total layout.UnitMix
+		for _, b := range r.Buildings {
+			if b.UnitMix != nil {
+				total = total.Add(*b.UnitMix)
+			}
+		}
+		return total
+	}
+
+	// Families become singles: studios replace three-bedroom units.
+	edited := loadExample(t)
+	edited.Demographics.Singles += 0.10
+	edited.Demographics.FamiliesYoung -= 0.10
+	after, err := Run(edited, opts)
+	if err != nil {
+		t.Fatalf("incremental run: %v", err)
+	}
+	for _, st := range after.Reuse.Reused {
+		if st == StageBuildings {
+			t.Error("buildings were reused after a cohort edit")
+		}
+	}
+	if after.Reuse.PodsReused != 0 {
+		t.Errorf("%d pods reused after a cohort edit", after.Reuse.PodsReused)
+	}
+	b, a := mix(before), mix(after)
+	if a.Studios <= b.Studios || a.ThreeBed >= b.ThreeBed {
+		t.Errorf("unit mix %+v after the edit, was %+v", a, b)
+	}
+}
+
+func TestRunIncrementalLaborForceEdit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city twice")
+	}
+	cache := NewCache()
+	opts := Options{Cache: cache, Targets: []Stage{StageEmployment}}
+	before, err := Run(loadExample(t), opts)
+	if err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+
+	// Retirees leave the labor force; young families join it.
+	edited := loadExample(t)
+	edited.Demographics.Retirees -= 0.05
+	edited.Demographics.FamiliesYoung += 0.05
+	after, err := Run(edited, opts)
+	if err != nil {
+		t.Fatalf("incremental run: %v", err)
+	}
+	for _, st := range after.Reuse.Reused {
+		if st == StageEmployment {
+			t.Fatal("employment was reused after a labor force edit")
+		}
+	}
+	if after.Employment.City.Workers == before.Employment.City.Workers {
+		t.Errorf("city workers unchanged at %d after a labor force edit", after.Employment.City.Workers)
+	}
+}
+
+func TestRunPlacesServicesWithoutOverlaps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city")
+	}
+	s := loadExample(t)
+	s.Pods.FixCollisions = false
+	r, err := Run(s, Options{Targets: []Stage{StageBuildings}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	civic := make(map[string]bool)
+	for _, b := range r.Buildings {
+		if b.Type == "civic" {
+			civic[b.ID] = true
+		}
+	}
+	if len(civic) == 0 {
+		t.Fatal("no civic buildings placed")
+	}
+	conflicts, _ := layout.CheckPlacement(r.Pods, r.Buildings, r.Paths, r.Plazas, r.SportsFields)
+	for _, c := range conflicts {
+		if c.Kind == layout.ConflictOverlap && (civic[c.EntityIDs[0]] || civic[c.EntityIDs[1]]) {
+			t.Errorf("civic building overlap before fix-up: %v", c.EntityIDs)
+		}
+	}
+}
+
+func TestRunIncrementalNoChanges(t *testing.T) {
+	cache := NewCache()
+	opts := Options{Cache: cache, Targets: []Stage{StagePlazas}}
+	if _, err := Run(loadExample(t), opts); err != nil {
+		t.Fatalf("initial run: %v", err)
+	}
+	r, err := Run(loadExample(t), opts)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if len(r.Reuse.Recomputed) != 0 {
+		t.Errorf("recomputed %v with an unchanged spec", r.Reuse.Recomputed)
+	}
+	if len(r.Plazas) == 0 || r.StageReport(StagePlazas) == nil {
+		t.Error("reused stage outputs missing")
+	}
+}
+
+func TestRunDeterministicTimestamps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city")
+	}
+	r, err := Run(loadExample(t), Options{Deterministic: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := r.Graph.Metadata.GeneratedAt; got != DeterministicTimestamp {
+		t.Errorf("scene generated_at = %q, want %q", got, DeterministicTimestamp)
+	}
+	if got := r.Scene2D.Metadata.GeneratedAt; got != DeterministicTimestamp {
+		t.Errorf("scene2d generated_at = %q, want %q", got, DeterministicTimestamp)
+	}
+}
+
+func TestRunContextCanceled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves the full example city twice")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cache := NewCache()
+	r, err := RunContext(ctx, loadExample(t), Options{
+		Cache: cache,
+		After: []Hook{func(stage Stage, _ *Result) error {
+			if stage == StagePods {
+				cancel()
+			}
+			return nil
+		}},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if r.Graph != nil || r.Buildings != nil {
+		t.Error("stages after the cancellation produced output")
+	}
+
+	// The canceled run must not have left partial state in the cache.
+	inc, err := Run(loadExample(t), Options{Cache: cache})
+	if err != nil {
+		t.Fatalf("run after cancel: %v", err)
+	}
+	full, err := Run(loadExample(t), Options{})
+	if err != nil {
+		t.Fatalf("full run: %v", err)
+	}
+	if digest(t, inc) != digest(t, full) {
+		t.Fatal("run after a canceled run differs from a full solve")
+	}
+}
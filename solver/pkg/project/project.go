@@ -0,0 +1,282 @@
+// Package project manages a project directory per ADR-013: the city.yaml
+// spec, the project.json manifest and the cached scene graph, cost report
+// and analysis derived from the spec.
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/education"
+	"github.com/ChicagoDave/cityplanner/pkg/employment"
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// ManifestFile is the manifest's file name within a project directory.
+const ManifestFile = "project.json"
+
+// Manifest is the contents of project.json. SpecHash, SolverVersion,
+// GeneratedAt and CacheValid describe the cached artifacts and are written
+// only by SaveArtifacts; a hand-written manifest leaves them out.
+type Manifest struct {
+	ProjectName     string          `json:"project_name"`
+	SpecFile        string          `json:"spec_file"`
+	SpecHash        string          `json:"spec_hash,omitempty"`
+	SolverVersion   string          `json:"solver_version,omitempty"`
+	GeneratedAt     string          `json:"generated_at,omitempty"`
+	CacheValid      bool            `json:"cache_valid,omitempty"`
+	SceneGraphFile  string          `json:"scene_graph_file"`
+	CostReportFile  string          `json:"cost_report_file"`
+	AnalysisFile    string          `json:"analysis_file"`
+	UserPreferences json.RawMessage `json:"user_preferences,omitempty"`
+}
+
+// Analysis is the part of a solve that is neither the scene graph nor the
+// cost report: the full validation report, spatial findings included, and
+// the results of the analysis stages. Parameters are not cached; they are
+// cheap to resolve again.
+type Analysis struct {
+	Validation *validation.Report     `json:"validation"`
+	Solar      *layout.SolarAnalysis  `json:"solar,omitempty"`
+	Metrics    *metrics.Report        `json:"metrics,omitempty"`
+	Employment *employment.Employment `json:"employment,omitempty"`
+	Education  *education.Plan        `json:"education,omitempty"`
+}
+
+// NewAnalysis returns the analysis of a solve result.
+func NewAnalysis(res *pipeline.Result) *Analysis {
+	return &Analysis{
+		Validation: res.Report,
+		Solar:      res.Solar,
+		Metrics:    res.Metrics,
+		Employment: res.Employment,
+		Education:  res.Education,
+	}
+}
+
+// Project is an opened project directory.
+type Project struct {
+	Dir      string
+	Manifest Manifest
+}
+
+// Open reads the manifest in dir. A missing manifest is not an error: the
+// project gets default file names and an invalid cache.
+func Open(dir string) (*Project, error) {
+	p := &Project{Dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &p.Manifest); err != nil {
+			return nil, fmt.Errorf("parsing manifest: %w", err)
+		}
+	}
+
+	m := &p.Manifest
+	if m.ProjectName == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			abs = dir
+		}
+		m.ProjectName = filepath.Base(abs)
+	}
+	if m.SpecFile == "" {
+		m.SpecFile = DefaultSpecFile
+	}
+	if m.SceneGraphFile == "" {
+		m.SceneGraphFile = "city.scene.json"
+	}
+	if m.CostReportFile == "" {
+		m.CostReportFile = "city.cost.json"
+	}
+	if m.AnalysisFile == "" {
+		m.AnalysisFile = "city.analysis.json"
+	}
+	return p, nil
+}
+
+// SpecPath returns the path of the spec file.
+func (p *Project) SpecPath() string {
+	return filepath.Join(p.Dir, p.Manifest.SpecFile)
+}
+
+// SceneGraphPath returns the path of the cached scene graph.
+func (p *Project) SceneGraphPath() string {
+	return filepath.Join(p.Dir, p.Manifest.SceneGraphFile)
+}
+
+// CostReportPath returns the path of the cached cost report.
+func (p *Project) CostReportPath() string {
+	return filepath.Join(p.Dir, p.Manifest.CostReportFile)
+}
+
+// AnalysisPath returns the path of the cached analysis.
+func (p *Project) AnalysisPath() string {
+	return filepath.Join(p.Dir, p.Manifest.AnalysisFile)
+}
+
+// SpecHash hashes the spec file together with the solver version, so a
+// solver upgrade invalidates the cache just like a spec edit.
+func (p *Project) SpecHash() (string, error) {
+	data, err := os.ReadFile(p.SpecPath())
+	if err != nil {
+		return "", fmt.Errorf("reading spec file: %w", err)
+	}
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte{0})
+	h.Write([]byte(pipeline.Version))
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CacheValid reports whether the cached artifacts were generated from the
+// current spec by the current solver and are still on disk.
+func (p *Project) CacheValid() (bool, error) {
+	m := p.Manifest
+	if !m.CacheValid || m.SolverVersion != pipeline.Version {
+		return false, nil
+	}
+	hash, err := p.SpecHash()
+	if err != nil {
+		return false, err
+	}
+	if hash != m.SpecHash {
+		return false, nil
+	}
+	for _, path := range []string{p.SceneGraphPath(), p.CostReportPath(), p.AnalysisPath()} {
+		if _, err := os.Stat(path); err != nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ReadSceneGraph returns the raw JSON of the cached scene graph.
+func (p *Project) ReadSceneGraph() ([]byte, error) {
+	return os.ReadFile(p.SceneGraphPath())
+}
+
+// ReadCostReport returns the raw JSON of the cached cost report.
+func (p *Project) ReadCostReport() ([]byte, error) {
+	return os.ReadFile(p.CostReportPath())
+}
+
+// ReadAnalysis decodes the cached analysis.
+func (p *Project) ReadAnalysis() (*Analysis, error) {
+	var a Analysis
+	if err := readJSON(p.AnalysisPath(), &a); err != nil {
+		return nil, fmt.Errorf("loading cached analysis: %w", err)
+	}
+	return &a, nil
+}
+
+// LoadCached decodes the cached scene graph and cost report.
+func (p *Project) LoadCached() (*scene.Graph, *cost.Report, error) {
+	var g scene.Graph
+	if err := readJSON(p.SceneGraphPath(), &g); err != nil {
+		return nil, nil, fmt.Errorf("loading cached scene graph: %w", err)
+	}
+	var c cost.Report
+	if err := readJSON(p.CostReportPath(), &c); err != nil {
+		return nil, nil, fmt.Errorf("loading cached cost report: %w", err)
+	}
+	return &g, &c, nil
+}
+
+// SaveArtifacts writes the scene graph, cost report and analysis solved
+// from specHash and marks the cache valid in the manifest. Files are replaced atomically,
+// and the manifest is invalidated first, so an interrupted save never leaves
+// a manifest vouching for stale artifacts.
+func (p *Project) SaveArtifacts(specHash string, g *scene.Graph, c *cost.Report, a *Analysis) error {
+	if p.Manifest.CacheValid {
+		p.Manifest.CacheValid = false
+		if err := p.writeManifest(); err != nil {
+			return err
+		}
+	}
+	if err := writeJSON(p.SceneGraphPath(), g); err != nil {
+		return fmt.Errorf("writing scene graph: %w", err)
+	}
+	if err := writeJSON(p.CostReportPath(), c); err != nil {
+		return fmt.Errorf("writing cost report: %w", err)
+	}
+	if err := writeJSON(p.AnalysisPath(), a); err != nil {
+		return fmt.Errorf("writing analysis: %w", err)
+	}
+
+	p.Manifest.SpecHash = specHash
+	p.Manifest.SolverVersion = pipeline.Version
+	p.Manifest.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	p.Manifest.CacheValid = true
+	return p.writeManifest()
+}
+
+func (p *Project) writeManifest() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p.Manifest); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(p.Dir, ManifestFile), func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	return nil
+}
+
+func readJSON(path string, v any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
+
+func writeJSON(path string, v any) error {
+	return writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// writeFile writes through a temporary file in the same directory and
+// renames it into place.
+func writeFile(path string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
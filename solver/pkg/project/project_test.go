@@ -0,0 +1,271 @@
+package project
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// copyExample copies the example project into a temporary directory.
+func copyExample(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"city.yaml", ManifestFile} {
+		data, err := os.ReadFile(filepath.Join("../../../examples/default-city", name))
+		if err != nil {
+			t.Fatalf("reading example: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("writing example: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestOpenExample(t *testing.T) {
+	p, err := Open(copyExample(t))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if p.Manifest.ProjectName != "default-city" || p.Manifest.SceneGraphFile != "city.scene.json" {
+		t.Errorf("manifest = %+v", p.Manifest)
+	}
+	if len(p.Manifest.UserPreferences) == 0 {
+		t.Error("user preferences not loaded")
+	}
+	if valid, err := p.CacheValid(); err != nil || valid {
+		t.Errorf("CacheValid = %v, %v; want false for a fresh project", valid, err)
+	}
+}
+
+func TestExampleManifestHasNoCacheState(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("../../../examples/default-city", ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"spec_hash", "solver_version", "generated_at", "cache_valid"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("committed example manifest has derived field %q; solve the example in a copy", key)
+		}
+	}
+}
+
+func TestOpenWithoutManifest(t *testing.T) {
+	dir := t.TempDir()
+	p, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if p.Manifest.SpecFile != "city.yaml" || p.Manifest.CostReportFile != "city.cost.json" {
+		t.Errorf("defaults not applied: %+v", p.Manifest)
+	}
+	if p.Manifest.ProjectName != filepath.Base(dir) {
+		t.Errorf("project name = %q, want %q", p.Manifest.ProjectName, filepath.Base(dir))
+	}
+}
+
+func TestSaveArtifactsValidatesCache(t *testing.T) {
+	dir := copyExample(t)
+	p, _ := Open(dir)
+	hash, err := p.SpecHash()
+	if err != nil {
+		t.Fatalf("SpecHash: %v", err)
+	}
+
+	g := scene.NewGraph()
+	g.Metadata.SpecVersion = "test"
+	c := &cost.Report{}
+	a := &Analysis{Validation: validation.NewReport()}
+	a.Validation.AddWarning(validation.Result{Level: validation.LevelSpatial, Message: "pod_test: spatial finding"})
+	if err := p.SaveArtifacts(hash, g, c, a); err != nil {
+		t.Fatalf("SaveArtifacts: %v", err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if valid, err := reopened.CacheValid(); err != nil || !valid {
+		t.Fatalf("CacheValid = %v, %v; want true after save", valid, err)
+	}
+	if len(reopened.Manifest.UserPreferences) == 0 {
+		t.Error("user preferences lost on save")
+	}
+	got, _, err := reopened.LoadCached()
+	if err != nil {
+		t.Fatalf("LoadCached: %v", err)
+	}
+	if got.Metadata.SpecVersion != "test" {
+		t.Errorf("cached graph spec_version = %q", got.Metadata.SpecVersion)
+	}
+	analysis, err := reopened.ReadAnalysis()
+	if err != nil {
+		t.Fatalf("ReadAnalysis: %v", err)
+	}
+	if w := analysis.Validation.Warnings; len(w) != 1 || w[0].Message != "pod_test: spatial finding" {
+		t.Errorf("cached validation warnings = %+v", w)
+	}
+
+	// Editing the spec invalidates the cache.
+	f, err := os.OpenFile(reopened.SpecPath(), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n# edited\n")
+	f.Close()
+	if valid, _ := reopened.CacheValid(); valid {
+		t.Error("cache still valid after spec edit")
+	}
+}
+
+func TestCacheInvalidWhenArtifactMissing(t *testing.T) {
+	dir := copyExample(t)
+	p, _ := Open(dir)
+	hash, _ := p.SpecHash()
+	if err := p.SaveArtifacts(hash, scene.NewGraph(), &cost.Report{}, &Analysis{}); err != nil {
+		t.Fatalf("SaveArtifacts: %v", err)
+	}
+	os.Remove(p.SceneGraphPath())
+	if valid, _ := p.CacheValid(); valid {
+		t.Error("cache valid with scene graph missing")
+	}
+}
+
+func TestCacheInvalidWhenAnalysisMissing(t *testing.T) {
+	dir := copyExample(t)
+	p, _ := Open(dir)
+	hash, _ := p.SpecHash()
+	if err := p.SaveArtifacts(hash, scene.NewGraph(), &cost.Report{}, &Analysis{}); err != nil {
+		t.Fatalf("SaveArtifacts: %v", err)
+	}
+	os.Remove(p.AnalysisPath())
+	if valid, _ := p.CacheValid(); valid {
+		t.Error("cache valid with analysis missing")
+	}
+}
+
+func TestCacheInvalidAfterSolverUpgrade(t *testing.T) {
+	dir := copyExample(t)
+	p, _ := Open(dir)
+	hash, _ := p.SpecHash()
+	if err := p.SaveArtifacts(hash, scene.NewGraph(), &cost.Report{}, &Analysis{}); err != nil {
+		t.Fatalf("SaveArtifacts: %v", err)
+	}
+	p.Manifest.SolverVersion = "0.0.1"
+	if valid, _ := p.CacheValid(); valid {
+		t.Error("cache valid for a different solver version")
+	}
+}
+
+func TestAnalysisRoundTrip(t *testing.T) {
+	dir := copyExample(t)
+	p, _ := Open(dir)
+	hash, _ := p.SpecHash()
+	citySpec, err := spec.Load(p.SpecPath())
+	if err != nil {
+		t.Fatalf("loading spec: %v", err)
+	}
+	res, err := pipeline.Run(citySpec, pipeline.Options{
+		Targets: []pipeline.Stage{pipeline.StageSolar, pipeline.StageMetrics, pipeline.StageEmployment, pipeline.StageEducation},
+	})
+	if err != nil {
+		t.Fatalf("solve: %v", err)
+	}
+	want := NewAnalysis(res)
+	if want.Metrics == nil || want.Employment == nil || want.Education == nil {
+		t.Fatalf("analysis missing sections: %+v", want)
+	}
+	if err := p.SaveArtifacts(hash, scene.NewGraph(), res.Cost, want); err != nil {
+		t.Fatalf("SaveArtifacts: %v", err)
+	}
+
+	// The cached analysis prints exactly as the solved one does.
+	got, err := p.ReadAnalysis()
+	if err != nil {
+		t.Fatalf("ReadAnalysis: %v", err)
+	}
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("cached analysis differs from the solve's:\n got %.200s\nwant %.200s", gotJSON, wantJSON)
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	dir := copyExample(t)
+	p, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if names, err := p.Scenarios(); err != nil || len(names) != 1 || names[0] != Baseline {
+		t.Errorf("Scenarios = %v, %v; want only the baseline", names, err)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, ScenariosDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p.ScenarioPath("dense"), []byte("city:\n  population: 90000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := p.Scenarios()
+	if err != nil || len(names) != 2 || names[1] != "dense" {
+		t.Fatalf("Scenarios = %v, %v; want baseline and dense", names, err)
+	}
+
+	s, err := p.LoadScenario("dense")
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	if s.City.Population != 90000 {
+		t.Errorf("population = %d, want 90000", s.City.Population)
+	}
+	for _, bad := range []string{"missing", "../city", ""} {
+		if _, err := p.LoadScenario(bad); err == nil {
+			t.Errorf("LoadScenario(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	ws := t.TempDir()
+	for _, dir := range []string{"north", "alpha", ".hidden", "empty"} {
+		if err := os.Mkdir(filepath.Join(ws, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"north", "alpha", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(ws, dir, DefaultSpecFile), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Discover(ws)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "alpha" || got[1].Name != "north" {
+		t.Fatalf("Discover = %+v, want alpha and north", got)
+	}
+	if got[0].Dir != filepath.Join(ws, "alpha") {
+		t.Errorf("dir = %q", got[0].Dir)
+	}
+
+	single, err := Discover(got[1].Dir)
+	if err != nil {
+		t.Fatalf("Discover(project): %v", err)
+	}
+	if len(single) != 1 || single[0].Name != "north" || single[0].Dir != got[1].Dir {
+		t.Errorf("Discover(project) = %+v, want the project itself", single)
+	}
+}
@@ -0,0 +1,65 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+// ScenariosDir is the directory within a project holding scenario
+// overlays: partial specs applied on top of the base spec file.
+const ScenariosDir = "scenarios"
+
+// Baseline names the unmodified base spec as a scenario.
+const Baseline = "baseline"
+
+// ScenarioPath returns the overlay file of a named scenario.
+func (p *Project) ScenarioPath(name string) string {
+	return filepath.Join(p.Dir, ScenariosDir, name+".yaml")
+}
+
+// Scenarios lists the project's scenarios: the baseline first, then every
+// scenarios/*.yaml overlay by name. A project without a scenarios
+// directory has only the baseline.
+func (p *Project) Scenarios() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(p.Dir, ScenariosDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading scenarios: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".yaml")
+		if e.IsDir() || !ok || name == Baseline || strings.HasPrefix(name, ".") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{Baseline}, names...), nil
+}
+
+// LoadScenario loads the base spec with the named scenario's overlay
+// applied. The baseline scenario is the base spec itself.
+func (p *Project) LoadScenario(name string) (*spec.CitySpec, error) {
+	if name == Baseline {
+		return spec.Load(p.SpecPath())
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("invalid scenario name %q", name)
+	}
+	path := p.ScenarioPath(name)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown scenario %q", name)
+	}
+	s, err := spec.LoadWithOverlay(p.SpecPath(), path)
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,55 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultSpecFile is the spec file name of a project without a manifest,
+// and the file whose presence marks a directory as a project.
+const DefaultSpecFile = "city.yaml"
+
+// Entry is a project found in a workspace.
+type Entry struct {
+	Name string // directory name, used in URLs
+	Dir  string
+}
+
+// IsProject reports whether dir contains a city.yaml.
+func IsProject(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, DefaultSpecFile))
+	return err == nil && !info.IsDir()
+}
+
+// Discover lists the projects in a workspace directory: every immediate,
+// non-hidden subdirectory containing a city.yaml, sorted by name. A
+// workspace that is itself a project is a workspace of that one project,
+// named after the directory.
+func Discover(workspace string) ([]Entry, error) {
+	if IsProject(workspace) {
+		abs, err := filepath.Abs(workspace)
+		if err != nil {
+			abs = workspace
+		}
+		return []Entry{{Name: filepath.Base(abs), Dir: workspace}}, nil
+	}
+	entries, err := os.ReadDir(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("reading workspace: %w", err)
+	}
+	var out []Entry
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(workspace, e.Name())
+		if IsProject(dir) {
+			out = append(out, Entry{Name: e.Name(), Dir: dir})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
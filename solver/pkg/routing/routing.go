@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"context"
 	"fmt"
 	"math"
 
@@ -70,7 +71,14 @@ type networkDef struct {
 
 // RouteInfrastructure generates all underground infrastructure routes
 // using hierarchical trunk-and-branch topology (ADR-007).
-func RouteInfrastructure(s *spec.CitySpec, pods []layout.Pod, _ []layout.Building) ([]Segment, *validation.Report) {
+func RouteInfrastructure(s *spec.CitySpec, pods []layout.Pod, buildings []layout.Building) ([]Segment, *validation.Report) {
+	segments, report, _ := RouteInfrastructureContext(context.Background(), s, pods, buildings)
+	return segments, report
+}
+
+// RouteInfrastructureContext is RouteInfrastructure that stops between
+// networks once ctx is done, returning ctx's error and no segments.
+func RouteInfrastructureContext(ctx context.Context, s *spec.CitySpec, pods []layout.Pod, _ []layout.Building) ([]Segment, *validation.Report, error) {
 	report := validation.NewReport()
 
 	if len(pods) == 0 {
@@ -78,7 +86,7 @@ func RouteInfrastructure(s *spec.CitySpec, pods []layout.Pod, _ []layout.Buildin
 			Level:   validation.LevelSpatial,
 			Message: "no pods for infrastructure routing",
 		})
-		return nil, report
+		return nil, report, nil
 	}
 
 	// Total population for capacity sizing.
@@ -115,6 +123,9 @@ func RouteInfrastructure(s *spec.CitySpec, pods []layout.Pod, _ []layout.Buildin
 	idx := 0
 
 	for _, nd := range networks {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 		// Lateral offset to separate networks sharing a layer.
 		lateralOffset := 0.0
 		if nd.net == NetworkWater {
@@ -164,7 +175,7 @@ func RouteInfrastructure(s *spec.CitySpec, pods []layout.Pod, _ []layout.Buildin
 			netCounts[NetworkPedway], netCounts[NetworkBikeTunnel]),
 	})
 
-	return allSegments, report
+	return allSegments, report, nil
 }
 
 // computeBackbone builds the shared trunk geometry from spec.
@@ -51,41 +51,100 @@ func Assemble(
 	return g
 }
 
+// useMaterials gives each floor use its material. Housing is concrete,
+// which the renderer shades by height.
+var useMaterials = map[string]string{
+	layout.UseResidential: "concrete",
+	layout.UseRetail:      "glass",
+	layout.UseCoworking:   "steel",
+	layout.UseCivic:       "brick",
+}
+
+// assembleBuildings emits one entity per building, or for a building of
+// several uses one stacked volume per floor run, with IDs <building>_f<n>
+// counted from the base.
 func assembleBuildings(buildings []layout.Building, g *Graph) {
 	for _, b := range buildings {
-		mat := "concrete"
-		meta := map[string]any{"stories": b.Stories}
-
-		switch b.Type {
-		case "commercial":
-			mat = "glass"
-			meta["commercial_sqm"] = b.CommercialSqM
-		case "civic":
-			mat = "brick"
-			meta["service_type"] = b.ServiceType
-		default: // residential
-			meta["dwelling_units"] = b.DwellingUnits
+		runs := b.Floors
+		stacked := len(runs) > 1
+		if len(runs) == 0 {
+			runs = []layout.FloorUse{{Use: buildingUse(b.Type), Stories: b.Stories}}
 		}
 
-		addEntity(g, Entity{
-			ID:   b.ID,
-			Type: EntityBuilding,
-			Position: Vec3{
-				X: b.Position[0],
-				Y: 0,
-				Z: b.Position[2],
-			},
-			Dimensions: Vec3{
-				X: b.Footprint[0],
-				Y: float64(b.Stories) * floorHeight,
-				Z: b.Footprint[1],
-			},
-			Rotation: identityQuat(),
-			Material: mat,
-			Pod:      b.PodID,
-			Layer:    LayerSurface,
-			Metadata: meta,
-		})
+		base := b.Position[1]
+		for k, f := range runs {
+			meta := map[string]any{"stories": f.Stories, "use": f.Use}
+			switch f.Use {
+			case layout.UseRetail, layout.UseCoworking:
+				meta["commercial_sqm"] = b.CommercialSqM
+				if stacked {
+					meta["commercial_sqm"] = f.UsableAreaM2()
+				}
+			case layout.UseCivic:
+				meta["service_type"] = b.ServiceType
+			default: // residential
+				meta["dwelling_units"] = b.DwellingUnits
+				if stacked {
+					meta["dwelling_units"] = f.Units
+				}
+				if m := b.UnitMix; m != nil {
+					meta["unit_mix"] = map[string]int{
+						"studios":   m.Studios,
+						"one_bed":   m.OneBed,
+						"two_bed":   m.TwoBed,
+						"three_bed": m.ThreeBed,
+						"four_bed":  m.FourBed,
+					}
+				}
+			}
+			if b.Typology != "" {
+				meta["typology"] = b.Typology
+			}
+
+			id := b.ID
+			if stacked {
+				id = fmt.Sprintf("%s_f%d", b.ID, k)
+				meta["building"] = b.ID
+			}
+			mat, ok := useMaterials[f.Use]
+			if !ok {
+				mat = "concrete"
+			}
+			height := float64(f.Stories) * floorHeight
+
+			addEntity(g, Entity{
+				ID:   id,
+				Type: EntityBuilding,
+				Position: Vec3{
+					X: b.Position[0],
+					Y: base,
+					Z: b.Position[2],
+				},
+				Dimensions: Vec3{
+					X: b.Footprint[0],
+					Y: height,
+					Z: b.Footprint[1],
+				},
+				Rotation: yawQuat(b.Rotation),
+				Material: mat,
+				Pod:      b.PodID,
+				Layer:    LayerSurface,
+				Metadata: meta,
+			})
+			base += height
+		}
+	}
+}
+
+// buildingUse is the floor use of a building without a floor stack.
+func buildingUse(buildingType string) string {
+	switch buildingType {
+	case "commercial":
+		return layout.UseRetail
+	case "civic":
+		return layout.UseCivic
+	default:
+		return layout.UseResidential
 	}
 }
 
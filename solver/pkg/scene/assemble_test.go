@@ -1,6 +1,7 @@
 package scene
 
 import (
+	"math"
 	"testing"
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
@@ -187,6 +188,38 @@ func TestAssembleUniqueEntityIDs(t *testing.T) {
 	}
 }
 
+func TestAssembleStacksMixedUseBuildings(t *testing.T) {
+	g := assembleTestGraph(t)
+
+	type volume struct{ base, top float64 }
+	stacks := map[string][]volume{}
+	uses := map[string]bool{}
+	for _, e := range g.Entities {
+		if e.Type != EntityBuilding {
+			continue
+		}
+		id, ok := e.Metadata["building"].(string)
+		if !ok {
+			continue
+		}
+		uses[e.Metadata["use"].(string)] = true
+		stacks[id] = append(stacks[id], volume{e.Position.Y, e.Position.Y + e.Dimensions.Y})
+	}
+	if len(stacks) == 0 {
+		t.Fatal("no stacked buildings")
+	}
+	if !uses["retail"] || !uses["coworking"] {
+		t.Errorf("stacked uses = %v, want retail and coworking", uses)
+	}
+	for id, vs := range stacks {
+		for k := 1; k < len(vs); k++ {
+			if math.Abs(vs[k].base-vs[k-1].top) > 1e-9 {
+				t.Errorf("%s: volume %d starts at %.1f m, below it ends at %.1f m", id, k, vs[k].base, vs[k-1].top)
+			}
+		}
+	}
+}
+
 func TestAssembleSystemsCoverAllNetworks(t *testing.T) {
 	g := assembleTestGraph(t)
 	for _, sys := range []SystemType{SystemSewage, SystemWater, SystemElectrical, SystemTelecom, SystemVehicle, SystemPedestrian, SystemBicycle, SystemShuttle} {
@@ -1,6 +1,9 @@
 package scene
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"math"
 	"testing"
 
@@ -131,3 +134,72 @@ func BenchmarkFullPipeline250K(b *testing.B) {
 		runFullPipeline(b, 250000)
 	}
 }
+
+func benchmarkEncode(b *testing.B, pop int, encode func(io.Writer, *Graph) error) {
+	g := runFullPipeline(b, pop)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := encode(&buf, g); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(buf.Len()), "bytes/scene")
+	b.ReportMetric(float64(buf.Len())/float64(len(g.Entities)), "bytes/entity")
+}
+
+func encodeJSON(w io.Writer, g *Graph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+func encodeJSONCompact(w io.Writer, g *Graph) error {
+	return json.NewEncoder(w).Encode(g)
+}
+
+func BenchmarkEncodeJSON50K(b *testing.B) {
+	benchmarkEncode(b, 50000, encodeJSON)
+}
+
+func BenchmarkEncodeJSONCompact50K(b *testing.B) {
+	benchmarkEncode(b, 50000, encodeJSONCompact)
+}
+
+func BenchmarkEncodeBinary50K(b *testing.B) {
+	benchmarkEncode(b, 50000, EncodeBinary)
+}
+
+func BenchmarkDecodeJSON50K(b *testing.B) {
+	g := runFullPipeline(b, 50000)
+	data, err := json.Marshal(g)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out Graph
+		if err := json.Unmarshal(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeBinary50K(b *testing.B) {
+	g := runFullPipeline(b, 50000)
+	var buf bytes.Buffer
+	if err := EncodeBinary(&buf, g); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeBinary(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package scene
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// BinaryContentType is the media type served for the compact scene encoding.
+const BinaryContentType = "application/vnd.cityplanner.scene+binary"
+
+// Binary scene format, version 1. All multi-byte values are little-endian.
+//
+//	magic       "CPSG"
+//	version     uint8
+//	strings     uvarint count, then (uvarint length, bytes) each; index 0 is ""
+//	metadata    uvarint spec_version, uvarint generated_at, 6 float64 city bounds
+//	entities    uvarint count N, then columns:
+//	              N uvarint string refs each for id, type, material, system,
+//	              pod, layer and metadata (JSON text, 0 = none);
+//	              per entity uvarint child count then child string refs;
+//	              3N float32 positions, 3N float32 dimensions, 4N float32 rotations
+//	groups      pods, systems, layers, entity_types in that order; each is
+//	              uvarint key count, then per key (sorted): uvarint key ref,
+//	              uvarint length, zigzag varint deltas of entity indices
+const (
+	binaryMagic   = "CPSG"
+	binaryVersion = 1
+
+	// maxPrealloc bounds the elements or bytes allocated ahead of reading
+	// them.
+	maxPrealloc = 1 << 16
+)
+
+// EncodeBinary writes g in the compact binary scene format. Every ID listed
+// in g.Groups must belong to an entity in g.Entities.
+func EncodeBinary(w io.Writer, g *Graph) error {
+	e := &binaryEncoder{
+		w:       bufio.NewWriter(w),
+		strings: map[string]uint64{"": 0},
+		table:   []string{""},
+	}
+
+	index := make(map[string]uint64, len(g.Entities))
+	for i, ent := range g.Entities {
+		if _, dup := index[ent.ID]; !dup {
+			index[ent.ID] = uint64(i)
+		}
+	}
+
+	// Intern every string first so the table can be written up front.
+	n := len(g.Entities)
+	refs := make([]uint64, 7*n)
+	children := make([][]uint64, n)
+	for i, ent := range g.Entities {
+		meta := ""
+		if len(ent.Metadata) > 0 {
+			b, err := json.Marshal(ent.Metadata)
+			if err != nil {
+				return fmt.Errorf("encoding metadata for %q: %w", ent.ID, err)
+			}
+			meta = string(b)
+		}
+		refs[i] = e.intern(ent.ID)
+		refs[n+i] = e.intern(string(ent.Type))
+		refs[2*n+i] = e.intern(ent.Material)
+		refs[3*n+i] = e.intern(string(ent.System))
+		refs[4*n+i] = e.intern(ent.Pod)
+		refs[5*n+i] = e.intern(string(ent.Layer))
+		refs[6*n+i] = e.intern(meta)
+		for _, c := range ent.Children {
+			children[i] = append(children[i], e.intern(c))
+		}
+	}
+
+	groups := [4]map[string][]string{
+		g.Groups.Pods,
+		stringKeyed(g.Groups.Systems),
+		stringKeyed(g.Groups.Layers),
+		stringKeyed(g.Groups.EntityTypes),
+	}
+	for _, grp := range groups {
+		for _, k := range SortedKeys(grp) {
+			e.intern(k)
+		}
+	}
+	specRef := e.intern(g.Metadata.SpecVersion)
+	genRef := e.intern(g.Metadata.GeneratedAt)
+
+	e.bytes([]byte(binaryMagic))
+	e.bytes([]byte{binaryVersion})
+
+	e.uvarint(uint64(len(e.table)))
+	for _, s := range e.table {
+		e.uvarint(uint64(len(s)))
+		e.bytes([]byte(s))
+	}
+
+	e.uvarint(specRef)
+	e.uvarint(genRef)
+	b := g.Metadata.CityBounds
+	for _, v := range []float64{b.Min.X, b.Min.Y, b.Min.Z, b.Max.X, b.Max.Y, b.Max.Z} {
+		e.float64(v)
+	}
+
+	e.uvarint(uint64(n))
+	for _, r := range refs {
+		e.uvarint(r)
+	}
+	for _, cs := range children {
+		e.uvarint(uint64(len(cs)))
+		for _, c := range cs {
+			e.uvarint(c)
+		}
+	}
+	for _, ent := range g.Entities {
+		e.vec3(ent.Position)
+	}
+	for _, ent := range g.Entities {
+		e.vec3(ent.Dimensions)
+	}
+	for _, ent := range g.Entities {
+		for _, q := range ent.Rotation {
+			e.float32(q)
+		}
+	}
+
+	for _, grp := range groups {
+		keys := make([]string, 0, len(grp))
+		for k := range grp {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		e.uvarint(uint64(len(keys)))
+		for _, k := range keys {
+			ids := grp[k]
+			e.uvarint(e.strings[k])
+			e.uvarint(uint64(len(ids)))
+			prev := int64(0)
+			for _, id := range ids {
+				idx, ok := index[id]
+				if !ok {
+					return fmt.Errorf("group %q references unknown entity %q", k, id)
+				}
+				e.varint(int64(idx) - prev)
+				prev = int64(idx)
+			}
+		}
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// DecodeBinary reads a graph written by EncodeBinary. Positions, dimensions
+// and rotations come back at float32 precision.
+func DecodeBinary(r io.Reader) (*Graph, error) {
+	d := &binaryDecoder{r: bufio.NewReader(r)}
+
+	magic := d.bytes(len(binaryMagic))
+	if d.err == nil && string(magic) != binaryMagic {
+		return nil, errors.New("not a binary scene graph (bad magic)")
+	}
+	if v := d.bytes(1); d.err == nil && v[0] != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary scene version %d", v[0])
+	}
+
+	count := d.count()
+	table := make([]string, 0, capacity(count))
+	for i := 0; i < count && d.err == nil; i++ {
+		table = append(table, string(d.bytes(d.count())))
+	}
+	d.table = table
+
+	g := NewGraph()
+	g.Metadata.SpecVersion = d.str()
+	g.Metadata.GeneratedAt = d.str()
+	var bv [6]float64
+	for i := range bv {
+		bv[i] = d.float64()
+	}
+	g.Metadata.CityBounds = BoundingBox{
+		Min: Vec3{X: bv[0], Y: bv[1], Z: bv[2]},
+		Max: Vec3{X: bv[3], Y: bv[4], Z: bv[5]},
+	}
+
+	// Entities grow as their IDs are read, so a corrupt count fails at the
+	// end of the input instead of allocating it up front.
+	n := d.count()
+	g.Entities = make([]Entity, 0, capacity(n))
+	for i := 0; i < n && d.err == nil; i++ {
+		g.Entities = append(g.Entities, Entity{ID: d.str()})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	for i := range g.Entities {
+		g.Entities[i].Type = EntityType(d.str())
+	}
+	for i := range g.Entities {
+		g.Entities[i].Material = d.str()
+	}
+	for i := range g.Entities {
+		g.Entities[i].System = SystemType(d.str())
+	}
+	for i := range g.Entities {
+		g.Entities[i].Pod = d.str()
+	}
+	for i := range g.Entities {
+		g.Entities[i].Layer = LayerType(d.str())
+	}
+	for i := range g.Entities {
+		meta := d.str()
+		if meta == "" || d.err != nil {
+			continue
+		}
+		if err := json.Unmarshal([]byte(meta), &g.Entities[i].Metadata); err != nil {
+			return nil, fmt.Errorf("decoding metadata for %q: %w", g.Entities[i].ID, err)
+		}
+	}
+	for i := range g.Entities {
+		nc := d.count()
+		for j := 0; j < nc && d.err == nil; j++ {
+			g.Entities[i].Children = append(g.Entities[i].Children, d.str())
+		}
+	}
+	for i := range g.Entities {
+		g.Entities[i].Position = d.vec3()
+	}
+	for i := range g.Entities {
+		g.Entities[i].Dimensions = d.vec3()
+	}
+	for i := range g.Entities {
+		for q := range g.Entities[i].Rotation {
+			g.Entities[i].Rotation[q] = float64(d.float32())
+		}
+	}
+
+	for gi := 0; gi < 4 && d.err == nil; gi++ {
+		nk := d.count()
+		for k := 0; k < nk && d.err == nil; k++ {
+			key := d.str()
+			length := d.count()
+			ids := make([]string, 0, capacity(length))
+			idx := int64(0)
+			for j := 0; j < length && d.err == nil; j++ {
+				idx += d.varint()
+				if idx < 0 || idx >= int64(n) {
+					d.fail(fmt.Errorf("group %q entity index %d out of range", key, idx))
+					break
+				}
+				ids = append(ids, g.Entities[idx].ID)
+			}
+			switch gi {
+			case 0:
+				g.Groups.Pods[key] = ids
+			case 1:
+				g.Groups.Systems[SystemType(key)] = ids
+			case 2:
+				g.Groups.Layers[LayerType(key)] = ids
+			case 3:
+				g.Groups.EntityTypes[EntityType(key)] = ids
+			}
+		}
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return g, nil
+}
+
+func stringKeyed[K ~string](m map[K][]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, v := range m {
+		out[string(k)] = v
+	}
+	return out
+}
+
+// binaryEncoder writes primitives with a sticky error.
+type binaryEncoder struct {
+	w       *bufio.Writer
+	err     error
+	buf     [binary.MaxVarintLen64]byte
+	strings map[string]uint64
+	table   []string
+}
+
+func (e *binaryEncoder) intern(s string) uint64 {
+	if i, ok := e.strings[s]; ok {
+		return i
+	}
+	i := uint64(len(e.table))
+	e.strings[s] = i
+	e.table = append(e.table, s)
+	return i
+}
+
+func (e *binaryEncoder) bytes(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *binaryEncoder) uvarint(v uint64) {
+	e.bytes(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *binaryEncoder) varint(v int64) {
+	e.bytes(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *binaryEncoder) float32(v float64) {
+	binary.LittleEndian.PutUint32(e.buf[:4], math.Float32bits(float32(v)))
+	e.bytes(e.buf[:4])
+}
+
+func (e *binaryEncoder) float64(v float64) {
+	binary.LittleEndian.PutUint64(e.buf[:8], math.Float64bits(v))
+	e.bytes(e.buf[:8])
+}
+
+func (e *binaryEncoder) vec3(v Vec3) {
+	e.float32(v.X)
+	e.float32(v.Y)
+	e.float32(v.Z)
+}
+
+// binaryDecoder reads primitives with a sticky error; after the first
+// failure every read returns a zero value.
+type binaryDecoder struct {
+	r     *bufio.Reader
+	err   error
+	table []string
+}
+
+func (d *binaryDecoder) fail(err error) {
+	if d.err == nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+	}
+}
+
+func (d *binaryDecoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n <= maxPrealloc {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			d.fail(err)
+			return nil
+		}
+		return b
+	}
+	// Longer reads grow with the data actually present.
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		d.fail(err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+	return v
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+	return v
+}
+
+// capacity returns the slice capacity to reserve for n elements read from
+// the input. Length prefixes are untrusted, so at most maxPrealloc are
+// reserved and larger slices grow as their elements are read.
+func capacity(n int) int {
+	return min(n, maxPrealloc)
+}
+
+// count reads a length prefix, rejecting values that cannot be a real size.
+func (d *binaryDecoder) count() int {
+	v := d.uvarint()
+	if v > math.MaxInt32 {
+		d.fail(fmt.Errorf("invalid length %d", v))
+		return 0
+	}
+	return int(v)
+}
+
+func (d *binaryDecoder) str() string {
+	i := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if i >= uint64(len(d.table)) {
+		d.fail(fmt.Errorf("string reference %d out of range (table has %d)", i, len(d.table)))
+		return ""
+	}
+	return d.table[i]
+}
+
+func (d *binaryDecoder) float32() float32 {
+	b := d.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+func (d *binaryDecoder) float64() float64 {
+	b := d.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+func (d *binaryDecoder) vec3() Vec3 {
+	return Vec3{X: float64(d.float32()), Y: float64(d.float32()), Z: float64(d.float32())}
+}
@@ -0,0 +1,161 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	g := assembleTestGraph(t)
+
+	var buf bytes.Buffer
+	if err := EncodeBinary(&buf, g); err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	got, err := DecodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("DecodeBinary: %v", err)
+	}
+
+	report := ValidateGraph(got)
+	if !report.Valid {
+		for _, e := range report.Errors {
+			t.Errorf("decoded graph: %s", e.Message)
+		}
+	}
+
+	if got.Metadata.SpecVersion != g.Metadata.SpecVersion || got.Metadata.GeneratedAt != g.Metadata.GeneratedAt {
+		t.Errorf("metadata = %+v, want %+v", got.Metadata, g.Metadata)
+	}
+	if got.Metadata.CityBounds != g.Metadata.CityBounds {
+		t.Errorf("city bounds = %+v, want %+v", got.Metadata.CityBounds, g.Metadata.CityBounds)
+	}
+	if len(got.Entities) != len(g.Entities) {
+		t.Fatalf("entities = %d, want %d", len(got.Entities), len(g.Entities))
+	}
+
+	const tol = 0.01 // float32 precision at city scale
+	near := func(a, b float64) bool { return math.Abs(a-b) <= tol }
+	for i, want := range g.Entities {
+		e := got.Entities[i]
+		if e.ID != want.ID || e.Type != want.Type || e.Material != want.Material ||
+			e.System != want.System || e.Pod != want.Pod || e.Layer != want.Layer {
+			t.Fatalf("entity %d = %+v, want %+v", i, e, want)
+		}
+		if !near(e.Position.X, want.Position.X) || !near(e.Position.Y, want.Position.Y) || !near(e.Position.Z, want.Position.Z) {
+			t.Fatalf("entity %s position %+v, want %+v", e.ID, e.Position, want.Position)
+		}
+		if !near(e.Dimensions.X, want.Dimensions.X) || !near(e.Dimensions.Y, want.Dimensions.Y) || !near(e.Dimensions.Z, want.Dimensions.Z) {
+			t.Fatalf("entity %s dimensions %+v, want %+v", e.ID, e.Dimensions, want.Dimensions)
+		}
+		for q := range e.Rotation {
+			if !near(e.Rotation[q], want.Rotation[q]) {
+				t.Fatalf("entity %s rotation %v, want %v", e.ID, e.Rotation, want.Rotation)
+			}
+		}
+		wantMeta, _ := json.Marshal(want.Metadata)
+		gotMeta, _ := json.Marshal(e.Metadata)
+		if !bytes.Equal(gotMeta, wantMeta) {
+			t.Fatalf("entity %s metadata %s, want %s", e.ID, gotMeta, wantMeta)
+		}
+	}
+
+	if !reflect.DeepEqual(got.Groups, g.Groups) {
+		t.Error("groups differ after round trip")
+	}
+}
+
+func TestBinarySmallerThanJSON(t *testing.T) {
+	g := assembleTestGraph(t)
+
+	var bin bytes.Buffer
+	if err := EncodeBinary(&bin, g); err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	js, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if bin.Len()*3 > len(js) {
+		t.Errorf("binary %d bytes is not at least 3x smaller than JSON %d bytes", bin.Len(), len(js))
+	}
+	t.Logf("binary %d bytes, json %d bytes (%.1fx)", bin.Len(), len(js), float64(len(js))/float64(bin.Len()))
+}
+
+func TestBinaryRejectsMalformedInput(t *testing.T) {
+	g := assembleTestGraph(t)
+	var buf bytes.Buffer
+	if err := EncodeBinary(&buf, g); err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	data := buf.Bytes()
+
+	if _, err := DecodeBinary(bytes.NewReader([]byte("JSON{}"))); err == nil {
+		t.Error("expected error for bad magic")
+	}
+	if _, err := DecodeBinary(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Error("expected error for truncated input")
+	}
+	bad := append([]byte{}, data...)
+	bad[len(binaryMagic)] = binaryVersion + 1
+	if _, err := DecodeBinary(bytes.NewReader(bad)); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
+
+func TestBinaryRejectsDanglingGroupReference(t *testing.T) {
+	g := NewGraph()
+	addEntity(g, Entity{ID: "a", Type: EntityTree, Layer: LayerSurface, Dimensions: Vec3{X: 1, Y: 1, Z: 1}})
+	g.Groups.Pods["pod_x"] = []string{"missing"}
+
+	var buf bytes.Buffer
+	if err := EncodeBinary(&buf, g); err == nil {
+		t.Error("expected error for group referencing unknown entity")
+	}
+}
+
+func TestBinaryRejectsHugeLengths(t *testing.T) {
+	huge := binary.AppendUvarint(nil, 2147483000)
+	header := append([]byte(binaryMagic), binaryVersion)
+	// An empty string table and metadata, then an entity count.
+	entities := append(append([]byte{}, header...), 1, 0, 0, 0)
+	entities = append(entities, make([]byte, 48)...)
+	entities = append(entities, huge...)
+
+	for name, data := range map[string][]byte{
+		"string table":  append(append([]byte{}, header...), huge...),
+		"string length": append(append(append([]byte{}, header...), 1), huge...),
+		"entities":      entities,
+	} {
+		if _, err := DecodeBinary(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected error for a length past the end of the input", name)
+		}
+	}
+}
+
+func FuzzDecodeBinary(f *testing.F) {
+	g := NewGraph()
+	addEntity(g, Entity{ID: "a", Type: EntityTree, Layer: LayerSurface, Pod: "pod_x", Dimensions: Vec3{X: 1, Y: 1, Z: 1}})
+	g.Groups.Pods["pod_x"] = []string{"a"}
+	var buf bytes.Buffer
+	if err := EncodeBinary(&buf, g); err != nil {
+		f.Fatalf("EncodeBinary: %v", err)
+	}
+	f.Add(buf.Bytes())
+	f.Add(append([]byte(binaryMagic), binaryVersion, 0xf8, 0xff, 0xff, 0xff, 0x07))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		g, err := DecodeBinary(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		var out bytes.Buffer
+		if err := EncodeBinary(&out, g); err != nil {
+			t.Fatalf("re-encoding a decoded graph: %v", err)
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package scene
+
+import "math"
+
+// ChangeKind classifies how an entity differs between two scene graphs.
+type ChangeKind string
+
+const (
+	ChangeAdded          ChangeKind = "added"
+	ChangeRemoved        ChangeKind = "removed"
+	ChangeRenamed        ChangeKind = "renamed" // matched by position, ID differs
+	ChangeMoved          ChangeKind = "moved"
+	ChangeResized        ChangeKind = "resized"
+	ChangeRotated        ChangeKind = "rotated"
+	ChangeRematerialized ChangeKind = "rematerialized"
+)
+
+// DiffOptions tunes entity matching.
+type DiffOptions struct {
+	// Epsilon is the largest difference in a coordinate, dimension or
+	// rotation component still considered equal. Zero means 1e-6.
+	Epsilon float64
+
+	// MatchRadius is how far, in meters, an entity whose ID has no match
+	// may have moved and still be matched to an entity of the same type.
+	// Zero means 5; a negative value disables spatial matching.
+	MatchRadius float64
+}
+
+func (o DiffOptions) withDefaults() DiffOptions {
+	if o.Epsilon == 0 {
+		o.Epsilon = 1e-6
+	}
+	if o.MatchRadius == 0 {
+		o.MatchRadius = 5
+	}
+	return o
+}
+
+// EntityChange describes one added, removed or changed entity. For a
+// matched pair, ID is the old entity's and NewID the new one's when they
+// differ. Old and new values are set only for the attributes that changed.
+type EntityChange struct {
+	ID       string       `json:"id"`
+	NewID    string       `json:"new_id,omitempty"`
+	Type     EntityType   `json:"type"`
+	Pod      string       `json:"pod,omitempty"`
+	System   SystemType   `json:"system,omitempty"`
+	Layer    LayerType    `json:"layer"`
+	Kinds    []ChangeKind `json:"kinds"`
+	Distance float64      `json:"distance,omitempty"` // meters moved
+
+	OldPosition   *Vec3  `json:"old_position,omitempty"`
+	NewPosition   *Vec3  `json:"new_position,omitempty"`
+	OldDimensions *Vec3  `json:"old_dimensions,omitempty"`
+	NewDimensions *Vec3  `json:"new_dimensions,omitempty"`
+	OldMaterial   string `json:"old_material,omitempty"`
+	NewMaterial   string `json:"new_material,omitempty"`
+}
+
+// Has reports whether the change includes kind.
+func (c EntityChange) Has(kind ChangeKind) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffSummary counts changes. An entity with several kinds of change counts
+// once under each; Changed counts it once.
+type DiffSummary struct {
+	Added          int `json:"added"`
+	Removed        int `json:"removed"`
+	Renamed        int `json:"renamed"`
+	Moved          int `json:"moved"`
+	Resized        int `json:"resized"`
+	Rotated        int `json:"rotated"`
+	Rematerialized int `json:"rematerialized"`
+	Changed        int `json:"changed"`
+	Unchanged      int `json:"unchanged"`
+}
+
+func (s *DiffSummary) add(kinds []ChangeKind) {
+	if len(kinds) == 0 {
+		s.Unchanged++
+		return
+	}
+	s.Changed++
+	for _, k := range kinds {
+		switch k {
+		case ChangeAdded:
+			s.Added++
+		case ChangeRemoved:
+			s.Removed++
+		case ChangeRenamed:
+			s.Renamed++
+		case ChangeMoved:
+			s.Moved++
+		case ChangeResized:
+			s.Resized++
+		case ChangeRotated:
+			s.Rotated++
+		case ChangeRematerialized:
+			s.Rematerialized++
+		}
+	}
+}
+
+// GraphDiff is the semantic difference between two scene graphs.
+type GraphDiff struct {
+	Summary DiffSummary `json:"summary"`
+
+	// Group-level summaries. Entities without a pod or system are not
+	// counted in Pods or Systems.
+	Pods    map[string]*DiffSummary     `json:"pods"`
+	Systems map[SystemType]*DiffSummary `json:"systems"`
+	Layers  map[LayerType]*DiffSummary  `json:"layers"`
+	Types   map[EntityType]*DiffSummary `json:"entity_types"`
+
+	// Changes lists every changed entity: matched and removed entities in
+	// the old graph's order, then added entities in the new graph's order.
+	Changes []EntityChange `json:"changes"`
+}
+
+// Empty reports whether the graphs have no entity differences.
+func (d *GraphDiff) Empty() bool {
+	return d.Summary.Changed == 0
+}
+
+// Diff compares two scene graphs entity by entity. Entities are matched by
+// ID first, then the remaining entities to the nearest unmatched entity of
+// the same type within opts.MatchRadius, so renumbered entities are not
+// reported as removed and re-added. Because solver IDs are sequential, an
+// ID that moved farther than the radius more likely names a different
+// entity: such pairs are matched only after spatial matching, if neither
+// side found a nearer partner. Group summaries attribute an entity to its
+// new pod, system and layer, or its old ones if removed.
+func Diff(before, after *Graph, opts DiffOptions) *GraphDiff {
+	opts = opts.withDefaults()
+	d := &GraphDiff{
+		Pods:    map[string]*DiffSummary{},
+		Systems: map[SystemType]*DiffSummary{},
+		Layers:  map[LayerType]*DiffSummary{},
+		Types:   map[EntityType]*DiffSummary{},
+		Changes: []EntityChange{},
+	}
+
+	newByID := make(map[string]int, len(after.Entities))
+	for i, e := range after.Entities {
+		newByID[e.ID] = i
+	}
+	match := make([]int, len(before.Entities)) // old index -> new index, or -1
+	claimed := make([]bool, len(after.Entities))
+	sameID := func(i int, e Entity, near bool) {
+		j, ok := newByID[e.ID]
+		if !ok || claimed[j] || after.Entities[j].Type != e.Type {
+			return
+		}
+		if near && distance(e.Position, after.Entities[j].Position) > opts.MatchRadius {
+			return
+		}
+		match[i] = j
+		claimed[j] = true
+	}
+	for i, e := range before.Entities {
+		match[i] = -1
+		sameID(i, e, opts.MatchRadius > 0)
+	}
+	if opts.MatchRadius > 0 {
+		matchSpatially(before, after, match, claimed, opts.MatchRadius)
+		for i, e := range before.Entities {
+			if match[i] < 0 {
+				sameID(i, e, false)
+			}
+		}
+	}
+
+	for i, j := range match {
+		o := before.Entities[i]
+		if j < 0 {
+			d.record(o, EntityChange{
+				ID: o.ID, Type: o.Type, Pod: o.Pod, System: o.System, Layer: o.Layer,
+				Kinds:         []ChangeKind{ChangeRemoved},
+				OldPosition:   vecPtr(o.Position),
+				OldDimensions: vecPtr(o.Dimensions),
+				OldMaterial:   o.Material,
+			})
+			continue
+		}
+		n := after.Entities[j]
+		d.record(n, compareEntities(o, n, opts.Epsilon))
+	}
+	for j, n := range after.Entities {
+		if claimed[j] {
+			continue
+		}
+		d.record(n, EntityChange{
+			ID: n.ID, Type: n.Type, Pod: n.Pod, System: n.System, Layer: n.Layer,
+			Kinds:         []ChangeKind{ChangeAdded},
+			NewPosition:   vecPtr(n.Position),
+			NewDimensions: vecPtr(n.Dimensions),
+			NewMaterial:   n.Material,
+		})
+	}
+	return d
+}
+
+// record counts c against e's groups and keeps it if anything changed.
+func (d *GraphDiff) record(e Entity, c EntityChange) {
+	d.Summary.add(c.Kinds)
+	if e.Pod != "" {
+		groupSummary(d.Pods, e.Pod).add(c.Kinds)
+	}
+	if e.System != "" {
+		groupSummary(d.Systems, e.System).add(c.Kinds)
+	}
+	groupSummary(d.Layers, e.Layer).add(c.Kinds)
+	groupSummary(d.Types, e.Type).add(c.Kinds)
+	if len(c.Kinds) > 0 {
+		d.Changes = append(d.Changes, c)
+	}
+}
+
+func groupSummary[K comparable](m map[K]*DiffSummary, k K) *DiffSummary {
+	s := m[k]
+	if s == nil {
+		s = &DiffSummary{}
+		m[k] = s
+	}
+	return s
+}
+
+func compareEntities(o, n Entity, eps float64) EntityChange {
+	c := EntityChange{
+		ID: o.ID, Type: n.Type, Pod: n.Pod, System: n.System, Layer: n.Layer,
+		Kinds: []ChangeKind{},
+	}
+	if n.ID != o.ID {
+		c.NewID = n.ID
+		c.Kinds = append(c.Kinds, ChangeRenamed)
+	}
+	if !vecEqual(o.Position, n.Position, eps) {
+		c.Kinds = append(c.Kinds, ChangeMoved)
+		c.Distance = distance(o.Position, n.Position)
+		c.OldPosition, c.NewPosition = vecPtr(o.Position), vecPtr(n.Position)
+	}
+	if !vecEqual(o.Dimensions, n.Dimensions, eps) {
+		c.Kinds = append(c.Kinds, ChangeResized)
+		c.OldDimensions, c.NewDimensions = vecPtr(o.Dimensions), vecPtr(n.Dimensions)
+	}
+	for k := range o.Rotation {
+		if math.Abs(o.Rotation[k]-n.Rotation[k]) > eps {
+			c.Kinds = append(c.Kinds, ChangeRotated)
+			break
+		}
+	}
+	if o.Material != n.Material {
+		c.Kinds = append(c.Kinds, ChangeRematerialized)
+		c.OldMaterial, c.NewMaterial = o.Material, n.Material
+	}
+	if len(c.Kinds) == 0 {
+		c.Kinds = nil
+	}
+	return c
+}
+
+// matchSpatially pairs unmatched old entities with the nearest unclaimed new
+// entity of the same type within radius. Old entities are taken in order
+// and ties go to the earlier new entity, so matching is deterministic.
+func matchSpatially(before, after *Graph, match []int, claimed []bool, radius float64) {
+	type cell struct {
+		t    EntityType
+		x, z int
+	}
+	cellOf := func(e Entity) cell {
+		return cell{e.Type, int(math.Floor(e.Position.X / radius)), int(math.Floor(e.Position.Z / radius))}
+	}
+	grid := map[cell][]int{}
+	for j, e := range after.Entities {
+		if !claimed[j] {
+			c := cellOf(e)
+			grid[c] = append(grid[c], j)
+		}
+	}
+	if len(grid) == 0 {
+		return
+	}
+
+	for i, e := range before.Entities {
+		if match[i] >= 0 {
+			continue
+		}
+		c := cellOf(e)
+		best, bestDist := -1, radius
+		for dx := -1; dx <= 1; dx++ {
+			for dz := -1; dz <= 1; dz++ {
+				for _, j := range grid[cell{c.t, c.x + dx, c.z + dz}] {
+					if claimed[j] {
+						continue
+					}
+					dist := distance(e.Position, after.Entities[j].Position)
+					if dist < bestDist || (dist == bestDist && best >= 0 && j < best) {
+						best, bestDist = j, dist
+					}
+				}
+			}
+		}
+		if best >= 0 {
+			match[i] = best
+			claimed[best] = true
+		}
+	}
+}
+
+func vecEqual(a, b Vec3, eps float64) bool {
+	return math.Abs(a.X-b.X) <= eps && math.Abs(a.Y-b.Y) <= eps && math.Abs(a.Z-b.Z) <= eps
+}
+
+func distance(a, b Vec3) float64 {
+	return math.Sqrt((a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y) + (a.Z-b.Z)*(a.Z-b.Z))
+}
+
+func vecPtr(v Vec3) *Vec3 {
+	return &v
+}
@@ -0,0 +1,144 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func diffTestGraphs() (*Graph, *Graph) {
+	before := NewGraph()
+	before.Entities = []Entity{
+		{ID: "b1", Type: EntityBuilding, Pod: "p1", Layer: LayerSurface, Material: "concrete", Dimensions: Vec3{10, 20, 10}},
+		{ID: "b2", Type: EntityBuilding, Pod: "p1", Layer: LayerSurface, Material: "concrete", Position: Vec3{X: 50}},
+		{ID: "b3", Type: EntityBuilding, Pod: "p2", Layer: LayerSurface, Material: "glass", Position: Vec3{X: 100}},
+		{ID: "t1", Type: EntityTree, Pod: "p2", Layer: LayerSurface, Position: Vec3{X: 200}},
+		{ID: "w1", Type: EntityPipe, System: SystemWater, Layer: LayerUnderground1, Position: Vec3{X: 300}},
+	}
+	after := NewGraph()
+	after.Entities = []Entity{
+		{ID: "b1", Type: EntityBuilding, Pod: "p1", Layer: LayerSurface, Material: "concrete", Dimensions: Vec3{10, 30, 10}},
+		{ID: "b2", Type: EntityBuilding, Pod: "p1", Layer: LayerSurface, Material: "timber", Position: Vec3{X: 53, Z: 4}},
+		{ID: "b3", Type: EntityBuilding, Pod: "p2", Layer: LayerSurface, Material: "glass", Position: Vec3{X: 100}},
+		{ID: "t9", Type: EntityTree, Pod: "p2", Layer: LayerSurface, Position: Vec3{X: 201}},
+		{ID: "s1", Type: EntityPipe, System: SystemSewage, Layer: LayerUnderground2, Position: Vec3{X: 900}},
+	}
+	return before, after
+}
+
+func changeByID(d *GraphDiff, id string) (EntityChange, bool) {
+	for _, c := range d.Changes {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return EntityChange{}, false
+}
+
+func TestDiff(t *testing.T) {
+	before, after := diffTestGraphs()
+	d := Diff(before, after, DiffOptions{})
+
+	want := DiffSummary{Added: 1, Removed: 1, Renamed: 1, Moved: 2, Resized: 1, Rematerialized: 1, Changed: 5, Unchanged: 1}
+	if d.Summary != want {
+		t.Errorf("summary = %+v, want %+v", d.Summary, want)
+	}
+
+	if c, _ := changeByID(d, "b1"); !c.Has(ChangeResized) || c.Has(ChangeMoved) {
+		t.Errorf("b1 change = %+v, want resized only", c)
+	}
+	c, _ := changeByID(d, "b2")
+	if !c.Has(ChangeMoved) || !c.Has(ChangeRematerialized) || c.Distance != 5 {
+		t.Errorf("b2 change = %+v, want moved 5m and rematerialized", c)
+	}
+	if _, ok := changeByID(d, "b3"); ok {
+		t.Error("unchanged b3 reported as changed")
+	}
+	if c, _ := changeByID(d, "t1"); c.NewID != "t9" || !c.Has(ChangeRenamed) || !c.Has(ChangeMoved) {
+		t.Errorf("t1 change = %+v, want renamed to t9 and moved", c)
+	}
+	if c, _ := changeByID(d, "w1"); !c.Has(ChangeRemoved) {
+		t.Errorf("w1 change = %+v, want removed", c)
+	}
+	if c, _ := changeByID(d, "s1"); !c.Has(ChangeAdded) {
+		t.Errorf("s1 change = %+v, want added", c)
+	}
+
+	if p := d.Pods["p2"]; p == nil || p.Unchanged != 1 || p.Renamed != 1 {
+		t.Errorf("pod p2 summary = %+v", p)
+	}
+	if s := d.Systems[SystemWater]; s == nil || s.Removed != 1 {
+		t.Errorf("water summary = %+v", s)
+	}
+	if s := d.Systems[SystemSewage]; s == nil || s.Added != 1 {
+		t.Errorf("sewage summary = %+v", s)
+	}
+}
+
+func TestDiffWithoutSpatialMatching(t *testing.T) {
+	before, after := diffTestGraphs()
+	d := Diff(before, after, DiffOptions{MatchRadius: -1})
+	if d.Summary.Renamed != 0 || d.Summary.Added != 2 || d.Summary.Removed != 2 {
+		t.Errorf("summary = %+v, want t1/t9 as removed and added", d.Summary)
+	}
+}
+
+func TestDiffIdentical(t *testing.T) {
+	g := assembleTestGraph(t)
+	d := Diff(g, g, DiffOptions{})
+	if !d.Empty() || d.Summary.Unchanged != len(g.Entities) {
+		t.Errorf("summary = %+v, want %d unchanged", d.Summary, len(g.Entities))
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	g := assembleTestGraph(t)
+	dir := t.TempDir()
+
+	var jsonBuf, binBuf bytes.Buffer
+	if err := json.NewEncoder(&jsonBuf).Encode(g); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncodeBinary(&binBuf, g); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"g.json": jsonBuf.Bytes(), "g.bin": binBuf.Bytes()} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", name, err)
+		}
+		if len(got.Entities) != len(g.Entities) {
+			t.Errorf("ReadFile(%s): %d entities, want %d", name, len(got.Entities), len(g.Entities))
+		}
+	}
+}
+
+func TestDiffRenumbered(t *testing.T) {
+	// Removing the first building shifts every later sequential ID.
+	before, after := NewGraph(), NewGraph()
+	for i, x := range []float64{0, 100, 200} {
+		before.Entities = append(before.Entities, Entity{ID: fmt.Sprintf("bldg_%05d", i), Type: EntityBuilding, Position: Vec3{X: x}})
+	}
+	for i, x := range []float64{100, 200} {
+		after.Entities = append(after.Entities, Entity{ID: fmt.Sprintf("bldg_%05d", i), Type: EntityBuilding, Position: Vec3{X: x}})
+	}
+
+	d := Diff(before, after, DiffOptions{})
+	want := DiffSummary{Removed: 1, Renamed: 2, Changed: 3}
+	if d.Summary != want {
+		t.Errorf("summary = %+v, want %+v", d.Summary, want)
+	}
+	if c, _ := changeByID(d, "bldg_00000"); !c.Has(ChangeRemoved) {
+		t.Errorf("bldg_00000 change = %+v, want removed", c)
+	}
+	if c, _ := changeByID(d, "bldg_00001"); c.NewID != "bldg_00000" || c.Has(ChangeMoved) {
+		t.Errorf("bldg_00001 change = %+v, want renamed to bldg_00000 in place", c)
+	}
+}
@@ -0,0 +1,32 @@
+package scene
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ReadFile loads a scene graph saved in either the JSON or the binary
+// encoding; the binary encoding is recognised by its magic bytes.
+func ReadFile(path string) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	if magic, err := r.Peek(len(binaryMagic)); err == nil && string(magic) == binaryMagic {
+		g, err := DecodeBinary(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return g, nil
+	}
+	var g Graph
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, fmt.Errorf("%s: decoding scene graph: %w", path, err)
+	}
+	return &g, nil
+}
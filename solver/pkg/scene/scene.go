@@ -1,5 +1,7 @@
 package scene
 
+import "sort"
+
 // SystemType identifies an infrastructure system.
 type SystemType string
 
@@ -107,3 +109,14 @@ func NewGraph() *Graph {
 		},
 	}
 }
+
+// SortedKeys returns the keys of a group map in order, for output that must
+// not depend on map iteration order.
+func SortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
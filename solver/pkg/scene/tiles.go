@@ -0,0 +1,425 @@
+package scene
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// TileOptions controls quadtree subdivision of a scene graph into tiles.
+type TileOptions struct {
+	MaxZoom            int // deepest quadtree level; the root tile is zoom 0
+	MaxEntitiesPerTile int // a tile is split while it holds more entities than this
+	TreeClusterCells   int // tree clusters per tile edge in LOD proxies
+}
+
+// DefaultTileOptions returns the subdivision settings used by the dev server.
+func DefaultTileOptions() TileOptions {
+	return TileOptions{
+		MaxZoom:            6,
+		MaxEntitiesPerTile: 4000,
+		TreeClusterCells:   8,
+	}
+}
+
+// TileSet is a quadtree partition of a scene graph for streaming.
+// Leaf tiles carry full entities; interior tiles carry coarse LOD proxies
+// (merged pod massing, tree clusters, large surface features).
+type TileSet struct {
+	Metadata TileSetMetadata `json:"metadata"`
+	Tiles    []Tile          `json:"tiles"`
+
+	index map[string]int
+}
+
+// TileSetMetadata describes the quadtree covering the city.
+type TileSetMetadata struct {
+	SpecVersion string      `json:"spec_version"`
+	GeneratedAt string      `json:"generated_at"`
+	Root        BoundingBox `json:"root"` // square XZ region of the zoom-0 tile
+	MaxZoom     int         `json:"max_zoom"`
+	TileCount   int         `json:"tile_count"`
+}
+
+// Tile is one node of the scene quadtree. X indexes the world X axis and
+// Y indexes the world Z axis, both counted from the root's minimum corner.
+type Tile struct {
+	ID          string      `json:"id"`
+	Zoom        int         `json:"z"`
+	X           int         `json:"x"`
+	Y           int         `json:"y"`
+	Bounds      BoundingBox `json:"bounds"` // AABB of every entity beneath this tile
+	Leaf        bool        `json:"leaf"`
+	Children    []string    `json:"children,omitempty"`
+	EntityCount int         `json:"entity_count"` // entities beneath this tile
+	Entities    []Entity    `json:"entities,omitempty"`
+	Proxies     []Entity    `json:"proxies,omitempty"`
+}
+
+// TileSummary is a tile without its entity payload, for index listings.
+type TileSummary struct {
+	ID          string      `json:"id"`
+	Zoom        int         `json:"z"`
+	X           int         `json:"x"`
+	Y           int         `json:"y"`
+	Bounds      BoundingBox `json:"bounds"`
+	Leaf        bool        `json:"leaf"`
+	Children    []string    `json:"children,omitempty"`
+	EntityCount int         `json:"entity_count"`
+}
+
+// TileIndex lists every tile in a tile set without entity payloads.
+type TileIndex struct {
+	Metadata TileSetMetadata `json:"metadata"`
+	Tiles    []TileSummary   `json:"tiles"`
+}
+
+// TileID returns the deterministic identifier for a quadtree address.
+func TileID(z, x, y int) string {
+	return fmt.Sprintf("tile_%d_%d_%d", z, x, y)
+}
+
+// BuildTiles partitions the graph's entities into a quadtree. Each entity
+// is assigned by its XZ position to exactly one leaf tile. Tiles are split
+// until they hold at most MaxEntitiesPerTile entities or reach MaxZoom.
+func BuildTiles(g *Graph, opts TileOptions) *TileSet {
+	if opts.MaxEntitiesPerTile < 1 {
+		opts.MaxEntitiesPerTile = DefaultTileOptions().MaxEntitiesPerTile
+	}
+	if opts.TreeClusterCells < 1 {
+		opts.TreeClusterCells = DefaultTileOptions().TreeClusterCells
+	}
+
+	bounds := g.Metadata.CityBounds
+	if len(g.Entities) > 0 && bounds == (BoundingBox{}) {
+		bounds = computeBounds(g.Entities)
+	}
+	root := squareRegion(bounds)
+
+	ts := &TileSet{
+		Metadata: TileSetMetadata{
+			SpecVersion: g.Metadata.SpecVersion,
+			GeneratedAt: g.Metadata.GeneratedAt,
+			Root:        root,
+			MaxZoom:     opts.MaxZoom,
+		},
+		index: make(map[string]int),
+	}
+
+	all := make([]int, len(g.Entities))
+	for i := range all {
+		all[i] = i
+	}
+	if len(all) > 0 {
+		ts.buildNode(g.Entities, all, 0, 0, 0, opts)
+	}
+
+	// Breadth-first order: zoom, then x, then y.
+	sort.Slice(ts.Tiles, func(i, j int) bool {
+		a, b := ts.Tiles[i], ts.Tiles[j]
+		if a.Zoom != b.Zoom {
+			return a.Zoom < b.Zoom
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Y < b.Y
+	})
+	for i, t := range ts.Tiles {
+		ts.index[t.ID] = i
+	}
+	ts.Metadata.TileCount = len(ts.Tiles)
+	return ts
+}
+
+// Lookup returns the tile at the given quadtree address.
+func (ts *TileSet) Lookup(z, x, y int) (*Tile, bool) {
+	i, ok := ts.index[TileID(z, x, y)]
+	if !ok {
+		return nil, false
+	}
+	return &ts.Tiles[i], true
+}
+
+// Index returns the tile listing without entity payloads.
+func (ts *TileSet) Index() TileIndex {
+	idx := TileIndex{
+		Metadata: ts.Metadata,
+		Tiles:    make([]TileSummary, 0, len(ts.Tiles)),
+	}
+	for _, t := range ts.Tiles {
+		idx.Tiles = append(idx.Tiles, TileSummary{
+			ID:          t.ID,
+			Zoom:        t.Zoom,
+			X:           t.X,
+			Y:           t.Y,
+			Bounds:      t.Bounds,
+			Leaf:        t.Leaf,
+			Children:    t.Children,
+			EntityCount: t.EntityCount,
+		})
+	}
+	return idx
+}
+
+// Region returns the square XZ region covered by a quadtree address.
+// The Y range is copied from the root.
+func (ts *TileSet) Region(z, x, y int) BoundingBox {
+	return tileRegion(ts.Metadata.Root, z, x, y)
+}
+
+func (ts *TileSet) buildNode(entities []Entity, members []int, z, x, y int, opts TileOptions) BoundingBox {
+	t := Tile{
+		ID:          TileID(z, x, y),
+		Zoom:        z,
+		X:           x,
+		Y:           y,
+		EntityCount: len(members),
+	}
+
+	if len(members) <= opts.MaxEntitiesPerTile || z >= opts.MaxZoom {
+		t.Leaf = true
+		t.Entities = make([]Entity, len(members))
+		for i, m := range members {
+			t.Entities[i] = entities[m]
+		}
+		t.Bounds = computeBounds(t.Entities)
+		ts.Tiles = append(ts.Tiles, t)
+		return t.Bounds
+	}
+
+	// Split into quadrants by entity center.
+	region := tileRegion(ts.Metadata.Root, z, x, y)
+	midX := (region.Min.X + region.Max.X) / 2
+	midZ := (region.Min.Z + region.Max.Z) / 2
+	var quads [4][]int
+	for _, m := range members {
+		e := &entities[m]
+		q := 0
+		if e.Position.X >= midX {
+			q |= 1
+		}
+		if e.Position.Z >= midZ {
+			q |= 2
+		}
+		quads[q] = append(quads[q], m)
+	}
+
+	first := true
+	for q, qm := range quads {
+		if len(qm) == 0 {
+			continue
+		}
+		cx, cy := 2*x+(q&1), 2*y+(q>>1)
+		t.Children = append(t.Children, TileID(z+1, cx, cy))
+		cb := ts.buildNode(entities, qm, z+1, cx, cy, opts)
+		if first {
+			t.Bounds = cb
+			first = false
+		} else {
+			t.Bounds = unionBounds(t.Bounds, cb)
+		}
+	}
+
+	t.Proxies = buildProxies(t.ID, entities, members, region, opts)
+	ts.Tiles = append(ts.Tiles, t)
+	return t.Bounds
+}
+
+// buildProxies creates the coarse LOD representation of an interior tile:
+// one massing box per pod for buildings, one clustered tree per grid cell,
+// and any remaining entity large enough to read at this tile's scale.
+func buildProxies(tileID string, entities []Entity, members []int, region BoundingBox, opts TileOptions) []Entity {
+	size := region.Max.X - region.Min.X
+	minExtent := size / float64(8*opts.TreeClusterCells)
+
+	var proxies []Entity
+
+	// Pod massing: merge each pod's buildings into one box whose height is
+	// the footprint-weighted mean so the proxy volume tracks the real one.
+	podBuildings := make(map[string][]Entity)
+	var podOrder []string
+	for _, m := range members {
+		e := entities[m]
+		if e.Type != EntityBuilding {
+			continue
+		}
+		if _, ok := podBuildings[e.Pod]; !ok {
+			podOrder = append(podOrder, e.Pod)
+		}
+		podBuildings[e.Pod] = append(podBuildings[e.Pod], e)
+	}
+	sort.Strings(podOrder)
+	for _, pod := range podOrder {
+		bs := podBuildings[pod]
+		b := computeBounds(bs)
+		area, volume, maxH := 0.0, 0.0, 0.0
+		for _, e := range bs {
+			a := e.Dimensions.X * e.Dimensions.Z
+			area += a
+			volume += a * e.Dimensions.Y
+			maxH = math.Max(maxH, e.Dimensions.Y)
+		}
+		height := maxH
+		if area > 0 {
+			height = volume / area
+		}
+		name := pod
+		if name == "" {
+			name = "unassigned"
+		}
+		proxies = append(proxies, Entity{
+			ID:   fmt.Sprintf("%s_massing_%s", tileID, name),
+			Type: EntityBuilding,
+			Position: Vec3{
+				X: (b.Min.X + b.Max.X) / 2,
+				Y: b.Min.Y,
+				Z: (b.Min.Z + b.Max.Z) / 2,
+			},
+			Dimensions: Vec3{
+				X: b.Max.X - b.Min.X,
+				Y: height,
+				Z: b.Max.Z - b.Min.Z,
+			},
+			Rotation: identityQuat(),
+			Material: "concrete",
+			Pod:      pod,
+			Layer:    LayerSurface,
+			Metadata: map[string]any{
+				"lod_proxy":    "pod_massing",
+				"source_count": len(bs),
+				"max_height":   maxH,
+			},
+		})
+	}
+
+	// Tree clusters on a TreeClusterCells x TreeClusterCells grid.
+	type cluster struct {
+		sumX, sumZ, sumH, sumC float64
+		minX, maxX, minZ, maxZ float64
+		n                      int
+		pod                    string
+	}
+	cells := opts.TreeClusterCells
+	cellSize := size / float64(cells)
+	clusters := make(map[[2]int]*cluster)
+	for _, m := range members {
+		e := &entities[m]
+		if e.Type != EntityTree {
+			continue
+		}
+		cx := clampCell(int((e.Position.X-region.Min.X)/cellSize), cells)
+		cz := clampCell(int((e.Position.Z-region.Min.Z)/cellSize), cells)
+		c, ok := clusters[[2]int{cx, cz}]
+		if !ok {
+			c = &cluster{
+				minX: math.MaxFloat64, maxX: -math.MaxFloat64,
+				minZ: math.MaxFloat64, maxZ: -math.MaxFloat64,
+				pod: e.Pod,
+			}
+			clusters[[2]int{cx, cz}] = c
+		}
+		c.sumX += e.Position.X
+		c.sumZ += e.Position.Z
+		c.sumH += e.Dimensions.Y
+		c.sumC += e.Dimensions.X
+		c.minX = math.Min(c.minX, e.Position.X)
+		c.maxX = math.Max(c.maxX, e.Position.X)
+		c.minZ = math.Min(c.minZ, e.Position.Z)
+		c.maxZ = math.Max(c.maxZ, e.Position.Z)
+		c.n++
+	}
+	keys := make([][2]int, 0, len(clusters))
+	for k := range clusters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		c := clusters[k]
+		n := float64(c.n)
+		canopy := c.sumC / n
+		proxies = append(proxies, Entity{
+			ID:   fmt.Sprintf("%s_trees_%d_%d", tileID, k[0], k[1]),
+			Type: EntityTree,
+			Position: Vec3{
+				X: c.sumX / n,
+				Y: 0,
+				Z: c.sumZ / n,
+			},
+			Dimensions: Vec3{
+				X: math.Max(canopy, c.maxX-c.minX+canopy),
+				Y: c.sumH / n,
+				Z: math.Max(canopy, c.maxZ-c.minZ+canopy),
+			},
+			Rotation: identityQuat(),
+			Material: "foliage",
+			Pod:      c.pod,
+			Layer:    LayerSurface,
+			Metadata: map[string]any{
+				"lod_proxy":    "tree_cluster",
+				"source_count": c.n,
+			},
+		})
+	}
+
+	// Large features (parks, sports fields, trunk lines) pass through as-is.
+	for _, m := range members {
+		e := entities[m]
+		if e.Type == EntityBuilding || e.Type == EntityTree {
+			continue
+		}
+		if math.Max(e.Dimensions.X, e.Dimensions.Z) >= minExtent {
+			proxies = append(proxies, e)
+		}
+	}
+
+	return proxies
+}
+
+// squareRegion expands an AABB's XZ footprint to the smallest enclosing
+// square sharing its center, so quadtree tiles stay square.
+func squareRegion(b BoundingBox) BoundingBox {
+	cx := (b.Min.X + b.Max.X) / 2
+	cz := (b.Min.Z + b.Max.Z) / 2
+	half := math.Max(b.Max.X-b.Min.X, b.Max.Z-b.Min.Z) / 2
+	if half < 1 {
+		half = 1
+	}
+	half *= 1.0001 // keep entities on the max edge strictly inside
+	return BoundingBox{
+		Min: Vec3{X: cx - half, Y: b.Min.Y, Z: cz - half},
+		Max: Vec3{X: cx + half, Y: b.Max.Y, Z: cz + half},
+	}
+}
+
+// unionBounds returns the smallest AABB enclosing both a and b.
+func unionBounds(a, b BoundingBox) BoundingBox {
+	return BoundingBox{
+		Min: Vec3{X: math.Min(a.Min.X, b.Min.X), Y: math.Min(a.Min.Y, b.Min.Y), Z: math.Min(a.Min.Z, b.Min.Z)},
+		Max: Vec3{X: math.Max(a.Max.X, b.Max.X), Y: math.Max(a.Max.Y, b.Max.Y), Z: math.Max(a.Max.Z, b.Max.Z)},
+	}
+}
+
+func tileRegion(root BoundingBox, z, x, y int) BoundingBox {
+	n := float64(int(1) << uint(z))
+	size := (root.Max.X - root.Min.X) / n
+	return BoundingBox{
+		Min: Vec3{X: root.Min.X + float64(x)*size, Y: root.Min.Y, Z: root.Min.Z + float64(y)*size},
+		Max: Vec3{X: root.Min.X + float64(x+1)*size, Y: root.Max.Y, Z: root.Min.Z + float64(y+1)*size},
+	}
+}
+
+func clampCell(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
@@ -0,0 +1,133 @@
+package scene
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testTileOptions() TileOptions {
+	return TileOptions{MaxZoom: 5, MaxEntitiesPerTile: 500, TreeClusterCells: 4}
+}
+
+func TestBuildTilesAssignsEveryEntityOnce(t *testing.T) {
+	g := assembleTestGraph(t)
+	ts := BuildTiles(g, testTileOptions())
+
+	if ts.Metadata.TileCount != len(ts.Tiles) {
+		t.Errorf("tile_count = %d, want %d", ts.Metadata.TileCount, len(ts.Tiles))
+	}
+
+	seen := make(map[string]int, len(g.Entities))
+	for _, tile := range ts.Tiles {
+		if !tile.Leaf {
+			if len(tile.Entities) != 0 {
+				t.Errorf("interior tile %s carries %d full entities", tile.ID, len(tile.Entities))
+			}
+			continue
+		}
+		if len(tile.Entities) != tile.EntityCount {
+			t.Errorf("leaf %s: %d entities, entity_count %d", tile.ID, len(tile.Entities), tile.EntityCount)
+		}
+		for _, e := range tile.Entities {
+			seen[e.ID]++
+		}
+	}
+
+	for _, e := range g.Entities {
+		if seen[e.ID] != 1 {
+			t.Errorf("entity %s appears in %d leaf tiles, want 1", e.ID, seen[e.ID])
+		}
+	}
+}
+
+func TestBuildTilesSplitsAndProxies(t *testing.T) {
+	g := assembleTestGraph(t)
+	ts := BuildTiles(g, testTileOptions())
+
+	root, ok := ts.Lookup(0, 0, 0)
+	if !ok {
+		t.Fatal("missing root tile")
+	}
+	if root.Leaf {
+		t.Fatalf("root should be split with %d entities", len(g.Entities))
+	}
+	if root.EntityCount != len(g.Entities) {
+		t.Errorf("root entity_count = %d, want %d", root.EntityCount, len(g.Entities))
+	}
+
+	massing, clusters := 0, 0
+	for _, p := range root.Proxies {
+		switch p.Metadata["lod_proxy"] {
+		case "pod_massing":
+			massing++
+		case "tree_cluster":
+			clusters++
+		}
+	}
+	if massing == 0 {
+		t.Error("root has no pod massing proxies")
+	}
+	if clusters == 0 {
+		t.Error("root has no tree cluster proxies")
+	}
+	if len(root.Proxies) >= root.EntityCount {
+		t.Errorf("root proxies (%d) should be coarser than its entities (%d)", len(root.Proxies), root.EntityCount)
+	}
+}
+
+func TestBuildTilesChildBoundsWithinParent(t *testing.T) {
+	g := assembleTestGraph(t)
+	ts := BuildTiles(g, testTileOptions())
+
+	const eps = 1e-6
+	for _, tile := range ts.Tiles {
+		for _, childID := range tile.Children {
+			var child *Tile
+			for i := range ts.Tiles {
+				if ts.Tiles[i].ID == childID {
+					child = &ts.Tiles[i]
+				}
+			}
+			if child == nil {
+				t.Fatalf("tile %s lists missing child %s", tile.ID, childID)
+			}
+			if child.Zoom != tile.Zoom+1 {
+				t.Errorf("child %s zoom %d, want %d", child.ID, child.Zoom, tile.Zoom+1)
+			}
+			pb, cb := tile.Bounds, child.Bounds
+			if cb.Min.X < pb.Min.X-eps || cb.Min.Z < pb.Min.Z-eps || cb.Max.X > pb.Max.X+eps || cb.Max.Z > pb.Max.Z+eps {
+				t.Errorf("child %s bounds escape parent %s", child.ID, tile.ID)
+			}
+		}
+	}
+
+	root, _ := ts.Lookup(0, 0, 0)
+	if root.Bounds != computeBounds(g.Entities) {
+		t.Errorf("root bounds %+v, want %+v", root.Bounds, computeBounds(g.Entities))
+	}
+}
+
+func TestBuildTilesDeterministic(t *testing.T) {
+	g := assembleTestGraph(t)
+	a := BuildTiles(g, testTileOptions())
+	b := BuildTiles(g, testTileOptions())
+
+	if !reflect.DeepEqual(a.Index(), b.Index()) {
+		t.Fatal("tile index differs between identical builds")
+	}
+	for i := range a.Tiles {
+		if !reflect.DeepEqual(a.Tiles[i].Proxies, b.Tiles[i].Proxies) {
+			t.Fatalf("tile %s proxies differ between identical builds", a.Tiles[i].ID)
+		}
+	}
+}
+
+func TestBuildTilesEmptyGraph(t *testing.T) {
+	ts := BuildTiles(NewGraph(), DefaultTileOptions())
+	if len(ts.Tiles) != 0 {
+		t.Errorf("expected no tiles for empty graph, got %d", len(ts.Tiles))
+	}
+	if _, ok := ts.Lookup(0, 0, 0); ok {
+		t.Error("lookup on empty tile set should fail")
+	}
+}
@@ -74,17 +74,18 @@ func validateGroupIndices(g *Graph, r *validation.Report) {
 		}
 	}
 
-	for name, ids := range g.Groups.Pods {
-		checkGroup("pods", name, ids)
+	// Sorted so findings come out in the same order on every run.
+	for _, name := range SortedKeys(g.Groups.Pods) {
+		checkGroup("pods", name, g.Groups.Pods[name])
 	}
-	for name, ids := range g.Groups.Systems {
-		checkGroup("systems", string(name), ids)
+	for _, name := range SortedKeys(g.Groups.Systems) {
+		checkGroup("systems", string(name), g.Groups.Systems[name])
 	}
-	for name, ids := range g.Groups.Layers {
-		checkGroup("layers", string(name), ids)
+	for _, name := range SortedKeys(g.Groups.Layers) {
+		checkGroup("layers", string(name), g.Groups.Layers[name])
 	}
-	for name, ids := range g.Groups.EntityTypes {
-		checkGroup("entity_types", string(name), ids)
+	for _, name := range SortedKeys(g.Groups.EntityTypes) {
+		checkGroup("entity_types", string(name), g.Groups.EntityTypes[name])
 	}
 }
 
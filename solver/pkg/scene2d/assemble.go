@@ -6,6 +6,7 @@ import (
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 	"github.com/ChicagoDave/cityplanner/pkg/geo"
 	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 )
 
@@ -25,11 +26,12 @@ func Assemble2D(
 	sportsFields []layout.SportsField,
 	plazas []layout.Plaza,
 	trees []layout.Tree,
+	m *metrics.Report,
 ) *Scene2D {
 	return &Scene2D{
 		Metadata:     assembleMetadata(s, params),
 		Rings:        assembleRings(s, params),
-		Pods:         assemblePods(s, pods),
+		Pods:         assemblePods(s, pods, m),
 		Paths:        assemblePaths(paths, bikePaths, shuttleRoutes),
 		Stations:     assembleStations(stations),
 		Sports:       assembleSports(sportsFields),
@@ -79,13 +81,12 @@ func assembleRings(s *spec.CitySpec, params *analytics.ResolvedParameters) []Rin
 	return rings
 }
 
-func assemblePods(s *spec.CitySpec, pods []layout.Pod) []Pod2D {
+func assemblePods(s *spec.CitySpec, pods []layout.Pod, m *metrics.Report) []Pod2D {
 	ringRadii := make(map[string][2]float64, len(s.CityZones.Rings))
-	ringStories := make(map[string]int, len(s.CityZones.Rings))
 	for _, ring := range s.CityZones.Rings {
 		ringRadii[ring.Name] = [2]float64{ring.RadiusFrom, ring.RadiusTo}
-		ringStories[ring.Name] = ring.MaxStories
 	}
+	env := s.HeightEnvelope()
 
 	result := make([]Pod2D, 0, len(pods))
 	for _, pod := range pods {
@@ -98,23 +99,32 @@ func assemblePods(s *spec.CitySpec, pods []layout.Pod) []Pod2D {
 
 		zones2d := make([]Zone2D, 0, len(zones))
 		for _, z := range zones {
-			zones2d = append(zones2d, Zone2D{
+			z2 := Zone2D{
 				Type:    string(z.Type),
 				Polygon: polygonToCoords(z.Polygon),
 				AreaHa:  z.AreaHa,
-			})
+			}
+			if z.Type == layout.ZoneResidential || z.Type == layout.ZoneCommercial {
+				z2.BlockCoverage = layout.BlockCoverage(z, layout.SubdivideIntoBlocks(z, pod.CenterPoint()))
+			}
+			zones2d = append(zones2d, z2)
 		}
 
-		result = append(result, Pod2D{
+		boundary := pod.BoundaryPolygon()
+		p := Pod2D{
 			ID:         pod.ID,
 			Ring:       pod.Ring,
 			Center:     pod.Center,
 			Boundary:   pod.Boundary,
 			Population: pod.TargetPopulation,
-			MaxStories: ringStories[pod.Ring],
+			MaxStories: env.PeakStories(boundary.DistanceTo(geo.Origin), boundary.MaxDistanceTo(geo.Origin)),
 			AreaHa:     pod.AreaHa,
 			Zones:      zones2d,
-		})
+		}
+		if m != nil {
+			p.Metrics = m.Pod(pod.ID)
+		}
+		result = append(result, p)
 	}
 	return result
 }
@@ -227,14 +237,20 @@ func assembleBuildingSummary(buildings []layout.Building) BuildingSummary {
 		bs.TotalBuildings++
 		bs.TotalDU += b.DwellingUnits
 
+		// Units and floor space come from every building's floors, so
+		// housing over shops counts toward both.
 		pbs := bs.ByPod[b.PodID]
+		pbs.TotalUnits += b.DwellingUnits
+		if b.UnitMix != nil {
+			pbs.UnitMix = pbs.UnitMix.Add(*b.UnitMix)
+		}
+		pbs.CommercialSqM += b.CommercialSqM
+		pbs.ServiceSqM += b.ServiceSqM
 		switch b.Type {
 		case "residential":
 			pbs.Residential++
-			pbs.TotalUnits += b.DwellingUnits
 		case "commercial":
 			pbs.Commercial++
-			pbs.CommercialSqM += b.CommercialSqM
 		case "civic":
 			pbs.Civic++
 			if b.ServiceType != "" {
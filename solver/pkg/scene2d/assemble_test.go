@@ -5,6 +5,7 @@ import (
 
 	"github.com/ChicagoDave/cityplanner/pkg/analytics"
 	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
 )
 
@@ -72,9 +73,10 @@ func assembleTestScene2D(t *testing.T) *Scene2D {
 	sportsFields, _ := layout.PlaceSportsFields(pods, adjacency, s.CityZones.Rings)
 	plazas, _ := layout.GeneratePlazas(pods, s)
 	trees, _ := layout.PlaceTrees(pods, greenZones, paths, bikePaths, plazas)
+	m, _ := metrics.Measure(s, params, pods, buildings, paths, greenZones, plazas)
 
 	return Assemble2D(s, params, pods, buildings, paths, greenZones,
-		bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees)
+		bikePaths, shuttleRoutes, stations, sportsFields, plazas, trees, m)
 }
 
 func TestAssemble2DProducesScene(t *testing.T) {
@@ -153,6 +155,9 @@ func TestAssemble2DPods(t *testing.T) {
 		if pod.AreaHa == 0 {
 			t.Errorf("pod %s has zero area", pod.ID)
 		}
+		if pod.Metrics == nil || pod.Metrics.FAR == 0 {
+			t.Errorf("pod %s has no measured metrics", pod.ID)
+		}
 	}
 }
 
@@ -263,8 +268,13 @@ func TestAssemble2DBuildingSummary(t *testing.T) {
 	}
 
 	for _, pod := range sc.Pods {
-		if _, ok := sc.Buildings.ByPod[pod.ID]; !ok {
+		pbs, ok := sc.Buildings.ByPod[pod.ID]
+		if !ok {
 			t.Errorf("by_pod missing entry for pod %s", pod.ID)
+			continue
+		}
+		if pbs.UnitMix.Total() != pbs.TotalUnits {
+			t.Errorf("pod %s: unit mix totals %d, want %d units", pod.ID, pbs.UnitMix.Total(), pbs.TotalUnits)
 		}
 	}
 	t.Logf("buildings: %d total, %d dwelling units, %d pods",
@@ -1,5 +1,10 @@
 package scene2d
 
+import (
+	"github.com/ChicagoDave/cityplanner/pkg/layout"
+	"github.com/ChicagoDave/cityplanner/pkg/metrics"
+)
+
 // Scene2D is the complete 2D scene output for an SVG top-down renderer.
 type Scene2D struct {
 	Metadata     Metadata        `json:"metadata"`
@@ -44,6 +49,8 @@ type Pod2D struct {
 	MaxStories int          `json:"max_stories"`
 	AreaHa     float64      `json:"area_ha"`
 	Zones      []Zone2D     `json:"zones"`
+	// Metrics are the pod's measured land-use figures, when measured.
+	Metrics *metrics.Metrics `json:"metrics,omitempty"`
 }
 
 // Zone2D describes a functional zone within a pod.
@@ -51,6 +58,9 @@ type Zone2D struct {
 	Type    string       `json:"type"`
 	Polygon [][2]float64 `json:"polygon"`
 	AreaHa  float64      `json:"area_ha"`
+	// BlockCoverage is the share of a residential or commercial zone's
+	// area its city blocks cover.
+	BlockCoverage float64 `json:"block_coverage,omitempty"`
 }
 
 // PathCollection groups all path types.
@@ -135,12 +145,14 @@ type BuildingSummary struct {
 
 // PodBuildingSum is the building aggregate for one pod.
 type PodBuildingSum struct {
-	Residential   int      `json:"residential"`
-	Commercial    int      `json:"commercial"`
-	Civic         int      `json:"civic"`
-	TotalUnits    int      `json:"total_units"`
-	CommercialSqM float64  `json:"commercial_sqm"`
-	ServiceTypes  []string `json:"service_types,omitempty"`
+	Residential   int            `json:"residential"`
+	Commercial    int            `json:"commercial"`
+	Civic         int            `json:"civic"`
+	TotalUnits    int            `json:"total_units"`
+	UnitMix       layout.UnitMix `json:"unit_mix"`
+	CommercialSqM float64        `json:"commercial_sqm"`
+	ServiceSqM    float64        `json:"service_sqm"`
+	ServiceTypes  []string       `json:"service_types,omitempty"`
 }
 
 // ExternalBand describes the perimeter infrastructure zone.
@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Difference is one value that differs between two snapshot documents.
+// Want or Got is nil when the value exists on only one side.
+type Difference struct {
+	Path string `json:"path"`
+	Want any    `json:"want"`
+	Got  any    `json:"got"`
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: want %s, got %s", d.Path, describe(d.Want), describe(d.Got))
+}
+
+// Compare walks two snapshot documents, as returned by Load or Document,
+// and lists every leaf value that differs under its path, such as
+// "cost.total" or "validation.warnings[3].message". Arrays are compared
+// element by element; extra elements on either side are listed as missing
+// on the other. Differences are in path order.
+func Compare(want, got any) []Difference {
+	var out []Difference
+	compareValue("", want, got, &out)
+	return out
+}
+
+func compareValue(path string, want, got any, out *[]Difference) {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			compareValue(join(path, k), w[k], g[k], out)
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(w) || i < len(g); i++ {
+			var we, ge any
+			if i < len(w) {
+				we = w[i]
+			}
+			if i < len(g) {
+				ge = g[i]
+			}
+			compareValue(fmt.Sprintf("%s[%d]", path, i), we, ge, out)
+		}
+		return
+	}
+	if !equalLeaf(want, got) {
+		if path == "" {
+			path = "."
+		}
+		*out = append(*out, Difference{Path: path, Want: want, Got: got})
+	}
+}
+
+func equalLeaf(a, b any) bool {
+	switch a.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	switch b.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	if an, ok := a.(json.Number); ok {
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		if aerr == nil && berr == nil {
+			return af == bf
+		}
+		return an == bn
+	}
+	return a == b
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// describe prints a value compactly; objects and arrays as JSON, cut short.
+func describe(v any) string {
+	if v == nil {
+		return "<missing>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	s := string(data)
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return strings.ReplaceAll(s, "\n", " ")
+}
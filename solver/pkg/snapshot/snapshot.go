@@ -0,0 +1,268 @@
+// Package snapshot reduces a pipeline result to a small canonical JSON
+// document for golden-output regression tests (ADR-009: same spec, same
+// output). Analytical results are kept verbatim; the scene graph, far too
+// large to store, is kept as entity counts and content digests per pod,
+// system, layer and entity type, so a change can be traced to where it
+// happened without storing the graph itself.
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/ChicagoDave/cityplanner/pkg/analytics"
+	"github.com/ChicagoDave/cityplanner/pkg/cost"
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/scene"
+	"github.com/ChicagoDave/cityplanner/pkg/validation"
+)
+
+// significantDigits is the precision floats are rounded to in canonical
+// output and digests. It absorbs last-bit differences between platforms
+// (fused multiply-add on some architectures) while catching real changes.
+const significantDigits = 9
+
+// Snapshot is the canonical summary of one solve.
+type Snapshot struct {
+	SolverVersion string                        `json:"solver_version"`
+	Parameters    *analytics.ResolvedParameters `json:"parameters"`
+	Cost          *cost.Report                  `json:"cost"`
+	Validation    *validation.Report            `json:"validation"`
+	Scene         *SceneDigest                  `json:"scene,omitempty"`
+	Scene2D       string                        `json:"scene_2d_digest,omitempty"`
+}
+
+// SceneDigest summarizes a scene graph.
+type SceneDigest struct {
+	Entities int               `json:"entities"`
+	Bounds   scene.BoundingBox `json:"bounds"`
+	Digest   string            `json:"digest"`
+
+	Pods    map[string]GroupDigest `json:"pods"`
+	Systems map[string]GroupDigest `json:"systems"`
+	Layers  map[string]GroupDigest `json:"layers"`
+	Types   map[string]GroupDigest `json:"entity_types"`
+}
+
+// GroupDigest counts and hashes the entities of one group.
+type GroupDigest struct {
+	Entities int    `json:"entities"`
+	Digest   string `json:"digest"`
+}
+
+// Options returns the pipeline options a snapshot solve uses: every stage,
+// deterministic timestamps, and lenient validation so a spec with errors
+// is still snapshotted with its errors.
+func Options() pipeline.Options {
+	return pipeline.Options{Deterministic: true, Lenient: true}
+}
+
+// Take builds the snapshot of a pipeline result.
+func Take(r *pipeline.Result) (*Snapshot, error) {
+	s := &Snapshot{
+		SolverVersion: pipeline.Version,
+		Parameters:    r.Params,
+		Cost:          r.Cost,
+		Validation:    r.Report,
+	}
+	if r.Graph != nil {
+		d, err := digestScene(r.Graph)
+		if err != nil {
+			return nil, err
+		}
+		s.Scene = d
+	}
+	if r.Scene2D != nil {
+		s2 := *r.Scene2D
+		s2.Metadata.GeneratedAt = ""
+		data, err := Canonical(&s2)
+		if err != nil {
+			return nil, err
+		}
+		s.Scene2D = digest(data)
+	}
+	return s, nil
+}
+
+// Marshal returns the snapshot's canonical JSON.
+func (s *Snapshot) Marshal() ([]byte, error) {
+	return Canonical(s)
+}
+
+// Load reads a snapshot file as a generic JSON document for Compare.
+func Load(path string) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// Document returns the snapshot as a generic JSON document for Compare.
+func (s *Snapshot) Document() (any, error) {
+	data, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding snapshot: %w", err)
+	}
+	return v, nil
+}
+
+// Canonical encodes v as indented JSON with sorted object keys and floats
+// rounded to a fixed number of significant digits.
+func Canonical(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(roundNumbers(doc)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func roundNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = roundNumbers(e)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = roundNumbers(e)
+		}
+		return v
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return v
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		return json.Number(roundFloat(f))
+	default:
+		return v
+	}
+}
+
+func roundFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', significantDigits, 64)
+	// Re-format the rounded value the way encoding/json would, so
+	// 1.50000000 and 1.5 print the same and large values stay readable.
+	r, _ := strconv.ParseFloat(s, 64)
+	if a := math.Abs(r); a != 0 && (a < 1e-6 || a >= 1e21) {
+		return strconv.FormatFloat(r, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// digestScene hashes every entity's canonical line into the whole-graph
+// digest and the digests of the groups it belongs to. Group digests follow
+// the group's own ID order.
+func digestScene(g *scene.Graph) (*SceneDigest, error) {
+	lines := make(map[string][]byte, len(g.Entities))
+	whole := sha256.New()
+	for _, e := range g.Entities {
+		line, err := entityLine(e)
+		if err != nil {
+			return nil, err
+		}
+		lines[e.ID] = line
+		whole.Write(line)
+	}
+	return &SceneDigest{
+		Entities: len(g.Entities),
+		Bounds:   g.Metadata.CityBounds,
+		Digest:   "sha256:" + hex.EncodeToString(whole.Sum(nil)),
+		Pods:     digestGroups(g.Groups.Pods, lines),
+		Systems:  digestGroups(g.Groups.Systems, lines),
+		Layers:   digestGroups(g.Groups.Layers, lines),
+		Types:    digestGroups(g.Groups.EntityTypes, lines),
+	}, nil
+}
+
+func digestGroups[K ~string](groups map[K][]string, lines map[string][]byte) map[string]GroupDigest {
+	out := make(map[string]GroupDigest, len(groups))
+	for k, ids := range groups {
+		var h hash.Hash = sha256.New()
+		for _, id := range ids {
+			h.Write([]byte(id))
+			h.Write(lines[id])
+		}
+		out[string(k)] = GroupDigest{Entities: len(ids), Digest: "sha256:" + hex.EncodeToString(h.Sum(nil))}
+	}
+	return out
+}
+
+// entityLine is an entity's canonical one-line encoding. It is written
+// directly rather than through Canonical: a city has hundreds of thousands
+// of entities.
+func entityLine(e scene.Entity) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(e.ID)
+	b.WriteByte('|')
+	b.WriteString(string(e.Type))
+	for _, f := range []float64{
+		e.Position.X, e.Position.Y, e.Position.Z,
+		e.Dimensions.X, e.Dimensions.Y, e.Dimensions.Z,
+		e.Rotation[0], e.Rotation[1], e.Rotation[2], e.Rotation[3],
+	} {
+		b.WriteByte('|')
+		b.WriteString(roundFloat(f))
+	}
+	for _, s := range []string{e.Material, string(e.System), e.Pod, string(e.Layer)} {
+		b.WriteByte('|')
+		b.WriteString(s)
+	}
+	for _, k := range scene.SortedKeys(e.Metadata) {
+		b.WriteByte('|')
+		b.WriteString(k)
+		b.WriteByte('=')
+		switch v := e.Metadata[k].(type) {
+		case float64:
+			b.WriteString(roundFloat(v))
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("entity %s metadata %s: %w", e.ID, k, err)
+			}
+			b.Write(data)
+		}
+	}
+	for _, c := range e.Children {
+		b.WriteString("|>")
+		b.WriteString(c)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChicagoDave/cityplanner/pkg/pipeline"
+	"github.com/ChicagoDave/cityplanner/pkg/spec"
+)
+
+var update = flag.Bool("update", false, "rewrite golden snapshots in testdata")
+
+const examplesDir = "../../../examples"
+
+// TestGolden solves every example project and compares its snapshot with
+// testdata/<project>.json. After an intended output change, regenerate
+// the files with: go test ./pkg/snapshot -run TestGolden -update
+func TestGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("solves every example project")
+	}
+	entries, err := os.ReadDir(examplesDir)
+	if err != nil {
+		t.Fatalf("reading examples: %v", err)
+	}
+	for _, e := range entries {
+		dir := filepath.Join(examplesDir, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, "city.yaml")); err != nil {
+			continue
+		}
+		t.Run(e.Name(), func(t *testing.T) {
+			s, err := spec.LoadProject(dir)
+			if err != nil {
+				t.Fatalf("LoadProject: %v", err)
+			}
+			res, err := pipeline.Run(s, Options())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			snap, err := Take(res)
+			if err != nil {
+				t.Fatalf("Take: %v", err)
+			}
+			data, err := snap.Marshal()
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			golden := filepath.Join("testdata", e.Name()+".json")
+			got, err := snap.Document()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Load(golden)
+			if *update {
+				// Output that changes under the same solver version would
+				// leave projects serving cached artifacts of the old one.
+				if err == nil && outputChanged(want, got) && goldenVersion(want) == pipeline.Version {
+					t.Fatalf("solver output changed but pipeline.Version is still %s; bump it before updating", pipeline.Version)
+				}
+				if err := os.WriteFile(golden, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if outputChanged(want, got) && goldenVersion(want) == pipeline.Version {
+				t.Errorf("solver output changed but pipeline.Version is still %s; bump it", pipeline.Version)
+			}
+			diffs := Compare(want, got)
+			for i, d := range diffs {
+				if i == 50 {
+					t.Errorf("... and %d more", len(diffs)-i)
+					break
+				}
+				t.Error(d)
+			}
+			if len(diffs) > 0 {
+				t.Log("if the change is intended, run: go test ./pkg/snapshot -run TestGolden -update")
+			}
+		})
+	}
+}
+
+// goldenVersion returns the solver version a golden snapshot was taken with.
+func goldenVersion(doc any) string {
+	m, _ := doc.(map[string]any)
+	v, _ := m["solver_version"].(string)
+	return v
+}
+
+// outputChanged reports whether two snapshots differ other than in their
+// solver version.
+func outputChanged(want, got any) bool {
+	for _, d := range Compare(want, got) {
+		if d.Path != "solver_version" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCanonicalRoundsFloats(t *testing.T) {
+	a, err := Canonical(map[string]any{"b": 0.1 + 0.2, "a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Canonical(map[string]any{"a": 3, "b": 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("canonical forms differ:\n%s\n%s", a, b)
+	}
+	if want := "{\n  \"a\": 3,\n  \"b\": 0.3\n}\n"; string(a) != want {
+		t.Errorf("Canonical = %q, want %q", a, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	doc := func(s string) any {
+		v, err := decode([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	want := doc(`{"cost":{"total":10,"per_capita":1.5},"warnings":[{"code":"a"},{"code":"b"}],"v":"0.1.0"}`)
+	got := doc(`{"cost":{"total":12,"per_capita":1.50},"warnings":[{"code":"a"}],"v":"0.1.0","extra":true}`)
+
+	diffs := Compare(want, got)
+	paths := []string{}
+	for _, d := range diffs {
+		paths = append(paths, d.Path)
+	}
+	wantPaths := []string{"cost.total", "extra", "warnings[1]"}
+	if len(paths) != len(wantPaths) {
+		t.Fatalf("paths = %v, want %v", paths, wantPaths)
+	}
+	for i := range paths {
+		if paths[i] != wantPaths[i] {
+			t.Errorf("paths = %v, want %v", paths, wantPaths)
+			break
+		}
+	}
+	if diffs[0].Want != json.Number("10") || diffs[0].Got != json.Number("12") {
+		t.Errorf("cost.total = %v -> %v, want 10 -> 12", diffs[0].Want, diffs[0].Got)
+	}
+	if diffs[2].Got != nil {
+		t.Errorf("warnings[1] got = %v, want missing", diffs[2].Got)
+	}
+	if Compare(want, want) != nil {
+		t.Error("Compare of a document with itself reported differences")
+	}
+}
@@ -0,0 +1,84 @@
+package spec
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff returns the dotted YAML paths at which a and b differ, such as
+// "city_zones.rings.2.max_stories" or "pods.ring_assignments.edge". A list
+// whose length changed is reported at the list's own path. The result is
+// sorted; nil means the specs are equal.
+func Diff(a, b *CitySpec) []string {
+	var paths []string
+	diffValue(reflect.ValueOf(*a), reflect.ValueOf(*b), "", &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+// PathAffects reports whether a change at path can affect a read of
+// pattern. Both are dotted paths; "*" in pattern matches any one segment.
+// A change affects a read when either path is a prefix of the other, so a
+// change to "city_zones.rings" affects "city_zones.rings.*.radius_to" and a
+// change to "city.population" affects "city".
+func PathAffects(path, pattern string) bool {
+	ps := strings.Split(path, ".")
+	qs := strings.Split(pattern, ".")
+	for i := 0; i < len(ps) && i < len(qs); i++ {
+		if qs[i] != "*" && qs[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func diffValue(a, b reflect.Value, path string, out *[]string) {
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				name = strings.ToLower(t.Field(i).Name)
+			}
+			diffValue(a.Field(i), b.Field(i), join(path, name), out)
+		}
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			*out = append(*out, path)
+			return
+		}
+		for i := 0; i < a.Len(); i++ {
+			diffValue(a.Index(i), b.Index(i), join(path, fmt.Sprint(i)), out)
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, k := range a.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for _, k := range b.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for name, k := range keys {
+			av, bv := a.MapIndex(k), b.MapIndex(k)
+			if !av.IsValid() || !bv.IsValid() {
+				*out = append(*out, join(path, name))
+				continue
+			}
+			diffValue(av, bv, join(path, name), out)
+		}
+	default:
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*out = append(*out, path)
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,52 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := LoadProject("../../../examples/default-city")
+	if err != nil {
+		t.Fatalf("LoadProject failed: %v", err)
+	}
+	b, _ := LoadProject("../../../examples/default-city")
+
+	if d := Diff(a, b); d != nil {
+		t.Fatalf("identical specs differ at %v", d)
+	}
+
+	b.City.Population++
+	b.CityZones.Rings[1].MaxStories++
+	delete(b.Pods.RingAssignments, b.CityZones.Rings[0].Name)
+	b.CityZones.Perimeter.Contents = append(b.CityZones.Perimeter.Contents, "extra")
+
+	want := []string{
+		"city.population",
+		"city_zones.perimeter_infrastructure.contents",
+		"city_zones.rings.1.max_stories",
+		"pods.ring_assignments." + b.CityZones.Rings[0].Name,
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+}
+
+func TestPathAffects(t *testing.T) {
+	cases := []struct {
+		path, pattern string
+		want          bool
+	}{
+		{"city_zones.rings.2.max_stories", "city_zones.rings.*.max_stories", true},
+		{"city_zones.rings.2.max_stories", "city_zones.rings.*.radius_to", false},
+		{"city_zones.rings", "city_zones.rings.*.radius_to", true},
+		{"city.population", "city", true},
+		{"city.population", "*", true},
+		{"vehicles.total_fleet", "city", false},
+	}
+	for _, c := range cases {
+		if got := PathAffects(c.path, c.pattern); got != c.want {
+			t.Errorf("PathAffects(%q, %q) = %v, want %v", c.path, c.pattern, got, c.want)
+		}
+	}
+}
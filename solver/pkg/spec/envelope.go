@@ -0,0 +1,193 @@
+package spec
+
+import (
+	"math"
+	"sort"
+)
+
+// Height profiles for CityDef.HeightProfile.
+const (
+	ProfileStep   = "step"   // each ring's max_stories
+	ProfileBowl   = "bowl"   // linear from max_height_center to max_height_edge
+	ProfileCosine = "cosine" // cosine ease from max_height_center to max_height_edge
+	ProfileCustom = "custom" // linear through height_control_points
+
+	// ProfileFlat is the pre-0.2 name for a profile without a slope from
+	// center to edge. It is deprecated and read as a step.
+	ProfileFlat = "flat"
+)
+
+// envelopeSamples is how many radii MeanStories and PeakStories sample.
+const envelopeSamples = 64
+
+// HeightPoint is a control point of a custom height profile.
+type HeightPoint struct {
+	Radius  float64 `yaml:"radius" json:"radius"` // meters from the city center
+	Stories float64 `yaml:"stories" json:"stories"`
+}
+
+// HeightEnvelope is the maximum building height by distance from the city
+// center.
+type HeightEnvelope struct {
+	Profile string
+	// Rings give the step profile's heights.
+	Rings []RingDef
+	// Points are the other profiles' control points, by ascending radius.
+	// Heights are held beyond the first and last.
+	Points []HeightPoint
+}
+
+// HeightEnvelope returns the spec's height envelope. Profiles other than
+// step run from the center to the outermost ring's edge; a missing
+// max_height_center or max_height_edge is taken from the innermost or
+// outermost ring. An empty profile is a bowl, the schema's default; flat
+// and unknown profiles are a step.
+func (s *CitySpec) HeightEnvelope() HeightEnvelope {
+	rings := s.CityZones.Rings
+	switch p := s.City.HeightProfile; p {
+	case "", ProfileBowl, ProfileCosine:
+		if p == "" {
+			p = ProfileBowl
+		}
+		center, edge := s.City.MaxHeightCenter, s.City.MaxHeightEdge
+		if len(rings) > 0 {
+			if center <= 0 {
+				center = rings[0].MaxStories
+			}
+			if edge <= 0 {
+				edge = rings[len(rings)-1].MaxStories
+			}
+		}
+		return HeightEnvelope{Profile: p, Points: []HeightPoint{
+			{Radius: 0, Stories: float64(center)},
+			{Radius: s.CityZones.OuterRadius(), Stories: float64(edge)},
+		}}
+	case ProfileCustom:
+		pts := append([]HeightPoint(nil), s.City.HeightControlPoints...)
+		sort.SliceStable(pts, func(i, j int) bool { return pts[i].Radius < pts[j].Radius })
+		return HeightEnvelope{Profile: p, Points: pts}
+	}
+	return HeightEnvelope{Profile: ProfileStep, Rings: rings}
+}
+
+// MaxStories returns the maximum stories at a distance from the center,
+// at least one.
+func (e HeightEnvelope) MaxStories(dist float64) int {
+	var h float64
+	if e.Profile == ProfileStep || e.Profile == "" {
+		h = e.stepStories(dist)
+	} else {
+		h = e.curveStories(dist)
+	}
+	return int(math.Max(1, math.Floor(h+1e-9)))
+}
+
+// stepStories returns the containing ring's max_stories, interpolating
+// linearly across gaps between rings and holding the outermost ring's
+// value beyond them.
+func (e HeightEnvelope) stepStories(dist float64) float64 {
+	rings := e.Rings
+	if len(rings) == 0 {
+		return 1
+	}
+	for _, ring := range rings {
+		if dist >= ring.RadiusFrom && dist <= ring.RadiusTo {
+			return float64(ring.MaxStories)
+		}
+	}
+	for i := 0; i < len(rings)-1; i++ {
+		if dist > rings[i].RadiusTo && dist < rings[i+1].RadiusFrom {
+			t := (dist - rings[i].RadiusTo) / (rings[i+1].RadiusFrom - rings[i].RadiusTo)
+			return float64(rings[i].MaxStories) + t*float64(rings[i+1].MaxStories-rings[i].MaxStories)
+		}
+	}
+	return float64(rings[len(rings)-1].MaxStories)
+}
+
+// curveStories interpolates between the control points, linearly or, for
+// the cosine profile, with a cosine ease.
+func (e HeightEnvelope) curveStories(dist float64) float64 {
+	pts := e.Points
+	switch {
+	case len(pts) == 0:
+		return 1
+	case dist <= pts[0].Radius:
+		return pts[0].Stories
+	case dist >= pts[len(pts)-1].Radius:
+		return pts[len(pts)-1].Stories
+	}
+	i := sort.Search(len(pts), func(i int) bool { return pts[i].Radius > dist }) - 1
+	a, b := pts[i], pts[i+1]
+	t := (dist - a.Radius) / (b.Radius - a.Radius)
+	if e.Profile == ProfileCosine {
+		t = (1 - math.Cos(math.Pi*t)) / 2
+	}
+	return a.Stories + t*(b.Stories-a.Stories)
+}
+
+// MeanStories returns the area-weighted mean of MaxStories over the annulus
+// from r0 to r1.
+func (e HeightEnvelope) MeanStories(r0, r1 float64) float64 {
+	if r1 <= r0 {
+		return float64(e.MaxStories(r0))
+	}
+	var sum, weight float64
+	step := (r1 - r0) / envelopeSamples
+	for i := 0; i < envelopeSamples; i++ {
+		r := r0 + (float64(i)+0.5)*step
+		sum += float64(e.MaxStories(r)) * r
+		weight += r
+	}
+	return sum / weight
+}
+
+// PeakStories returns the largest MaxStories over the annulus from r0 to r1.
+// Like MinStories, it leaves out a neighbouring ring that only touches the
+// annulus at r0 or r1.
+func (e HeightEnvelope) PeakStories(r0, r1 float64) int {
+	r0, r1 = inside(r0, r1)
+	peak := max(e.MaxStories(r0), e.MaxStories(r1))
+	step := (r1 - r0) / envelopeSamples
+	for i := 1; i < envelopeSamples; i++ {
+		peak = max(peak, e.MaxStories(r0+float64(i)*step))
+	}
+	for _, p := range e.Points {
+		if p.Radius > r0 && p.Radius < r1 {
+			peak = max(peak, e.MaxStories(p.Radius))
+		}
+	}
+	return peak
+}
+
+// MinStories returns the smallest MaxStories over the annulus from r0 to r1.
+func (e HeightEnvelope) MinStories(r0, r1 float64) int {
+	r0, r1 = inside(r0, r1)
+	low := min(e.MaxStories(r0), e.MaxStories(r1))
+	step := (r1 - r0) / envelopeSamples
+	for i := 1; i < envelopeSamples; i++ {
+		low = min(low, e.MaxStories(r0+float64(i)*step))
+	}
+	for _, p := range e.Points {
+		if p.Radius > r0 && p.Radius < r1 {
+			low = min(low, e.MaxStories(p.Radius))
+		}
+	}
+	for i := 0; i+1 < len(e.Rings); i++ {
+		// Step heights change at ring edges.
+		for _, r := range []float64{e.Rings[i].RadiusTo, e.Rings[i+1].RadiusFrom} {
+			if r > r0 && r < r1 {
+				low = min(low, e.MaxStories(math.Nextafter(r, r1)))
+			}
+		}
+	}
+	return low
+}
+
+// inside moves r0 and r1 just inside the annulus between them, off any ring
+// edge they lie on.
+func inside(r0, r1 float64) (float64, float64) {
+	if r1 <= r0 {
+		return r0, r1
+	}
+	return math.Nextafter(r0, r1), math.Nextafter(r1, r0)
+}
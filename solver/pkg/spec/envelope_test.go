@@ -0,0 +1,119 @@
+package spec
+
+import (
+	"math"
+	"testing"
+)
+
+func envelopeSpec(profile string) *CitySpec {
+	return &CitySpec{
+		City: CityDef{HeightProfile: profile, MaxHeightCenter: 20, MaxHeightEdge: 4},
+		CityZones: CityZones{Rings: []RingDef{
+			{Name: "center", RadiusFrom: 0, RadiusTo: 300, MaxStories: 20},
+			{Name: "middle", RadiusFrom: 300, RadiusTo: 600, MaxStories: 10},
+			{Name: "edge", RadiusFrom: 600, RadiusTo: 900, MaxStories: 4},
+		}},
+	}
+}
+
+func TestHeightEnvelopeStep(t *testing.T) {
+	// With contiguous rings, each ring returns its flat max_stories value.
+	e := envelopeSpec(ProfileStep).HeightEnvelope()
+	tests := []struct {
+		dist     float64
+		expected int
+	}{
+		{0, 20},
+		{150, 20},
+		{300, 20},
+		{450, 10},
+		{600, 10},
+		{750, 4},
+		{900, 4},
+		{1200, 4},
+	}
+	for _, tt := range tests {
+		if got := e.MaxStories(tt.dist); got != tt.expected {
+			t.Errorf("MaxStories(%.0f) = %d, want %d", tt.dist, got, tt.expected)
+		}
+	}
+	if got := e.MinStories(250, 320); got != 10 {
+		t.Errorf("MinStories across the center ring's edge = %d, want 10", got)
+	}
+	if got := e.MeanStories(300, 600); got != 10 {
+		t.Errorf("MeanStories over the middle ring = %f, want 10", got)
+	}
+}
+
+func TestHeightEnvelopeDefaultsToBowl(t *testing.T) {
+	if p := envelopeSpec("").HeightEnvelope().Profile; p != ProfileBowl {
+		t.Errorf("profile without height_profile = %q, want bowl", p)
+	}
+	if p := envelopeSpec(ProfileFlat).HeightEnvelope().Profile; p != ProfileStep {
+		t.Errorf("flat profile = %q, want step", p)
+	}
+}
+
+func TestHeightEnvelopeGapInterpolates(t *testing.T) {
+	s := envelopeSpec(ProfileStep)
+	s.CityZones.Rings[1].RadiusFrom = 400 // gap from 300 to 400
+	if got := s.HeightEnvelope().MaxStories(350); got != 15 {
+		t.Errorf("MaxStories in the gap = %d, want 15", got)
+	}
+}
+
+func TestHeightEnvelopeBowls(t *testing.T) {
+	tests := []struct {
+		profile string
+		dist    float64
+		want    int
+	}{
+		{ProfileBowl, 0, 20},
+		{ProfileBowl, 450, 12},
+		{ProfileBowl, 900, 4},
+		{ProfileBowl, 1000, 4},
+		{ProfileCosine, 0, 20},
+		{ProfileCosine, 225, 17}, // 4 + 16·(1+cos(π/4))/2 = 17.66
+		{ProfileCosine, 450, 12},
+		{ProfileCosine, 675, 6}, // 4 + 16·(1-cos(π/4))/2 = 6.34
+		{ProfileCosine, 900, 4},
+	}
+	for _, tt := range tests {
+		if got := envelopeSpec(tt.profile).HeightEnvelope().MaxStories(tt.dist); got != tt.want {
+			t.Errorf("%s MaxStories(%.0f) = %d, want %d", tt.profile, tt.dist, got, tt.want)
+		}
+	}
+
+	// The edge height defaults to the outermost ring's.
+	s := envelopeSpec(ProfileBowl)
+	s.City.MaxHeightEdge = 0
+	s.CityZones.Rings[2].MaxStories = 2
+	if got := s.HeightEnvelope().MaxStories(900); got != 2 {
+		t.Errorf("MaxStories at the edge = %d, want the outer ring's 2", got)
+	}
+}
+
+func TestHeightEnvelopeCustom(t *testing.T) {
+	s := envelopeSpec(ProfileCustom)
+	s.City.HeightControlPoints = []HeightPoint{{Radius: 800, Stories: 3}, {Radius: 0, Stories: 8}, {Radius: 400, Stories: 24}}
+	e := s.HeightEnvelope()
+	for _, tt := range []struct {
+		dist float64
+		want int
+	}{{0, 8}, {200, 16}, {400, 24}, {600, 13}, {800, 3}, {2000, 3}} {
+		if got := e.MaxStories(tt.dist); got != tt.want {
+			t.Errorf("custom MaxStories(%.0f) = %d, want %d", tt.dist, got, tt.want)
+		}
+	}
+	if got := e.PeakStories(0, 800); got != 24 {
+		t.Errorf("PeakStories = %d, want 24", got)
+	}
+	if got := e.MinStories(100, 700); got != 8 {
+		t.Errorf("MinStories = %d, want 8", got)
+	}
+	// Heights fall outward from 400 m, so the annulus mean lies between
+	// its end heights.
+	if got := e.MeanStories(400, 800); got <= 3 || got >= 24 || math.IsNaN(got) {
+		t.Errorf("MeanStories(400, 800) = %f", got)
+	}
+}
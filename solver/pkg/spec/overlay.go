@@ -0,0 +1,74 @@
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadWithOverlay reads the spec at basePath and applies the partial spec
+// at overlayPath on top of it. The overlay uses merge-patch semantics:
+// mappings merge key by key, any other value (including a list) replaces
+// the base value, and null removes a key. Only the values a variant
+// changes need to appear in the overlay:
+//
+//	city:
+//	  population: 90000
+//	city_zones:
+//	  rings: [...]
+func LoadWithOverlay(basePath, overlayPath string) (*CitySpec, error) {
+	baseData, err := os.ReadFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading spec file: %w", err)
+	}
+	overlayData, err := os.ReadFile(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading overlay: %w", err)
+	}
+	return ApplyOverlay(baseData, overlayData)
+}
+
+// ApplyOverlay merges the YAML overlay onto the YAML base spec and decodes
+// the result. Fields in the overlay that are not part of the spec are an
+// error, so a misspelt key does not silently leave the base value in place.
+func ApplyOverlay(base, overlay []byte) (*CitySpec, error) {
+	var b, o any
+	if err := yaml.Unmarshal(base, &b); err != nil {
+		return nil, fmt.Errorf("parsing spec YAML: %w", err)
+	}
+	if err := yaml.Unmarshal(overlay, &o); err != nil {
+		return nil, fmt.Errorf("parsing overlay YAML: %w", err)
+	}
+	if o == nil {
+		o = map[string]any{}
+	}
+	if _, ok := o.(map[string]any); !ok {
+		return nil, fmt.Errorf("overlay must be a mapping")
+	}
+	if err := decodeStrict(overlay, &CitySpec{}); err != nil {
+		return nil, fmt.Errorf("overlay: %w", err)
+	}
+
+	merged, err := yaml.Marshal(mergeValue(b, o))
+	if err != nil {
+		return nil, fmt.Errorf("encoding merged spec: %w", err)
+	}
+	var out CitySpec
+	if err := yaml.Unmarshal(merged, &out); err != nil {
+		return nil, fmt.Errorf("parsing merged spec: %w", err)
+	}
+	return &out, nil
+}
+
+func decodeStrict(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package spec
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestApplyOverlay(t *testing.T) {
+	base, err := os.ReadFile("../../../examples/default-city/city.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseSpec, err := Load("../../../examples/default-city/city.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ApplyOverlay(base, []byte("city:\n  population: 90000\npods:\n  walk_radius: 350\n"))
+	if err != nil {
+		t.Fatalf("ApplyOverlay failed: %v", err)
+	}
+	want := []string{"city.population", "pods.walk_radius"}
+	if got := Diff(baseSpec, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+
+	// Lists replace rather than merge.
+	s, err = ApplyOverlay(base, []byte("city_zones:\n  perimeter_infrastructure:\n    contents: [solar]\n"))
+	if err != nil {
+		t.Fatalf("ApplyOverlay failed: %v", err)
+	}
+	if got := s.CityZones.Perimeter.Contents; !reflect.DeepEqual(got, []string{"solar"}) {
+		t.Errorf("contents = %v, want [solar]", got)
+	}
+
+	if _, err := ApplyOverlay(base, []byte("city:\n  populaton: 1\n")); err == nil {
+		t.Error("expected an error for an unknown overlay field")
+	}
+	if _, err := ApplyOverlay(base, []byte("- 1\n")); err == nil {
+		t.Error("expected an error for a non-mapping overlay")
+	}
+}
@@ -0,0 +1,343 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to s and returns the
+// patched spec; s is not modified. Keys are the spec's field names, so
+// {"city":{"population":80000}} sets the population. Unknown fields are an
+// error rather than silently dropped.
+func MergePatch(s *CitySpec, patch []byte) (*CitySpec, error) {
+	doc, err := toDocument(s)
+	if err != nil {
+		return nil, err
+	}
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("parsing merge patch: %w", err)
+	}
+	return fromDocument(mergeValue(doc, p))
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to s and returns the patched
+// spec; s is not modified. Paths are JSON Pointers (RFC 6901) over the
+// spec's field names, such as "/city_zones/rings/0/radius_to". The patch
+// is applied atomically: if any operation fails, no change is made.
+func JSONPatch(s *CitySpec, patch []byte) (*CitySpec, error) {
+	doc, err := toDocument(s)
+	if err != nil {
+		return nil, err
+	}
+	var ops []patchOp
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("parsing JSON patch: %w", err)
+	}
+	for i, op := range ops {
+		doc, err = op.apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return fromDocument(doc)
+}
+
+func toDocument(s *CitySpec) (any, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("encoding spec: %w", err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decoding spec: %w", err)
+	}
+	return doc, nil
+}
+
+func fromDocument(doc any) (*CitySpec, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding patched spec: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var out CitySpec
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("patched spec: %w", err)
+	}
+	return &out, nil
+}
+
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergeValue(t[k], v)
+	}
+	return t
+}
+
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (op patchOp) value() (any, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("missing value")
+	}
+	var v any
+	err := json.Unmarshal(op.Value, &v)
+	return v, err
+}
+
+func (op patchOp) apply(doc any) (any, error) {
+	switch op.Op {
+	case "add":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+	case "replace":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		doc, _, err = pointerRemove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into itself", op.From)
+		}
+		doc, v, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "copy":
+		v, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err = deepCopy(v)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+	case "test":
+		want, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, fmt.Errorf("test failed: value is %v", got)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer splits a JSON Pointer into its unescaped tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc any, ptr string) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for _, t := range tokens {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("no member %q", t)
+			}
+			cur = v
+		case []any:
+			i, err := arrayIndex(t, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", cur, t)
+		}
+	}
+	return cur, nil
+}
+
+// pointerAdd adds v at ptr and returns the updated document. Arrays are
+// rebuilt rather than mutated so the caller's slices are never aliased.
+func pointerAdd(doc any, ptr string, v any) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	return addAt(doc, tokens, v)
+}
+
+func addAt(cur any, tokens []string, v any) (any, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	t, rest := tokens[0], tokens[1:]
+	switch c := cur.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			c[t] = v
+			return c, nil
+		}
+		child, ok := c[t]
+		if !ok {
+			return nil, fmt.Errorf("no member %q", t)
+		}
+		child, err := addAt(child, rest, v)
+		if err != nil {
+			return nil, err
+		}
+		c[t] = child
+		return c, nil
+	case []any:
+		if len(rest) == 0 {
+			i := len(c)
+			if t != "-" {
+				var err error
+				if i, err = arrayIndex(t, len(c)); err != nil {
+					return nil, err
+				}
+			}
+			out := make([]any, 0, len(c)+1)
+			out = append(out, c[:i]...)
+			out = append(out, v)
+			return append(out, c[i:]...), nil
+		}
+		i, err := arrayIndex(t, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		child, err := addAt(c[i], rest, v)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	default:
+		return nil, fmt.Errorf("cannot index %T with %q", cur, t)
+	}
+}
+
+// pointerRemove removes the value at ptr, returning the updated document
+// and the removed value.
+func pointerRemove(doc any, ptr string) (any, any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole spec")
+	}
+	return removeAt(doc, tokens)
+}
+
+func removeAt(cur any, tokens []string) (any, any, error) {
+	t, rest := tokens[0], tokens[1:]
+	switch c := cur.(type) {
+	case map[string]any:
+		child, ok := c[t]
+		if !ok {
+			return nil, nil, fmt.Errorf("no member %q", t)
+		}
+		if len(rest) == 0 {
+			delete(c, t)
+			return c, child, nil
+		}
+		child, removed, err := removeAt(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[t] = child
+		return c, removed, nil
+	case []any:
+		i, err := arrayIndex(t, len(c)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			out := make([]any, 0, len(c)-1)
+			out = append(out, c[:i]...)
+			return append(out, c[i+1:]...), c[i], nil
+		}
+		child, removed, err := removeAt(c[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[i] = child
+		return c, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("cannot index %T with %q", cur, t)
+	}
+}
+
+// arrayIndex parses an array index token no greater than max.
+func arrayIndex(t string, max int) (int, error) {
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || (len(t) > 1 && t[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func deepCopy(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
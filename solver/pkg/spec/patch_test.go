@@ -0,0 +1,73 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	s, err := LoadProject("../../../examples/default-city")
+	if err != nil {
+		t.Fatalf("LoadProject failed: %v", err)
+	}
+	p, err := MergePatch(s, []byte(`{"city":{"population":80000},"pods":{"walk_radius":350}}`))
+	if err != nil {
+		t.Fatalf("MergePatch failed: %v", err)
+	}
+	want := []string{"city.population", "pods.walk_radius"}
+	if got := Diff(s, p); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff = %v, want %v", got, want)
+	}
+	if s.City.Population != 64000 {
+		t.Error("MergePatch modified its input")
+	}
+
+	if _, err := MergePatch(s, []byte(`{"city":{"populaton":1}}`)); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	s, err := LoadProject("../../../examples/default-city")
+	if err != nil {
+		t.Fatalf("LoadProject failed: %v", err)
+	}
+	p, err := JSONPatch(s, []byte(`[
+		{"op":"test","path":"/city_zones/rings/0/name","value":"center"},
+		{"op":"replace","path":"/city_zones/rings/0/radius_to","value":260},
+		{"op":"replace","path":"/city_zones/rings/1/radius_from","value":260},
+		{"op":"add","path":"/city_zones/perimeter_infrastructure/contents/-","value":"data_center"},
+		{"op":"remove","path":"/city_zones/perimeter_infrastructure/contents/0"}
+	]`))
+	if err != nil {
+		t.Fatalf("JSONPatch failed: %v", err)
+	}
+	if p.CityZones.Rings[0].RadiusTo != 260 || p.CityZones.Rings[1].RadiusFrom != 260 {
+		t.Errorf("ring radii = %v/%v, want 260/260", p.CityZones.Rings[0].RadiusTo, p.CityZones.Rings[1].RadiusFrom)
+	}
+	contents := p.CityZones.Perimeter.Contents
+	if contents[0] != s.CityZones.Perimeter.Contents[1] || contents[len(contents)-1] != "data_center" {
+		t.Errorf("contents = %v", contents)
+	}
+
+	for _, bad := range []string{
+		`[{"op":"test","path":"/city/population","value":1}]`,
+		`[{"op":"replace","path":"/city/no_such_field","value":1}]`,
+		`[{"op":"remove","path":"/city_zones/rings/9"}]`,
+		`[{"op":"frobnicate","path":"/city"}]`,
+	} {
+		if _, err := JSONPatch(s, []byte(bad)); err == nil {
+			t.Errorf("JSONPatch(%s) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestParsePointerEscapes(t *testing.T) {
+	got, err := parsePointer("/a~1b/c~0d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a/b", "c~d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePointer = %v, want %v", got, want)
+	}
+}
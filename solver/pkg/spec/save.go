@@ -0,0 +1,315 @@
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Save writes s to the YAML file at path. An existing file is edited in
+// place rather than regenerated: only the values that differ from the file
+// are touched, so comments, key order and blank lines survive. Changed
+// scalars are spliced into the original text; structural changes (added or
+// removed keys, resized lists) re-encode the file from its parsed node tree,
+// which keeps comments and key order but not blank lines.
+func Save(path string, s *CitySpec) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		out, err := yaml.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("encoding spec: %w", err)
+		}
+		return writeFileAtomic(path, out, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("reading spec file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading spec file: %w", err)
+	}
+
+	out, err := editYAML(data, s)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return writeFileAtomic(path, out, info.Mode().Perm())
+}
+
+// editYAML returns data with the values that differ from s replaced.
+func editYAML(data []byte, s *CitySpec) ([]byte, error) {
+	var onDisk CitySpec
+	if err := yaml.Unmarshal(data, &onDisk); err != nil {
+		return nil, fmt.Errorf("parsing spec YAML: %w", err)
+	}
+	changes := Diff(&onDisk, s)
+	if len(changes) == 0 {
+		return data, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing spec YAML: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("spec file is not a YAML document")
+	}
+	root := doc.Content[0]
+	var want yaml.Node
+	if err := want.Encode(s); err != nil {
+		return nil, fmt.Errorf("encoding spec: %w", err)
+	}
+
+	var splices []splice
+	structural := false
+	for _, path := range changes {
+		segs := strings.Split(path, ".")
+		sp, ok := scalarSplice(data, lookupNode(root, segs), lookupNode(&want, segs))
+		if !ok {
+			structural = true
+			break
+		}
+		splices = append(splices, sp)
+	}
+	if !structural {
+		return applySplices(data, splices), nil
+	}
+
+	for _, path := range changes {
+		setNode(root, &want, strings.Split(path, "."))
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding spec YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding spec YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// lookupNode follows a dotted path through mappings and sequences.
+func lookupNode(n *yaml.Node, segs []string) *yaml.Node {
+	for _, seg := range segs {
+		if n == nil {
+			return nil
+		}
+		switch n.Kind {
+		case yaml.MappingNode:
+			var next *yaml.Node
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == seg {
+					next = n.Content[i+1]
+					break
+				}
+			}
+			n = next
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return nil
+			}
+			n = n.Content[i]
+		default:
+			return nil
+		}
+	}
+	return n
+}
+
+// setNode makes the value at segs in root match want: the node is replaced,
+// inserted with any missing parents, or removed when want has no value.
+// Comments attached to a replaced node are kept.
+func setNode(root, want *yaml.Node, segs []string) {
+	parent, wantParent := root, want
+	for depth, seg := range segs {
+		last := depth == len(segs)-1
+		wantChild := lookupNode(wantParent, []string{seg})
+		switch parent.Kind {
+		case yaml.MappingNode:
+			idx := -1
+			for i := 0; i+1 < len(parent.Content); i += 2 {
+				if parent.Content[i].Value == seg {
+					idx = i
+					break
+				}
+			}
+			switch {
+			case idx < 0 && wantChild == nil:
+				return
+			case idx < 0:
+				key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: seg}
+				parent.Content = append(parent.Content, key, wantChild)
+				return
+			case wantChild == nil:
+				parent.Content = append(parent.Content[:idx], parent.Content[idx+2:]...)
+				return
+			case last:
+				replaceNode(parent.Content[idx+1], wantChild)
+				return
+			}
+			parent = parent.Content[idx+1]
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(parent.Content) || wantChild == nil {
+				return
+			}
+			if last {
+				replaceNode(parent.Content[i], wantChild)
+				return
+			}
+			parent = parent.Content[i]
+		default:
+			return
+		}
+		wantParent = wantChild
+	}
+}
+
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	style := dst.Style
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+	if dst.Kind == yaml.ScalarNode && dst.Tag == "!!str" {
+		dst.Style = style
+	}
+}
+
+// splice replaces data[start:end] with text.
+type splice struct {
+	start, end int
+	text       string
+}
+
+// scalarSplice locates old's source text and renders its replacement,
+// keeping the quoting style of strings and the column of a trailing
+// comment. It fails for anything but a single-line scalar it can find
+// verbatim in data.
+func scalarSplice(data []byte, old, want *yaml.Node) (splice, bool) {
+	if old == nil || want == nil || old.Kind != yaml.ScalarNode || want.Kind != yaml.ScalarNode {
+		return splice{}, false
+	}
+	var src string
+	switch old.Style {
+	case 0:
+		src = old.Value
+	case yaml.DoubleQuotedStyle:
+		if strings.ContainsAny(old.Value, "\"\\") {
+			return splice{}, false
+		}
+		src = `"` + old.Value + `"`
+	case yaml.SingleQuotedStyle:
+		if strings.Contains(old.Value, "'") {
+			return splice{}, false
+		}
+		src = "'" + old.Value + "'"
+	default:
+		return splice{}, false
+	}
+
+	start, ok := offsetOf(data, old.Line, old.Column)
+	if !ok || !bytes.HasPrefix(data[start:], []byte(src)) {
+		return splice{}, false
+	}
+	end := start + len(src)
+
+	n := *want
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	if n.Tag == "!!str" && old.Tag == "!!str" {
+		n.Style = old.Style
+	}
+	out, err := yaml.Marshal(&n)
+	if err != nil {
+		return splice{}, false
+	}
+	text := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(text, "\n") {
+		return splice{}, false
+	}
+
+	// Keep an aligned trailing comment in its column.
+	rest := data[end:]
+	pad := 0
+	for pad < len(rest) && rest[pad] == ' ' {
+		pad++
+	}
+	if pad >= 2 && pad < len(rest) && rest[pad] == '#' {
+		newPad := pad + len(src) - len(text)
+		if newPad < 1 {
+			newPad = 1
+		}
+		text += strings.Repeat(" ", newPad)
+		end += pad
+	}
+	return splice{start: start, end: end, text: text}, true
+}
+
+// offsetOf converts a 1-based line and column (in characters) to a byte
+// offset in data.
+func offsetOf(data []byte, line, col int) (int, bool) {
+	off := 0
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i < 0 {
+			return 0, false
+		}
+		off += i + 1
+	}
+	for c := 1; c < col; c++ {
+		if off >= len(data) || data[off] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(data[off:])
+		off += size
+	}
+	return off, true
+}
+
+func applySplices(data []byte, splices []splice) []byte {
+	sort.Slice(splices, func(i, j int) bool { return splices[i].start > splices[j].start })
+	out := append([]byte(nil), data...)
+	for _, sp := range splices {
+		out = append(out[:sp.start], append([]byte(sp.text), out[sp.end:]...)...)
+	}
+	return out
+}
+
+// writeFileAtomic writes data to a hidden temporary file beside path and
+// renames it into place, so readers and file watchers never see a partial
+// spec.
+func writeFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing spec file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing spec file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing spec file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("writing spec file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing spec file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func copySpec(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("../../../examples/default-city/city.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "city.yaml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSaveSplicesScalars(t *testing.T) {
+	path := copySpec(t)
+	before, _ := os.ReadFile(path)
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.City.ExcavationDepth = 12.5
+	s.CityZones.Rings[0].RadiusTo = 260
+	if err := Save(path, s); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+
+	// Only the two edited lines change; comments stay in their column.
+	bl, al := strings.Split(string(before), "\n"), strings.Split(string(after), "\n")
+	if len(bl) != len(al) {
+		t.Fatalf("line count changed from %d to %d", len(bl), len(al))
+	}
+	var changed []string
+	for i := range bl {
+		if bl[i] != al[i] {
+			changed = append(changed, al[i])
+		}
+	}
+	want := []string{
+		"  excavation_depth: 12.5    # meters, 3 underground layers",
+		"      radius_to: 260",
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed lines = %q, want %q", changed, want)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(s, reloaded); d != nil {
+		t.Errorf("saved spec differs at %v", d)
+	}
+}
+
+func TestSaveStructuralKeepsComments(t *testing.T) {
+	path := copySpec(t)
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CityZones.Perimeter.Contents = append(s.CityZones.Perimeter.Contents, "data_center")
+	s.City.Population = 70000
+	if err := Save(path, s); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	after, _ := os.ReadFile(path)
+	if !strings.Contains(string(after), "# meters, 3 underground layers") {
+		t.Error("comment lost on structural save")
+	}
+	if strings.Index(string(after), "spec_version:") > strings.Index(string(after), "city:") {
+		t.Error("key order changed on structural save")
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := Diff(s, reloaded); d != nil {
+		t.Errorf("saved spec differs at %v", d)
+	}
+}
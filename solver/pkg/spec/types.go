@@ -1,5 +1,10 @@
 package spec
 
+import (
+	"fmt"
+	"time"
+)
+
 // CitySpec is the top-level specification for a charter city.
 type CitySpec struct {
 	SpecVersion string       `yaml:"spec_version" json:"spec_version"`
@@ -19,9 +24,14 @@ type CityDef struct {
 	Population      int     `yaml:"population" json:"population"`
 	FootprintShape  string  `yaml:"footprint_shape" json:"footprint_shape"`
 	ExcavationDepth float64 `yaml:"excavation_depth" json:"excavation_depth"`
+	// HeightProfile shapes the height envelope: step, bowl, cosine or
+	// custom (see HeightEnvelope). Empty is bowl; the deprecated flat is
+	// read as step.
 	HeightProfile   string  `yaml:"height_profile" json:"height_profile"`
 	MaxHeightCenter int     `yaml:"max_height_center" json:"max_height_center"`
 	MaxHeightEdge   int     `yaml:"max_height_edge" json:"max_height_edge"`
+	// HeightControlPoints are the custom profile's heights by radius.
+	HeightControlPoints []HeightPoint `yaml:"height_control_points,omitempty" json:"height_control_points,omitempty"`
 }
 
 type CityZones struct {
@@ -75,6 +85,12 @@ type SolarRingDef struct {
 type PodsDef struct {
 	WalkRadius      float64            `yaml:"walk_radius" json:"walk_radius"`
 	RingAssignments map[string]PodRing `yaml:"ring_assignments" json:"ring_assignments"`
+	// BuildingTypologies overrides, per ring character, the residential
+	// building typologies blocks are built with, in order of preference.
+	BuildingTypologies map[string][]string `yaml:"building_typologies,omitempty" json:"building_typologies,omitempty"`
+	// FixCollisions nudges or removes buildings that overlap or crowd other
+	// buildings, paths, plazas or sports fields, or leave their pod.
+	FixCollisions bool `yaml:"fix_collisions,omitempty" json:"fix_collisions,omitempty"`
 }
 
 type PodRing struct {
@@ -151,4 +167,61 @@ type Revenue struct {
 type SiteRequirements struct {
 	MinAreaHa        float64 `yaml:"min_area_ha" json:"min_area_ha"`
 	SolarIrradiance  float64 `yaml:"solar_irradiance_kwh_m2_day" json:"solar_irradiance_kwh_m2_day"`
+	// Latitude is the site's latitude in degrees, positive north. It sets
+	// the sun's path for solar access analysis.
+	Latitude float64 `yaml:"latitude,omitempty" json:"latitude,omitempty"`
+	// SolarAccess sets the dates and hours solar access is analyzed over.
+	// The analysis runs only when it names a date.
+	SolarAccess SolarAccessDef `yaml:"solar_access,omitempty" json:"solar_access,omitempty"`
+}
+
+// SolarAccessDef is a range of dates, as MM-DD, and of daily hours in local
+// solar time. A range may wrap past the end of the year. A missing end date
+// is the other date; missing hours mean the whole day.
+type SolarAccessDef struct {
+	DateFrom string  `yaml:"date_from,omitempty" json:"date_from,omitempty"`
+	DateTo   string  `yaml:"date_to,omitempty" json:"date_to,omitempty"`
+	HourFrom float64 `yaml:"hour_from,omitempty" json:"hour_from,omitempty"`
+	HourTo   float64 `yaml:"hour_to,omitempty" json:"hour_to,omitempty"`
+}
+
+// Enabled reports whether solar access analysis is requested.
+func (d SolarAccessDef) Enabled() bool {
+	return d.DateFrom != "" || d.DateTo != ""
+}
+
+// DayRange returns the first and last day of the year (1 to 365) of the
+// date range. The last day is less than the first if the range wraps.
+func (d SolarAccessDef) DayRange() (from, to int, err error) {
+	first, last := d.DateFrom, d.DateTo
+	if first == "" {
+		first = last
+	}
+	if last == "" {
+		last = first
+	}
+	if from, err = dayOfYear(first); err != nil {
+		return 0, 0, err
+	}
+	if to, err = dayOfYear(last); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+// Hours returns the daily hour range, defaulting to the whole day.
+func (d SolarAccessDef) Hours() (from, to float64) {
+	if d.HourFrom == 0 && d.HourTo == 0 {
+		return 0, 24
+	}
+	return d.HourFrom, d.HourTo
+}
+
+// dayOfYear parses an MM-DD date as a day of a non-leap year.
+func dayOfYear(date string) (int, error) {
+	t, err := time.Parse("2006-01-02", "2001-"+date)
+	if err != nil {
+		return 0, fmt.Errorf("date %q is not MM-DD", date)
+	}
+	return t.YearDay(), nil
 }
@@ -19,6 +19,7 @@ func ValidateSchema(s *spec.CitySpec) *Report {
 	validateCity(s, r)
 	validateRevenue(s, r)
 	validateInfrastructure(s, r)
+	validateSite(s, r)
 
 	return r
 }
@@ -37,16 +38,21 @@ func validatePopulation(s *spec.CitySpec, r *Report) {
 
 func validateDemographics(s *spec.CitySpec, r *Report) {
 	d := s.Demographics
-	ratios := map[string]float64{
-		"singles":        d.Singles,
-		"couples":        d.Couples,
-		"families_young": d.FamiliesYoung,
-		"families_teen":  d.FamiliesTeen,
-		"empty_nest":     d.EmptyNest,
-		"retirees":       d.Retirees,
+	// A slice, not a map, so errors are reported in spec order.
+	ratios := []struct {
+		name  string
+		ratio float64
+	}{
+		{"singles", d.Singles},
+		{"couples", d.Couples},
+		{"families_young", d.FamiliesYoung},
+		{"families_teen", d.FamiliesTeen},
+		{"empty_nest", d.EmptyNest},
+		{"retirees", d.Retirees},
 	}
 
-	for name, ratio := range ratios {
+	for _, cr := range ratios {
+		name, ratio := cr.name, cr.ratio
 		if ratio < 0 {
 			r.AddError(Result{
 				Level:       LevelSchema,
@@ -141,6 +147,45 @@ func validateCity(s *spec.CitySpec, r *Report) {
 			Expected:    "4-15",
 		})
 	}
+
+	switch s.City.HeightProfile {
+	case "", spec.ProfileStep, spec.ProfileBowl, spec.ProfileCosine:
+	case spec.ProfileFlat:
+		r.AddWarning(Result{
+			Level:       LevelSchema,
+			Message:     "height_profile flat is deprecated and is read as step",
+			SpecPath:    "city.height_profile",
+			ActualValue: s.City.HeightProfile,
+			Expected:    "step, bowl, cosine or custom",
+		})
+	case spec.ProfileCustom:
+		if len(s.City.HeightControlPoints) == 0 {
+			r.AddError(Result{
+				Level:    LevelSchema,
+				Message:  "a custom height_profile needs height_control_points",
+				SpecPath: "city.height_control_points",
+				Expected: "at least one point",
+			})
+		}
+		for i, p := range s.City.HeightControlPoints {
+			if p.Radius < 0 || p.Stories < 1 {
+				r.AddError(Result{
+					Level:       LevelSchema,
+					Message:     fmt.Sprintf("height control point %d must have radius >= 0 and stories >= 1", i),
+					SpecPath:    fmt.Sprintf("city.height_control_points[%d]", i),
+					ActualValue: p,
+				})
+			}
+		}
+	default:
+		r.AddError(Result{
+			Level:       LevelSchema,
+			Message:     fmt.Sprintf("unknown height_profile %q", s.City.HeightProfile),
+			SpecPath:    "city.height_profile",
+			ActualValue: s.City.HeightProfile,
+			Expected:    "step, bowl, cosine or custom",
+		})
+	}
 }
 
 func validateRevenue(s *spec.CitySpec, r *Report) {
@@ -187,3 +232,35 @@ func validateInfrastructure(s *spec.CitySpec, r *Report) {
 		})
 	}
 }
+
+func validateSite(s *spec.CitySpec, r *Report) {
+	site := s.Site
+	if site.Latitude < -90 || site.Latitude > 90 {
+		r.AddError(Result{
+			Level:       LevelSchema,
+			Message:     "latitude must be between -90 and 90 degrees",
+			SpecPath:    "site_requirements.latitude",
+			ActualValue: site.Latitude,
+			Expected:    "-90 to 90",
+		})
+	}
+	if !site.SolarAccess.Enabled() {
+		return
+	}
+	if _, _, err := site.SolarAccess.DayRange(); err != nil {
+		r.AddError(Result{
+			Level:    LevelSchema,
+			Message:  fmt.Sprintf("solar_access: %v", err),
+			SpecPath: "site_requirements.solar_access",
+			Expected: "dates as MM-DD",
+		})
+	}
+	if from, to := site.SolarAccess.Hours(); from < 0 || to > 24 || from >= to {
+		r.AddError(Result{
+			Level:       LevelSchema,
+			Message:     "solar_access hours must satisfy 0 <= hour_from < hour_to <= 24",
+			SpecPath:    "site_requirements.solar_access",
+			ActualValue: [2]float64{from, to},
+		})
+	}
+}
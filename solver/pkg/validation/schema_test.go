@@ -1,6 +1,9 @@
 package validation
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ChicagoDave/cityplanner/pkg/spec"
@@ -149,6 +152,79 @@ func TestValidateSchemaMaxStories(t *testing.T) {
 	}
 }
 
+func TestValidateSchemaSolarAccess(t *testing.T) {
+	s := validSpec()
+	s.Site.Latitude = 35
+	s.Site.SolarAccess = spec.SolarAccessDef{DateFrom: "11-01", DateTo: "02-28", HourFrom: 9, HourTo: 15}
+	if r := ValidateSchema(s); !r.Valid {
+		t.Errorf("expected valid report, got %v", r.Errors)
+	}
+
+	s.Site.SolarAccess.DateTo = "02-30"
+	assertHasError(t, ValidateSchema(s), "site_requirements.solar_access")
+
+	s.Site.SolarAccess.DateTo = ""
+	s.Site.SolarAccess.HourTo = 8
+	assertHasError(t, ValidateSchema(s), "site_requirements.solar_access")
+
+	s.Site.SolarAccess.HourTo = 15
+	s.Site.Latitude = 95
+	assertHasError(t, ValidateSchema(s), "site_requirements.latitude")
+}
+
+func TestValidateSchemaHeightProfile(t *testing.T) {
+	s := validSpec()
+	s.City.HeightProfile = spec.ProfileCustom
+	s.City.HeightControlPoints = []spec.HeightPoint{{Radius: 0, Stories: 12}, {Radius: 500, Stories: 20}, {Radius: 900, Stories: 3}}
+	if r := ValidateSchema(s); !r.Valid {
+		t.Errorf("expected valid report, got %v", r.Errors)
+	}
+
+	s.City.HeightControlPoints[2].Stories = 0
+	assertHasError(t, ValidateSchema(s), "city.height_control_points[2]")
+
+	s.City.HeightControlPoints = nil
+	assertHasError(t, ValidateSchema(s), "city.height_control_points")
+
+	s.City.HeightProfile = "dome"
+	assertHasError(t, ValidateSchema(s), "city.height_profile")
+}
+
+func TestValidateSchemaFlatHeightProfile(t *testing.T) {
+	// Specs written before the step, cosine and custom profiles used flat.
+	data, err := os.ReadFile("../../../examples/default-city/city.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte("height_profile: bowl"), []byte("height_profile: flat"), 1)
+	path := filepath.Join(t.TempDir(), "city.yaml")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := spec.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if s.City.HeightProfile != spec.ProfileFlat {
+		t.Fatalf("height_profile = %q, want flat", s.City.HeightProfile)
+	}
+
+	r := ValidateSchema(s)
+	if !r.Valid {
+		t.Errorf("expected a flat spec to be valid, got %v", r.Errors)
+	}
+	warned := false
+	for _, w := range r.Warnings {
+		warned = warned || w.SpecPath == "city.height_profile"
+	}
+	if !warned {
+		t.Error("expected a deprecation warning for height_profile flat")
+	}
+	if p := s.HeightEnvelope().Profile; p != spec.ProfileStep {
+		t.Errorf("flat envelope profile = %q, want step", p)
+	}
+}
+
 func assertHasError(t *testing.T, r *Report, specPath string) {
 	t.Helper()
 	for _, e := range r.Errors {
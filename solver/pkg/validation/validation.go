@@ -30,6 +30,9 @@ type Result struct {
 	Expected       string   `json:"expected,omitempty"`
 	ConflictWith   string   `json:"conflict_with,omitempty"`
 	Suggestions    []string `json:"suggestions,omitempty"`
+	// EntityIDs names the scene entities a spatial finding is about, so
+	// viewers can highlight them.
+	EntityIDs []string `json:"entity_ids,omitempty"`
 }
 
 // Report is the complete validation output.